@@ -0,0 +1,113 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// linkTextRe matches a link, capturing its text: an <a href> element
+	// or a markdown [text](url) link.
+	linkTextRe = regexp.MustCompile(`<a\s[^>]*href="[^"]*"[^>]*>(.*?)</a>|(?:^|[^!])\[([^\]]*)\]\([^)]*\)`)
+	// imageRe matches an image, capturing the alt text of a markdown
+	// image, or the whole of an <img> element.
+	imageRe = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)|(<img\b[^>]*>)`)
+	altRe   = regexp.MustCompile(`\balt\s*=\s*"[^"]*\S[^"]*"`)
+	bareURL = regexp.MustCompile(`^(https?://|www\.)\S+$`)
+)
+
+// vagueLinkText lists link texts that say nothing about where the link
+// goes, compared in lower case.
+var vagueLinkText = map[string]bool{
+	"here":       true,
+	"click here": true,
+	"this":       true,
+	"link":       true,
+	"more":       true,
+	"read more":  true,
+}
+
+// levelFixer follows the heading levels of a document, finding
+// headings that skip a level, e.g. an H4 straight after an H2.
+type levelFixer struct {
+	// fix raises such headings, and the headings under them, so that
+	// they are one level below the heading before them.
+	fix bool
+	// open holds the original and new levels of the headings enclosing
+	// the current line, innermost last.
+	open []fixedLevel
+}
+
+type fixedLevel struct{ level, newLevel int }
+
+// level returns the level a heading at level should have, and whether
+// it skips levels.
+func (f *levelFixer) level(level int) (newLevel int, gap bool) {
+	prev := fixedLevel{}
+	if len(f.open) > 0 {
+		prev = f.open[len(f.open)-1]
+	}
+	gap = level-prev.level > 1
+	newLevel = level
+	if f.fix {
+		for len(f.open) > 0 && f.open[len(f.open)-1].level >= level {
+			f.open = f.open[:len(f.open)-1]
+		}
+		parent := fixedLevel{}
+		if len(f.open) > 0 {
+			parent = f.open[len(f.open)-1]
+		}
+		newLevel = min(parent.newLevel+level-parent.level, prev.newLevel+1)
+	}
+	f.keep(level, newLevel)
+	return
+}
+
+// keep records a heading at level that is given newLevel, e.g. an
+// unnumbered heading the passes leave alone.
+func (f *levelFixer) keep(level, newLevel int) {
+	for len(f.open) > 0 && f.open[len(f.open)-1].level >= level {
+		f.open = f.open[:len(f.open)-1]
+	}
+	f.open = append(f.open, fixedLevel{level, newLevel})
+}
+
+// verifyA11y runs the accessibility checks over a processed document:
+// the document should have exactly one H1, link text should describe
+// where the link goes, and images need alt text.  Heading level gaps
+// are reported by passMkHeads.
+func (p *Processor) verifyA11y(lines []string) {
+	code := codeMask(lines)
+	// count is the number of H1s, after any were demoted
+	count := 0
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if m := headerRegexp.FindStringSubmatch(line); m != nil && len(m[1]) == 1 {
+			count++
+			if count > 1 {
+				p.warnf("a11y-h1", i, "#", "More than one H1; screen reader users expect one page title: %s", m[2])
+			}
+		}
+		for _, m := range linkTextRe.FindAllStringSubmatch(line, -1) {
+			text := strings.TrimSpace(htmlTagRe.ReplaceAllString(m[1]+m[2], ""))
+			switch {
+			case text == "":
+				p.warnf("a11y-link-text", i, m[0], "Link has no text")
+			case vagueLinkText[strings.ToLower(text)]:
+				p.warnf("a11y-link-text", i, text, "Link text doesn't say where it goes: %s", text)
+			case bareURL.MatchString(text):
+				p.warnf("a11y-link-text", i, text, "Link text is a bare URL: %s", text)
+			}
+		}
+		for _, m := range imageRe.FindAllStringSubmatch(line, -1) {
+			if m[2] == "" && strings.TrimSpace(m[1]) == "" || m[2] != "" && !altRe.MatchString(m[2]) {
+				p.warnf("a11y-image-alt", i, m[0], "Image has no alt text: %s", m[0])
+			}
+		}
+	}
+	if count == 0 {
+		p.warnf("a11y-h1", -1, "", "Document has no H1 to serve as its title")
+	}
+}
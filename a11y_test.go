@@ -0,0 +1,69 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestA11y(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"For details [click here](details.md), or see <https://example.com>.",
+		"![](diagram.png) and ![Data flow](flow.png)",
+		`<img src="logo.png">`,
+		"# Design",
+	}
+	opts := DefaultOptions()
+	opts.A11y = true
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	rules := []string{}
+	for _, w := range p.Warnings() {
+		rules = append(rules, w.Rule)
+	}
+	want := []string{"a11y-link-text", "a11y-image-alt", "a11y-image-alt", "a11y-h1"}
+	Tassert(t, reflect.DeepEqual(rules, want), "\nwant: %v\nhave: %v", want, p.Warnings())
+	Tassert(t, p.Warnings()[0].Line == 2 && p.Warnings()[0].Column == 14, "have %v", p.Warnings()[0])
+	Tassert(t, p.Warnings()[3].Line == 5, "have %v", p.Warnings()[3])
+
+	_, err = p.Process([]string{"Just text."})
+	Ck(err)
+	Tassert(t, len(p.Warnings()) == 1 && p.Warnings()[0].Rule == "a11y-h1", "have %v", p.Warnings())
+}
+
+func TestFixHeadingGaps(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"### Detail",
+		"#### More",
+		"## Scope",
+	}
+	opts := DefaultOptions()
+	opts.FixHeadingGaps = true
+	opts.MkTOC = false
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Ck(err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Detail",
+		`<a name="sec1_1_1"></a>`,
+		"### 1.1.1. More",
+		`<a name="sec1_2"></a>`,
+		"## 1.2. Scope",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	// the gap is still reported
+	Tassert(t, len(p.Warnings()) == 1 && p.Warnings()[0].Rule == "heading-gap", "have %v", p.Warnings())
+
+	sections := p.Outline(lines)
+	Tassert(t, sections[1].Level == 2 && sections[1].Number == "1.1", "have %v", sections)
+}
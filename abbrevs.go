@@ -0,0 +1,177 @@
+package markproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Abbreviation is an entry of a project's abbreviations dictionary:
+// a short form such as "CRDT", the section defining it, given by title,
+// or an external URL, and optionally its expansion for <abbr title>.
+type Abbreviation struct {
+	Abbr    string `json:"abbr"`
+	Section string `json:"section,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
+
+// ReadAbbreviations reads an abbreviations dictionary written as a JSON
+// array, e.g.
+//
+//	[{"abbr": "CRDT", "section": "Replicated Data Types",
+//	  "title": "Conflict-free Replicated Data Type"},
+//	 {"abbr": "RFC", "url": "https://www.rfc-editor.org/"}]
+func ReadAbbreviations(r io.Reader) (abbrevs []Abbreviation, err error) {
+	err = json.NewDecoder(r).Decode(&abbrevs)
+	if err != nil {
+		return
+	}
+	for i, a := range abbrevs {
+		if a.Abbr == "" || (a.Section == "") == (a.URL == "") {
+			return nil, fmt.Errorf("abbreviation %d needs abbr and one of section or url", i+1)
+		}
+	}
+	return
+}
+
+// abbrWrapRe matches the links and <abbr> elements passAbbreviations
+// makes, capturing the abbreviation.
+var abbrWrapRe = regexp.MustCompile(`<a href="[^"]*">([^<]*)</a>|<abbr title="[^"]*">([^<]*)</abbr>`)
+
+// abbrevUse is one use of an abbreviation on a line: plain text to link
+// or wrap, or a link or <abbr> element made by an earlier run.
+type abbrevUse struct {
+	start, end int
+	linked     bool
+	plain      bool
+}
+
+// passAbbreviations links the first use of each abbreviation in
+// p.Abbreviations in each section to the section or URL defining it.
+// With p.AbbrTitles set, later uses in the section are wrapped in
+// <abbr> elements giving the expansion.
+func (p *Processor) passAbbreviations(lines []string) []string {
+	targets := p.project.sectionTargets()
+	if targets == nil {
+		targets = p.sectionTargets(lines, "")
+	}
+	hrefs := map[string]string{}
+	uses := map[string]*regexp.Regexp{}
+	for _, a := range p.Abbreviations {
+		uses[a.Abbr] = regexp.MustCompile(`\b` + regexp.QuoteMeta(a.Abbr) + `\b`)
+		if a.URL != "" {
+			hrefs[a.Abbr] = a.URL
+			continue
+		}
+		target, ok := targets[strings.ToLower(a.Section)]
+		if !ok {
+			matches := p.matcher().Match(a.Section, keys(targets))
+			if len(matches) != 1 {
+				p.warnf("abbr-unresolved", -1, "", "Abbreviation %s: no single section matches %q", a.Abbr, a.Section)
+				continue
+			}
+			target = targets[matches[0]]
+		}
+		hrefs[a.Abbr] = p.fileHref(target.File) + "#" + target.Name
+	}
+
+	// linked holds the abbreviations linked in the current section
+	linked := map[string]bool{}
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if headerRegexp.MatchString(line) {
+			linked = map[string]bool{}
+			out.add(i, line)
+			continue
+		}
+		if extLinkRegexp.MatchString(line) || termDefRe.MatchString(line) || acroDefRe.MatchString(line) {
+			out.add(i, line)
+			continue
+		}
+		for _, a := range p.Abbreviations {
+			if href, ok := hrefs[a.Abbr]; ok {
+				line = p.linkAbbreviation(line, a, uses[a.Abbr], href, linked)
+			}
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// linkAbbreviation returns line with the uses of a, matched by re,
+// linked to href or wrapped in <abbr>, recording in linked whether its
+// section's first use has been linked.
+func (p *Processor) linkAbbreviation(line string, a Abbreviation, re *regexp.Regexp, href string, linked map[string]bool) string {
+	uses := []abbrevUse{}
+	for _, m := range abbrWrapRe.FindAllStringSubmatchIndex(line, -1) {
+		if m[2] >= 0 && line[m[2]:m[3]] == a.Abbr {
+			uses = append(uses, abbrevUse{start: m[0], end: m[1], linked: true})
+		} else if m[4] >= 0 && line[m[4]:m[5]] == a.Abbr {
+			uses = append(uses, abbrevUse{start: m[0], end: m[1]})
+		}
+	}
+	masked := abbrWrapRe.ReplaceAllStringFunc(line, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+	for _, loc := range re.FindAllStringIndex(proseMask(masked), -1) {
+		uses = append(uses, abbrevUse{start: loc[0], end: loc[1], plain: true})
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].start < uses[j].start })
+
+	// decide in order, then replace from the end
+	replace := make([]string, len(uses))
+	for j, use := range uses {
+		switch {
+		case use.linked:
+			linked[a.Abbr] = true
+		case !use.plain:
+		case !linked[a.Abbr]:
+			replace[j] = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), a.Abbr)
+			linked[a.Abbr] = true
+		case p.AbbrTitles && a.Title != "":
+			replace[j] = fmt.Sprintf(`<abbr title="%s">%s</abbr>`, html.EscapeString(a.Title), a.Abbr)
+		}
+	}
+	for j := len(uses) - 1; j >= 0; j-- {
+		if replace[j] != "" {
+			line = line[:uses[j].start] + replace[j] + line[uses[j].end:]
+		}
+	}
+	return line
+}
+
+// unlinkAbbreviations undoes passAbbreviations on lines for Strip.
+func (p *Processor) unlinkAbbreviations(lines []string) []string {
+	if len(p.Abbreviations) == 0 {
+		return lines
+	}
+	dict := map[string]bool{}
+	for _, a := range p.Abbreviations {
+		dict[a.Abbr] = true
+	}
+	code := codeMask(lines)
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if !code[i] {
+			line = abbrWrapRe.ReplaceAllStringFunc(line, func(s string) string {
+				m := abbrWrapRe.FindStringSubmatch(s)
+				if abbr := m[1] + m[2]; dict[abbr] {
+					return abbr
+				}
+				return s
+			})
+		}
+		out[i] = line
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package markproc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestAbbreviations(t *testing.T) {
+	abbrevs, err := ReadAbbreviations(strings.NewReader(`[
+		{"abbr": "CRDT", "section": "Replicated Data Types", "title": "Conflict-free Replicated Data Type"},
+		{"abbr": "RFC", "url": "https://www.rfc-editor.org/"}
+	]`))
+	Ck(err)
+	lines := []string{
+		"# Replicated Data Types",
+		"A CRDT merges; each CRDT converges.",
+		"",
+		"# Sync",
+		"Per RFC rules, a CRDT is sent, `CRDT` in code.",
+		"Another CRDT, another RFC.",
+	}
+	opts := DefaultOptions()
+	opts.Abbreviations = abbrevs
+	opts.AbbrTitles = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Replicated Data Types",
+		`A <a href="#sec1">CRDT</a> merges; each <abbr title="Conflict-free Replicated Data Type">CRDT</abbr> converges.`,
+		"",
+		`<a name="sec2"></a>`,
+		"# 2. Sync",
+		`Per <a href="https://www.rfc-editor.org/">RFC</a> rules, a <a href="#sec1">CRDT</a> is sent, ` + "`CRDT` in code.",
+		`Another <abbr title="Conflict-free Replicated Data Type">CRDT</abbr>, another RFC.`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+
+	_, err = ReadAbbreviations(strings.NewReader(`[{"abbr": "X"}]`))
+	Tassert(t, err != nil, "want error for an entry without section or url")
+}
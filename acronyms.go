@@ -0,0 +1,175 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// acroDefRe matches an [acro API]: definition.
+	acroDefRe = regexp.MustCompile(`^\[acro\s+([^\]\s]+)\]:\s*(.*?)\s*$`)
+	// acroLinkRe matches a link passAcronyms made from a later use.
+	acroLinkRe = regexp.MustCompile(`<a href="#acro-[^"]+">([^<]*)</a>`)
+	// acroEntryRe matches an entry of a List of Acronyms.
+	acroEntryRe = regexp.MustCompile(`^- <a name="acro-[^"]+"></a>\*\*([^*]+)\*\*: (.*)$`)
+	// proseMaskRe matches the parts of a line that aren't prose for
+	// acronym expansion: code spans, links and their text, bracketed
+	// references and HTML tags.
+	proseMaskRe = regexp.MustCompile("`+[^`]*`+|<a\\s[^>]*>.*?</a>|\\[[^\\]]*\\](?:\\([^)]*\\))?|<[^<>]+>")
+)
+
+// The comments passAcronyms puts around a List of Acronyms.
+const (
+	acroStart = "<!-- acronyms -->"
+	acroEnd   = "<!-- /acronyms -->"
+)
+
+// acroAnchor returns the anchor name of the definition of acronym.
+func acroAnchor(acronym string) string {
+	return "acro-" + githubSlug(acronym)
+}
+
+// acroDef is one [acro API]: definition.
+type acroDef struct {
+	acronym, expansion string
+	re                 *regexp.Regexp
+}
+
+// acronymDefs returns the [acro X]: definitions in lines, sorted.
+func acronymDefs(lines []string) (defs []*acroDef) {
+	code := codeMask(lines)
+	seen := map[string]bool{}
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		m := acroDefRe.FindStringSubmatch(line)
+		if m == nil {
+			m = acroEntryRe.FindStringSubmatch(line)
+		}
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		defs = append(defs, &acroDef{
+			acronym:   m[1],
+			expansion: m[2],
+			re:        regexp.MustCompile(`\b` + regexp.QuoteMeta(m[1]) + `\b`),
+		})
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return strings.ToLower(defs[i].acronym) < strings.ToLower(defs[j].acronym)
+	})
+	return
+}
+
+// proseMask returns line with everything but prose blanked out, keeping
+// the byte offsets.
+func proseMask(line string) string {
+	return proseMaskRe.ReplaceAllStringFunc(line, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+}
+
+// passAcronyms expands the first use in body text of each acronym
+// defined as [acro API]: Application Programming Interface to
+// "Application Programming Interface (API)".  With p.LinkAcronyms set
+// later uses link to the definition.  With p.AcronymList set the
+// definitions are gathered into a List of Acronyms, sorted, at the
+// place of the first one; otherwise each stays where it was written.
+// A use that already follows its expansion, e.g. in a document
+// processed before, isn't expanded again.
+func (p *Processor) passAcronyms(lines []string) []string {
+	code := codeMask(lines)
+	defs := []*acroDef{}
+	for _, def := range acronymDefs(lines) {
+		// a List of Acronyms from an earlier run has no raw definitions
+		for i, line := range lines {
+			if !code[i] && acroDefRe.MatchString(line) && acroDefRe.FindStringSubmatch(line)[1] == def.acronym {
+				defs = append(defs, def)
+				break
+			}
+		}
+	}
+	if len(defs) == 0 {
+		return lines
+	}
+
+	expanded := map[string]bool{}
+	listed := false
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if m := acroDefRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case !p.AcronymList:
+				out.add(i, fmt.Sprintf(`<a name="%s"></a>`, acroAnchor(m[1])))
+				out.add(i, line)
+			case !listed:
+				out.add(i, acroStart)
+				for _, def := range defs {
+					out.add(i, fmt.Sprintf(`- <a name="%s"></a>**%s**: %s`, acroAnchor(def.acronym), def.acronym, def.expansion))
+				}
+				out.add(i, acroEnd)
+				listed = true
+			}
+			continue
+		}
+		if headerRegexp.MatchString(line) || extLinkRegexp.MatchString(line) || termDefRe.MatchString(line) {
+			out.add(i, line)
+			continue
+		}
+		for _, def := range defs {
+			line = p.expandAcronym(line, def, expanded)
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// expandAcronym returns line with the uses of def's acronym in its
+// prose expanded or linked, recording in expanded whether the first
+// use has been seen.
+func (p *Processor) expandAcronym(line string, def *acroDef, expanded map[string]bool) string {
+	locs := def.re.FindAllStringIndex(proseMask(line), -1)
+	first := -1
+	if !expanded[def.acronym] && len(locs) > 0 {
+		first = 0
+		expanded[def.acronym] = true
+	}
+	for j := len(locs) - 1; j >= 0; j-- {
+		start, end := locs[j][0], locs[j][1]
+		switch {
+		case j == first:
+			if strings.HasSuffix(line[:start], def.expansion+" (") {
+				continue
+			}
+			line = fmt.Sprintf("%s%s (%s)%s", line[:start], def.expansion, def.acronym, line[end:])
+		case p.LinkAcronyms:
+			line = fmt.Sprintf(`%s<a href="#%s">%s</a>%s`, line[:start], acroAnchor(def.acronym), def.acronym, line[end:])
+		}
+	}
+	return line
+}
+
+// unexpandAcronyms undoes passAcronyms on lines for Strip.
+func unexpandAcronyms(lines []string) []string {
+	defs := acronymDefs(lines)
+	code := codeMask(lines)
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if !code[i] {
+			line = acroLinkRe.ReplaceAllString(line, "$1")
+			for _, def := range defs {
+				line = strings.Replace(line, fmt.Sprintf("%s (%s)", def.expansion, def.acronym), def.acronym, 1)
+			}
+		}
+		out[i] = line
+	}
+	return out
+}
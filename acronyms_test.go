@@ -0,0 +1,74 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestAcronyms(t *testing.T) {
+	lines := []string{
+		"# The API",
+		"The API serves `API` calls; the API is versioned.",
+		"Each API call is logged.",
+		"",
+		"[acro API]: Application Programming Interface",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	opts.LinkAcronyms = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. The API",
+		"The Application Programming Interface (API) serves `API` calls; the " +
+			`<a href="#acro-api">API</a> is versioned.`,
+		`Each <a href="#acro-api">API</a> call is logged.`,
+		"",
+		`<a name="acro-api"></a>`,
+		"[acro API]: Application Programming Interface",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+
+	p.AcronymList = true
+	p.LinkAcronyms = false
+	out, err = p.Process([]string{
+		"An SDK wraps the API.",
+		"[acro SDK]: Software Development Kit",
+		"[acro API]: Application Programming Interface",
+	})
+	Ck(err)
+	want = []string{
+		"An Software Development Kit (SDK) wraps the Application Programming Interface (API).",
+		acroStart,
+		`- <a name="acro-api"></a>**API**: Application Programming Interface`,
+		`- <a name="acro-sdk"></a>**SDK**: Software Development Kit`,
+		acroEnd,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	again, err = p.Process(out)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	// stripping the list gives back definitions that expand again
+	stripped = p.Strip(out)
+	want = []string{
+		"An SDK wraps the API.",
+		"[acro API]: Application Programming Interface",
+		"[acro SDK]: Software Development Kit",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, want), "\nwant: %q\nhave: %q", want, stripped)
+	again, err = p.Process(stripped)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+}
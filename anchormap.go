@@ -0,0 +1,141 @@
+package markproc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// AnchorMap records the anchor each numbered section of a document was
+// given, and the number-based anchors, e.g. sec2_3, each section has
+// had.  Kept between runs, e.g. in a sidecar file, it keeps the anchors
+// of sections the same when sections are inserted before them, and
+// keeps deep links to the numbers they had before working: an
+// <a name> tag for each old number, an alias, is inserted before the
+// section's heading.
+type AnchorMap struct {
+	// Anchors maps the lowercased title of each section to its anchor.
+	Anchors map[string]string `json:"anchors"`
+	// Aliases maps each number-based anchor to the lowercased title of
+	// the first section to have it.
+	Aliases map[string]string `json:"aliases"`
+}
+
+// NewAnchorMap returns an empty AnchorMap.
+func NewAnchorMap() *AnchorMap {
+	return &AnchorMap{Anchors: map[string]string{}, Aliases: map[string]string{}}
+}
+
+// ReadAnchorMap reads an AnchorMap written by Write.  A missing file is
+// not an error; it yields an empty AnchorMap.
+func ReadAnchorMap(path string) (m *AnchorMap, err error) {
+	m = NewAnchorMap()
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(buf, m)
+	if m.Anchors == nil {
+		m.Anchors = map[string]string{}
+	}
+	if m.Aliases == nil {
+		m.Aliases = map[string]string{}
+	}
+	return
+}
+
+// Write writes m to the file at path as JSON.
+func (m *AnchorMap) Write(path string) (err error) {
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// owners returns the lowercased title of the section each anchor and
+// alias of m belongs to.
+func (m *AnchorMap) owners() map[string]string {
+	owners := map[string]string{}
+	for key, anchor := range m.Anchors {
+		owners[anchor] = key
+	}
+	for alias, key := range m.Aliases {
+		if _, ok := owners[alias]; !ok {
+			owners[alias] = key
+		}
+	}
+	return owners
+}
+
+// namer returns a function that gives each section the anchor m
+// records for it, or else the one name gives it, made unique among
+// those m records for other sections by appending the slugged title
+// and, if need be, a count.  Only the first section with a title takes
+// the recorded anchor.
+func (m *AnchorMap) namer(name func(number, title string) string, separator string) func(number, title string) string {
+	owners := m.owners()
+	seen := map[string]bool{}
+	return func(number, title string) string {
+		key := strings.ToLower(title)
+		first := !seen[key]
+		seen[key] = true
+		anchor := name(number, title)
+		if recorded, ok := m.Anchors[key]; ok && first {
+			return recorded
+		}
+		taken := func(anchor string) bool {
+			owner, ok := owners[anchor]
+			return ok && (owner != key || !first)
+		}
+		if !taken(anchor) {
+			return anchor
+		}
+		base := safeAnchorName(anchor + separator + githubSlug(title))
+		anchor = base
+		for n := 1; taken(anchor); n++ {
+			anchor = fmt.Sprintf("%s-%d", base, n)
+		}
+		return anchor
+	}
+}
+
+// aliases returns the aliases to insert before the heading of the
+// section with title, anchor and number-based anchor numbered, claiming
+// numbered for it if no other section has had it, and records anchor as
+// the section's anchor.  Only the first section with a title is
+// recorded or given aliases.
+func (m *AnchorMap) aliases(title, anchor, numbered string, seen map[string]bool) (aliases []string) {
+	key := strings.ToLower(title)
+	if seen[key] {
+		return nil
+	}
+	seen[key] = true
+	if _, ok := m.owners()[numbered]; !ok {
+		m.Aliases[numbered] = key
+	}
+	m.Anchors[key] = anchor
+	for alias, owner := range m.Aliases {
+		if owner == key && alias != anchor {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return
+}
+
+// isAlias reports whether name is an alias m records.
+func (m *AnchorMap) isAlias(name string) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.Aliases[name]
+	return ok
+}
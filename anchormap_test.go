@@ -0,0 +1,66 @@
+package markproc
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestAnchorMap(t *testing.T) {
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	p.AnchorMap = NewAnchorMap()
+	_, err := p.Process([]string{
+		"# Intro",
+		"# Design",
+		"See [sec desig].",
+	})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := &AnchorMap{
+		Anchors: map[string]string{"intro": "sec1", "design": "sec2"},
+		Aliases: map[string]string{"sec1": "intro", "sec2": "design"},
+	}
+	Tassert(t, reflect.DeepEqual(p.AnchorMap, want), "\nwant: %v\nhave: %v", want, p.AnchorMap)
+
+	path := filepath.Join(t.TempDir(), "doc.md.anchors.json")
+	err = p.AnchorMap.Write(path)
+	Ck(err)
+	m, err := ReadAnchorMap(path)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(m, want), "have %v", m)
+
+	// inserting a section keeps the anchor of Design, and its new
+	// number's anchor is an alias
+	p.AnchorMap = m
+	lines := []string{
+		"# Intro",
+		"# Goals",
+		"# Design",
+		"See [sec desig] and [sec goal].",
+	}
+	have, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`<a name="sec2_goals"></a>`,
+		"# 2. Goals",
+		`<a name="sec3"></a>`,
+		`<a name="sec2"></a>`,
+		"# 3. Design",
+		`See [<a href="#sec2">sec 3</a>] and [<a href="#sec2_goals">sec 2</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+	Tassert(t, m.Aliases["sec3"] == "design", "have %v", m.Aliases)
+
+	// reprocessing gives the same output
+	again, err := p.Process(have)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(expect, again), "\nwant: %q\nhave: %q", expect, again)
+
+	m, err = ReadAnchorMap(filepath.Join(t.TempDir(), "missing.json"))
+	Tassert(t, err == nil && len(m.Anchors) == 0 && len(m.Aliases) == 0, "missing map: %v %v", m, err)
+}
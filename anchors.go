@@ -0,0 +1,150 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// anchorTagRe matches an <a> tag with a name or id attribute, or a
+	// heading tag with an id.
+	anchorTagRe = regexp.MustCompile(`<(?:a|h[1-6])\s+(?:[^>]*\s)?(?:name|id)="([^"]+)"[^>]*>`)
+	// attrIDRe matches a Pandoc/kramdown style {#id} attribute.
+	attrIDRe = regexp.MustCompile(`\{#([^}\s]+)\}`)
+)
+
+// anchorTargets returns the names of the link targets defined on line,
+// in any of the forms <a name="x">, <a id="x">, <h2 id="x"> or {#x}.
+func anchorTargets(line string) (names []string) {
+	for _, m := range anchorTagRe.FindAllStringSubmatch(line, -1) {
+		names = append(names, m[1])
+	}
+	for _, m := range attrIDRe.FindAllStringSubmatch(line, -1) {
+		names = append(names, m[1])
+	}
+	return
+}
+
+// Anchor styles for Options.AnchorStyle.
+const (
+	// AnchorSecnum names section anchors after their numbers, e.g.
+	// sec1_2, and inserts an <a name> tag before each heading.  The
+	// name's parts are set by Options.AnchorPrefix, AnchorSeparator
+	// and AnchorSlug.
+	AnchorSecnum = "secnum"
+	// AnchorGitHub uses the anchors GitHub and most other renderers
+	// generate from heading text, e.g. 12-design-goals, and inserts no
+	// <a name> tags of its own.
+	AnchorGitHub = "github"
+	// AnchorCustom names section anchors using Options.AnchorFormat and
+	// inserts an <a name> tag before each heading.
+	AnchorCustom = "custom"
+)
+
+// AnchorStyles lists the valid values of Options.AnchorStyle.
+var AnchorStyles = []string{AnchorSecnum, AnchorGitHub, AnchorCustom}
+
+// anchorSeparator returns p.AnchorSeparator, or "_" if it is empty.
+func (p *Processor) anchorSeparator() string {
+	if p.AnchorSeparator == "" {
+		return "_"
+	}
+	return p.AnchorSeparator
+}
+
+// anchorNumber returns a section or equation number as it appears in
+// anchor names, with its dots replaced by p's AnchorSeparator.
+func (p *Processor) anchorNumber(number string) string {
+	return strings.Replace(number, ".", p.anchorSeparator(), -1)
+}
+
+// sectionAnchor returns the secnum style anchor name for a section
+// number, e.g. sec1_2, made of p's AnchorPrefix and the number and,
+// with AnchorSlug set, the slugged title.
+func (p *Processor) sectionAnchor(number, title string) string {
+	prefix := p.AnchorPrefix
+	if prefix == "" {
+		prefix = "sec"
+	}
+	name := prefix + p.anchorNumber(number)
+	if p.AnchorSlug {
+		name += p.anchorSeparator() + githubSlug(title)
+	}
+	return name
+}
+
+// emitsHeadAnchors reports whether passMkHeads should insert an
+// <a name> tag before each heading.  With an AnchorMap the anchors
+// recorded may differ from those a renderer generates, so it does.
+func (p *Processor) emitsHeadAnchors() bool {
+	return p.AnchorStyle != AnchorGitHub || p.AnchorMap != nil
+}
+
+// anchorNamer returns a function that gives the anchor name for each
+// numbered heading of a document.  It must be called for every numbered
+// heading in document order, since some styles make names unique by
+// counting repeats.  With an AnchorMap, sections take the anchors it
+// records for them.
+func (p *Processor) anchorNamer() func(number, title string) string {
+	name := p.styleNamer()
+	if p.AnchorMap != nil {
+		return p.AnchorMap.namer(name, p.anchorSeparator())
+	}
+	return name
+}
+
+// styleNamer returns the anchorNamer of p's AnchorStyle.
+func (p *Processor) styleNamer() func(number, title string) string {
+	switch p.AnchorStyle {
+	case AnchorGitHub:
+		slug := githubSlugger()
+		return func(number, title string) string {
+			return slug(p.numberText(number) + " " + title)
+		}
+	case AnchorCustom:
+		return func(number, title string) string {
+			r := strings.NewReplacer(
+				"{number}", p.anchorNumber(number),
+				"{slug}", githubSlug(title),
+			)
+			return safeAnchorName(r.Replace(p.AnchorFormat))
+		}
+	default:
+		return func(number, title string) string {
+			return safeAnchorName(p.sectionAnchor(number, title))
+		}
+	}
+}
+
+// githubSlug returns the anchor GitHub generates for a heading: the
+// text lowercased, with punctuation removed and spaces turned into
+// hyphens.
+func githubSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// githubSlugger returns a function that slugs successive headings the
+// way GitHub does, appending -1, -2, ... to repeated slugs.
+func githubSlugger() func(text string) string {
+	seen := map[string]int{}
+	return func(text string) string {
+		slug := githubSlug(text)
+		n := seen[slug]
+		seen[slug]++
+		if n > 0 {
+			slug = fmt.Sprintf("%s-%d", slug, n)
+		}
+		return slug
+	}
+}
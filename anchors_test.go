@@ -0,0 +1,139 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestGithubSlug(t *testing.T) {
+	cases := map[string]string{
+		"1.1. A Sub-Level Header": "11-a-sub-level-header",
+		"What's new?":             "whats-new",
+		"snake_case & more":       "snake_case--more",
+		"Ünïcode Title":           "ünïcode-title",
+	}
+	for in, want := range cases {
+		have := githubSlug(in)
+		Tassert(t, have == want, "%q: want %q, have %q", in, want, have)
+	}
+
+	slug := githubSlugger()
+	have := []string{slug("Notes"), slug("Notes"), slug("Notes")}
+	Tassert(t, reflect.DeepEqual(have, []string{"notes", "notes-1", "notes-2"}), "have %v", have)
+}
+
+func TestAnchorStyles(t *testing.T) {
+	lines := []string{
+		"# Top",
+		"## Design Goals",
+		"See [sec dgoals].",
+	}
+
+	opts := DefaultOptions()
+	opts.AnchorStyle = AnchorGitHub
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		"# 1. Top",
+		"## 1.1. Design Goals",
+		`See [<a href="#11-design-goals">sec 1.1</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	opts.AnchorStyle = AnchorCustom
+	opts.AnchorFormat = "s{number}-{slug}"
+	p = NewProcessor(opts)
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want = []string{
+		`<a name="s1-top"></a>`,
+		"# 1. Top",
+		`<a name="s1_1-design-goals"></a>`,
+		"## 1.1. Design Goals",
+		`See [<a href="#s1_1-design-goals">sec 1.1</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	opts.AnchorStyle = AnchorSecnum
+	opts.AnchorPrefix = "section-"
+	opts.AnchorSeparator = "-"
+	opts.AnchorSlug = true
+	p = NewProcessor(opts)
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want = []string{
+		`<a name="section-1-top"></a>`,
+		"# 1. Top",
+		`<a name="section-1-1-design-goals"></a>`,
+		"## 1.1. Design Goals",
+		`See [<a href="#section-1-1-design-goals">sec 1.1</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	again, err := p.Process(out)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+	stripped := p.Strip(out)
+	Tassert(t, stripped[2] == "See [sec designgoals].", "have %q", stripped[2])
+}
+
+func TestAnchorTargets(t *testing.T) {
+	cases := map[string][]string{
+		`<a name="sec1"></a>`:                 {"sec1"},
+		`<a id="intro"></a>`:                  {"intro"},
+		`<a class="x" id="y">text</a>`:        {"y"},
+		`## Design Goals {#goals}`:            {"goals"},
+		`<a href="#sec1">sec 1</a>`:           nil,
+		`<a name="a"></a> and <a id="b"></a>`: {"a", "b"},
+	}
+	for line, want := range cases {
+		have := anchorTargets(line)
+		Tassert(t, reflect.DeepEqual(have, want), "%q: want %v, have %v", line, want, have)
+	}
+
+	// all three forms are valid targets for verification
+	p := NewProcessor(DefaultOptions())
+	err := p.verify([]string{
+		`<a id="intro"></a>`,
+		`## Goals {#goals}`,
+		`See <a href="#intro">intro</a> and <a href="#goals">goals</a>.`,
+	})
+	Tassert(t, err == nil, "verify failed: %v", err)
+}
+
+func TestHeadingIDs(t *testing.T) {
+	lines := []string{
+		"# Top",
+		"See [sec #goals] and [#goals], not [#goals](x).",
+		"## Design Goals {#goals}",
+	}
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Top",
+		`See [<a href="#goals">sec 1.1</a>] and [<a href="#goals">sec 1.1</a>], not [#goals](x).`,
+		"## 1.1. Design Goals {#goals}",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := []string{
+		"# Top",
+		"See [sec #goals] and [sec #goals], not [#goals](x).",
+		"## Design Goals {#goals}",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+
+	_, err = p.Process([]string{"# Top", "See [sec #missing]."})
+	Tassert(t, err != nil, "want error for unknown ID")
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "sec-unresolved", "have %v", warnings)
+}
@@ -0,0 +1,79 @@
+package markproc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// isAppendixMarker reports whether line marks the start of the
+// appendices, after which top-level sections are lettered.
+func isAppendixMarker(line string) bool {
+	return strings.ToLower(strings.TrimSpace(line)) == "<!-- appendix -->" || isDirective(line, "appendix")
+}
+
+// precedesAppendix reports whether a heading titled title is the
+// p.AppendixAfter heading, the last one before the appendices.
+func (p *Processor) precedesAppendix(title string) bool {
+	return p.AppendixAfter != "" && strings.EqualFold(strings.TrimSpace(title), p.AppendixAfter)
+}
+
+// appendixMask returns, for each line, whether it comes after the start
+// of the appendices: an appendix marker, or the p.AppendixAfter
+// heading.
+func (p *Processor) appendixMask(lines []string) []bool {
+	mask := make([]bool, len(lines))
+	code := codeMask(lines)
+	in := false
+	for i, line := range lines {
+		mask[i] = in
+		if code[i] {
+			continue
+		}
+		if isAppendixMarker(line) {
+			in = true
+		} else if m := headerRegexp.FindStringSubmatch(line); m != nil && p.AppendixAfter != "" {
+			title := m[2]
+			if h := p.prevNumbered(line, false); h != nil {
+				title = h[2]
+			}
+			title, _ = headingMarkers(title)
+			in = in || p.precedesAppendix(title)
+		}
+	}
+	return mask
+}
+
+// startAppendix makes the numberer letter top-level sections from A
+// on.  Calling it again has no effect.
+func (n *numberer) startAppendix() {
+	if n.appendix {
+		return
+	}
+	n.appendix = true
+	n.counts = nil
+}
+
+// appendixLetter returns the letter of the nth appendix: A, B, ... Z,
+// then AA, AB and so on.
+func appendixLetter(n int) string {
+	s := ""
+	for n > 0 {
+		n--
+		s = string(rune('A'+n%26)) + s
+		n /= 26
+	}
+	return s
+}
+
+// sectionOrdinal returns a number giving the document order of one
+// component of a section number: numbers sort before appendix letters.
+func sectionOrdinal(component string) int {
+	if n, err := strconv.Atoi(component); err == nil {
+		return n
+	}
+	n := 0
+	for _, c := range component {
+		n = n*26 + int(c-'A') + 1
+	}
+	return 1<<20 + n
+}
@@ -0,0 +1,247 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Citation styles for Options.CiteStyle.
+const (
+	// CiteNumeric numbers references in order of first citation and
+	// cites them as [1].
+	CiteNumeric = "numeric"
+	// CiteAuthorYear sorts references by author and cites them as
+	// (Author, 2024).
+	CiteAuthorYear = "author-year"
+)
+
+// CiteStyles lists the valid values of Options.CiteStyle, "" leaving
+// [ref] citations as they are.
+var CiteStyles = []string{"", CiteNumeric, CiteAuthorYear}
+
+var (
+	// yearRe matches a publication year in a reference definition.
+	yearRe = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+	// authorRe matches the first author's surname at the start of a
+	// reference definition, e.g. "Bradner" in "Bradner, S. (1997)".
+	authorRe = regexp.MustCompile(`^([^,(.]+)`)
+)
+
+// isBibliographyMarker reports whether line asks for a formatted
+// References list.
+func isBibliographyMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[bibliography]", "<!-- bibliography -->":
+		return true
+	}
+	return isDirective(line, "bibliography")
+}
+
+// bibEntry is one reference definition.
+type bibEntry struct {
+	ref, text, author, year string
+	// citedIn is the list of citing sections passCitedIn appended to
+	// the definition, if any.
+	citedIn string
+	// number is the entry's position in the sorted bibliography.
+	number int
+}
+
+// bibliography holds the reference definitions of a document, sorted
+// for the citation style.
+type bibliography struct {
+	entries []*bibEntry
+	byRef   map[string]*bibEntry
+	// marker is the index of the file holding the bibliography marker,
+	// or -1 if there is none.
+	marker int
+}
+
+// newBibliography collects the [ref]: definitions of docs, the files of
+// a document in order, and sorts them for style.
+func newBibliography(docs [][]string, style string) *bibliography {
+	bib := &bibliography{byRef: map[string]*bibEntry{}, marker: -1}
+	cited := map[string]int{}
+	for d, lines := range docs {
+		code := codeMask(lines)
+		for i, line := range lines {
+			if code[i] {
+				continue
+			}
+			if isBibliographyMarker(line) && bib.marker < 0 {
+				bib.marker = d
+			}
+			if extMatch := extLinkRegexp.FindStringSubmatch(line); len(extMatch) > 0 {
+				ref := extMatch[1]
+				if bib.byRef[ref] != nil {
+					continue
+				}
+				text := line[len(extMatch[0]):]
+				citedIn := strings.TrimSpace(citedInRe.FindString(text))
+				text = citedInRe.ReplaceAllString(text, "")
+				text = strings.TrimSpace(displayFieldRe.ReplaceAllString(text, ""))
+				e := &bibEntry{ref: ref, text: text, author: ref, year: "n.d.", citedIn: citedIn}
+				if m := authorRe.FindStringSubmatch(text); m != nil && strings.TrimSpace(m[1]) != "" {
+					e.author = strings.TrimSpace(m[1])
+				}
+				if y := yearRe.FindString(text); y != "" {
+					e.year = y
+				}
+				bib.entries = append(bib.entries, e)
+				bib.byRef[ref] = e
+				continue
+			}
+			for _, c := range citations(line) {
+				if _, ok := cited[c.ref]; !ok {
+					cited[c.ref] = len(cited)
+				}
+			}
+		}
+	}
+
+	switch style {
+	case CiteNumeric:
+		// cited references in order of first citation, then the rest
+		// in the order they are defined
+		order := func(e *bibEntry) int {
+			if n, ok := cited[e.ref]; ok {
+				return n
+			}
+			return len(cited)
+		}
+		sort.SliceStable(bib.entries, func(i, j int) bool {
+			return order(bib.entries[i]) < order(bib.entries[j])
+		})
+	case CiteAuthorYear:
+		sort.SliceStable(bib.entries, func(i, j int) bool {
+			a, b := bib.entries[i], bib.entries[j]
+			if !strings.EqualFold(a.author, b.author) {
+				return strings.ToLower(a.author) < strings.ToLower(b.author)
+			}
+			return a.year < b.year
+		})
+	}
+	for i, e := range bib.entries {
+		e.number = i + 1
+	}
+	return bib
+}
+
+// citation returns the text of a citation of ref, linking to href, or
+// "" if ref is undefined or bib is nil.
+func (bib *bibliography) citation(ref, href, style string) string {
+	if bib == nil {
+		return ""
+	}
+	e := bib.byRef[ref]
+	if e == nil {
+		return ""
+	}
+	switch style {
+	case CiteNumeric:
+		return fmt.Sprintf(`[<a href="%s">%d</a>]`, href, e.number)
+	case CiteAuthorYear:
+		return fmt.Sprintf(`(<a href="%s">%s, %s</a>)`, href, escapeText(e.author), escapeText(e.year))
+	}
+	return ""
+}
+
+// render returns the formatted References list.  With anchors set
+// each entry carries the anchor of its definition.
+func (bib *bibliography) render(style string, anchors bool) (lines []string) {
+	for _, e := range bib.entries {
+		anchor := ""
+		if anchors {
+			anchor = fmt.Sprintf(`<a name="%s"></a>`, e.ref)
+		}
+		text := e.text
+		if e.citedIn != "" {
+			text += " " + e.citedIn
+		}
+		if style == CiteNumeric {
+			lines = append(lines, fmt.Sprintf("- %s[%d] %s", anchor, e.number, text))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s%s", anchor, text))
+	}
+	return
+}
+
+// isDefinition reports whether lines[i] is a [ref]: definition, or the
+// anchor passMkExterns inserted before one.
+func isDefinition(lines []string, i int) bool {
+	if extLinkRegexp.MatchString(lines[i]) {
+		return true
+	}
+	if i+1 < len(lines) {
+		if extMatch := extLinkRegexp.FindStringSubmatch(lines[i+1]); len(extMatch) > 0 {
+			return lines[i] == fmt.Sprintf(`<a name="%s"></a>`, extMatch[1])
+		}
+	}
+	return false
+}
+
+// bibliography returns the bibliography of every file, or nil for a
+// single document.
+func (pr *project) bibliography() *bibliography {
+	if pr == nil {
+		return nil
+	}
+	return pr.bib
+}
+
+// docBibliography returns the bibliography for the document being
+// processed, collecting it from lines the first time it is needed, as
+// linking the citations hides them from later passes.
+func (p *Processor) docBibliography(lines []string) *bibliography {
+	if bib := p.project.bibliography(); bib != nil {
+		return bib
+	}
+	if p.bib == nil {
+		p.bib = newBibliography([][]string{lines}, p.CiteStyle)
+	}
+	return p.bib
+}
+
+// The comments passBibliography puts around a formatted References
+// list.
+const (
+	bibStart = "<!-- bibliography -->"
+	bibEnd   = "<!-- /bibliography -->"
+)
+
+// passBibliography replaces each [bibliography] or
+// <!-- bibliography --> marker line with the formatted list of
+// reference definitions, between <!-- bibliography --> and
+// <!-- /bibliography --> comments.  A document without definitions,
+// e.g. one processed before with HideDefinitions, keeps its list.  With p.HideDefinitions set and a marker in
+// the document, the definitions themselves are removed and their
+// anchors move to the formatted list.
+func (p *Processor) passBibliography(lines []string) []string {
+	bib := p.docBibliography(lines)
+	hide := p.HideDefinitions && bib.marker >= 0
+	// only the first list carries the anchors
+	anchors := hide
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if isBibliographyMarker(line) && len(bib.entries) > 0 {
+			out.add(i, bibStart)
+			out.add(i, bib.render(p.CiteStyle, anchors)...)
+			out.add(i, bibEnd)
+			anchors = false
+			continue
+		}
+		if hide && isDefinition(lines, i) {
+			continue
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
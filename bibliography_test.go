@@ -0,0 +1,83 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestBibliography(t *testing.T) {
+	lines := []string{
+		"See [rfc2119] and [knuth] here.",
+		"Again [rfc2119] here.",
+		"## References",
+		"[bibliography]",
+		"[unused]: Zeta, Z. Never cited.",
+		"[knuth]: Knuth, D. (1984). Literate Programming.",
+		"[rfc2119]: Bradner, S. (1997). Key words for use in RFCs.",
+	}
+
+	opts := DefaultOptions()
+	opts.MkHeads = false
+	opts.CiteStyle = CiteNumeric
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[0] == `See [<a href="#rfc2119">1</a>] and [<a href="#knuth">2</a>] here.`, "have %q", out[0])
+	want := []string{
+		"<!-- bibliography -->",
+		"- [1] Bradner, S. (1997). Key words for use in RFCs.",
+		"- [2] Knuth, D. (1984). Literate Programming.",
+		"- [3] Zeta, Z. Never cited.",
+		"<!-- /bibliography -->",
+	}
+	Tassert(t, reflect.DeepEqual(out[3:8], want), "\nwant: %q\nhave: %q", want, out[3:8])
+
+	p.CiteStyle = CiteAuthorYear
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[0] == `See (<a href="#rfc2119">Bradner, 1997</a>) and (<a href="#knuth">Knuth, 1984</a>) here.`, "have %q", out[0])
+	want = []string{
+		"<!-- bibliography -->",
+		"- Bradner, S. (1997). Key words for use in RFCs.",
+		"- Knuth, D. (1984). Literate Programming.",
+		"- Zeta, Z. Never cited.",
+		"<!-- /bibliography -->",
+	}
+	Tassert(t, reflect.DeepEqual(out[3:8], want), "\nwant: %q\nhave: %q", want, out[3:8])
+	e := newBibliography([][]string{lines}, CiteAuthorYear).byRef["unused"]
+	Tassert(t, e.year == "n.d.", "have %q", e.year)
+}
+
+func TestHideDefinitions(t *testing.T) {
+	lines := []string{
+		"See [knuth] here.",
+		"[bibliography]",
+		"[knuth]: Knuth, D. (1984). Literate Programming.",
+	}
+
+	opts := DefaultOptions()
+	opts.CiteStyle = CiteNumeric
+	opts.HideDefinitions = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`See [<a href="#knuth">1</a>] here.`,
+		"<!-- bibliography -->",
+		`- <a name="knuth"></a>[1] Knuth, D. (1984). Literate Programming.`,
+		"<!-- /bibliography -->",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	// processing the output again keeps the list
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	// without a list to show them the definitions stay
+	out, err = p.Process([]string{"See [knuth] here.", "[knuth]: Knuth, D. (1984)."})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(out) == 3, "have %q", out)
+}
@@ -0,0 +1,159 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// indexMarkRe matches an index mark: [[index:term]], indexing the
+	// place it is written, or [text]{.idx}, indexing the text it wraps.
+	// The groups are the term of the first form and the text of the
+	// second.
+	indexMarkRe = regexp.MustCompile(`\[\[index:\s*([^\[\]]+?)\s*\]\]|\[([^\[\]]+)\]\{\.idx\}`)
+	// indexCommentRe matches an index mark as passMkIndex writes it,
+	// <!-- index: term -->: the group is the term.
+	indexCommentRe = regexp.MustCompile(`<!-- index: (.+?) -->`)
+)
+
+// The comments passMkIndex puts around a generated Index.
+const (
+	indexStart = "<!-- index -->"
+	indexEnd   = "<!-- /index -->"
+)
+
+// isIndexMarker reports whether line asks for a generated Index.
+func isIndexMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[index]", indexStart:
+		return true
+	}
+	return isDirective(line, "index")
+}
+
+// indexEntry is one term of the Index and the sections it appears in,
+// in document order.
+type indexEntry struct {
+	term     string
+	sections []Target
+}
+
+// passMkIndex turns each index mark, [[index:term]] or [text]{.idx},
+// into an invisible <!-- index: term --> comment, the latter after the
+// text, and replaces each [index] or <!-- index --> marker line with
+// the marked terms sorted alphabetically, each linking to every
+// numbered section it is marked in, between <!-- index --> and
+// <!-- /index --> comments.  Terms are grouped ignoring case, and
+// marks in headings, code and before the first numbered heading aren't
+// indexed.
+func (p *Processor) passMkIndex(lines []string) []string {
+	targets := p.sectionTargets(lines, "")
+	code := codeMask(lines)
+	entries := map[string]*indexEntry{}
+	index := func(term string, section *Target) {
+		if section == nil {
+			return
+		}
+		key := termKey(term)
+		entry, ok := entries[key]
+		if !ok {
+			entry = &indexEntry{term: strings.Join(strings.Fields(term), " ")}
+			entries[key] = entry
+		}
+		for _, t := range entry.sections {
+			if t.Number == section.Number {
+				return
+			}
+		}
+		entry.sections = append(entry.sections, *section)
+	}
+
+	marked := make([]string, len(lines))
+	marker := false
+	var current *Target
+	for i, line := range lines {
+		marked[i] = line
+		if code[i] {
+			continue
+		}
+		if isIndexMarker(line) {
+			marker = true
+			continue
+		}
+		if m := p.numberedHeading(line); m != nil {
+			title, _ := tocExcluded(m[3])
+			if t, ok := targets[strings.ToLower(title)]; ok {
+				t = t.numbered(strings.TrimSuffix(m[2], "."))
+				current = &t
+			}
+			continue
+		}
+		if headerRegexp.MatchString(line) {
+			continue
+		}
+		marked[i] = outsideCode(line, func(part string) string {
+			for _, m := range indexCommentRe.FindAllStringSubmatch(part, -1) {
+				index(m[1], current)
+			}
+			return indexMarkRe.ReplaceAllStringFunc(part, func(mark string) string {
+				m := indexMarkRe.FindStringSubmatch(mark)
+				if m[1] != "" {
+					index(m[1], current)
+					return fmt.Sprintf("<!-- index: %s -->", m[1])
+				}
+				index(m[2], current)
+				return fmt.Sprintf("%s<!-- index: %s -->", m[2], m[2])
+			})
+		})
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range marked {
+		if marker && !code[i] && isIndexMarker(line) {
+			out.add(i, indexStart)
+			out.add(i, p.renderIndex(entries)...)
+			out.add(i, indexEnd)
+			continue
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// renderIndex returns the Index list.
+func (p *Processor) renderIndex(entries map[string]*indexEntry) (lines []string) {
+	keys := []string{}
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry := entries[key]
+		links := make([]string, len(entry.sections))
+		for i, t := range entry.sections {
+			links[i] = fmt.Sprintf(`<a href="#%s">§%s</a>`, t.Name, t.Number)
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", escapeText(entry.term), strings.Join(links, ", ")))
+	}
+	return
+}
+
+// unmarkIndex returns line with the index marks passMkIndex wrote
+// turned back into [text]{.idx}, where the term follows its text, or
+// [[index:term]].
+func unmarkIndex(line string) string {
+	locs := indexCommentRe.FindAllStringSubmatchIndex(line, -1)
+	for j := len(locs) - 1; j >= 0; j-- {
+		loc := locs[j]
+		term := line[loc[2]:loc[3]]
+		if strings.HasSuffix(line[:loc[0]], term) {
+			start := loc[0] - len(term)
+			line = line[:start] + "[" + term + "]{.idx}" + line[loc[1]:]
+			continue
+		}
+		line = line[:loc[0]] + "[[index:" + term + "]]" + line[loc[1]:]
+	}
+	return line
+}
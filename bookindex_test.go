@@ -0,0 +1,50 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestMkIndex(t *testing.T) {
+	p := NewProcessor(DefaultOptions())
+	lines := []string{
+		"# Intro",
+		"A [parser]{.idx} reads input.[[index:Lexing]]",
+		"## Design",
+		"The [[index: parser]]parser and [[index:API]] again: [Parser]{.idx}.",
+		"`[[index:code]]`",
+		"# Index",
+		"[index]",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"A parser<!-- index: parser --> reads input.<!-- index: Lexing -->",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Design",
+		"The <!-- index: parser -->parser and <!-- index: API --> again: Parser<!-- index: Parser -->.",
+		"`[[index:code]]`",
+		`<a name="sec2"></a>`,
+		"# 2. Index",
+		"<!-- index -->",
+		`- API: <a href="#sec1_1">§1.1</a>`,
+		`- Lexing: <a href="#sec1">§1</a>`,
+		`- parser: <a href="#sec1">§1</a>, <a href="#sec1_1">§1.1</a>`,
+		"<!-- /index -->",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := append([]string{}, lines...)
+	wantStripped[3] = "The [[index:parser]]parser and [[index:API]] again: [Parser]{.idx}."
+	wantStripped[6] = "<!-- index -->"
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
@@ -0,0 +1,56 @@
+package markproc
+
+import "sort"
+
+// Chooser picks the section an ambiguous [sec ...] reference means:
+// ref is the text of the reference, line its 1-based line in the input
+// document and candidates the sections it matches.  It returns the
+// index of the chosen candidate, or -1 to leave the reference
+// unresolved.  It is called from one goroutine at a time unless a
+// project is processed with Jobs above one.
+type Chooser func(ref string, line int, candidates []Target) int
+
+// Choice is a target picked by Options.Choose for an ambiguous
+// reference.
+type Choice struct {
+	// Line is the 1-based line of the reference in the input document.
+	Line int
+	// Ref is the text of the reference, e.g. "dsg" for [sec dsg].
+	Ref    string
+	Target Target
+	// Rewrite, if not empty, is reference text the Matcher resolves to
+	// Target alone, which can replace Ref in the source.
+	Rewrite string
+}
+
+// Choices returns the targets Options.Choose picked for ambiguous
+// references during the last Process call.
+func (p *Processor) Choices() []Choice {
+	return p.choices
+}
+
+// choose asks p.Choose which of matches, keys of targets, the reference
+// ref on line i means, recording the choice.  The candidates are offered
+// in document order.
+func (p *Processor) choose(i int, ref string, matches []string, targets map[string]Target) (key string, ok bool) {
+	matches = append([]string{}, matches...)
+	sort.SliceStable(matches, func(a, b int) bool {
+		return direction(p.numberFormat(), targets[matches[a]].Number, targets[matches[b]].Number) == " below"
+	})
+	candidates := make([]Target, len(matches))
+	for j, key := range matches {
+		candidates[j] = targets[key]
+	}
+	line := p.lineOf(i)
+	n := p.Choose(ref, line, candidates)
+	if n < 0 || n >= len(matches) {
+		return "", false
+	}
+	key = matches[n]
+	c := Choice{Line: line, Ref: ref, Target: targets[key]}
+	if abbrev, ok := abbreviate(p.matcher(), key, keys(targets)); ok {
+		c.Rewrite = abbrev
+	}
+	p.choices = append(p.choices, c)
+	return key, true
+}
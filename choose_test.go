@@ -0,0 +1,36 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestChoose(t *testing.T) {
+	opts := DefaultOptions()
+	asked := []string{}
+	opts.Choose = func(ref string, line int, candidates []Target) int {
+		for _, c := range candidates {
+			asked = append(asked, c.Heading)
+		}
+		Tassert(t, ref == "dsg" && line == 3, "have %q on line %d", ref, line)
+		return 0
+	}
+	p := NewProcessor(opts)
+	out, err := p.Process([]string{"# Design goals", "# Design", "See [sec dsg]."})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[4] == `See [<a href="#sec1">sec 1</a>].`, "have %q", out[4])
+	want := []string{"Design goals", "Design"}
+	Tassert(t, reflect.DeepEqual(asked, want), "\nwant: %q\nhave: %q", want, asked)
+	choices := p.Choices()
+	Tassert(t, len(choices) == 1 && choices[0].Target.Heading == "Design goals" && choices[0].Rewrite == "designgoals", "have %+v", choices)
+
+	// declining leaves the reference ambiguous
+	p.Choose = func(string, int, []Target) int { return -1 }
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{"# Design goals", "# Design", "See [sec dsg]."})
+	Tassert(t, err != nil, "want an error for an ambiguous reference")
+	Tassert(t, len(p.Choices()) == 0, "have %+v", p.Choices())
+}
@@ -0,0 +1,119 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// citedInMarker starts the list of citing sections passCitedIn appends
+// to a [ref]: definition.
+const citedInMarker = "<!-- cited-in -->"
+
+var (
+	// citedInRe matches the list of citing sections in a definition.
+	citedInRe = regexp.MustCompile(`\s*` + regexp.QuoteMeta(citedInMarker) + `Cited in (?:<a [^>]*>[^<]*</a>(?:, )?)+\.`)
+	// refLinkRe matches a link made from a [ref] citation by an earlier
+	// run: the groups are the file and the ref.
+	refLinkRe = regexp.MustCompile(`<a href="([^"#]*)#(\w+)">`)
+)
+
+// passCitedIn appends to each [ref]: definition, before any display:
+// field, a list of back-links to the numbered sections citing it, e.g.
+// "Cited in §2.1, §4.3.", so that readers of the references can jump
+// back to the citing text.  Citations are recognized both as [ref] and
+// as links made from them by an earlier run; those before the first
+// numbered heading, and in other files of a project, aren't listed.  It
+// must run after passMkHeads and before passLinkExterns.
+func (p *Processor) passCitedIn(lines []string) []string {
+	targets := p.project.sectionTargets()
+	if targets == nil {
+		targets = p.sectionTargets(lines, "")
+	}
+
+	defined := map[string]bool{}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if m := extLinkRegexp.FindStringSubmatch(line); m != nil && !code[i] {
+			defined[m[1]] = true
+		}
+	}
+
+	// citedIn maps each ref to the sections citing it, in order
+	citedIn := map[string][]Target{}
+	cite := func(ref string, section *Target) {
+		if !defined[ref] || section == nil {
+			return
+		}
+		for _, t := range citedIn[ref] {
+			if t.Name == section.Name && t.File == section.File {
+				return
+			}
+		}
+		citedIn[ref] = append(citedIn[ref], *section)
+	}
+	var current *Target
+	inBib := false
+	for i, line := range lines {
+		switch line {
+		case bibStart:
+			inBib = true
+		case bibEnd:
+			inBib = false
+		}
+		if code[i] || inBib || extLinkRegexp.MatchString(line) {
+			continue
+		}
+		if m := p.numberedHeading(line); m != nil {
+			title, _ := tocExcluded(m[3])
+			if t, ok := targets[strings.ToLower(title)]; ok {
+				t = t.numbered(strings.TrimSuffix(m[2], "."))
+				current = &t
+			}
+			continue
+		}
+		outsideCode(line, func(line string) string {
+			return outsideComments(line, func(part string) string {
+				for _, c := range citations(part + " ") {
+					cite(c.ref, current)
+				}
+				for _, m := range refLinkRe.FindAllStringSubmatch(part, -1) {
+					if p.fileHref(p.project.externFile(m[2])) == m[1] {
+						cite(m[2], current)
+					}
+				}
+				return part
+			})
+		})
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = line
+		m := extLinkRegexp.FindStringSubmatch(line)
+		if m == nil || code[i] {
+			continue
+		}
+		line = citedInRe.ReplaceAllString(line, "")
+		sections := citedIn[m[1]]
+		if len(sections) == 0 {
+			out[i] = line
+			continue
+		}
+		field := ""
+		if loc := displayFieldRe.FindStringIndex(line); loc != nil {
+			line, field = strings.TrimRight(line[:loc[0]], " \t"), " "+line[loc[0]:]
+		}
+		out[i] = line + " " + p.citedIn(sections) + field
+	}
+	return out
+}
+
+// citedIn returns the list of back-links to sections.
+func (p *Processor) citedIn(sections []Target) string {
+	links := make([]string, len(sections))
+	for i, target := range sections {
+		links[i] = fmt.Sprintf(`<a href="%s#%s">§%s</a>`, p.fileHref(target.File), target.Name, target.Number)
+	}
+	return fmt.Sprintf("%sCited in %s.", citedInMarker, strings.Join(links, ", "))
+}
@@ -0,0 +1,58 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestCitedIn(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CitedIn = true
+	opts.CiteStyle = CiteNumeric
+	opts.ExternDisplay = true
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Intro",
+		"See [knuth] and [rfc].",
+		"## More",
+		"Again [knuth].",
+		"# References",
+		"[bibliography]",
+		"[knuth]: Knuth, D. (1984) Literate programming.",
+		`[rfc]: Bradner, S. (1997) Key words. display: "RFC 2119"`,
+		"[unused]: Nobody (2000) Uncited.",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`See [<a href="#knuth">1</a>] and [<a href="#rfc">2</a>].`,
+		`<a name="sec1_1"></a>`,
+		"## 1.1. More",
+		`Again [<a href="#knuth">1</a>].`,
+		`<a name="sec2"></a>`,
+		"# 2. References",
+		"<!-- bibliography -->",
+		`- [1] Knuth, D. (1984) Literate programming. <!-- cited-in -->Cited in <a href="#sec1">§1</a>, <a href="#sec1_1">§1.1</a>.`,
+		`- [2] Bradner, S. (1997) Key words. <!-- cited-in -->Cited in <a href="#sec1">§1</a>.`,
+		"- [3] Nobody (2000) Uncited.",
+		"<!-- /bibliography -->",
+		`<a name="knuth"></a>`,
+		`[knuth]: Knuth, D. (1984) Literate programming. <!-- cited-in -->Cited in <a href="#sec1">§1</a>, <a href="#sec1_1">§1.1</a>.`,
+		`<a name="rfc"></a>`,
+		`[rfc]: Bradner, S. (1997) Key words. <!-- cited-in -->Cited in <a href="#sec1">§1</a>.`,
+		`<a name="unused"></a>`,
+		"[unused]: Nobody (2000) Uncited.",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	Tassert(t, stripped[len(stripped)-3] == lines[6], "\nwant: %q\nhave: %q", lines[6], stripped[len(stripped)-3])
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdBuild implements `markproc build -o DIR files...`, processing the
+// files as the chapters of one document and writing the results under
+// DIR.
+func cmdBuild(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	outDir := fs.String("o", "", "directory to write processed files to (required)")
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.ProjectLinkExt, "link-ext", opts.ProjectLinkExt, "extension used in links between files")
+	fs.StringVar(&opts.Permalink, "permalink", "", "link between files with absolute URLs from this template, e.g. /{path}/; {path}, {dir} and {name} are replaced")
+	fs.StringVar(&opts.RefShortcodes, "ref-shortcodes", "", "link between files with hugo {{% relref %}} or jekyll {% link %} shortcodes")
+	fs.StringVar(&opts.SiteRoot, "site-root", "", "directory -permalink and -ref-shortcodes paths are relative to, e.g. content")
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AnchorPrefix, "anchor-prefix", "sec", "prefix of section anchor names, e.g. s or section-")
+	fs.StringVar(&opts.AnchorSeparator, "anchor-separator", "_", "separator between the parts of numbers in anchor names, e.g. - or .")
+	fs.BoolVar(&opts.AnchorSlug, "anchor-slug", false, "append the slugged heading title to section anchor names")
+	fs.StringVar(&opts.SecRefFormat, "sec-ref-format", opts.SecRefFormat, "text of [sec ...] links; {number} and {title} are replaced")
+	fs.Var(refTemplates{&opts}, "ref-template", "link text template, KIND=TEMPLATE or TEMPLATE for sec; repeat for each kind")
+	fs.IntVar(&opts.Jobs, "j", runtime.NumCPU(), "number of files to link and verify at once")
+	fs.IntVar(&opts.ShiftHeadings, "shift-headings", 0, "move every heading N levels down before numbering, or up if N is negative")
+	fs.StringVar(&opts.StartNumber, "start-number", "", "number of the first section of the first file, e.g. 4 or 2.3")
+	fs.StringVar(&opts.NumberFormat, "number-format", "", "style of section numbers at each level, e.g. I.A.1.a: 1 for numbers, I and i for roman numerals, A and a for letters")
+	fs.StringVar(&opts.NumberSuffix, "number-suffix", ".", "text after section numbers in headings: . for \"1. Title\", .0 for \"1.0 Title\" or none for \"1 Title\"")
+	numberState := fs.String("number-state", "", "carry on numbering from where it stopped in FILE, if it exists, and save where it stops in FILE")
+	fs.IntVar(&opts.TOCDepth, "toc-depth", opts.TOCDepth, "deepest heading level listed in a table of contents (0 for all)")
+	fs.BoolVar(&opts.CheckURLs, "check-urls", false, "request each http and https URL linked to and warn about dead or redirected links")
+	fs.StringVar(&opts.URLPolicy, "url-policy", markproc.URLWarn, "with -check-urls, warn or error on dead links")
+	urlJobs := fs.Int("url-jobs", 8, "with -check-urls, number of URLs to request at once")
+	profileName := fs.String("profile", "", "apply named bundles of settings and rules, e.g. ietf, github or book, and keep the <!-- if: NAME --> content for each, e.g. internal,draft; other flags override them")
+	profilesFile := fs.String("profiles", "", "add the profiles in the JSON FILE to those -profile can select")
+	fs.Parse(args)
+	if _, err := applyProfile(fs, args, &opts, *profileName, *profilesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if opts.CheckURLs {
+		opts.URLChecker = markproc.NewURLChecker(opts.Limits.NetworkTimeout, *urlJobs)
+	}
+	if !oneOf(opts.RefShortcodes, markproc.RefShortcodeStyles) {
+		fmt.Fprintf(os.Stderr, "unknown shortcode style %q\n", opts.RefShortcodes)
+		return 2
+	}
+	// the paths of the documents are relative to the working directory
+	opts.IncludeFS = os.DirFS(".")
+	err := opts.Set("start-number", opts.StartNumber)
+	if err == nil {
+		err = opts.Set("number-format", opts.NumberFormat)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if *numberState != "" {
+		state, err := markproc.ReadNumberState(*numberState)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *numberState, err)
+			return 2
+		}
+		opts.StartState = state
+	}
+
+	if *outDir == "" || fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: markproc build -o DIR files...\n")
+		return 2
+	}
+
+	paths, err := expandArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	docs := []markproc.Document{}
+	for _, path := range paths {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		lines, err := markproc.ReadLines(bytes.NewReader(buf))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		docs = append(docs, markproc.Document{Path: path, Lines: lines})
+	}
+
+	p := markproc.NewProcessor(opts)
+	out, err := p.ProcessProject(docs)
+	status := 0
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		status = 1
+	}
+	for _, doc := range out {
+		err = writeOutput(*outDir, doc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = 1
+		}
+	}
+	if state := p.NumberState(); *numberState != "" && state != nil {
+		err = state.Write(*numberState)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// outputPath returns where the output for the file read from path goes
+// under dir: at the same relative path, or its base name for an
+// absolute one.
+func outputPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		path = filepath.Base(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// writeOutput writes doc under dir, at the same relative path it was
+// read from.
+func writeOutput(dir string, doc markproc.Document) (err error) {
+	path := outputPath(dir, doc.Path)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	err = markproc.WriteLines(f, doc.Lines)
+	if err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestCmdBuild(t *testing.T) {
+	dir := t.TempDir()
+	one := filepath.Join(dir, "one.md")
+	two := filepath.Join(dir, "two.md")
+	err := os.WriteFile(one, []byte("# Intro\nSee [sec dsgn].\n"), 0644)
+	Ck(err)
+	err = os.WriteFile(two, []byte("# Design\n"), 0644)
+	Ck(err)
+
+	out := filepath.Join(dir, "out")
+	status := cmdBuild([]string{"-o", out, one, two})
+	Tassert(t, status == 0, "build failed with status %d", status)
+
+	buf, err := os.ReadFile(filepath.Join(out, "one.md"))
+	Ck(err)
+	Tassert(t, strings.Contains(string(buf), `<a href="two.html#sec2">sec 2</a>`), "have %s", buf)
+	buf, err = os.ReadFile(filepath.Join(out, "two.md"))
+	Ck(err)
+	Tassert(t, strings.Contains(string(buf), "# 2. Design"), "have %s", buf)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/stevegt/markproc"
+)
+
+// diagnostics collects the warnings of a run for -check and -format.
+type diagnostics struct {
+	w    io.Writer
+	json bool
+	all  []markproc.Warning
+}
+
+// add adds the warnings found in the file at path.  In text format
+// they are written to d.w at once as path:line:column: lines.
+func (d *diagnostics) add(path string, warnings []markproc.Warning) {
+	for _, warning := range warnings {
+		if warning.File == "" {
+			warning.File = path
+		}
+		d.all = append(d.all, warning)
+		if !d.json {
+			fmt.Fprintf(d.w, "%s: %s: %s: %s\n", location(warning), warning.Severity, warning.Rule, warning.Message)
+		}
+	}
+}
+
+// location formats the position of a warning as file:line:column,
+// leaving out the parts that are unknown.
+func location(w markproc.Warning) string {
+	switch {
+	case w.Line == 0:
+		return w.File
+	case w.Column == 0:
+		return fmt.Sprintf("%s:%d", w.File, w.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", w.File, w.Line, w.Column)
+}
+
+// flush writes the collected warnings as a JSON array in JSON format.
+func (d *diagnostics) flush() (err error) {
+	if !d.json {
+		return
+	}
+	all := d.all
+	if all == nil {
+		all = []markproc.Warning{}
+	}
+	enc := json.NewEncoder(d.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(all)
+}
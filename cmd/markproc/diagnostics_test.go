@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+func TestDiagnostics(t *testing.T) {
+	warnings := []markproc.Warning{
+		{Rule: "heading-gap", Severity: "warning", Line: 3, Column: 1, Message: "gap"},
+		{Rule: "undefined-target", Severity: "error", Line: 5, Message: "no target"},
+		{Rule: "front-matter", Severity: "warning", Message: "bad setting"},
+	}
+
+	buf := &strings.Builder{}
+	d := &diagnostics{w: buf}
+	d.add("doc.md", warnings)
+	want := "doc.md:3:1: warning: heading-gap: gap\n" +
+		"doc.md:5: error: undefined-target: no target\n" +
+		"doc.md: warning: front-matter: bad setting\n"
+	Tassert(t, buf.String() == want, "\nwant: %q\nhave: %q", want, buf.String())
+
+	buf.Reset()
+	d = &diagnostics{w: buf, json: true}
+	err := d.flush()
+	Ck(err)
+	Tassert(t, strings.TrimSpace(buf.String()) == "[]", "have %q", buf.String())
+	d.add("doc.md", warnings)
+	Tassert(t, strings.TrimSpace(buf.String()) == "[]", "JSON written before flush: %q", buf.String())
+	buf.Reset()
+	err = d.flush()
+	Ck(err)
+	var have []markproc.Warning
+	err = json.Unmarshal([]byte(buf.String()), &have)
+	Ck(err)
+	Tassert(t, len(have) == 3 && have[1].File == "doc.md" && have[1].Severity == "error", "have %v", have)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdDiff implements `markproc diff OLD NEW`, reporting how the section
+// numbers of a document shifted from one version to the next, e.g. "old
+// §3.2 is now §3.4", and which [sec ...] references now resolve to a
+// different section.  Like diff, it exits 1 if anything changed and 2
+// on trouble.
+func cmdDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AppendixAfter, "appendix-after", "", "title of the last heading before the appendices")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "usage: markproc diff [-json] OLD NEW\n")
+		return 2
+	}
+
+	versions := [2][]string{}
+	for i, path := range fs.Args() {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 2
+		}
+		versions[i], err = markproc.ReadLines(bytes.NewReader(buf))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 2
+		}
+	}
+
+	d := markproc.NewProcessor(opts).Diff(versions[0], versions[1])
+	if *asJSON {
+		buf, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 2
+		}
+		fmt.Println(string(buf))
+	} else {
+		writeDiff(os.Stdout, d)
+	}
+	if len(d.Sections) > 0 || len(d.Refs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeDiff writes d one change per line.
+func writeDiff(w io.Writer, d markproc.DocDiff) {
+	for _, s := range d.Sections {
+		switch {
+		case s.OldNumber == "":
+			fmt.Fprintf(w, "§%s %s was added\n", s.NewNumber, s.Title)
+		case s.NewNumber == "":
+			fmt.Fprintf(w, "old §%s %s was removed\n", s.OldNumber, s.Title)
+		default:
+			fmt.Fprintf(w, "old §%s %s is now §%s\n", s.OldNumber, s.Title, s.NewNumber)
+		}
+	}
+	for _, r := range d.Refs {
+		fmt.Fprintf(w, "line %d: %s now refers to §%s %s, not §%s %s\n", r.Line, r.Text, r.NewNumber, r.NewTitle, r.OldNumber, r.OldTitle)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+// interactive asks the user which section each ambiguous [sec ...]
+// reference means, and whether to rewrite the reference in the source
+// so that it resolves by itself next time.
+type interactive struct {
+	in  *bufio.Reader
+	out io.Writer
+	// path is the file being processed, for prompts.
+	path string
+}
+
+// newInteractive returns an interactive reading answers from the
+// terminal, or from stdin if that isn't the document, and prompting on
+// stderr.
+func newInteractive(stdinIsDoc bool) (ia *interactive, err error) {
+	in, err := os.Open("/dev/tty")
+	if err != nil {
+		if stdinIsDoc {
+			return nil, fmt.Errorf("-interactive needs a terminal when the document is read from stdin: %w", err)
+		}
+		in = os.Stdin
+	}
+	return &interactive{in: bufio.NewReader(in), out: os.Stderr}, nil
+}
+
+// choose is a markproc.Chooser asking the user to pick one of
+// candidates.
+func (ia *interactive) choose(ref string, line int, candidates []markproc.Target) int {
+	fmt.Fprintf(ia.out, "%s:%d: [sec %s] matches more than one section:\n", ia.path, line, ref)
+	for i, c := range candidates {
+		fmt.Fprintf(ia.out, "  %d) %s %s\n", i+1, c.Number, c.Heading)
+	}
+	for {
+		fmt.Fprintf(ia.out, "Which one [1-%d, Enter to leave it unresolved]? ", len(candidates))
+		answer, readErr := ia.in.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return -1
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(candidates) {
+			return n - 1
+		}
+		if readErr != nil {
+			return -1
+		}
+	}
+}
+
+// confirm asks question, returning true if the user answers yes.
+func (ia *interactive) confirm(question string) bool {
+	fmt.Fprintf(ia.out, "%s [y/N] ", question)
+	answer, _ := ia.in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// rewrite offers to replace each reference resolved by choices in the
+// file at path with its unambiguous form, writing the file if any is
+// accepted.
+func (ia *interactive) rewrite(path string, choices []markproc.Choice) (err error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines, err := markproc.ReadLines(bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+	changed := false
+	for _, c := range choices {
+		if c.Rewrite == "" || c.Line < 1 || c.Line > len(lines) {
+			continue
+		}
+		old, new := "[sec "+c.Ref+"]", "[sec "+c.Rewrite+"]"
+		if !strings.Contains(lines[c.Line-1], old) {
+			continue
+		}
+		if !ia.confirm(fmt.Sprintf("%s:%d: rewrite %s as %s?", path, c.Line, old, new)) {
+			continue
+		}
+		lines[c.Line-1] = strings.Replace(lines[c.Line-1], old, new, 1)
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	return writeLinesTo(path, lines, markproc.DetectTextStyle(buf))
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+var (
+	// lspRefRe matches a bracketed reference: [sec ...], [#id] or [ref].
+	lspRefRe = regexp.MustCompile(`\[[^\[\]]+\]`)
+	// lspSecPrefixRe matches the text of a line before the cursor when
+	// the cursor is inside [sec ...]: the group is what has been typed.
+	lspSecPrefixRe = regexp.MustCompile(`\[sec ([^\[\]]*)$`)
+)
+
+// cmdLSP implements `markproc lsp`, a Language Server speaking LSP on
+// stdin and stdout: it reports problems with references as diagnostics
+// while a document is edited, jumps from a reference to its section or
+// definition and completes section titles inside [sec ...].
+func cmdLSP(args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	opts := markproc.DefaultOptions()
+	profileName := fs.String("profile", "", "apply named bundles of settings and rules, e.g. ietf, github or book, and keep the <!-- if: NAME --> content for each, e.g. internal,draft; other flags override them")
+	profilesFile := fs.String("profiles", "", "add the profiles in the JSON FILE to those -profile can select")
+	fs.Parse(args)
+	if _, err := applyProfile(fs, args, &opts, *profileName, *profilesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	// URLs aren't checked as you type
+	opts.CheckURLs = false
+
+	s := newLSPServer(opts, os.Stdin, os.Stdout)
+	status, err := s.serve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "markproc lsp: %v\n", err)
+		return 1
+	}
+	return status
+}
+
+// lspServer holds the state of a Language Server session.
+type lspServer struct {
+	opts markproc.Options
+	in   *bufio.Reader
+	out  io.Writer
+	// docs holds the lines of each open document by URI.
+	docs map[string][]string
+	// index holds the anchors and links of the open documents, so that
+	// links between them are checked too.  Each change re-indexes only
+	// the document changed.
+	index *markproc.Index
+	// shutdown is set once the client has asked the server to shut
+	// down.
+	shutdown bool
+}
+
+func newLSPServer(opts markproc.Options, in io.Reader, out io.Writer) *lspServer {
+	return &lspServer{opts: opts, in: bufio.NewReader(in), out: out, docs: map[string][]string{}, index: markproc.NewIndex()}
+}
+
+// lspMessage is a JSON-RPC request or notification from the client.
+type lspMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspCompletionItem struct {
+	Label      string      `json:"label"`
+	Detail     string      `json:"detail"`
+	FilterText string      `json:"filterText"`
+	TextEdit   lspTextEdit `json:"textEdit"`
+}
+
+// lspPositionParams are the parameters of definition and completion
+// requests.
+type lspPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// LSP diagnostic severities.
+const (
+	lspError   = 1
+	lspWarning = 2
+)
+
+// serve handles messages until the client sends exit or closes its
+// end, returning the exit status: zero only if the client asked for a
+// shutdown first.
+func (s *lspServer) serve() (status int, err error) {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return 1, nil
+		}
+		if err != nil {
+			return 1, err
+		}
+		switch msg.Method {
+		case "initialize":
+			err = s.reply(msg.ID, map[string]any{
+				"capabilities": map[string]any{
+					// full document sync
+					"textDocumentSync":   1,
+					"definitionProvider": true,
+					"completionProvider": map[string]any{"triggerCharacters": []string{" "}},
+				},
+				"serverInfo": map[string]any{"name": "markproc"},
+			})
+		case "shutdown":
+			s.shutdown = true
+			err = s.reply(msg.ID, nil)
+		case "exit":
+			if s.shutdown {
+				return 0, nil
+			}
+			return 1, nil
+		case "textDocument/didOpen":
+			var params struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil {
+				err = s.update(params.TextDocument.URI, params.TextDocument.Text)
+			}
+		case "textDocument/didChange":
+			var params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+				err = s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+			}
+		case "textDocument/didClose":
+			var params lspPositionParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				uri := params.TextDocument.URI
+				delete(s.docs, uri)
+				err = s.notify("textDocument/publishDiagnostics", map[string]any{
+					"uri":         uri,
+					"diagnostics": []lspDiagnostic{},
+				})
+				if err == nil {
+					err = s.republish(uri, s.index.Remove(lspPath(uri)))
+				}
+			}
+		case "textDocument/definition":
+			var params lspPositionParams
+			json.Unmarshal(msg.Params, &params)
+			err = s.reply(msg.ID, s.definition(params))
+		case "textDocument/completion":
+			var params lspPositionParams
+			json.Unmarshal(msg.Params, &params)
+			err = s.reply(msg.ID, s.completion(params))
+		default:
+			if msg.ID != nil {
+				err = s.write(map[string]any{
+					"jsonrpc": "2.0",
+					"id":      msg.ID,
+					"error":   map[string]any{"code": -32601, "message": "method not found: " + msg.Method},
+				})
+			}
+		}
+		if err != nil {
+			return 1, err
+		}
+	}
+}
+
+// read reads the next message, framed by a Content-Length header.
+func (s *lspServer) read() (msg lspMessage, err error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return msg, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return msg, fmt.Errorf("bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return msg, fmt.Errorf("message without Content-Length")
+	}
+	buf := make([]byte, length)
+	_, err = io.ReadFull(s.in, buf)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(buf, &msg)
+	return
+}
+
+// write writes v as a message framed by a Content-Length header.
+func (s *lspServer) write(v any) (err error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(buf), buf)
+	return
+}
+
+// reply answers the request with id.
+func (s *lspServer) reply(id json.RawMessage, result any) error {
+	return s.write(map[string]any{"jsonrpc": "2.0", "id": id, "result": result})
+}
+
+// notify sends the client a notification.
+func (s *lspServer) notify(method string, params any) error {
+	return s.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// update records the new text of the document at uri and publishes its
+// diagnostics, and those of the other open documents whose links into
+// it may have broken or been fixed by the change.
+func (s *lspServer) update(uri, text string) (err error) {
+	lines, err := markproc.ReadLines(strings.NewReader(text))
+	if err != nil {
+		return
+	}
+	s.docs[uri] = lines
+	p, out := s.process(lines)
+	affected := s.index.Update(lspPath(uri), out)
+	err = s.publish(uri, p)
+	if err != nil {
+		return
+	}
+	return s.republish(uri, affected)
+}
+
+// republish publishes again the diagnostics of the open documents
+// among the affected paths, other than the one at uri.
+func (s *lspServer) republish(uri string, affected []string) (err error) {
+	set := map[string]bool{}
+	for _, path := range affected {
+		set[path] = true
+	}
+	var uris []string
+	for other := range s.docs {
+		if other != uri && set[lspPath(other)] {
+			uris = append(uris, other)
+		}
+	}
+	sort.Strings(uris)
+	for _, other := range uris {
+		p, _ := s.process(s.docs[other])
+		err = s.publish(other, p)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// publish publishes the diagnostics of the open document at uri: the
+// warnings p found processing it, and its links to anchors missing from
+// the other open documents.
+func (s *lspServer) publish(uri string, p *markproc.Processor) error {
+	lines := s.docs[uri]
+	diagnostics := []lspDiagnostic{}
+	add := func(d lspDiagnostic, n, col int) {
+		if n >= 1 && n <= len(lines) {
+			line := lines[n-1]
+			d.Range = lspRange{
+				Start: lspPosition{n - 1, utf16Offset(line, col)},
+				End:   lspPosition{n - 1, utf16Offset(line, len(line))},
+			}
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	for _, w := range p.Warnings() {
+		d := lspDiagnostic{Severity: lspWarning, Code: w.Rule, Source: "markproc", Message: w.Message}
+		if w.Severity == markproc.SeverityError {
+			d.Severity = lspError
+		}
+		add(d, w.Line, max(w.Column-1, 0))
+	}
+	for _, link := range s.index.Broken(lspPath(uri)) {
+		if link.File == "" {
+			// same-file links were checked by processing
+			continue
+		}
+		target := link.File + "#" + link.Anchor
+		d := lspDiagnostic{
+			Severity: lspError,
+			Code:     "undefined-target",
+			Source:   "markproc",
+			Message:  "Link points to an undefined target: " + target,
+		}
+		// the link is found where it is in the text, which processing
+		// may have moved
+		n, col := 0, 0
+		for i, line := range lines {
+			if c := strings.Index(line, `href="`+target); c >= 0 {
+				n, col = i+1, c
+				break
+			}
+		}
+		add(d, n, col)
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": diagnostics})
+}
+
+// process processes lines, for the warnings and references found and
+// the processed lines.
+func (s *lspServer) process(lines []string) (p *markproc.Processor, out []string) {
+	p = markproc.NewProcessor(s.opts)
+	p.Stderr = io.Discard
+	out, _ = p.Process(lines)
+	return
+}
+
+// lspPath returns the file path of uri, or uri itself if it isn't a
+// file: URI.
+func lspPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// definition returns the location of the section or definition the
+// reference at the position of params refers to, or nil.
+func (s *lspServer) definition(params lspPositionParams) *lspLocation {
+	uri := params.TextDocument.URI
+	lines := s.docs[uri]
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
+		return nil
+	}
+	line := lines[params.Position.Line]
+	col := byteOffset(line, params.Position.Character)
+	ref := ""
+	for _, loc := range lspRefRe.FindAllStringIndex(line, -1) {
+		if loc[0] <= col && col <= loc[1] {
+			ref = line[loc[0]:loc[1]]
+		}
+	}
+	if ref == "" {
+		return nil
+	}
+	at := func(i int) *lspLocation {
+		end := lspPosition{i, utf16Offset(lines[i], len(lines[i]))}
+		return &lspLocation{URI: uri, Range: lspRange{Start: lspPosition{i, 0}, End: end}}
+	}
+
+	if !strings.HasPrefix(ref, "[sec ") && !strings.HasPrefix(ref, "[#") {
+		for i, l := range lines {
+			if strings.HasPrefix(l, ref+":") {
+				return at(i)
+			}
+		}
+		return nil
+	}
+	p, _ := s.process(lines)
+	for _, r := range p.References() {
+		if r.Line != params.Position.Line+1 || r.Text != ref {
+			continue
+		}
+		for _, section := range p.Sections(lines) {
+			if section.Number == r.Target.Number && section.Line >= 1 {
+				return at(section.Line - 1)
+			}
+		}
+	}
+	return nil
+}
+
+// completion returns the sections that may complete the [sec ...]
+// reference being typed at the position of params.
+func (s *lspServer) completion(params lspPositionParams) []lspCompletionItem {
+	items := []lspCompletionItem{}
+	lines := s.docs[params.TextDocument.URI]
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
+		return items
+	}
+	line := lines[params.Position.Line]
+	col := byteOffset(line, params.Position.Character)
+	m := lspSecPrefixRe.FindStringSubmatchIndex(line[:col])
+	if m == nil {
+		return items
+	}
+	typed := lspRange{
+		Start: lspPosition{params.Position.Line, utf16Offset(line, m[2])},
+		End:   params.Position,
+	}
+	p := markproc.NewProcessor(s.opts)
+	for _, c := range p.Completions(lines) {
+		items = append(items, lspCompletionItem{
+			Label:      c.Section.Title,
+			Detail:     c.Section.Number,
+			FilterText: c.Section.Title,
+			TextEdit:   lspTextEdit{Range: typed, NewText: c.Text},
+		})
+	}
+	return items
+}
+
+// utf16Offset returns the offset in UTF-16 code units, which LSP
+// positions count, of the byte offset b of line.
+func utf16Offset(line string, b int) (n int) {
+	for i, r := range line {
+		if i >= b {
+			break
+		}
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return
+}
+
+// byteOffset returns the byte offset of the UTF-16 offset u of line.
+func byteOffset(line string, u int) int {
+	n := 0
+	for i, r := range line {
+		if n >= u {
+			return i
+		}
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return len(line)
+}
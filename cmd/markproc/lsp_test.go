@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+// lspSession runs a Language Server over the messages and returns the
+// messages it sent back and its exit status.
+func lspSession(t *testing.T, messages ...string) (replies []map[string]any, status int) {
+	in := &bytes.Buffer{}
+	for _, m := range messages {
+		fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	out := &bytes.Buffer{}
+	status, err := newLSPServer(markproc.DefaultOptions(), in, out).serve()
+	Tassert(t, err == nil, "serve failed: %v", err)
+	r := bufio.NewReader(out)
+	for {
+		var length int
+		header, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		fmt.Sscanf(header, "Content-Length: %d", &length)
+		r.ReadString('\n')
+		buf := make([]byte, length)
+		_, err = io.ReadFull(r, buf)
+		Ck(err)
+		reply := map[string]any{}
+		Ck(json.Unmarshal(buf, &reply))
+		replies = append(replies, reply)
+	}
+	return
+}
+
+func TestLSP(t *testing.T) {
+	doc := "# Intro\\nSee [sec dsgn] and [sec nowhere].\\nCites [ref].\\n## Design\\n## Goals\\n\\n[ref]: A thing.\\n"
+	replies, status := lspSession(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","text":"`+doc+`"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/definition","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":1,"character":8}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/definition","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":2,"character":8}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///a.md"},"contentChanges":[{"text":"# Intro\nSee [sec go\n## Design\n## Goals\n"}]}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/completion","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":1,"character":11}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	Tassert(t, status == 0, "exit status %d", status)
+	Tassert(t, len(replies) == 7, "have %v", replies)
+
+	// the broken reference is reported where it is
+	diags := replies[1]["params"].(map[string]any)["diagnostics"].([]any)
+	Tassert(t, len(diags) == 1, "have %v", diags)
+	d := diags[0].(map[string]any)
+	Tassert(t, d["code"] == "sec-unresolved", "have %v", d)
+	start := d["range"].(map[string]any)["start"]
+	want := map[string]any{"line": 1.0, "character": 19.0}
+	Tassert(t, reflect.DeepEqual(start, want), "\nwant: %v\nhave: %v", want, start)
+
+	// [sec dsgn] leads to Design, [ref] to its definition
+	for i, line := range []float64{3, 6} {
+		loc := replies[2+i]["result"].(map[string]any)
+		have := loc["range"].(map[string]any)["start"].(map[string]any)["line"]
+		Tassert(t, have == line, "definition %d: want line %v, have %v", i, line, have)
+	}
+
+	// the edit, dropping the broken reference, clears the diagnostics
+	diags = replies[4]["params"].(map[string]any)["diagnostics"].([]any)
+	Tassert(t, len(diags) == 0, "have %v", diags)
+
+	items := replies[5]["result"].([]any)
+	labels := []string{}
+	for _, item := range items {
+		item := item.(map[string]any)
+		edit := item["textEdit"].(map[string]any)
+		labels = append(labels, fmt.Sprintf("%s=%s", item["label"], edit["newText"]))
+	}
+	wantLabels := []string{"Intro=intr", "Design=desig", "Goals=goal"}
+	Tassert(t, reflect.DeepEqual(labels, wantLabels), "\nwant: %q\nhave: %q", wantLabels, labels)
+}
+
+func TestUTF16Offsets(t *testing.T) {
+	line := "é😀x"
+	Tassert(t, utf16Offset(line, len("é😀")) == 3, "have %d", utf16Offset(line, len("é😀")))
+	Tassert(t, byteOffset(line, 3) == len("é😀"), "have %d", byteOffset(line, 3))
+}
+
+func TestLSPCrossFileLinks(t *testing.T) {
+	replies, _ := lspSession(t,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///doc/a.md","text":"# A\nSee <a href=\"b.html#x\">x</a>.\n"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///doc/b.md","text":"# B\n<a name=\"x\"></a>\n"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///doc/b.md"},"contentChanges":[{"text":"# B\n"}]}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///doc/b.md"},"contentChanges":[{"text":"# B\n<a name=\"x\"></a>\n"}]}}`,
+	)
+	type published struct {
+		uri   string
+		codes []string
+	}
+	var have []published
+	for _, reply := range replies {
+		params := reply["params"].(map[string]any)
+		pub := published{uri: params["uri"].(string), codes: []string{}}
+		for _, d := range params["diagnostics"].([]any) {
+			pub.codes = append(pub.codes, d.(map[string]any)["code"].(string))
+		}
+		have = append(have, pub)
+	}
+	// a.md's link is broken until b.md has its anchor, and each change
+	// to b.md republishes a.md's diagnostics
+	want := []published{
+		{"file:///doc/a.md", []string{"undefined-target"}},
+		{"file:///doc/b.md", []string{}},
+		{"file:///doc/a.md", []string{}},
+		{"file:///doc/b.md", []string{}},
+		{"file:///doc/a.md", []string{"undefined-target"}},
+		{"file:///doc/b.md", []string{}},
+		{"file:///doc/a.md", []string{}},
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %v\nhave: %v", want, have)
+}
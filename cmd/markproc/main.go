@@ -0,0 +1,605 @@
+// Command markproc preprocesses a Markdown document, numbering its
+// sections and linking its references.  See the markproc package for
+// details.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+var exitCode = 0
+
+// stdout receives the processed documents; -check discards them.
+var stdout io.Writer = os.Stdout
+
+// writeDoc writes a processed document in the output format, with the
+// line endings and byte order mark of style; -html replaces it.
+var writeDoc = func(w io.Writer, lines []string, style markproc.TextStyle) error {
+	return style.WriteLines(w, lines)
+}
+
+// lineEnding is the -line-ending style of the documents written.
+var lineEnding = markproc.LineEndingAuto
+
+// docExt, if set, is the extension -w gives the files it writes in
+// place of the source file's own, leaving the source alone.
+var docExt string
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "stats":
+			os.Exit(cmdStats(os.Args[2:]))
+		case "build":
+			os.Exit(cmdBuild(os.Args[2:]))
+		case "outline":
+			os.Exit(cmdOutline(os.Args[2:]))
+		case "strip":
+			os.Exit(cmdStrip(os.Args[2:]))
+		case "owners":
+			os.Exit(cmdOwners(os.Args[2:]))
+		case "query":
+			os.Exit(cmdQuery(os.Args[2:]))
+		case "stream":
+			os.Exit(cmdStream(os.Args[2:]))
+		case "serve-api":
+			os.Exit(cmdServeAPI(os.Args[2:]))
+		case "mdbook":
+			os.Exit(cmdMdbook(os.Args[2:]))
+		case "lsp":
+			os.Exit(cmdLSP(os.Args[2:]))
+		case "refactor":
+			os.Exit(cmdRefactor(os.Args[2:]))
+		case "diff":
+			os.Exit(cmdDiff(os.Args[2:]))
+		}
+	}
+
+	inPlace := flag.Bool("w", false, "write result to the source files instead of stdout")
+	backup := flag.Bool("bak", false, "with -w, keep each original file as FILE.bak")
+	summary := flag.Bool("report", false, "print a summary of the run to stderr")
+	timings := flag.Bool("timings", false, "print how long each pass took for each file to stderr")
+	check := flag.Bool("check", false, "run all passes and verification but only print diagnostics, exiting nonzero on problems")
+	refLog := flag.Bool("ref-log", false, "keep the heading each [sec ...] reference resolves to in FILE.refs.json and warn when it is reworded")
+	anchorMap := flag.Bool("anchor-map", false, "keep each section's anchor in FILE.anchors.json, so anchors survive renumbering, and add aliases for old section numbers")
+	format := flag.String("format", "text", "format of -check diagnostics: text or json; json also collects warnings without -check")
+	requireOutline := flag.String("require-outline", "", "fail verification unless each document has the sections listed in the template FILE")
+	matcher := flag.String("matcher", "insertion", "how [sec ...] references are resolved: insertion, levenshtein, token-set, exact, prefix, substring or words")
+	maxEdits := flag.Int("max-edits", -1, "with -matcher=insertion, also allow N substituted characters; with -matcher=levenshtein, the largest edit distance matched")
+	abbrevsFile := flag.String("abbreviations", "", "link the first use in each section of the abbreviations in the JSON FILE to the section or URL defining them")
+	rulesFile := flag.String("rules", "", "check each document against the custom rules in the JSON FILE")
+	freeze := flag.String("freeze", "", "warn about sections numbered differently than in FILE, the previous release's outline as written by the outline subcommand")
+	watchMode := flag.Bool("watch", false, "keep running, reprocessing the files or directories given into -o DIR whenever they change")
+	outDir := flag.String("o", "", "with -watch or -split-level, directory to write processed files to")
+	splitLevel := flag.Int("split-level", 0, "write each section at level N or above as a separate page in -o DIR, with an index page, rewriting links between them")
+	pollInterval := flag.Duration("poll", 500*time.Millisecond, "with -watch, how often to check the files for changes")
+	flag.StringVar(&lineEnding, "line-ending", lineEnding, "line endings of the output: auto, those of the input, lf or crlf")
+	htmlOut := flag.Bool("html", false, "write each document as a standalone HTML page; with -w, to FILE.html beside the source")
+	cssFile := flag.String("css", "", "with -html, style the pages with the CSS in FILE")
+	interactiveMode := flag.Bool("interactive", false, "ask which section each ambiguous [sec ...] reference means, and offer to rewrite it in the source file")
+	reportFile := flag.String("report-file", "", "write a summary of the run to FILE as JSON, or as HTML if FILE ends in .html")
+	opts := markproc.DefaultOptions()
+	flag.StringVar(&opts.ProjectLinkExt, "link-ext", opts.ProjectLinkExt, "with -split-level, extension used in links between pages")
+	flag.BoolVar(&opts.Sanitize, "sanitize", false, "escape raw HTML and strip script elements from the input")
+	flag.BoolVar(&opts.ConvertAnchors, "convert-anchors", false, "rewrite <a id> and heading {#id} anchors into <a name> anchors")
+	flag.BoolVar(&opts.FrontMatterConfig, "front-matter-config", false, "let settings under a markproc key in a document's front matter override flags")
+	flag.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	flag.StringVar(&opts.LinkStyle, "link-style", "", "write links and anchors as html <a> tags, the default, markdown [text](#anchor) links and {#anchor} attributes, or latex \\hyperref and \\label")
+	flag.StringVar(&opts.HeadingAnchors, "heading-anchors", "", "where heading anchors go: line, an <a name> line before the heading, the default, attribute, a {#anchor} at its end, or html, an <h2 id=anchor> heading")
+	latex := flag.Bool("latex", false, "same as -link-style=latex")
+	flag.StringVar(&opts.AnchorPrefix, "anchor-prefix", "sec", "prefix of section anchor names, e.g. s or section-")
+	flag.StringVar(&opts.AnchorSeparator, "anchor-separator", "_", "separator between the parts of numbers in anchor names, e.g. - or .")
+	flag.BoolVar(&opts.AnchorSlug, "anchor-slug", false, "append the slugged heading title to section anchor names")
+	flag.StringVar(&opts.AnchorFormat, "anchor-format", "sec{number}", "anchor name template for -anchor-style=custom; {number} and {slug} are replaced")
+	flag.StringVar(&opts.SecRefFormat, "sec-ref-format", opts.SecRefFormat, "text of [sec ...] links, e.g. \"Section {number}\" or \"§ {number}\"; {number} and {title} are replaced")
+	flag.Var(refTemplates{&opts}, "ref-template", "link text template, KIND=TEMPLATE or TEMPLATE for sec, e.g. 'Section {number} ({title})' or 'eq=Equation ({number})'; repeat for each kind")
+	flag.StringVar(&opts.CiteStyle, "cite-style", "", "render [REF] citations as numeric [1] or author-year (Author, 2024), and a [bibliography] line as the formatted references")
+	flag.BoolVar(&opts.HideDefinitions, "hide-definitions", false, "with -cite-style, leave out the raw [REF]: definitions when a [bibliography] list shows them")
+	flag.StringVar(&opts.BibPrefix, "bib-prefix", "", "only [ref]: definitions whose ref starts with PREFIX are references to anchor and link; the rest are markdown link definitions")
+	flag.StringVar(&opts.Requirements, "requirements", "", "anchor requirements defined by lines starting with [PREFIX-042], link other [PREFIX-042] tags to them and fill in a [requirements] traceability table, e.g. -requirements REQ")
+	flag.StringVar(&opts.DocVersion, "doc-version", "", "document version stamped in place of each <!-- markproc: version --> directive; git takes it, and -doc-date, from git describe and the last commit")
+	flag.StringVar(&opts.DocDate, "doc-date", "", "document date stamped with -doc-version, e.g. 2026-10-16")
+	flag.BoolVar(&opts.VersionFooter, "version-footer", false, "also stamp the version and date in a footer at the end of each document, or of each -split-level page")
+	flag.BoolVar(&opts.Blockquotes, "blockquotes", false, "link references in blockquotes too; by default quoted text is left alone")
+	flag.StringVar(&opts.Setext, "setext", opts.Setext, "number setext headings (Title over ===== or -----) and keep their style or write them as ATX headings: keep or atx; empty leaves them alone")
+	flag.StringVar(&opts.Footnotes, "footnotes", "", "renumber [^1] footnotes in order of use and move their definitions to the end of the document or of each section: end or section")
+	flag.BoolVar(&opts.MkFigures, "figures", false, "number figures and tables captioned with Figure: and Table: lines and fill in [lof] and [lot] lists")
+	flag.BoolVar(&opts.CitedIn, "cited-in", false, "append to each [REF]: definition back-links to the sections citing it, e.g. \"Cited in §2.1, §4.3.\"")
+	flag.BoolVar(&opts.LinkAcronyms, "link-acronyms", false, "link each use of an [acro X]: acronym after the first to its definition")
+	flag.BoolVar(&opts.AcronymList, "acronym-list", false, "gather the [acro X]: definitions into a sorted List of Acronyms")
+	flag.BoolVar(&opts.AbbrTitles, "abbr-titles", false, "with -abbreviations, wrap later uses in a section in <abbr> elements giving the expansion")
+	flag.BoolVar(&opts.ExternDisplay, "extern-display", false, "show [REF] links with the text of a display: field in the definition, e.g. [rfc2119]: ... display: \"RFC 2119\"")
+	flag.StringVar(&opts.SelfRefText, "self-ref-text", "", "text, e.g. \"this section\", replacing a [sec ...] reference to the section it appears in")
+	flag.BoolVar(&opts.RefDirection, "ref-direction", false, "add \"above\" or \"below\" to [sec ...] references to earlier or later sections")
+	flag.StringVar(&opts.AppendixAfter, "appendix-after", "", "title of the last heading before the appendices, which are lettered A, B, ...")
+	flag.StringVar(&opts.MultipleH1, "multiple-h1", opts.MultipleH1, "what to do with more than one H1: allow, warn, error, or demote the later ones")
+	flag.IntVar(&opts.ShiftHeadings, "shift-headings", 0, "move every heading N levels down before numbering, or up if N is negative")
+	flag.BoolVar(&opts.FixHeadingGaps, "fix-heading-gaps", false, "raise headings that skip a level to one below the heading before them")
+	flag.BoolVar(&opts.FixHeadingGaps, "fix-levels", false, "same as -fix-heading-gaps")
+	flag.BoolVar(&opts.RequireOwners, "require-owners", false, "fail verification unless every top-level section has an <!-- owner: ... --> line")
+	flag.BoolVar(&opts.StrictExpiry, "strict-expiry", false, "fail verification if the date of a section's <!-- expires: YYYY-MM-DD --> line has passed")
+	flag.BoolVar(&opts.CheckURLs, "check-urls", false, "request each http and https URL linked to and warn about dead or redirected links")
+	flag.StringVar(&opts.URLPolicy, "url-policy", markproc.URLWarn, "with -check-urls, warn or error on dead links")
+	urlJobs := flag.Int("url-jobs", 8, "with -check-urls, number of URLs to request at once")
+	flag.BoolVar(&opts.A11y, "a11y", false, "check accessibility: one H1, descriptive link text and image alt text")
+	flag.BoolVar(&opts.WarnOrphans, "warn-orphans", false, "warn about [REF]: definitions nothing cites and anchors nothing links to")
+	flag.StringVar(&opts.StartNumber, "start-number", "", "number of the first section, e.g. 4 to follow a chapter numbered 1 to 3, or 2.3")
+	flag.StringVar(&opts.NumberFormat, "number-format", "", "style of section numbers at each level, e.g. I.A.1.a: 1 for numbers, I and i for roman numerals, A and a for letters")
+	flag.StringVar(&opts.NumberSuffix, "number-suffix", ".", "text after section numbers in headings: . for \"1. Title\", .0 for \"1.0 Title\" or none for \"1 Title\"")
+	numberState := flag.String("number-state", "", "carry on numbering from where it stopped in FILE, if it exists, and save where it stops in FILE; with several files, each carries on from the one before")
+	flag.IntVar(&opts.TOCDepth, "toc-depth", opts.TOCDepth, "deepest heading level listed in a table of contents (0 for all)")
+	flag.StringVar(&opts.FreezeNumbers, "freeze-policy", markproc.FreezeWarn, "with -freeze, warn or error on renumbered sections")
+	flag.Int64Var(&opts.Limits.MaxInputSize, "max-size", opts.Limits.MaxInputSize, "maximum input size in bytes (0 for no limit)")
+	flag.IntVar(&opts.Limits.MaxIncludeDepth, "max-include-depth", opts.Limits.MaxIncludeDepth, "maximum nesting depth of included files (0 for no limit)")
+	flag.DurationVar(&opts.Limits.NetworkTimeout, "net-timeout", opts.Limits.NetworkTimeout, "timeout for each network request (0 for no limit)")
+	includeRoot := flag.String("include-root", ".", "directory include directives may read files from; empty to leave them alone")
+	profileName := flag.String("profile", "", "apply named bundles of settings and rules, e.g. ietf, github or book, and keep the <!-- if: NAME --> content for each, e.g. internal,draft; other flags override them")
+	profilesFile := flag.String("profiles", "", "add the profiles in the JSON FILE to those -profile can select")
+	flag.Parse()
+	profile, err := applyProfile(flag.CommandLine, os.Args[1:], &opts, *profileName, *profilesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if profile.Output == "html" && !flagGiven(flag.CommandLine, "html") {
+		*htmlOut = true
+	}
+
+	if !oneOf(opts.AnchorStyle, markproc.AnchorStyles) {
+		fmt.Fprintf(os.Stderr, "unknown anchor style %q\n", opts.AnchorStyle)
+		os.Exit(2)
+	}
+	if *latex {
+		opts.LinkStyle = markproc.LinkLaTeX
+	}
+	if !oneOf(opts.LinkStyle, markproc.LinkStyles) {
+		fmt.Fprintf(os.Stderr, "unknown link style %q\n", opts.LinkStyle)
+		os.Exit(2)
+	}
+	if !oneOf(lineEnding, markproc.LineEndings) {
+		fmt.Fprintf(os.Stderr, "unknown line ending %q\n", lineEnding)
+		os.Exit(2)
+	}
+	if !oneOf(opts.HeadingAnchors, markproc.HeadingAnchorForms) {
+		fmt.Fprintf(os.Stderr, "unknown heading anchor form %q\n", opts.HeadingAnchors)
+		os.Exit(2)
+	}
+	if !oneOf(opts.Footnotes, markproc.FootnotePlacements) {
+		fmt.Fprintf(os.Stderr, "unknown footnote placement %q\n", opts.Footnotes)
+		os.Exit(2)
+	}
+	if !oneOf(opts.Setext, markproc.SetextStyles) {
+		fmt.Fprintf(os.Stderr, "unknown setext style %q\n", opts.Setext)
+		os.Exit(2)
+	}
+	if !oneOf(opts.CiteStyle, markproc.CiteStyles) {
+		fmt.Fprintf(os.Stderr, "unknown citation style %q\n", opts.CiteStyle)
+		os.Exit(2)
+	}
+	if !oneOf(opts.FreezeNumbers, []string{markproc.FreezeWarn, markproc.FreezeError}) {
+		fmt.Fprintf(os.Stderr, "unknown freeze policy %q\n", opts.FreezeNumbers)
+		os.Exit(2)
+	}
+	if !oneOf(opts.URLPolicy, []string{markproc.URLWarn, markproc.URLError}) {
+		fmt.Fprintf(os.Stderr, "unknown URL policy %q\n", opts.URLPolicy)
+		os.Exit(2)
+	}
+	if opts.DocVersion == "git" {
+		dir := "."
+		if flag.NArg() > 0 {
+			dir = filepath.Dir(flag.Arg(0))
+		}
+		version, date, err := gitVersion(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		opts.DocVersion = version
+		if opts.DocDate == "" {
+			opts.DocDate = date
+		}
+	}
+	if opts.CheckURLs {
+		opts.URLChecker = markproc.NewURLChecker(opts.Limits.NetworkTimeout, *urlJobs)
+	}
+	if *includeRoot != "" {
+		opts.IncludeFS = os.DirFS(*includeRoot)
+	}
+	err = opts.Set("matcher", *matcher)
+	if err == nil && *maxEdits >= 0 {
+		err = opts.Set("max-edits", strconv.Itoa(*maxEdits))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	err = opts.Set("start-number", opts.StartNumber)
+	if err == nil {
+		err = opts.Set("number-format", opts.NumberFormat)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if *numberState != "" {
+		opts.StartState, err = markproc.ReadNumberState(*numberState)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *numberState, err)
+			os.Exit(2)
+		}
+	}
+	if *rulesFile != "" {
+		f, err := os.Open(*rulesFile)
+		if err == nil {
+			var rules []markproc.Rule
+			rules, err = markproc.ReadRules(f)
+			opts.Rules = append(opts.Rules, rules...)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *rulesFile, err)
+			os.Exit(2)
+		}
+	}
+	if *abbrevsFile != "" {
+		f, err := os.Open(*abbrevsFile)
+		if err == nil {
+			opts.Abbreviations, err = markproc.ReadAbbreviations(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *abbrevsFile, err)
+			os.Exit(2)
+		}
+	}
+	if *htmlOut {
+		css := ""
+		if *cssFile != "" {
+			buf, err := os.ReadFile(*cssFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(2)
+			}
+			css = string(buf)
+		}
+		writeDoc = func(w io.Writer, lines []string, _ markproc.TextStyle) error {
+			return markproc.RenderHTML(w, lines, "", css)
+		}
+		docExt = ".html"
+	}
+	if *freeze != "" {
+		f, err := os.Open(*freeze)
+		if err == nil {
+			opts.FrozenOutline, err = markproc.ReadOutline(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
+	if !oneOf(opts.MultipleH1, markproc.H1Policies) {
+		fmt.Fprintf(os.Stderr, "unknown H1 policy %q\n", opts.MultipleH1)
+		os.Exit(2)
+	}
+
+	if *requireOutline != "" {
+		f, err := os.Open(*requireOutline)
+		if err == nil {
+			opts.RequiredOutline, err = markproc.ReadOutlineTemplate(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	var ia *interactive
+	if *interactiveMode {
+		ia, err = newInteractive(flag.NArg() == 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		ia.path = "<stdin>"
+		opts.Choose = ia.choose
+	}
+
+	p := markproc.NewProcessor(opts)
+	diags := &diagnostics{w: os.Stderr}
+	switch *format {
+	case "text":
+	case "json":
+		diags.json = true
+		p.Stderr = io.Discard
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+	if *check {
+		if *inPlace {
+			fmt.Fprintf(os.Stderr, "-check and -w are mutually exclusive\n")
+			os.Exit(2)
+		}
+		stdout = io.Discard
+		p.Stderr = io.Discard
+		diags.w = os.Stdout
+	}
+	collect := *check || diags.json
+
+	if *watchMode {
+		if *outDir == "" || flag.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "usage: markproc -watch -o DIR files or directories...\n")
+			os.Exit(2)
+		}
+		err := watch(p, flag.Args(), *outDir, *pollInterval, os.Stderr)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if *splitLevel > 0 {
+		if *outDir == "" || flag.NArg() > 1 {
+			fmt.Fprintf(os.Stderr, "usage: markproc -split-level N -o DIR [file]\n")
+			os.Exit(2)
+		}
+		if flag.NArg() == 1 {
+			p.IncludePath = includePath(*includeRoot, flag.Arg(0))
+		}
+		err := split(p, flag.Arg(0), *splitLevel, *outDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			exitCode = 1
+		}
+		finish(diags, *check)
+	}
+
+	if flag.NArg() == 0 {
+		if *inPlace || *refLog || *anchorMap {
+			fmt.Fprintf(os.Stderr, "-w, -ref-log and -anchor-map require file arguments\n")
+			os.Exit(2)
+		}
+		lines, style, err := markproc.ReadText(os.Stdin, opts.Limits.MaxInputSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+		lines = process(p, lines)
+		if *timings {
+			printTimings("<stdin>", p.Timings())
+		}
+		err = writeDoc(stdout, lines, style.WithLineEnding(lineEnding))
+		Ck(err)
+		if collect {
+			diags.add("<stdin>", p.Warnings())
+		}
+		if !*check {
+			saveNumberState(p, *numberState)
+		}
+		finish(diags, *check)
+	}
+
+	paths, err := expandArgs(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	report := newBatchReport()
+	for _, path := range paths {
+		start := time.Now()
+		if *refLog {
+			p.RefLog, err = markproc.ReadRefLog(path + ".refs.json")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				exitCode = 1
+				continue
+			}
+		}
+		if *anchorMap {
+			p.AnchorMap, err = markproc.ReadAnchorMap(path + ".anchors.json")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				exitCode = 1
+				continue
+			}
+		}
+		p.IncludePath = includePath(*includeRoot, path)
+		if ia != nil {
+			ia.path = path
+		}
+		sections, err := processFile(p, path, *inPlace, *backup)
+		// with -w the processed file has the chosen links already
+		if ia != nil && !*check && (!*inPlace || docExt != "") {
+			if rerr := ia.rewrite(path, p.Choices()); rerr != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, rerr)
+				exitCode = 1
+			}
+		}
+		if err == nil && *refLog && !*check {
+			err = p.RefLog.Write(path + ".refs.json")
+		}
+		if err == nil && *anchorMap && !*check {
+			err = p.AnchorMap.Write(path + ".anchors.json")
+		}
+		fr := fileReport{Path: path, Sections: sections, Duration: time.Since(start)}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			fr.Error = err.Error()
+			exitCode = 1
+		}
+		if *numberState != "" && p.NumberState() != nil {
+			p.StartState = p.NumberState()
+		}
+		report.add(fr, p.Warnings())
+		if collect {
+			diags.add(path, p.Warnings())
+		}
+		if *timings {
+			printTimings(path, p.Timings())
+		}
+	}
+	if !*check {
+		saveNumberState(p, *numberState)
+	}
+	if *summary {
+		report.writeText(os.Stderr)
+	}
+	if *reportFile != "" {
+		err = report.writeFile(*reportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			exitCode = 1
+		}
+	}
+	finish(diags, *check)
+}
+
+// saveNumberState writes where p's numbering stopped to the file at
+// path, unless path is empty.
+func saveNumberState(p *markproc.Processor, path string) {
+	state := p.NumberState()
+	if path == "" || state == nil {
+		return
+	}
+	err := state.Write(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		exitCode = 1
+	}
+}
+
+// finish writes any collected diagnostics and exits.  With -check any
+// diagnostic at all makes the exit status nonzero.
+func finish(diags *diagnostics, check bool) {
+	err := diags.flush()
+	Ck(err)
+	if check && len(diags.all) > 0 {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+// refTemplates is a flag.Value setting link text templates with
+// Options.SetRefTemplate; the flag can be given once per kind.
+type refTemplates struct {
+	opts *markproc.Options
+}
+
+func (r refTemplates) String() string { return "" }
+
+func (r refTemplates) Set(spec string) error { return r.opts.SetRefTemplate(spec) }
+
+// oneOf reports whether value is one of choices.
+func oneOf(value string, choices []string) bool {
+	for _, choice := range choices {
+		if choice == value {
+			return true
+		}
+	}
+	return false
+}
+
+// process runs p over lines, reporting any error on stderr.
+func process(p *markproc.Processor, lines []string) []string {
+	lines, err := p.Process(lines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		exitCode = 1
+	}
+	return lines
+}
+
+// printTimings writes the per-pass timings for one file to stderr.
+func printTimings(path string, timings []markproc.PassTiming) {
+	total := time.Duration(0)
+	for _, pt := range timings {
+		fmt.Fprintf(os.Stderr, "%s: %-12s %v\n", path, pt.Pass, pt.Duration)
+		total += pt.Duration
+	}
+	fmt.Fprintf(os.Stderr, "%s: %-12s %v\n", path, "total", total)
+}
+
+// includePath returns the path of the file at path relative to the
+// include root, which include directives in it are resolved from.
+func includePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// processFile processes the file at path, writing the result to stdout
+// or, if inPlace is set, back to path, or beside it with the extension
+// docExt if that is set.  With backup set the original content is first
+// saved to path + ".bak".  It returns the number of
+// sections in the file.
+func processFile(p *markproc.Processor, path string, inPlace, backup bool) (sections int, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if max := p.Limits.MaxInputSize; max > 0 && info.Size() > max {
+		err = fmt.Errorf("file size %d exceeds maximum of %d bytes", info.Size(), max)
+		return
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines, err := markproc.ReadLines(bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+	style := markproc.DetectTextStyle(buf).WithLineEnding(lineEnding)
+	sections = len(p.Outline(lines))
+	lines = process(p, lines)
+	if !inPlace {
+		err = writeDoc(stdout, lines, style)
+		return
+	}
+
+	target := path
+	if docExt != "" {
+		target = strings.TrimSuffix(path, filepath.Ext(path)) + docExt
+	} else if backup {
+		err = os.WriteFile(path+".bak", buf, info.Mode().Perm())
+		if err != nil {
+			return
+		}
+	}
+
+	// write to a temp file in the same directory and rename it over
+	// the original so a failed write never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	err = writeDoc(tmp, lines, style)
+	if err != nil {
+		tmp.Close()
+		return
+	}
+	err = tmp.Close()
+	if err != nil {
+		return
+	}
+	err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), target)
+	return
+}
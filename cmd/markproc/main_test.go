@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+func TestProcessFileInPlace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	input := "# Title\n\nSee [ref1].\n\n[ref1]: A reference.\n"
+	err := os.WriteFile(path, []byte(input), 0640)
+	Ck(err)
+
+	p := markproc.NewProcessor(markproc.DefaultOptions())
+	p.Stderr = io.Discard
+	sections, err := processFile(p, path, true, true)
+	Tassert(t, err == nil, "processFile failed: %v", err)
+	Tassert(t, sections == 1, "want 1 section, have %d", sections)
+
+	bak, err := os.ReadFile(path + ".bak")
+	Tassert(t, err == nil, "backup not written: %v", err)
+	Tassert(t, string(bak) == input, "backup content changed: %q", bak)
+
+	out, err := os.ReadFile(path)
+	Ck(err)
+	Tassert(t, strings.Contains(string(out), "# 1. Title"), "file not rewritten: %q", out)
+
+	info, err := os.Stat(path)
+	Ck(err)
+	Tassert(t, info.Mode().Perm() == 0640, "mode not preserved: %v", info.Mode())
+}
+
+func TestRefactorCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	Ck(os.WriteFile(path, []byte("\ufeff# Design\r\nSee [sec dsgn].\r\n"), 0644))
+	status := cmdRefactor([]string{"-rename", "Design=Goals", path})
+	Tassert(t, status == 0, "exit status %d", status)
+	out, err := os.ReadFile(path)
+	Ck(err)
+	want := "\ufeff# Goals\r\nSee [sec goal].\r\n"
+	Tassert(t, string(out) == want, "\nwant: %q\nhave: %q", want, out)
+}
+
+func TestInteractiveRewriteCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	Ck(os.WriteFile(path, []byte("# Top\r\nSee [sec d].\r\n"), 0644))
+	ia := &interactive{in: bufio.NewReader(strings.NewReader("y\n")), out: io.Discard}
+	err := ia.rewrite(path, []markproc.Choice{{Line: 2, Ref: "d", Rewrite: "dsgn"}})
+	Tassert(t, err == nil, "rewrite failed: %v", err)
+	out, err := os.ReadFile(path)
+	Ck(err)
+	want := "# Top\r\nSee [sec dsgn].\r\n"
+	Tassert(t, string(out) == want, "\nwant: %q\nhave: %q", want, out)
+}
+
+func TestStripFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	Ck(os.WriteFile(path, []byte("<a name=\"sec1\"></a>\n# 1. Title\n"), 0644))
+	outPath := filepath.Join(dir, "out.md")
+	out, err := os.Create(outPath)
+	Ck(err)
+	stdout := os.Stdout
+	os.Stdout = out
+	status := cmdStrip([]string{path})
+	os.Stdout = stdout
+	Ck(out.Close())
+	Tassert(t, status == 0, "exit status %d", status)
+	buf, err := os.ReadFile(outPath)
+	Ck(err)
+	Tassert(t, string(buf) == "# Title\n", "have %q", buf)
+
+	status = cmdStrip([]string{path, path})
+	Tassert(t, status == 2, "exit status %d", status)
+}
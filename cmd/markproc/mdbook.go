@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+// mdbookKeys are the keys of a [preprocessor.markproc] table that are
+// mdBook's own rather than markproc settings.
+var mdbookKeys = map[string]bool{
+	"command":   true,
+	"renderers": true,
+	"before":    true,
+	"after":     true,
+	"optional":  true,
+}
+
+// cmdMdbook implements `markproc mdbook`, the mdBook preprocessor
+// protocol: `markproc mdbook supports RENDERER` succeeds for any
+// renderer, and `markproc mdbook` reads the [context, book] JSON mdBook
+// writes to stdin and writes the book to stdout with its chapters
+// processed as the files of one project, so that sections are numbered
+// and referenced consistently across chapters.  Settings come from the
+// [preprocessor.markproc] table of book.toml, e.g. toc-depth = 2.
+func cmdMdbook(args []string) int {
+	if len(args) > 0 && args[0] == "supports" {
+		return 0
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "usage: markproc mdbook [supports RENDERER]\n")
+		return 2
+	}
+	err := mdbook(os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "markproc: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// mdbook reads mdBook's [context, book] JSON from in, processes the
+// book's chapters and writes the book to out.  Fields markproc doesn't
+// know are passed through.  The book is written even if processing
+// fails, with the error returned after.
+func mdbook(in io.Reader, out io.Writer) (err error) {
+	dec := json.NewDecoder(in)
+	dec.UseNumber()
+	var input []any
+	err = dec.Decode(&input)
+	if err != nil {
+		return fmt.Errorf("reading mdBook input: %w", err)
+	}
+	if len(input) != 2 {
+		return fmt.Errorf("reading mdBook input: want [context, book], have %d elements", len(input))
+	}
+	context, _ := input[0].(map[string]any)
+	book, ok := input[1].(map[string]any)
+	if !ok {
+		return fmt.Errorf("reading mdBook input: the book is not an object")
+	}
+
+	opts, err := mdbookOptions(context)
+	if err != nil {
+		return
+	}
+
+	chapters := []map[string]any{}
+	for _, key := range []string{"sections", "items"} {
+		items, _ := book[key].([]any)
+		mdbookChapters(items, &chapters)
+	}
+	docs := make([]markproc.Document, len(chapters))
+	for i, ch := range chapters {
+		path, _ := ch["path"].(string)
+		content, _ := ch["content"].(string)
+		lines, err := markproc.ReadLines(strings.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		docs[i] = markproc.Document{Path: filepath.ToSlash(path), Lines: lines}
+	}
+
+	processed, err := markproc.NewProcessor(opts).ProcessProject(docs)
+	for i, doc := range processed {
+		b := &strings.Builder{}
+		if werr := markproc.WriteLines(b, doc.Lines); werr != nil {
+			return werr
+		}
+		chapters[i]["content"] = b.String()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	if werr := enc.Encode(book); werr != nil {
+		return werr
+	}
+	return
+}
+
+// mdbookChapters appends the chapters in items, and their sub-chapters,
+// to chapters in book order.  Draft chapters, which have no path, are
+// left out.
+func mdbookChapters(items []any, chapters *[]map[string]any) {
+	for _, item := range items {
+		m, _ := item.(map[string]any)
+		ch, ok := m["Chapter"].(map[string]any)
+		if !ok {
+			continue
+		}
+		if path, _ := ch["path"].(string); path != "" {
+			*chapters = append(*chapters, ch)
+		}
+		subItems, _ := ch["sub_items"].([]any)
+		mdbookChapters(subItems, chapters)
+	}
+}
+
+// mdbookOptions returns the options set by the [preprocessor.markproc]
+// table of book.toml in context, with includes read from the book's
+// source directory.
+func mdbookOptions(context map[string]any) (opts markproc.Options, err error) {
+	opts = markproc.DefaultOptions()
+	config, _ := context["config"].(map[string]any)
+	root, _ := context["root"].(string)
+	src := "src"
+	if b, ok := config["book"].(map[string]any); ok {
+		if s, ok := b["src"].(string); ok {
+			src = s
+		}
+	}
+	opts.IncludeFS = os.DirFS(filepath.Join(root, src))
+
+	preprocessors, _ := config["preprocessor"].(map[string]any)
+	settings, _ := preprocessors["markproc"].(map[string]any)
+	keys := []string{}
+	for key := range settings {
+		if !mdbookKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		err = opts.Set(key, fmt.Sprint(settings[key]))
+		if err != nil {
+			return opts, fmt.Errorf("book.toml [preprocessor.markproc]: %w", err)
+		}
+	}
+	return
+}
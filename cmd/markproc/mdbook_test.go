@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestMdbook(t *testing.T) {
+	input := `[
+		{"root": "/book", "renderer": "html", "mdbook_version": "0.4.36",
+		 "config": {"book": {"src": "src"}, "preprocessor": {"markproc": {"command": "markproc mdbook", "toc": false}}}},
+		{"sections": [
+			{"Chapter": {"name": "Intro", "content": "# Intro\nSee [sec dsgn].\n", "number": [1], "path": "intro.md",
+			 "sub_items": [{"Chapter": {"name": "Design", "content": "# Design\n", "number": [1, 1], "path": "intro/design.md", "sub_items": []}}]}},
+			"Separator",
+			{"Chapter": {"name": "Draft", "content": "", "path": null, "sub_items": []}}
+		], "__non_exhaustive": null}
+	]`
+	out := &bytes.Buffer{}
+	err := mdbook(strings.NewReader(input), out)
+	Tassert(t, err == nil, "mdbook failed: %v", err)
+
+	var book struct {
+		Sections []json.RawMessage `json:"sections"`
+	}
+	err = json.Unmarshal(out.Bytes(), &book)
+	Ck(err)
+	Tassert(t, len(book.Sections) == 3, "have %s", out)
+	Tassert(t, string(book.Sections[1]) == `"Separator"`, "have %s", out)
+
+	var intro struct {
+		Chapter struct {
+			Content  string
+			Number   []int `json:"number"`
+			SubItems []struct {
+				Chapter struct{ Content string }
+			} `json:"sub_items"`
+		}
+	}
+	err = json.Unmarshal(book.Sections[0], &intro)
+	Ck(err)
+	want := "<a name=\"sec1\"></a>\n# 1. Intro\nSee [<a href=\"intro/design.html#sec2\">sec 2</a>].\n"
+	Tassert(t, intro.Chapter.Content == want, "\nwant: %q\nhave: %q", want, intro.Chapter.Content)
+	Tassert(t, len(intro.Chapter.Number) == 1, "have %s", out)
+	want = "<a name=\"sec2\"></a>\n# 2. Design\n"
+	have := intro.Chapter.SubItems[0].Chapter.Content
+	Tassert(t, have == want, "\nwant: %q\nhave: %q", want, have)
+}
+
+func TestMdbookBadSetting(t *testing.T) {
+	input := `[{"config": {"preprocessor": {"markproc": {"no-such-setting": 1}}}}, {"sections": []}]`
+	err := mdbook(strings.NewReader(input), &bytes.Buffer{})
+	Tassert(t, err != nil && strings.Contains(err.Error(), "no-such-setting"), "have %v", err)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdOutline implements `markproc outline [FILE]`, reading markdown from
+// FILE or stdin, processed or not, and writing its numbered sections to
+// stdout as JSON or YAML, with the lines each spans and the references
+// into it, e.g. to keep for -freeze, or as an OPML outline or mind map
+// of the heading tree.
+func cmdOutline(args []string) int {
+	fs := flag.NewFlagSet("outline", flag.ExitOnError)
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AppendixAfter, "appendix-after", "", "title of the last heading before the appendices")
+	fs.StringVar(&opts.MultipleH1, "multiple-h1", opts.MultipleH1, "what to do with more than one H1: allow, warn, error or demote")
+	format := fs.String("format", "json", "output format: json, yaml, opml, or mm for a Freeplane mind map")
+	title := fs.String("title", "", "with -format opml or mm, title of the outline; default the first heading")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: markproc outline [flags] [FILE]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	if !oneOf(*format, []string{"json", "yaml", "opml", "mm"}) {
+		fmt.Fprintf(os.Stderr, "unknown outline format %q\n", *format)
+		return 2
+	}
+
+	in, name := io.Reader(os.Stdin), "stdin"
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in, name = f, fs.Arg(0)
+	}
+	lines, err := markproc.ReadLines(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+		return 1
+	}
+
+	p := markproc.NewProcessor(opts)
+	switch *format {
+	case "opml":
+		err = markproc.WriteOPML(os.Stdout, p.Sections(lines), *title)
+	case "mm":
+		err = markproc.WriteMindmap(os.Stdout, p.Sections(lines), *title)
+	case "yaml":
+		err = markproc.WriteStructureYAML(os.Stdout, p.Structure(lines))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if *format != "json" {
+		return 0
+	}
+	buf, err := json.MarshalIndent(p.Structure(lines), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(buf))
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdOwners implements `markproc owners`, reading markdown from stdin
+// and writing who owns each numbered section to stdout, one section per
+// line or as JSON.  It exits nonzero if a top-level section has no
+// owner.
+func cmdOwners(args []string) int {
+	fs := flag.NewFlagSet("owners", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AppendixAfter, "appendix-after", "", "title of the last heading before the appendices")
+	fs.Parse(args)
+
+	lines, err := markproc.ReadLines(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+		return 1
+	}
+
+	owners := markproc.NewProcessor(opts).Owners(lines)
+	if *asJSON {
+		buf, err := json.MarshalIndent(owners, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(buf))
+	} else {
+		writeOwners(os.Stdout, owners)
+	}
+
+	status := 0
+	for _, sec := range owners {
+		if len(sec.Owners) == 0 && !strings.Contains(sec.Number, ".") {
+			fmt.Fprintf(os.Stderr, "Section %s %s has no owner\n", sec.Number, sec.Title)
+			status = 1
+		}
+	}
+	return status
+}
+
+// writeOwners writes the ownership report in the style of a CODEOWNERS
+// file: each section's anchor, then its owners.
+func writeOwners(w io.Writer, owners []markproc.SectionOwners) {
+	for _, sec := range owners {
+		names := strings.Join(sec.Owners, " ")
+		if names == "" {
+			names = "(none)"
+		}
+		fmt.Fprintf(w, "#%s %s # %s %s\n", sec.Anchor, names, sec.Number, sec.Title)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+// applyProfile adds the profiles in the JSON file, if not empty, to
+// markproc.Profiles, then selects the comma-separated profile names in
+// opts and parses args into fs again, so that the flags given override
+// the profiles.  fs must already have parsed args into opts.  The
+// profile returned has the output of the last one setting it.
+func applyProfile(fs *flag.FlagSet, args []string, opts *markproc.Options, names, file string) (profile markproc.Profile, err error) {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return profile, err
+		}
+		profiles, err := markproc.ReadProfiles(f)
+		f.Close()
+		if err != nil {
+			return profile, fmt.Errorf("%s: %w", file, err)
+		}
+		for n, p := range profiles {
+			markproc.Profiles[n] = p
+		}
+	}
+	if names == "" {
+		return
+	}
+	err = opts.SelectProfiles(names)
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Split(names, ",") {
+		if p, ok := markproc.Profiles[strings.TrimSpace(name)]; ok && p.Output != "" {
+			profile.Output = p.Output
+		}
+	}
+	err = fs.Parse(args)
+	return
+}
+
+// flagGiven reports whether the flag name was given to fs.
+func flagGiven(fs *flag.FlagSet, name string) (given bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return
+}
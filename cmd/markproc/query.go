@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// queryMatch is a section found by the query subcommand.
+type queryMatch struct {
+	File string `json:"file,omitempty"`
+	markproc.Section
+}
+
+// cmdQuery implements `markproc query EXPR [FILE...]`, writing the
+// sections of the documents in the FILEs, or stdin, that EXPR selects
+// to stdout, one per line as LINE, number, anchor and title separated
+// by tabs, LINE prefixed with FILE: when FILEs are given.  The
+// documents may be processed or not.  Like grep, it exits 1 if no
+// section matched.
+func cmdQuery(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the matching sections as JSON")
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AppendixAfter, "appendix-after", "", "title of the last heading before the appendices")
+	fs.StringVar(&opts.MultipleH1, "multiple-h1", opts.MultipleH1, "what to do with more than one H1: allow, warn, error or demote")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: markproc query [flags] EXPR [FILE...]\n\nEXPR is e.g. 'heading ~ \"overtone\" and level <= 2' or '$[?(@.number == \"2.3\")]'\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	q, err := markproc.ParseQuery(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	p := markproc.NewProcessor(opts)
+	matches := []queryMatch{}
+	query := func(file string, lines []string) {
+		for _, sec := range q.Select(p.Sections(lines)) {
+			matches = append(matches, queryMatch{File: file, Section: sec})
+		}
+	}
+	if fs.NArg() == 1 {
+		lines, err := markproc.ReadLines(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			return 1
+		}
+		query("", lines)
+	}
+	for _, file := range fs.Args()[1:] {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		lines, err := markproc.ReadLines(bytes.NewReader(buf))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		query(file, lines)
+	}
+
+	if *asJSON {
+		buf, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(buf))
+	} else {
+		for _, m := range matches {
+			if m.File != "" {
+				fmt.Printf("%s:", m.File)
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\n", m.Line, m.Number, m.Anchor, m.Title)
+		}
+	}
+	if len(matches) == 0 {
+		return 1
+	}
+	return 0
+}
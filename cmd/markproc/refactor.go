@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/stevegt/markproc"
+)
+
+// renames collects -rename OLD=NEW flags.
+type renames [][2]string
+
+func (r *renames) String() string {
+	return ""
+}
+
+func (r *renames) Set(value string) error {
+	old, new, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(old) == "" || strings.TrimSpace(new) == "" {
+		return fmt.Errorf("want \"Old Title=New Title\"")
+	}
+	*r = append(*r, [2]string{strings.TrimSpace(old), strings.TrimSpace(new)})
+	return nil
+}
+
+// cmdRefactor implements `markproc refactor -rename "Old=New" files...`,
+// renaming a heading and rewriting the [sec ...] references to it in
+// the source files, which are rewritten in place.
+func cmdRefactor(args []string) int {
+	fs := flag.NewFlagSet("refactor", flag.ExitOnError)
+	var rename renames
+	fs.Var(&rename, "rename", "rename the heading titled OLD to NEW and update the references to it: \"Old Title=New Title\"; repeat for more")
+	dryRun := fs.Bool("n", false, "print the changes instead of writing them")
+	fs.Parse(args)
+	if len(rename) == 0 || fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: markproc refactor -rename \"Old Title=New Title\" files...\n")
+		return 2
+	}
+
+	paths, err := expandArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	docs := []markproc.Document{}
+	styles := map[string]markproc.TextStyle{}
+	for _, path := range paths {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		lines, err := markproc.ReadLines(bytes.NewReader(buf))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		docs = append(docs, markproc.Document{Path: path, Lines: lines})
+		styles[path] = markproc.DetectTextStyle(buf)
+	}
+
+	p := markproc.NewProcessor(markproc.DefaultOptions())
+	changed := map[string]bool{}
+	for _, r := range rename {
+		var edits []markproc.Edit
+		docs, edits, err = p.RenameHeading(docs, r[0], r[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		for _, e := range edits {
+			changed[e.Path] = true
+			if *dryRun {
+				fmt.Printf("%s:%d:\n-%s\n+%s\n", e.Path, e.Line, e.Old, e.New)
+			}
+		}
+	}
+	if *dryRun {
+		return 0
+	}
+	status := 0
+	for _, doc := range docs {
+		if !changed[doc.Path] {
+			continue
+		}
+		if err := writeLinesTo(doc.Path, doc.Lines, styles[doc.Path]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// writeLinesTo replaces the file at path with lines written in style,
+// the one it was read with, keeping its permissions.
+func writeLinesTo(path string, lines []string, style markproc.TextStyle) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	out := &bytes.Buffer{}
+	err = style.WriteLines(out, lines)
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, out.Bytes(), info.Mode().Perm())
+}
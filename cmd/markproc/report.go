@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stevegt/markproc"
+)
+
+// fileReport summarizes the processing of one file.
+type fileReport struct {
+	Path        string        `json:"path"`
+	Sections    int           `json:"sections"`
+	BrokenLinks int           `json:"broken_links"`
+	Warnings    int           `json:"warnings"`
+	Duration    time.Duration `json:"duration_ns"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// batchReport summarizes a run over many files.
+type batchReport struct {
+	Files          []fileReport   `json:"files"`
+	TotalSections  int            `json:"total_sections"`
+	BrokenLinks    int            `json:"broken_links"`
+	WarningsByRule map[string]int `json:"warnings_by_rule"`
+	Slowest        []fileReport   `json:"slowest"`
+}
+
+// maxSlowest is the number of files listed as slowest in a report.
+const maxSlowest = 5
+
+func newBatchReport() *batchReport {
+	return &batchReport{Files: []fileReport{}, WarningsByRule: map[string]int{}}
+}
+
+// add adds the result of processing one file to the report.
+func (r *batchReport) add(fr fileReport, warnings []markproc.Warning) {
+	for _, w := range warnings {
+		r.WarningsByRule[w.Rule]++
+		switch w.Rule {
+		case "undefined-target", "duplicate-target":
+			fr.BrokenLinks++
+		}
+	}
+	fr.Warnings = len(warnings)
+	r.Files = append(r.Files, fr)
+	r.TotalSections += fr.Sections
+	r.BrokenLinks += fr.BrokenLinks
+
+	r.Slowest = append([]fileReport{}, r.Files...)
+	sort.SliceStable(r.Slowest, func(i, j int) bool {
+		return r.Slowest[i].Duration > r.Slowest[j].Duration
+	})
+	if len(r.Slowest) > maxSlowest {
+		r.Slowest = r.Slowest[:maxSlowest]
+	}
+}
+
+// writeText writes a plain text summary of the report to w.
+func (r *batchReport) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Files processed: %d\n", len(r.Files))
+	fmt.Fprintf(w, "Total sections:  %d\n", r.TotalSections)
+	fmt.Fprintf(w, "Broken links:    %d\n", r.BrokenLinks)
+	for _, fr := range r.Files {
+		if fr.BrokenLinks > 0 {
+			fmt.Fprintf(w, "  %s: %d\n", fr.Path, fr.BrokenLinks)
+		}
+	}
+	if len(r.WarningsByRule) > 0 {
+		fmt.Fprintf(w, "Warnings by rule:\n")
+	}
+	rules := []string{}
+	for rule := range r.WarningsByRule {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Fprintf(w, "  %-20s %d\n", rule, r.WarningsByRule[rule])
+	}
+	fmt.Fprintf(w, "Slowest files:\n")
+	for _, fr := range r.Slowest {
+		fmt.Fprintf(w, "  %-10v %s\n", fr.Duration.Round(time.Microsecond), fr.Path)
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>markproc report</title></head>
+<body>
+<h1>markproc report</h1>
+<p>{{len .Files}} files, {{.TotalSections}} sections, {{.BrokenLinks}} broken links</p>
+<h2>Files</h2>
+<table>
+<tr><th>File</th><th>Sections</th><th>Broken links</th><th>Warnings</th><th>Time</th><th>Error</th></tr>
+{{range .Files}}<tr><td>{{.Path}}</td><td>{{.Sections}}</td><td>{{.BrokenLinks}}</td><td>{{.Warnings}}</td><td>{{.Duration}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{if .WarningsByRule}}<h2>Warnings by rule</h2>
+<table>
+{{range $rule, $n := .WarningsByRule}}<tr><td>{{$rule}}</td><td>{{$n}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Slowest files</h2>
+<ol>
+{{range .Slowest}}<li>{{.Path}} ({{.Duration}})</li>
+{{end}}</ol>
+</body>
+</html>
+`))
+
+// writeFile writes the report to path as HTML if path ends in .html or
+// .htm, and as JSON otherwise.
+func (r *batchReport) writeFile(path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		err = reportTemplate.Execute(f, r)
+	default:
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if err != nil {
+		return
+	}
+	return f.Close()
+}
+
+// expandArgs replaces each directory in args with the Markdown files
+// found beneath it.
+func expandArgs(args []string) (paths []string, err error) {
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil || !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".md", ".markdown":
+				if !d.IsDir() {
+					paths = append(paths, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+func TestBatchReport(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	Ck(err)
+	files := map[string]string{
+		"a.md":      "# A\n### Gap\n",
+		"sub/b.md":  "# B\n## C\nSee [sec nothing].\n",
+		"notes.txt": "# Not markdown\n",
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		Ck(err)
+	}
+
+	paths, err := expandArgs([]string{dir})
+	Ck(err)
+	Tassert(t, len(paths) == 2, "have %v", paths)
+
+	p := markproc.NewProcessor(markproc.DefaultOptions())
+	p.Stderr = io.Discard
+	report := newBatchReport()
+	for _, path := range paths {
+		lines, err := os.ReadFile(path)
+		Ck(err)
+		sections := len(p.Outline(strings.Split(string(lines), "\n")))
+		_, err = p.Process(strings.Split(string(lines), "\n"))
+		fr := fileReport{Path: path, Sections: sections}
+		if err != nil {
+			fr.Error = err.Error()
+		}
+		report.add(fr, p.Warnings())
+	}
+	Tassert(t, report.TotalSections == 4, "have %d", report.TotalSections)
+	Tassert(t, report.WarningsByRule["heading-gap"] == 1, "have %v", report.WarningsByRule)
+	Tassert(t, report.WarningsByRule["sec-unresolved"] == 1, "have %v", report.WarningsByRule)
+
+	for _, name := range []string{"report.json", "report.html"} {
+		err = report.writeFile(filepath.Join(dir, name))
+		Tassert(t, err == nil, "writing %s failed: %v", name, err)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "report.html"))
+	Ck(err)
+	Tassert(t, strings.Contains(string(buf), "heading-gap"), "have %s", buf)
+}
+
+func TestBatchReportNoWarnings(t *testing.T) {
+	report := newBatchReport()
+	report.add(fileReport{Path: "a.md", Sections: 1}, nil)
+	var b bytes.Buffer
+	report.writeText(&b)
+	Tassert(t, !strings.Contains(b.String(), "Warnings by rule"), "have %q", b.String())
+	path := filepath.Join(t.TempDir(), "report.html")
+	Ck(report.writeFile(path))
+	buf, err := os.ReadFile(path)
+	Ck(err)
+	Tassert(t, !strings.Contains(string(buf), "Warnings by rule"), "have %s", buf)
+}
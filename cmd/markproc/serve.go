@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdServeAPI implements `markproc serve-api [-listen addr]`, serving
+// the markproc HTTP API until the server fails.
+func cmdServeAPI(args []string) int {
+	fs := flag.NewFlagSet("serve-api", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	opts := markproc.DefaultOptions()
+	opts.Limits.MaxInputSize = 10 << 20
+	fs.BoolVar(&opts.Sanitize, "sanitize", false, "escape raw HTML and strip script elements from the input")
+	fs.Int64Var(&opts.Limits.MaxInputSize, "max-size", opts.Limits.MaxInputSize, "maximum request body size in bytes (0 for no limit)")
+	fs.DurationVar(&opts.Limits.NetworkTimeout, "net-timeout", opts.Limits.NetworkTimeout, "timeout for reading requests and writing responses (0 for no limit)")
+	fs.Parse(args)
+
+	srv := &http.Server{
+		Addr:         *listen,
+		Handler:      markproc.NewHandler(opts),
+		ReadTimeout:  opts.Limits.NetworkTimeout,
+		WriteTimeout: opts.Limits.NetworkTimeout,
+	}
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *listen)
+	err := srv.ListenAndServe()
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	return 1
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// split processes the document at path, or stdin if path is empty, and
+// writes it into dir as one page for each section at level or above and
+// an index page.
+func split(p *markproc.Processor, path string, level int, dir string) (err error) {
+	in := os.Stdin
+	if path != "" {
+		in, err = os.Open(path)
+		if err != nil {
+			return
+		}
+		defer in.Close()
+	}
+	lines, err := markproc.ReadLimited(in, p.Limits.MaxInputSize)
+	if err != nil {
+		return
+	}
+	for _, page := range p.Split(process(p, lines), level) {
+		err = writeOutput(dir, page)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdStats implements `markproc stats [-json]`, reading markdown from
+// stdin and writing the size of each section, the citation report and
+// the sections due to expire to stdout.
+func cmdStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	fs.Parse(args)
+
+	lines, err := markproc.ReadLines(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+		return 1
+	}
+
+	stats := markproc.Citations(lines)
+	stats.Expiring = markproc.NewProcessor(markproc.DefaultOptions()).Expirations(lines)
+	if *asJSON {
+		buf, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(buf))
+		return 0
+	}
+	stats.WriteText(os.Stdout)
+	return 0
+}
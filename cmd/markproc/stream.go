@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdStream implements `markproc stream [FILE]`, processing one large
+// document from FILE or stdin without holding it in memory and writing
+// the result to stdout.  Input from a pipe is spooled to a temporary
+// file, since it has to be read twice.
+func cmdStream(args []string) int {
+	fs := flag.NewFlagSet("stream", flag.ExitOnError)
+	opts := markproc.StreamOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style: secnum, github or custom")
+	fs.StringVar(&opts.AnchorFormat, "anchor-format", "sec{number}", "anchor name template for -anchor-style=custom; {number} and {slug} are replaced")
+	fs.StringVar(&opts.SecRefFormat, "sec-ref-format", opts.SecRefFormat, "text of [sec ...] links; {number} and {title} are replaced")
+	fs.StringVar(&opts.NumberSuffix, "number-suffix", ".", "text after section numbers in headings: . for \"1. Title\", .0 for \"1.0 Title\" or none for \"1 Title\"")
+	fs.StringVar(&opts.MultipleH1, "multiple-h1", opts.MultipleH1, "what to do with more than one H1: allow, warn, error or demote")
+	fs.BoolVar(&opts.ExternDisplay, "extern-display", false, "show [REF] links with the text of a display: field in the definition")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "usage: markproc stream [FILE]\n")
+		return 2
+	}
+
+	in, err := streamInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer in.Close()
+
+	err = markproc.NewProcessor(opts).ProcessStream(in, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// streamInput opens path, or stdin if path is empty, for reading
+// twice, copying stdin to a temporary file if it can't seek.  The
+// temporary file is removed when the returned file is closed.
+func streamInput(path string) (f io.ReadSeekCloser, err error) {
+	if path != "" {
+		return os.Open(path)
+	}
+	if _, err = os.Stdin.Seek(0, io.SeekCurrent); err == nil {
+		return os.Stdin, nil
+	}
+	tmp, err := os.CreateTemp("", "markproc-*.md")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(tmp, os.Stdin)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &tempFile{tmp}, nil
+}
+
+// tempFile is a temporary file removed when closed.
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stevegt/markproc"
+)
+
+// cmdStrip implements `markproc strip [FILE]`, reading processed
+// markdown from FILE or stdin and writing the source it was made from
+// to stdout.
+func cmdStrip(args []string) int {
+	fs := flag.NewFlagSet("strip", flag.ExitOnError)
+	opts := markproc.DefaultOptions()
+	fs.StringVar(&opts.AnchorStyle, "anchor-style", opts.AnchorStyle, "section anchor style the input was processed with: secnum, github or custom")
+	fs.StringVar(&opts.AnchorPrefix, "anchor-prefix", "sec", "prefix of section anchor names the input was processed with, e.g. s or section-")
+	fs.StringVar(&opts.AnchorSeparator, "anchor-separator", "_", "separator between the parts of numbers in anchor names the input was processed with, e.g. - or .")
+	fs.BoolVar(&opts.AnchorSlug, "anchor-slug", false, "append the slugged heading title to section anchor names the input was processed with")
+	fs.StringVar(&opts.AnchorFormat, "anchor-format", "sec{number}", "anchor name template the input was processed with, for -anchor-style=custom")
+	fs.StringVar(&opts.SecRefFormat, "sec-ref-format", opts.SecRefFormat, "text of [sec ...] links the input was processed with")
+	fs.Var(refTemplates{&opts}, "ref-template", "link text template the input was processed with, KIND=TEMPLATE or TEMPLATE for sec; repeat for each kind")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: markproc strip [flags] [FILE]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	in, name := io.Reader(os.Stdin), "stdin"
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in, name = f, fs.Arg(0)
+	}
+	lines, err := markproc.ReadLines(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+		return 1
+	}
+	err = markproc.WriteLines(os.Stdout, markproc.NewProcessor(opts).Strip(lines))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
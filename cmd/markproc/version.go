@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitVersion returns the version of the git work tree holding dir, as
+// git describe gives it, e.g. v1.2.0-3-gabc1234-dirty, and the date of
+// its last commit.
+func gitVersion(dir string) (version, date string, err error) {
+	out, err := exec.Command("git", "-C", dir, "describe", "--tags", "--always", "--dirty").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git describe in %s: %w", dir, err)
+	}
+	version = strings.TrimSpace(string(out))
+	out, err = exec.Command("git", "-C", dir, "log", "-1", "--format=%cs").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git log in %s: %w", dir, err)
+	}
+	date = strings.TrimSpace(string(out))
+	return
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/stevegt/markproc"
+)
+
+// snapshot maps each watched file to its modification time.
+type snapshot map[string]time.Time
+
+// scan returns the modification times of the markdown files named by
+// args, searching directories as expandArgs does.
+func scan(args []string) (snap snapshot, err error) {
+	paths, err := expandArgs(args)
+	if err != nil {
+		return
+	}
+	snap = snapshot{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			// removed since the directory was read
+			continue
+		}
+		snap[path] = info.ModTime()
+	}
+	return
+}
+
+// changed returns the files in cur that are new or modified since prev,
+// sorted.
+func (cur snapshot) changed(prev snapshot) (paths []string) {
+	for path, mtime := range cur {
+		if old, ok := prev[path]; !ok || !old.Equal(mtime) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return
+}
+
+// watch processes the files named by args as one project into outDir,
+// then polls them every interval, reprocessing the project whenever a
+// file changes so that references between files are resolved again,
+// and printing diagnostics to w.  It only returns on error.
+func watch(p *markproc.Processor, args []string, outDir string, interval time.Duration, w io.Writer) (err error) {
+	wa := newWatcher(p, outDir, w)
+	prev := snapshot{}
+	for {
+		cur, err := scan(args)
+		if err != nil {
+			return err
+		}
+		changed := cur.changed(prev)
+		if len(changed) > 0 || len(cur) != len(prev) {
+			err = wa.run(cur.paths(), changed)
+			if err != nil {
+				fmt.Fprintf(w, "%v\n", err)
+			}
+		}
+		prev = cur
+		time.Sleep(interval)
+	}
+}
+
+// paths returns the files in snap, sorted.
+func (snap snapshot) paths() (paths []string) {
+	for path := range snap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return
+}
+
+// watcher holds what watch keeps from one run to the next.
+type watcher struct {
+	p      *markproc.Processor
+	outDir string
+	w      io.Writer
+	// warnings holds the warnings the last run found in each file.
+	warnings map[string][]markproc.Warning
+	// paths holds the files the last run processed.
+	paths []string
+}
+
+// newWatcher returns a watcher processing with p into outDir.  p keeps
+// an index of the project across runs, so that each run re-indexes
+// only the files whose output changed.
+func newWatcher(p *markproc.Processor, outDir string, w io.Writer) *watcher {
+	p.Stderr = io.Discard
+	p.Index = markproc.NewIndex()
+	return &watcher{p: p, outDir: outDir, w: w, warnings: map[string][]markproc.Warning{}}
+}
+
+// run processes the files at paths as one project and writes the
+// results under wa.outDir, removing those of files the last run
+// processed that are gone.  The warnings found replace those of the
+// last run; they are printed for the files changed and for those
+// whose warnings changed, with "ok" for the ones left without any.
+func (wa *watcher) run(paths, changed []string) (err error) {
+	docs := []markproc.Document{}
+	for _, path := range paths {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		lines, err := markproc.ReadLines(bytes.NewReader(buf))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		docs = append(docs, markproc.Document{Path: path, Lines: lines})
+	}
+	out, err := wa.p.ProcessProject(docs)
+	for _, doc := range out {
+		werr := writeOutput(wa.outDir, doc)
+		if err == nil {
+			err = werr
+		}
+	}
+	current := map[string]bool{}
+	for _, path := range paths {
+		current[path] = true
+	}
+	for _, path := range wa.paths {
+		if current[path] {
+			continue
+		}
+		rerr := os.Remove(outputPath(wa.outDir, path))
+		if err == nil && rerr != nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+	}
+	wa.paths = paths
+
+	warnings := map[string][]markproc.Warning{}
+	for _, warning := range wa.p.Warnings() {
+		warnings[warning.File] = append(warnings[warning.File], warning)
+	}
+	isChanged := map[string]bool{}
+	for _, path := range changed {
+		isChanged[path] = true
+	}
+	diags := &diagnostics{w: wa.w}
+	for _, path := range paths {
+		if !isChanged[path] && reflect.DeepEqual(warnings[path], wa.warnings[path]) {
+			continue
+		}
+		diags.add(path, warnings[path])
+		if len(warnings[path]) == 0 {
+			fmt.Fprintf(wa.w, "%s: ok\n", path)
+		}
+	}
+	wa.warnings = warnings
+	return
+}
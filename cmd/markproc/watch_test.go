@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/stevegt/goadapt"
+	"github.com/stevegt/markproc"
+)
+
+func TestSnapshotChanged(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	Ck(os.WriteFile(a, []byte("# A\n"), 0644))
+	Ck(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x\n"), 0644))
+
+	first, err := scan([]string{dir})
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(first.changed(snapshot{}), []string{a}), "have %v", first.changed(snapshot{}))
+
+	Ck(os.WriteFile(b, []byte("# B\n"), 0644))
+	later := time.Now().Add(time.Second)
+	Ck(os.Chtimes(a, later, later))
+	second, err := scan([]string{dir})
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(second.changed(first), []string{a, b}), "have %v", second.changed(first))
+	Tassert(t, len(second.changed(second)) == 0, "unchanged files reported")
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	Ck(os.WriteFile(a, []byte("# A\nSee [sec dsgn].\n"), 0644))
+	Ck(os.WriteFile(b, []byte("# Design\n"), 0644))
+	out := t.TempDir()
+
+	w := &bytes.Buffer{}
+	wa := newWatcher(markproc.NewProcessor(markproc.DefaultOptions()), out, w)
+	err := wa.run([]string{a, b}, []string{a, b})
+	Tassert(t, err == nil, "run failed: %v", err)
+	want := a + ": ok\n" + b + ": ok\n"
+	Tassert(t, w.String() == want, "\nwant: %q\nhave: %q", want, w.String())
+	buf, err := os.ReadFile(filepath.Join(out, "a.md"))
+	Tassert(t, err == nil, "output not written: %v", err)
+	Tassert(t, strings.Contains(string(buf), `<a href="b.html#sec2">sec 2</a>`), "have %q", buf)
+
+	// renaming the section in b.md breaks the reference in a.md, which
+	// hasn't changed, and with it the link to b.md
+	w.Reset()
+	Ck(os.WriteFile(b, []byte("# Other\n"), 0644))
+	err = wa.run([]string{a, b}, []string{b})
+	Tassert(t, err != nil, "unresolved reference not reported")
+	Tassert(t, strings.Contains(w.String(), a+":2:"), "have %q", w.String())
+	Tassert(t, strings.Contains(w.String(), b+": warning: unreachable-doc"), "have %q", w.String())
+	Tassert(t, len(wa.warnings[a]) == 1, "have %v", wa.warnings[a])
+
+	// the warnings are replaced, not added to, on each run
+	w.Reset()
+	Ck(os.WriteFile(b, []byte("# Design\n"), 0644))
+	err = wa.run([]string{a, b}, []string{b})
+	Tassert(t, err == nil, "run failed: %v", err)
+	want = a + ": ok\n" + b + ": ok\n"
+	Tassert(t, w.String() == want, "\nwant: %q\nhave: %q", want, w.String())
+	Tassert(t, len(wa.warnings) == 0, "have %v", wa.warnings)
+
+	w.Reset()
+	err = wa.run([]string{a, b}, nil)
+	Tassert(t, err == nil, "run failed: %v", err)
+	Tassert(t, w.Len() == 0, "unchanged files reported: %q", w.String())
+
+	// a removed file's output goes with it
+	Ck(os.WriteFile(a, []byte("# A\n"), 0644))
+	Ck(os.Remove(b))
+	err = wa.run([]string{a}, []string{a})
+	Tassert(t, err == nil, "run failed: %v", err)
+	_, err = os.Stat(filepath.Join(out, "b.md"))
+	Tassert(t, os.IsNotExist(err), "output of removed file left: %v", err)
+}
@@ -0,0 +1,177 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	fenceRegexp    = regexp.MustCompile("^ {0,3}(```+|~~~+)(.*)$")
+	listItemRegexp = regexp.MustCompile(`^ {0,3}([-+*]|\d+[.)])\s`)
+)
+
+// codeScanner tracks whether successive lines of a document are inside
+// a fenced (``` or ~~~) or indented code block, a region between
+// <!-- markproc: off --> and <!-- markproc: on --> directives, or a
+// blockquote or link definitions bracketed by passQuotes or
+// passLinkDefs.
+type codeScanner struct {
+	// fence is the opening fence while inside a fenced block.
+	fence string
+	// indented is set while inside an indented block.
+	indented bool
+	// prevBlank is set if the previous line was blank.
+	prevBlank bool
+	// inList is set after a list item, where indented lines continue
+	// the item rather than start a code block.
+	inList bool
+	// off is set between an off directive and the next on directive.
+	off bool
+	// verbatim is the directive ending the blockquote or link
+	// definitions passQuotes or passLinkDefs bracketed, while inside
+	// them.
+	verbatim string
+}
+
+// verbatimEnds maps the directives passQuotes and passLinkDefs start a
+// bracketed region with to those ending it.
+var verbatimEnds = map[string]string{
+	"quote":     "end-quote",
+	"link-defs": "end-link-defs",
+}
+
+// inCode reports whether line, the next line of the document, is part
+// of a code block.  Fence lines count as part of the block they open
+// or close, and directive lines as part of the region they open or
+// close.
+func (s *codeScanner) inCode(line string) (code bool) {
+	blank := strings.TrimSpace(line) == ""
+	defer func() { s.prevBlank = blank }()
+
+	if s.off {
+		s.off = !isDirective(line, "on")
+		return true
+	}
+	if s.verbatim != "" {
+		if isDirective(line, s.verbatim) {
+			s.verbatim = ""
+		}
+		return true
+	}
+
+	if s.fence != "" {
+		m := fenceRegexp.FindStringSubmatch(line)
+		if m != nil && m[1][0] == s.fence[0] && len(m[1]) >= len(s.fence) && strings.TrimSpace(m[2]) == "" {
+			s.fence = ""
+		}
+		return true
+	}
+	if m := fenceRegexp.FindStringSubmatch(line); m != nil {
+		// a backtick fence's info string can't contain backticks
+		if m[1][0] != '`' || !strings.Contains(m[2], "`") {
+			s.fence = m[1]
+			s.indented = false
+			return true
+		}
+	}
+
+	indent := strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+	if s.indented {
+		if blank || indent {
+			return true
+		}
+		s.indented = false
+	}
+	if indent && !blank && s.prevBlank && !s.inList {
+		s.indented = true
+		return true
+	}
+
+	if isDirective(line, "off") {
+		s.off = true
+		return true
+	}
+	if name, _, ok := parseDirective(line); ok && verbatimEnds[name] != "" {
+		s.verbatim = verbatimEnds[name]
+		return true
+	}
+	if !blank && !indent {
+		s.inList = listItemRegexp.MatchString(line)
+	}
+	return false
+}
+
+// codeMask returns, for each line, whether it is part of a code block
+// or of the front matter.  Passes leave these lines alone.
+func codeMask(lines []string) []bool {
+	s := &codeScanner{prevBlank: true}
+	mask := make([]bool, len(lines))
+	fm := frontMatterEnd(lines)
+	for i, line := range lines {
+		if i < fm {
+			mask[i] = true
+			continue
+		}
+		mask[i] = s.inCode(line)
+	}
+	return mask
+}
+
+// codeSpans returns the start and end of each inline code span of line:
+// a run of backticks, not escaped, up to the next run of the same
+// length.  A run with no closing run is literal text.
+func codeSpans(line string) (spans [][2]int) {
+	for i := 0; i < len(line); {
+		if line[i] == '\\' {
+			i += 2
+			continue
+		}
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(line, i)
+		end := -1
+		for j := i + n; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			m := backtickRun(line, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, [2]int{i, end})
+		i = end
+	}
+	return
+}
+
+// backtickRun returns the number of backticks starting at line[i].
+func backtickRun(line string, i int) (n int) {
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+	return
+}
+
+// outsideCode returns line with f applied to each part of it that isn't
+// an inline code span.
+func outsideCode(line string, f func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range codeSpans(line) {
+		b.WriteString(f(line[last:span[0]]))
+		b.WriteString(line[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(f(line[last:]))
+	return b.String()
+}
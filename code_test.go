@@ -0,0 +1,117 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodeMask(t *testing.T) {
+	lines := []string{
+		"# Heading",
+		"```sh",
+		"# comment",
+		"x=[foo]",
+		"```",
+		"text [ref]",
+		"~~~~",
+		"```",
+		"~~~",
+		"still code",
+		"~~~~",
+		"",
+		"    indented code",
+		"",
+		"    more code",
+		"text",
+		"- list item",
+		"",
+		"    list continuation",
+		"  ```",
+		"unclosed",
+	}
+	want := []bool{
+		false,
+		true, true, true, true,
+		false,
+		true, true, true, true, true,
+		false,
+		true, true, true,
+		false,
+		false, false, false,
+		true, true,
+	}
+	have := codeMask(lines)
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nwant: %v\nhave: %v", want, have)
+	}
+}
+
+func TestProcessSkipsCode(t *testing.T) {
+	lines := []string{
+		"# Title",
+		"```sh",
+		"# not a heading",
+		"arr=[foo] and [sec ttl]",
+		"```",
+		"See [ref1].",
+		"~~~",
+		"[ref2]: not a definition",
+		"~~~",
+		"[ref1]: A reference.",
+	}
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Title",
+		"```sh",
+		"# not a heading",
+		"arr=[foo] and [sec ttl]",
+		"```",
+		`See [<a href="#ref1">ref1</a>].`,
+		"~~~",
+		"[ref2]: not a definition",
+		"~~~",
+		`<a name="ref1"></a>`,
+		"[ref1]: A reference.",
+	}
+	p := NewProcessor(DefaultOptions())
+	have, err := p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nwant: %q\nhave: %q", want, have)
+	}
+}
+
+func TestCodeSpans(t *testing.T) {
+	cases := map[string][][2]int{
+		"a `b` c":          {{2, 5}},
+		"``a ` b`` `c`":    {{0, 9}, {10, 13}},
+		"no `closing":      nil,
+		"\\`not` code `x`": {{5, 13}},
+		"```a`` b```":      {{0, 11}},
+	}
+	for line, want := range cases {
+		have := codeSpans(line)
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%q: want %v, have %v", line, want, have)
+		}
+	}
+}
+
+func TestProcessSkipsCodeSpans(t *testing.T) {
+	lines := []string{
+		"# Title",
+		"Use `array[ref1]`, ``[sec ttl] `x` `` or [ref1].",
+		"[ref1]: A reference.",
+	}
+	want := "Use `array[ref1]`, ``[sec ttl] `x` `` or [<a href=\"#ref1\">ref1</a>]."
+	p := NewProcessor(DefaultOptions())
+	have, err := p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if have[2] != want {
+		t.Errorf("\nwant: %q\nhave: %q", want, have[2])
+	}
+}
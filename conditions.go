@@ -0,0 +1,95 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ifRe matches the <!-- if: NAME --> line starting a conditional
+	// block: the group is the list of names.
+	ifRe = regexp.MustCompile(`^\s*<!--\s*if:\s*(.*?)\s*-->\s*$`)
+	// elseRe and endifRe match the lines dividing and ending one.
+	elseRe  = regexp.MustCompile(`^\s*<!--\s*else\s*-->\s*$`)
+	endifRe = regexp.MustCompile(`^\s*<!--\s*endif\s*-->\s*$`)
+)
+
+// condition reports whether names, the list of a <!-- if: ... -->
+// line, holds for p's Conditions: whether any of its names, separated
+// by commas or spaces, is one of them, or, written !name, isn't.
+func (p *Processor) condition(names string) bool {
+	for _, name := range strings.FieldsFunc(names, func(r rune) bool { return r == ',' || r == ' ' }) {
+		want := !strings.HasPrefix(name, "!")
+		name = strings.TrimPrefix(name, "!")
+		found := false
+		for _, c := range p.Conditions {
+			found = found || strings.EqualFold(c, name)
+		}
+		if found == want {
+			return true
+		}
+	}
+	return false
+}
+
+// passConditions keeps the content of each conditional block,
+// <!-- if: internal --> ... <!-- endif -->, whose condition holds for
+// p's Conditions, e.g. those -profile internal,draft selects, and
+// removes the others along with the directive lines, so that sections
+// are numbered as the variant of the document has them.  A block may
+// have an <!-- else --> part, kept when the condition doesn't hold,
+// and may be nested in another.  The variant is what the output holds:
+// Strip can't bring excluded content back.
+func (p *Processor) passConditions(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	type block struct {
+		// line is the index of the <!-- if: --> line
+		line int
+		// outer is whether the enclosing content is kept, and holds
+		// whether the condition does
+		outer, holds, inElse bool
+	}
+	blocks := []block{}
+	keep := func() bool {
+		if len(blocks) == 0 {
+			return true
+		}
+		b := blocks[len(blocks)-1]
+		return b.outer && b.holds != b.inElse
+	}
+	for i, line := range lines {
+		if code[i] {
+			if keep() {
+				out.add(i, line)
+			}
+			continue
+		}
+		if m := ifRe.FindStringSubmatch(line); m != nil {
+			blocks = append(blocks, block{line: i, outer: keep(), holds: p.condition(m[1])})
+			continue
+		}
+		if elseRe.MatchString(line) || endifRe.MatchString(line) {
+			if len(blocks) == 0 {
+				p.warnf("if-unbalanced", i, strings.TrimSpace(line), "%s without <!-- if: ... -->", strings.TrimSpace(line))
+				p.fail(fmt.Sprintf("unbalanced %s", strings.TrimSpace(line)))
+				continue
+			}
+			if endifRe.MatchString(line) {
+				blocks = blocks[:len(blocks)-1]
+			} else {
+				blocks[len(blocks)-1].inElse = true
+			}
+			continue
+		}
+		if keep() {
+			out.add(i, line)
+		}
+	}
+	for _, b := range blocks {
+		p.warnf("if-unbalanced", b.line, strings.TrimSpace(lines[b.line]), "%s without <!-- endif -->", strings.TrimSpace(lines[b.line]))
+		p.fail("unbalanced <!-- if: ... -->")
+	}
+	return p.done(out)
+}
@@ -0,0 +1,64 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestConditions(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"<!-- if: internal -->",
+		"# Staffing",
+		"<!-- if: !draft -->",
+		"Final numbers.",
+		"<!-- else -->",
+		"Draft numbers.",
+		"<!-- endif -->",
+		"<!-- else -->",
+		"Public text.",
+		"<!-- endif -->",
+		"# Design",
+		"See [sec dsgn].",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	Ck(opts.SelectProfiles("internal, draft"))
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`<a name="sec2"></a>`,
+		"# 2. Staffing",
+		"Draft numbers.",
+		`<a name="sec3"></a>`,
+		"# 3. Design",
+		`See [<a href="#sec3">sec 3</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(want, out), "\nwant: %q\nhave: %q", want, out)
+
+	// the public variant is numbered without the internal section
+	p = NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want = []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"Public text.",
+		`<a name="sec2"></a>`,
+		"# 2. Design",
+		`See [<a href="#sec2">sec 2</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(want, out), "\nwant: %q\nhave: %q", want, out)
+
+	_, err = p.Process([]string{"<!-- if: internal -->", "# Intro"})
+	Tassert(t, err != nil, "unclosed block not reported")
+	Tassert(t, p.Warnings()[0].Rule == "if-unbalanced" && p.Warnings()[0].Line == 1, "have %v", p.Warnings())
+}
@@ -0,0 +1,153 @@
+package markproc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+// TestCorpus processes each testdata/corpus/NAME-in.md, a sample of
+// real-world markdown, and checks that the result matches NAME-out.md,
+// that processing it again changes nothing, and that the only changes
+// are the ones markproc means to make: stripping the result gives back
+// the input, up to how [sec ...] references are abbreviated.
+func TestCorpus(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/corpus/*-in.md")
+	Ck(err)
+	Tassert(t, len(inputs) > 0, "no corpus files")
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			lines := readCorpusFile(t, input)
+			want := readCorpusFile(t, strings.TrimSuffix(input, "-in.md")+"-out.md")
+
+			p := NewProcessor(DefaultOptions())
+			p.Stderr = io.Discard
+			out, err := p.Process(lines)
+			Tassert(t, err == nil, "Process failed: %v", err)
+			checkCorpusLines(t, "output", want, out)
+
+			again, err := p.Process(out)
+			Tassert(t, err == nil, "reprocessing failed: %v", err)
+			checkCorpusLines(t, "reprocessed output", out, again)
+
+			checkCorpusLines(t, "stripped output", normalizeRefs(lines), normalizeRefs(p.Strip(out)))
+		})
+	}
+}
+
+// TestCorpusLarge checks that a large document, mixing the constructs
+// of the corpus, is processed the same way again and stripped back to
+// its source.  BenchmarkCorpusLarge times it.
+func TestCorpusLarge(t *testing.T) {
+	checkLargeCorpus(t, largeCorpusDoc(500))
+}
+
+// TestCorpusHuge is TestCorpusLarge for a document of nearly two
+// megabytes.
+func TestCorpusHuge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("huge document")
+	}
+	checkLargeCorpus(t, largeCorpusDoc(5000))
+}
+
+func BenchmarkCorpusLarge(b *testing.B) {
+	lines := largeCorpusDoc(500)
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := p.Process(lines)
+		Ck(err)
+	}
+}
+
+func checkLargeCorpus(t *testing.T, lines []string) {
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "reprocessing failed: %v", err)
+	checkCorpusLines(t, "reprocessed output", out, again)
+	checkCorpusLines(t, "stripped output", normalizeRefs(lines), normalizeRefs(p.Strip(out)))
+}
+
+// largeCorpusDoc returns a document of the given number of chapters,
+// each holding a table, a task list, CJK text, an HTML block, a code
+// block and a footnote.
+func largeCorpusDoc(chapters int) []string {
+	lines := []string{"[toc]"}
+	for i := 0; i < chapters; i++ {
+		lines = append(lines,
+			fmt.Sprintf("# Chapter %d", i),
+			fmt.Sprintf("## Section %d", i),
+			"Text with `code`, *emphasis* and a [link](http://example.com).",
+			"",
+			"| Name | Value |",
+			"|:-----|------:|",
+			fmt.Sprintf("| row  | %d |", i),
+			"",
+			"- [x] done",
+			"- [ ] not done",
+			"",
+			fmt.Sprintf("### 第%d章 概要", i),
+			"日本語のテキストと中文文本。",
+			"",
+			"<div class=\"note\">",
+			"  <p>An HTML block.</p>",
+			"</div>",
+			"",
+			"```go",
+			"# not a heading",
+			"```",
+			"",
+			fmt.Sprintf("A footnote.[^n%d]", i),
+			"",
+			fmt.Sprintf("[^n%d]: The note.", i),
+			"",
+		)
+	}
+	return lines
+}
+
+func readCorpusFile(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	Ck(err)
+	defer f.Close()
+	lines, err := ReadLines(f)
+	Ck(err)
+	return lines
+}
+
+// normalizeRefs returns lines with the acronym of each [sec ...]
+// reference dropped and table of contents markers made alike.
+func normalizeRefs(lines []string) (out []string) {
+	for _, line := range lines {
+		if isTOCMarker(line) {
+			line = tocStart
+		}
+		out = append(out, sectionRefRegexp.ReplaceAllString(line, "[sec]"))
+	}
+	return
+}
+
+// checkCorpusLines reports the first line where have differs from want.
+func checkCorpusLines(t *testing.T, what string, want, have []string) {
+	t.Helper()
+	if reflect.DeepEqual(want, have) {
+		return
+	}
+	for i := 0; i < len(want) && i < len(have); i++ {
+		if want[i] != have[i] {
+			t.Fatalf("%s differs at line %d:\nwant: %q\nhave: %q", what, i+1, want[i], have[i])
+		}
+	}
+	t.Fatalf("%s has %d lines, want %d", what, len(have), len(want))
+}
@@ -0,0 +1,105 @@
+package markproc
+
+import (
+	"io"
+	"strings"
+)
+
+// SectionShift is a section whose number differs between two versions
+// of a document.  OldNumber is empty for a section added in the new
+// version and NewNumber for one removed from it.
+type SectionShift struct {
+	Title     string `json:"title"`
+	OldNumber string `json:"old_number"`
+	NewNumber string `json:"new_number"`
+}
+
+// RefShift is a [sec ...] reference of the new version of a document
+// that resolves to a different section than the same reference did in
+// the old version.
+type RefShift struct {
+	// Line is the 1-based line of the reference in the new version,
+	// stripped if it has been processed.
+	Line      int    `json:"line"`
+	Text      string `json:"text"`
+	OldNumber string `json:"old_number"`
+	OldTitle  string `json:"old_title"`
+	NewNumber string `json:"new_number"`
+	NewTitle  string `json:"new_title"`
+}
+
+// DocDiff is what changed in the structure of a document from one
+// version to the next.
+type DocDiff struct {
+	Sections []SectionShift `json:"sections"`
+	Refs     []RefShift     `json:"refs"`
+}
+
+// Diff compares two versions of a document, processed or not, and
+// returns the sections whose numbers shifted, in the order of the new
+// version followed by those removed, and the references whose target
+// changed.  Sections are matched by title, repeated titles in order.
+// References are matched by their text; those of a processed version
+// are stripped to abbreviations of their targets' titles, so a
+// reference by number is only compared between source versions.
+func (p *Processor) Diff(old, new []string) (d DocDiff) {
+	d = DocDiff{Sections: []SectionShift{}, Refs: []RefShift{}}
+
+	// numbers holds the old numbers of each title not yet matched
+	numbers := map[string][]string{}
+	oldSections := p.Sections(old)
+	for _, sec := range oldSections {
+		key := strings.ToLower(sec.Title)
+		numbers[key] = append(numbers[key], sec.Number)
+	}
+	for _, sec := range p.Sections(new) {
+		key := strings.ToLower(sec.Title)
+		shift := SectionShift{Title: sec.Title, NewNumber: sec.Number}
+		if olds := numbers[key]; len(olds) > 0 {
+			shift.OldNumber, numbers[key] = olds[0], olds[1:]
+		}
+		if shift.OldNumber != shift.NewNumber {
+			d.Sections = append(d.Sections, shift)
+		}
+	}
+	for _, sec := range oldSections {
+		key := strings.ToLower(sec.Title)
+		if olds := numbers[key]; len(olds) > 0 && olds[0] == sec.Number {
+			numbers[key] = olds[1:]
+			d.Sections = append(d.Sections, SectionShift{Title: sec.Title, OldNumber: sec.Number})
+		}
+	}
+
+	before := map[string]Target{}
+	for _, ref := range p.diffRefs(old) {
+		if _, ok := before[ref.Text]; !ok {
+			before[ref.Text] = ref.Target
+		}
+	}
+	for _, ref := range p.diffRefs(new) {
+		was, ok := before[ref.Text]
+		if !ok || strings.EqualFold(was.Heading, ref.Target.Heading) {
+			continue
+		}
+		d.Refs = append(d.Refs, RefShift{
+			Line:      ref.Line,
+			Text:      ref.Text,
+			OldNumber: was.Number,
+			OldTitle:  was.Heading,
+			NewNumber: ref.Target.Number,
+			NewTitle:  ref.Target.Heading,
+		})
+	}
+	return
+}
+
+// diffRefs returns the [sec ...] references of lines, a version of a
+// document, stripped first if it has been processed.
+func (p *Processor) diffRefs(lines []string) []Reference {
+	opts := p.Options
+	opts.Verify, opts.CheckURLs = false, false
+	q := NewProcessor(opts)
+	q.Stderr = io.Discard
+	q.Process(p.Strip(lines))
+	return q.References()
+}
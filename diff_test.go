@@ -0,0 +1,53 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestDiff(t *testing.T) {
+	old := []string{
+		"# Intro",
+		"# Design",
+		"## Goals",
+		"# Legacy",
+		"See [sec desig] and [sec 3].",
+	}
+	new := []string{
+		"# Intro",
+		"# Background",
+		"# Design",
+		"## Goals",
+		"See [sec desig] and [sec 3].",
+	}
+	want := DocDiff{
+		Sections: []SectionShift{
+			{Title: "Background", NewNumber: "2"},
+			{Title: "Design", OldNumber: "2", NewNumber: "3"},
+			{Title: "Goals", OldNumber: "2.1", NewNumber: "3.1"},
+			{Title: "Legacy", OldNumber: "3"},
+		},
+		Refs: []RefShift{
+			{Line: 5, Text: "[sec 3]", OldNumber: "3", OldTitle: "Legacy", NewNumber: "3", NewTitle: "Design"},
+		},
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	have := p.Diff(old, new)
+	Tassert(t, reflect.DeepEqual(want, have), "\nwant: %+v\nhave: %+v", want, have)
+
+	// a published, processed version's sections compare the same; its
+	// links strip to [sec legac], not [sec 3]
+	published, err := p.Process(old)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have = p.Diff(published, new)
+	Tassert(t, reflect.DeepEqual(want.Sections, have.Sections), "\nwant: %+v\nhave: %+v", want.Sections, have.Sections)
+	Tassert(t, len(have.Refs) == 0, "have %+v", have.Refs)
+
+	have = p.Diff(new, new)
+	Tassert(t, len(have.Sections) == 0 && len(have.Refs) == 0, "have %+v", have)
+}
@@ -0,0 +1,58 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// directiveRe matches a <!-- markproc: NAME ARGS --> directive line.
+// Directives give markproc instructions without a syntax of their own
+// for each feature:
+//
+//   - off and on bracket a region every pass leaves alone, like a code
+//     block;
+//   - quote and end-quote, and link-defs and end-link-defs, do the
+//     same for a blockquote and for link definitions; passQuotes and
+//     passLinkDefs insert them and they are removed from the output;
+//   - reset restarts section numbering, so that the next heading at the
+//     top level is numbered 1, or at the level and number given, e.g.
+//     <!-- markproc: reset 3.2 -->;
+//   - toc, bibliography, glossary and appendix do what the markers of
+//     the same names do, e.g. <!-- toc -->;
+//   - version is replaced with the document's version and date.
+//
+// At the end of a heading, <!-- markproc: nonum --> and
+// <!-- markproc: toc-exclude --> do what <!-- nonum --> and
+// <!-- toc-exclude --> do.
+var directiveRe = regexp.MustCompile(`^\s*<!--\s*markproc:\s*([\w-]+)\s*(.*?)\s*-->\s*$`)
+
+// parseDirective returns the name, lowercased, and argument of the
+// directive on line, if any.
+func parseDirective(line string) (name, arg string, ok bool) {
+	m := directiveRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	return strings.ToLower(m[1]), m[2], true
+}
+
+// isDirective reports whether line is the directive name with no
+// argument.
+func isDirective(line, name string) bool {
+	n, arg, ok := parseDirective(line)
+	return ok && n == name && arg == ""
+}
+
+// resetDirective returns the counts a numberer restarts from after line,
+// if it is a reset directive.  An invalid start number restarts from
+// the beginning.
+func resetDirective(line string) (counts []int, ok bool) {
+	name, arg, ok := parseDirective(line)
+	if !ok || name != "reset" {
+		return nil, false
+	}
+	if arg != "" {
+		counts, _ = parseStartNumber(arg)
+	}
+	return counts, true
+}
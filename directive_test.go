@@ -0,0 +1,55 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestDirectives(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MkTOC = true
+	p := NewProcessor(opts)
+	lines := []string{
+		"<!-- markproc: toc -->",
+		"# Intro",
+		"<!-- markproc: off -->",
+		"# Not numbered [sec intr]",
+		"<!-- markproc: on -->",
+		"## Aside <!-- markproc: nonum -->",
+		"## Hidden <!-- markproc: toc-exclude -->",
+		"<!-- markproc: reset 5 -->",
+		"# Later",
+		"<!-- markproc: appendix -->",
+		"# Extra",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		"<!-- toc -->",
+		`- <a href="#sec1">1. Intro</a>`,
+		`- <a href="#sec5">5. Later</a>`,
+		`- <a href="#secA">A. Extra</a>`,
+		"<!-- /toc -->",
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"<!-- markproc: off -->",
+		"# Not numbered [sec intr]",
+		"<!-- markproc: on -->",
+		"## Aside <!-- markproc: nonum -->",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Hidden <!-- markproc: toc-exclude -->",
+		"<!-- markproc: reset 5 -->",
+		`<a name="sec5"></a>`,
+		"# 5. Later",
+		"<!-- markproc: appendix -->",
+		`<a name="secA"></a>`,
+		"# A. Extra",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+}
@@ -0,0 +1,148 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// eqLabelRe matches the <!-- eq LABEL --> comment naming the
+	// display-math block after it.
+	eqLabelRe = regexp.MustCompile(`^\s*<!--\s*eq\s+(.+?)\s*-->\s*$`)
+	// eqRefRe matches an [eq ...] reference.
+	eqRefRe = regexp.MustCompile(`\[eq\s+([^\]]+)\]`)
+	// eqTagRe matches the \tag{N} passMkEquations adds to a block, on
+	// its own line or at the end of a one-line block.
+	eqTagRe = regexp.MustCompile(`\s*\\tag\{[\dA-Z][\d.]*\}(\s*\$\$\s*)?$`)
+)
+
+// mathBlockEnd returns the index of the last line of the display-math
+// block starting at lines[i], a $$ ... $$ block or a fenced math block,
+// or -1 if none starts there.  code is the codeMask of lines.
+func mathBlockEnd(lines []string, code []bool, i int) int {
+	line := strings.TrimSpace(lines[i])
+	if m := fenceRegexp.FindStringSubmatch(lines[i]); m != nil && strings.TrimSpace(m[2]) == "math" {
+		for j := i + 1; j < len(lines) && code[j]; j++ {
+			if fenceRegexp.MatchString(lines[j]) {
+				return j
+			}
+		}
+		return -1
+	}
+	if code[i] || !strings.HasPrefix(line, "$$") {
+		return -1
+	}
+	if len(line) > 4 && strings.HasSuffix(line, "$$") {
+		return i
+	}
+	for j := i + 1; j < len(lines) && !code[j]; j++ {
+		if strings.HasSuffix(strings.TrimSpace(lines[j]), "$$") {
+			return j
+		}
+	}
+	return -1
+}
+
+// eqAnchor returns the anchor name of equation number, e.g. eq2_3,
+// with p's AnchorSeparator between the parts of the number.
+func (p *Processor) eqAnchor(number string) string {
+	return safeAnchorName("eq" + p.anchorNumber(number))
+}
+
+// passMkEquations numbers the display-math blocks, $$ ... $$ or fenced
+// math blocks, by top-level section, e.g. (2.3) for the third equation
+// of section 2, with a \tag{2.3} inside the block and an anchor before
+// it.  It must run after passMkHeads.
+func (p *Processor) passMkEquations(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	chapter, count := "", 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := p.numberedHeading(line); m != nil && !code[i] && len(m[1]) == 1 {
+			chapter, count = strings.TrimSuffix(m[2], "."), 0
+		}
+		end := mathBlockEnd(lines, code, i)
+		if end < 0 {
+			out.add(i, line)
+			continue
+		}
+		count++
+		number := fmt.Sprint(count)
+		if chapter != "" {
+			number = chapter + "." + number
+		}
+		tag := fmt.Sprintf(`\tag{%s}`, number)
+		out.add(i, fmt.Sprintf(`<a name="%s"></a>`, p.eqAnchor(number)))
+		if end == i {
+			// a one-line $$ ... $$ block
+			body := strings.TrimSuffix(strings.TrimRight(line, " \t"), "$$")
+			out.add(i, fmt.Sprintf("%s %s $$", strings.TrimRight(body, " \t"), tag))
+			continue
+		}
+		for j := i; j < end; j++ {
+			out.add(j, lines[j])
+		}
+		out.add(end, tag)
+		out.add(end, lines[end])
+		i = end
+	}
+	return p.done(out)
+}
+
+// equationTargets returns the labeled equations of processed lines,
+// keyed by their lowercased labels.
+func (p *Processor) equationTargets(lines []string) map[string]Target {
+	targets := map[string]Target{}
+	code := codeMask(lines)
+	for i := 0; i+1 < len(lines); i++ {
+		m := eqLabelRe.FindStringSubmatch(lines[i])
+		if m == nil || code[i] {
+			continue
+		}
+		a := anchorLineRe.FindStringSubmatch(strings.TrimSpace(lines[i+1]))
+		if a == nil || !strings.HasPrefix(a[1], "eq") {
+			continue
+		}
+		key := strings.ToLower(m[1])
+		number := strings.Replace(strings.TrimPrefix(a[1], "eq"), p.anchorSeparator(), ".", -1)
+		targets[key] = Target{Name: a[1], Heading: m[1], Number: number, HeadingLower: key}
+	}
+	return targets
+}
+
+// passLinkEquations turns [eq ...] references into links to the
+// equations whose <!-- eq LABEL --> labels they abbreviate, matched the
+// way [sec ...] references are, with text following p.EqRefFormat.
+func (p *Processor) passLinkEquations(lines []string) []string {
+	targets := p.equationTargets(lines)
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		for _, match := range eqRefRe.FindAllStringSubmatch(line, -1) {
+			acronym := match[1]
+			matches := p.matcher().Match(acronym, keys(targets))
+			switch len(matches) {
+			case 0:
+				p.warnf("eq-unresolved", i, match[0], "[eq %s] no fuzzy match found", acronym)
+				p.fail("unresolved references")
+			case 1:
+				target := targets[matches[0]]
+				link := fmt.Sprintf(`[<a href="#%s">%s</a>]`, target.Name, refText(p.EqRefFormat, "eq {number}", target))
+				line = strings.Replace(line, match[0], link, -1)
+			default:
+				msg := fmt.Sprintf("[eq %s] multiple fuzzy matches found:", acronym)
+				msg += candidates(acronym, matches, func(key string) string { return targets[key].Heading })
+				p.warnf("eq-ambiguous", i, match[0], "%s", msg)
+				p.fail("unresolved references")
+			}
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
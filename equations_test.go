@@ -0,0 +1,66 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestEquations(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"By [eq enrgy] and [eq mom]:",
+		"<!-- eq energy balance -->",
+		"$$",
+		"E_{in} = E_{out}",
+		"$$",
+		"# Model",
+		"$$ a = b $$",
+		"<!-- eq momentum -->",
+		"```math",
+		"p = mv",
+		"```",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`By [<a href="#eq1_1">eq 1.1</a>] and [<a href="#eq2_2">eq 2.2</a>]:`,
+		"<!-- eq energy balance -->",
+		`<a name="eq1_1"></a>`,
+		"$$",
+		"E_{in} = E_{out}",
+		`\tag{1.1}`,
+		"$$",
+		`<a name="sec2"></a>`,
+		"# 2. Model",
+		`<a name="eq2_1"></a>`,
+		`$$ a = b \tag{2.1} $$`,
+		"<!-- eq momentum -->",
+		`<a name="eq2_2"></a>`,
+		"```math",
+		"p = mv",
+		`\tag{2.2}`,
+		"```",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	stripped := p.Strip(out)
+	wantStripped := append([]string{}, lines...)
+	wantStripped[1] = "By [eq energybalance] and [eq momentu]:"
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{"See [eq nothing].", "$$ x $$"})
+	Tassert(t, err != nil && p.Warnings()[0].Rule == "eq-unresolved", "have %v", p.Warnings())
+}
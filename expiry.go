@@ -0,0 +1,125 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// expiresRe matches an <!-- expires: 2025-06-01 --> line giving the
+// date the section it is in goes out of date.
+var expiresRe = regexp.MustCompile(`^\s*<!--\s*expires:\s*(.*?)\s*-->\s*$`)
+
+// expiryLayout is the format of expiry dates.
+const expiryLayout = "2006-01-02"
+
+// SectionExpiry is the expiry date of one section.
+type SectionExpiry struct {
+	// Number, Title and Anchor are empty for a date given before the
+	// first heading, which covers the whole document.
+	Number  string `json:"number"`
+	Title   string `json:"title"`
+	Anchor  string `json:"anchor"`
+	Expires string `json:"expires"`
+	// Line is the 1-based line number of the expires line.
+	Line int `json:"line"`
+	// Days is the number of days from today until the date, negative
+	// once it has passed.
+	Days int `json:"days"`
+}
+
+// Expired reports whether the date has passed.
+func (e SectionExpiry) Expired() bool {
+	return e.Days < 0
+}
+
+// Expirations returns the expiry dates given by <!-- expires: DATE -->
+// lines in the unprocessed document lines, earliest first, with the
+// number of days left until each as of p.Today.  Lines with a date that
+// isn't YYYY-MM-DD are left out.
+func (p *Processor) Expirations(lines []string) (expiries []SectionExpiry) {
+	expiries = []SectionExpiry{}
+	p.expiryMarkers(lines, func(e SectionExpiry, err error) {
+		if err == nil {
+			expiries = append(expiries, e)
+		}
+	})
+	sort.SliceStable(expiries, func(i, j int) bool {
+		return expiries[i].Expires < expiries[j].Expires
+	})
+	return
+}
+
+// expiryMarkers calls f with each expires line of lines, in order, and
+// the error parsing its date, if any.
+func (p *Processor) expiryMarkers(lines []string, f func(e SectionExpiry, err error)) {
+	today, err := p.today()
+	if err != nil {
+		f(SectionExpiry{}, err)
+		return
+	}
+	headings := p.headings(lines)
+	code := codeMask(lines)
+	h := -1
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		for h+1 < len(headings) && headings[h+1].Line == i+1 {
+			h++
+		}
+		m := expiresRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		e := SectionExpiry{Expires: m[1], Line: i + 1}
+		if h >= 0 {
+			sec := headings[h].Section
+			e.Number, e.Title, e.Anchor = sec.Number, sec.Title, sec.Anchor
+		}
+		date, err := time.Parse(expiryLayout, m[1])
+		if err == nil {
+			e.Days = int(date.Sub(today).Hours() / 24)
+		}
+		f(e, err)
+	}
+}
+
+// today returns p.Today, or the current date if it is empty.
+func (p *Processor) today() (today time.Time, err error) {
+	if p.Today == "" {
+		y, m, d := time.Now().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+	}
+	today, err = time.Parse(expiryLayout, p.Today)
+	if err != nil {
+		err = fmt.Errorf("today's date %q is not YYYY-MM-DD", p.Today)
+	}
+	return
+}
+
+// verifyExpiry warns about each expires line of the input document
+// whose date has passed, or can't be read.  The expired sections make
+// verification fail if p.StrictExpiry is set.
+func (p *Processor) verifyExpiry() (err error) {
+	expired := 0
+	p.expiryMarkers(p.input, func(e SectionExpiry, perr error) {
+		switch {
+		case e.Line == 0:
+			err = perr
+		case perr != nil:
+			p.warnfLine("expiry-invalid", e.Line, e.Expires, "Expiry date %q is not YYYY-MM-DD", e.Expires)
+		case e.Expired() && e.Number == "":
+			p.warnfLine("section-expired", e.Line, e.Expires, "Document expired on %s", e.Expires)
+			expired++
+		case e.Expired():
+			p.warnfLine("section-expired", e.Line, e.Expires, "Section %s %s expired on %s", e.Number, e.Title, e.Expires)
+			expired++
+		}
+	})
+	if err == nil && expired > 0 && p.StrictExpiry {
+		err = fmt.Errorf("%d expired sections", expired)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestExpirations(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"<!-- expires: 2025-07-01 -->",
+		"## Scope",
+		"```",
+		"<!-- expires: 2000-01-01 -->",
+		"```",
+		"# Storage",
+		"<!-- expires: 2025-05-30 -->",
+		"## Disks",
+		"<!-- expires: someday -->",
+	}
+	opts := DefaultOptions()
+	opts.Today = "2025-06-01"
+	p := NewProcessor(opts)
+	have := p.Expirations(lines)
+	want := []SectionExpiry{
+		{Number: "2", Title: "Storage", Anchor: "sec2", Expires: "2025-05-30", Line: 8, Days: -2},
+		{Number: "1", Title: "Intro", Anchor: "sec1", Expires: "2025-07-01", Line: 2, Days: 30},
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %+v\nhave: %+v", want, have)
+
+	p.Stderr = io.Discard
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	rules := []string{}
+	for _, w := range p.Warnings() {
+		rules = append(rules, w.Rule+": "+w.Message)
+	}
+	wantRules := []string{
+		"section-expired: Section 2 Storage expired on 2025-05-30",
+		`expiry-invalid: Expiry date "someday" is not YYYY-MM-DD`,
+	}
+	Tassert(t, reflect.DeepEqual(rules, wantRules), "\nwant: %q\nhave: %q", wantRules, rules)
+
+	p.StrictExpiry = true
+	_, err = p.Process(lines)
+	Tassert(t, err != nil, "want error for an expired section")
+	Tassert(t, p.Warnings()[0].Severity == SeverityError, "have %+v", p.Warnings()[0])
+
+	p.Today = "2025-05-01"
+	_, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+}
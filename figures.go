@@ -0,0 +1,144 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// captionRe matches a Figure: or Table: caption line: the groups are
+	// the kind and the caption.
+	captionRe = regexp.MustCompile(`^(Figure|Table):\s+(.+)$`)
+	// numberedCaptionRe matches a caption numbered by passMkFigures:
+	// the groups are the anchor, the kind, the number and the caption.
+	numberedCaptionRe = regexp.MustCompile(`^<a name="([^"]+)"></a>(Figure|Table) ([\dA-Z][\w.]*): (.+)$`)
+	// imageLineRe matches a line holding nothing but an image.
+	imageLineRe = regexp.MustCompile(`^\s*!\[[^\]]*\]\([^)]*\)(?:\{[^}]*\})?\s*$`)
+)
+
+// The comments passMkFigures puts around lists of figures and tables.
+const (
+	lofStart = "<!-- lof -->"
+	lofEnd   = "<!-- /lof -->"
+	lotStart = "<!-- lot -->"
+	lotEnd   = "<!-- /lot -->"
+)
+
+// isLOFMarker reports whether line asks for a list of figures.
+func isLOFMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[lof]", lofStart:
+		return true
+	}
+	return isDirective(line, "lof")
+}
+
+// isLOTMarker reports whether line asks for a list of tables.
+func isLOTMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[lot]", lotStart:
+		return true
+	}
+	return isDirective(line, "lot")
+}
+
+// captionAnchor returns the anchor name of figure or table number,
+// e.g. fig2_3 or tbl2_3.
+func (p *Processor) captionAnchor(kind, number string) string {
+	prefix := "fig"
+	if kind == "Table" {
+		prefix = "tbl"
+	}
+	return safeAnchorName(prefix + p.anchorNumber(number))
+}
+
+// captions reports whether lines[i] is a caption of kind: the previous
+// or next line, or the one beyond it across a blank line, must be an
+// image for a Figure or a pipe table row for a Table.
+func captions(lines []string, code []bool, i int, kind string) bool {
+	for _, step := range []int{-1, 1} {
+		for j := i + step; j >= 0 && j < len(lines) && j != i+3*step; j += step {
+			line := lines[j]
+			if strings.TrimSpace(line) == "" && j == i+step {
+				continue
+			}
+			if code[j] {
+				break
+			}
+			if kind == "Figure" && imageLineRe.MatchString(line) ||
+				kind == "Table" && strings.HasPrefix(strings.TrimSpace(line), "|") {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
+
+// passMkFigures numbers each captioned figure and table by top-level
+// section, like equations: a "Figure: caption" or "Table: caption"
+// line next to an image or a pipe table becomes e.g.
+// `<a name="fig2_1"></a>Figure 2.1: caption`.  Each [lof] or
+// <!-- lof --> marker line is replaced with a list of links to the
+// figures, and each [lot] or <!-- lot --> marker with one to the
+// tables, between <!-- lof --> and <!-- /lof --> or <!-- lot --> and
+// <!-- /lot --> comments.  It must run after passMkHeads.
+func (p *Processor) passMkFigures(lines []string) []string {
+	code := codeMask(lines)
+	numbered := make([]string, len(lines))
+	lists := map[string][]string{}
+	chapter := ""
+	counts := map[string]int{}
+	for i, line := range lines {
+		numbered[i] = line
+		if code[i] {
+			continue
+		}
+		if m := p.numberedHeading(line); m != nil && len(m[1]) == 1 {
+			chapter, counts = strings.TrimSuffix(m[2], "."), map[string]int{}
+		}
+		m := captionRe.FindStringSubmatch(line)
+		if m == nil || !captions(lines, code, i, m[1]) {
+			continue
+		}
+		kind, caption := m[1], strings.TrimSpace(m[2])
+		counts[kind]++
+		number := fmt.Sprint(counts[kind])
+		if chapter != "" {
+			number = chapter + "." + number
+		}
+		anchor := p.captionAnchor(kind, number)
+		numbered[i] = fmt.Sprintf(`<a name="%s"></a>%s %s: %s`, anchor, kind, number, caption)
+		lists[kind] = append(lists[kind], fmt.Sprintf(`- <a href="#%s">%s %s: %s</a>`, anchor, kind, number, escapeText(caption)))
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range numbered {
+		switch {
+		case code[i]:
+			out.add(i, line)
+		case isLOFMarker(line):
+			out.add(i, lofStart)
+			out.add(i, lists["Figure"]...)
+			out.add(i, lofEnd)
+		case isLOTMarker(line):
+			out.add(i, lotStart)
+			out.add(i, lists["Table"]...)
+			out.add(i, lotEnd)
+		default:
+			out.add(i, line)
+		}
+	}
+	return p.done(out)
+}
+
+// unnumberCaption returns line, a caption numbered by passMkFigures,
+// as it was written, or line itself if it is not one.
+func unnumberCaption(line string) string {
+	m := numberedCaptionRe.FindStringSubmatch(line)
+	if m == nil || !strings.HasPrefix(m[1], "fig") && !strings.HasPrefix(m[1], "tbl") {
+		return line
+	}
+	return fmt.Sprintf("%s: %s", m[2], m[4])
+}
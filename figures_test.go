@@ -0,0 +1,70 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestMkFigures(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MkFigures = true
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Contents",
+		"[lof]",
+		"<!-- lot -->",
+		"# Design",
+		"![Boxes](arch.png)",
+		"Figure: Architecture & data flow",
+		"",
+		"Table: Limits",
+		"",
+		"| Name | Value |",
+		"|------|-------|",
+		"Figure: not next to an image",
+		"# Results",
+		"Figure: Timings",
+		"",
+		"![](times.png)",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Contents",
+		"<!-- lof -->",
+		`- <a href="#fig2_1">Figure 2.1: Architecture &amp; data flow</a>`,
+		`- <a href="#fig3_1">Figure 3.1: Timings</a>`,
+		"<!-- /lof -->",
+		"<!-- lot -->",
+		`- <a href="#tbl2_1">Table 2.1: Limits</a>`,
+		"<!-- /lot -->",
+		`<a name="sec2"></a>`,
+		"# 2. Design",
+		"![Boxes](arch.png)",
+		`<a name="fig2_1"></a>Figure 2.1: Architecture & data flow`,
+		"",
+		`<a name="tbl2_1"></a>Table 2.1: Limits`,
+		"",
+		"| Name | Value |",
+		"|------|-------|",
+		"Figure: not next to an image",
+		`<a name="sec3"></a>`,
+		"# 3. Results",
+		`<a name="fig3_1"></a>Figure 3.1: Timings`,
+		"",
+		"![](times.png)",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := append([]string{}, lines...)
+	wantStripped[1] = "<!-- lof -->"
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
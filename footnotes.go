@@ -0,0 +1,246 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Footnote placements for Options.Footnotes.
+const (
+	// FootnotesEnd collects the footnote definitions at the end of the
+	// document.
+	FootnotesEnd = "end"
+	// FootnotesSection puts each definition at the end of the section
+	// where its footnote is first used.
+	FootnotesSection = "section"
+)
+
+// FootnotePlacements lists the valid values of Options.Footnotes, ""
+// meaning footnotes are left alone.
+var FootnotePlacements = []string{"", FootnotesEnd, FootnotesSection}
+
+var (
+	// fnDefRe matches the first line of a footnote definition: the
+	// groups are the label and the text.
+	fnDefRe = regexp.MustCompile(`^\[\^([^\]\s]+)\]:`)
+	// fnRefRe matches a footnote reference; one followed by a colon at
+	// the start of a line is a definition instead.
+	fnRefRe = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// footnoteDef is a footnote definition: its first line and indented
+// continuation lines, start to end inclusive in the pass input.
+type footnoteDef struct {
+	label      string
+	start, end int
+}
+
+// footnoteEnd returns the index of the last line of the footnote
+// definition starting at lines[i]: later lines indented by four spaces
+// or a tab continue it, also across blank lines.
+func footnoteEnd(lines []string, i int) int {
+	end := i
+	for j := i + 1; j < len(lines); j++ {
+		line := lines[j]
+		if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			end = j
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			break
+		}
+	}
+	return end
+}
+
+// passFootnotes normalizes [^label] footnotes: numeric labels are
+// renumbered 1, 2, 3 in order of first use, those of definitions never
+// used after them, and the definitions,
+// [^label]: text, are moved to the end of the document, or with
+// p.Footnotes set to FootnotesSection to the end of the section where
+// each is first used, in order of first use.  Footnotes used but never
+// defined fail processing and keep their labels, which no other
+// footnote is renumbered to; definitions never used are warned about
+// and moved last.
+func (p *Processor) passFootnotes(lines []string) []string {
+	code := codeMask(lines)
+	defs := map[string]*footnoteDef{}
+	defAt := map[int]*footnoteDef{}
+	var unused []*footnoteDef
+	for i := 0; i < len(lines); i++ {
+		m := fnDefRe.FindStringSubmatch(lines[i])
+		if m == nil || code[i] {
+			continue
+		}
+		if _, ok := defs[m[1]]; ok {
+			p.warnf("footnote-duplicate", i, m[0], "Footnote defined more than once: [^%s]", m[1])
+			continue
+		}
+		def := &footnoteDef{label: m[1], start: i, end: footnoteEnd(lines, i)}
+		defs[m[1]], defAt[i] = def, def
+		i = def.end
+	}
+
+	// starts reports whether a section starts at line i: a heading, or
+	// the anchor line before one
+	starts := func(i int) bool {
+		if code[i] {
+			return false
+		}
+		if anchorLineRe.MatchString(lines[i]) {
+			return i+1 < len(lines) && !code[i+1] && headerRegexp.MatchString(lines[i+1])
+		}
+		return headerRegexp.MatchString(lines[i]) && !(i > 0 && !code[i-1] && anchorLineRe.MatchString(lines[i-1]))
+	}
+
+	// order lists the labels in order of first use, and section the
+	// index of the line starting the section after the one each is
+	// first used in
+	order := []string{}
+	section := map[string]int{}
+	pending := []string{}
+	used := func(label string, i int) {
+		if _, ok := section[label]; ok {
+			return
+		}
+		section[label] = len(lines)
+		order = append(order, label)
+		pending = append(pending, label)
+		if _, ok := defs[label]; !ok {
+			p.warnf("footnote-undefined", i, "[^"+label+"]", "Footnote used but never defined: [^%s]", label)
+			p.fail(fmt.Sprintf("undefined footnote: [^%s]", label))
+		}
+	}
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if starts(i) {
+			for _, label := range pending {
+				section[label] = i
+			}
+			pending = nil
+		}
+		if m := fnDefRe.FindString(line); m != "" {
+			line = line[len(m):]
+		}
+		outsideCode(line, func(part string) string {
+			for _, m := range fnRefRe.FindAllStringSubmatch(part, -1) {
+				used(m[1], i)
+			}
+			return part
+		})
+	}
+	for _, def := range defs {
+		if _, ok := section[def.label]; !ok {
+			p.warnf("footnote-unused", def.start, "[^"+def.label+"]:", "Footnote defined but never used: [^%s]", def.label)
+		}
+	}
+	for i := range lines {
+		if def, ok := defAt[i]; ok {
+			if _, ok := section[def.label]; !ok {
+				unused = append(unused, def)
+			}
+		}
+	}
+
+	// taken holds the labels kept: those of footnotes never defined
+	taken := map[string]bool{}
+	for _, label := range order {
+		if _, ok := defs[label]; !ok {
+			taken[label] = true
+		}
+	}
+	renumber := map[string]string{}
+	n := 0
+	number := func(label string) {
+		if _, err := strconv.Atoi(label); err != nil {
+			return
+		}
+		for n++; taken[strconv.Itoa(n)]; n++ {
+		}
+		renumber[label] = strconv.Itoa(n)
+	}
+	for _, label := range order {
+		if _, ok := defs[label]; ok {
+			number(label)
+		}
+	}
+	for _, def := range unused {
+		number(def.label)
+	}
+
+	relabel := func(line string) string {
+		return outsideCode(line, func(part string) string {
+			return fnRefRe.ReplaceAllStringFunc(part, func(ref string) string {
+				if n, ok := renumber[fnRefRe.FindStringSubmatch(ref)[1]]; ok {
+					return "[^" + n + "]"
+				}
+				return ref
+			})
+		})
+	}
+	out := p.newLineWriter(lines)
+	emit := func(defs []*footnoteDef) {
+		if len(defs) == 0 {
+			return
+		}
+		out.trimBlank()
+		out.add(defs[0].start, "")
+		for _, def := range defs {
+			for j := def.start; j <= def.end; j++ {
+				out.add(j, relabel(lines[j]))
+			}
+		}
+	}
+	// at returns the definitions of the footnotes first used in the
+	// section ending at line i, in order of first use.
+	at := func(i int) (found []*footnoteDef) {
+		for _, label := range order {
+			if def, ok := defs[label]; ok && section[label] == i {
+				found = append(found, def)
+			}
+		}
+		return
+	}
+
+	skipBlank := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if code[i] {
+			out.add(i, line)
+			skipBlank = false
+			continue
+		}
+		if def, ok := defAt[i]; ok {
+			i = def.end
+			skipBlank = len(out.lines) == 0 || strings.TrimSpace(out.lines[len(out.lines)-1]) == ""
+			continue
+		}
+		if strings.TrimSpace(line) == "" && skipBlank {
+			continue
+		}
+		skipBlank = false
+		if p.Footnotes == FootnotesSection && starts(i) {
+			if found := at(i); len(found) > 0 {
+				emit(found)
+				out.add(i, "")
+			}
+		}
+		out.add(i, relabel(line))
+	}
+	if p.Footnotes == FootnotesSection {
+		emit(append(at(len(lines)), unused...))
+	} else {
+		all := []*footnoteDef{}
+		for _, label := range order {
+			if def, ok := defs[label]; ok {
+				all = append(all, def)
+			}
+		}
+		emit(append(all, unused...))
+	}
+	return p.done(out)
+}
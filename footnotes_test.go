@@ -0,0 +1,112 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestFootnotes(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"First[^3] and named[^note].",
+		"",
+		"[^3]: Three.",
+		"",
+		"## Design",
+		"Again[^3], then[^7] and `[^9]`.",
+		"",
+		"[^note]: A note",
+		"    with more.",
+		"[^7]: Seven.",
+		"[^old]: Never used.",
+	}
+	tests := []struct {
+		placement string
+		want      []string
+	}{
+		{FootnotesEnd, []string{
+			`<a name="sec1"></a>`,
+			"# 1. Intro",
+			"First[^1] and named[^note].",
+			"",
+			`<a name="sec1_1"></a>`,
+			"## 1.1. Design",
+			"Again[^1], then[^2] and `[^9]`.",
+			"",
+			"[^1]: Three.",
+			"[^note]: A note",
+			"    with more.",
+			"[^2]: Seven.",
+			"[^old]: Never used.",
+		}},
+		{FootnotesSection, []string{
+			`<a name="sec1"></a>`,
+			"# 1. Intro",
+			"First[^1] and named[^note].",
+			"",
+			"[^1]: Three.",
+			"[^note]: A note",
+			"    with more.",
+			"",
+			`<a name="sec1_1"></a>`,
+			"## 1.1. Design",
+			"Again[^1], then[^2] and `[^9]`.",
+			"",
+			"[^2]: Seven.",
+			"[^old]: Never used.",
+		}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Footnotes = tt.placement
+		p := NewProcessor(opts)
+		p.Stderr = io.Discard
+		out, err := p.Process(lines)
+		Tassert(t, err == nil, "%s: Process failed: %v", tt.placement, err)
+		Tassert(t, reflect.DeepEqual(out, tt.want), "%s:\nwant: %q\nhave: %q", tt.placement, tt.want, out)
+		rules := []string{}
+		for _, w := range p.Warnings() {
+			rules = append(rules, w.Rule)
+		}
+		Tassert(t, reflect.DeepEqual(rules, []string{"footnote-unused"}), "%s: have %v", tt.placement, p.Warnings())
+
+		again, err := p.Process(out)
+		Tassert(t, err == nil, "%s: Process failed: %v", tt.placement, err)
+		Tassert(t, reflect.DeepEqual(again, tt.want), "%s:\nwant: %q\nhave: %q", tt.placement, tt.want, again)
+	}
+
+	opts := DefaultOptions()
+	opts.Footnotes = FootnotesEnd
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err := p.Process([]string{"Text[^1]."})
+	Tassert(t, err != nil, "undefined footnote not reported")
+	Tassert(t, p.Warnings()[0].Rule == "footnote-undefined", "have %v", p.Warnings())
+
+	// unused definitions are renumbered after the used ones, and no
+	// footnote takes the label of one never defined
+	out, err := p.Process([]string{
+		"Text[^5], more[^2] and[^6].",
+		"",
+		"[^5]: Five.",
+		"[^1]: Unused one.",
+		"[^6]: Six.",
+	})
+	Tassert(t, err != nil, "undefined footnote not reported")
+	want := []string{
+		"Text[^1], more[^2] and[^3].",
+		"",
+		"[^1]: Five.",
+		"[^3]: Six.",
+		"[^4]: Unused one.",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	rules := []string{}
+	for _, w := range p.Warnings() {
+		rules = append(rules, w.Rule)
+	}
+	Tassert(t, reflect.DeepEqual(rules, []string{"footnote-undefined", "footnote-unused"}), "have %v", p.Warnings())
+}
@@ -0,0 +1,99 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// fmKeyRe matches a top-level "key: value" line of YAML.
+	fmKeyRe = regexp.MustCompile(`^([\w-]+):\s*(.*)$`)
+	// fmNestedRe matches an indented "key: value" line of YAML.
+	fmNestedRe = regexp.MustCompile(`^\s+([\w-]+):\s*(.*)$`)
+)
+
+// frontMatterEnd returns the number of lines taken by the YAML front
+// matter at the top of lines, including its --- delimiters, or by
+// Hugo's TOML front matter between +++ lines, or zero if there is none.
+func frontMatterEnd(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	ends := []string{"---", "..."}
+	switch strings.TrimRight(lines[0], " \t") {
+	case "---":
+	case "+++":
+		ends = []string{"+++"}
+	default:
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		for _, end := range ends {
+			if line == end {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// fmSetting is one setting read from front matter, found on the
+// zero-based line of the document.
+type fmSetting struct {
+	key, value string
+	line       int
+}
+
+// frontMatterConfig returns the settings under the markproc key of the
+// front matter at the top of lines, written either as a flow mapping,
+//
+//	markproc: {toc: true, toc-depth: 2}
+//
+// or as a block mapping with one indented setting per line.  Only this
+// subset of YAML is understood.
+func frontMatterConfig(lines []string) (config []fmSetting) {
+	end := frontMatterEnd(lines)
+	inBlock := false
+	for i := 1; i < end-1; i++ {
+		line := lines[i]
+		if inBlock {
+			if m := fmNestedRe.FindStringSubmatch(line); m != nil {
+				config = append(config, fmSetting{m[1], unquote(m[2]), i})
+				continue
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			inBlock = false
+		}
+		m := fmKeyRe.FindStringSubmatch(line)
+		if m == nil || m[1] != "markproc" {
+			continue
+		}
+		value := strings.TrimSpace(m[2])
+		if value == "" {
+			inBlock = true
+			continue
+		}
+		if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				kv := strings.SplitN(item, ":", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				config = append(config, fmSetting{strings.TrimSpace(kv[0]), unquote(kv[1]), i})
+			}
+		}
+	}
+	return
+}
+
+// unquote trims a YAML scalar and removes surrounding quotes.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
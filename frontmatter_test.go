@@ -0,0 +1,66 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestFrontMatterConfig(t *testing.T) {
+	lines := []string{
+		"---",
+		"title: Foo",
+		"markproc: {toc: true, toc-depth: 2}",
+		"---",
+	}
+	have := frontMatterConfig(lines)
+	want := []fmSetting{{"toc", "true", 2}, {"toc-depth", "2", 2}}
+	Tassert(t, reflect.DeepEqual(have, want), "have %v", have)
+
+	lines = []string{
+		"---",
+		"markproc:",
+		"  anchor-style: \"github\"",
+		"  toc: false",
+		"tags:",
+		"  - [x]",
+		"...",
+	}
+	have = frontMatterConfig(lines)
+	want = []fmSetting{{"anchor-style", "github", 2}, {"toc", "false", 3}}
+	Tassert(t, reflect.DeepEqual(have, want), "have %v", have)
+
+	Tassert(t, frontMatterEnd([]string{"---", "no end"}) == 0, "unterminated front matter accepted")
+}
+
+func TestProcessFrontMatter(t *testing.T) {
+	lines := []string{
+		"---",
+		"title: Foo",
+		"# not a heading",
+		"tags: [x]",
+		"markproc: {mk-heads: false}",
+		"---",
+		"# Heading",
+	}
+
+	opts := DefaultOptions()
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := append(append([]string{}, lines[:6]...), `<a name="sec1"></a>`, "# 1. Heading")
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	opts.FrontMatterConfig = true
+	p = NewProcessor(opts)
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(out, lines), "\nwant: %q\nhave: %q", lines, out)
+	Tassert(t, p.MkHeads, "front matter settings leaked into the processor")
+
+	p.Stderr = io.Discard
+	p.Process([]string{"---", "markproc: {bogus: 1}", "---"})
+	Tassert(t, len(p.Warnings()) == 1 && p.Warnings()[0].Rule == "front-matter", "have %v", p.Warnings())
+}
@@ -0,0 +1,167 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// termDefRe matches a [term X]: definition.
+	termDefRe = regexp.MustCompile(`^\[term\s+([^\]]+)\]:\s*(.*)$`)
+	// termRefRe matches a [term X] usage.  A match followed by a colon
+	// is a definition instead.
+	termRefRe = regexp.MustCompile(`\[term\s+([^\]]+)\]`)
+	// termLinkRe matches a link passGlossary made from a usage.
+	termLinkRe = regexp.MustCompile(`<a href="#(term-[^"]+)">([^<]*)</a>`)
+	// glossaryEntryRe matches an entry renderGlossary made from a
+	// definition.
+	glossaryEntryRe = regexp.MustCompile(`^- <a name="term-[^"]*"></a>\*\*(.+?)\*\*: (.*)$`)
+)
+
+// isGlossaryMarker reports whether line asks for a generated Glossary.
+func isGlossaryMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[glossary]", "<!-- glossary -->":
+		return true
+	}
+	return isDirective(line, "glossary")
+}
+
+// The comments passGlossary puts around a generated Glossary.
+const (
+	glossaryStart = "<!-- glossary -->"
+	glossaryEnd   = "<!-- /glossary -->"
+)
+
+// termKey returns the key a term is looked up by: lowercased, with
+// runs of spaces collapsed.
+func termKey(term string) string {
+	return strings.ToLower(strings.Join(strings.Fields(term), " "))
+}
+
+// termAnchor returns the anchor name of the definition of term.
+func termAnchor(term string) string {
+	return "term-" + githubSlug(termKey(term))
+}
+
+// termDef is one [term X]: definition.
+type termDef struct {
+	term, text string
+	// line is the index of the definition in the pass input.
+	line int
+}
+
+// passGlossary links each [term X] usage to the definition of X,
+// [term X]: text, warning about terms that are used but not defined or
+// defined but not used.  Each [glossary] or <!-- glossary --> marker
+// line is replaced with the definitions sorted alphabetically, between
+// <!-- glossary --> and <!-- /glossary --> comments, and the
+// definitions are removed from where they were written; without a
+// marker each definition keeps its place and gets an anchor.  A
+// document without definitions, e.g. one processed before, keeps its
+// Glossary.  Terms are looked up within the document, ignoring case.
+func (p *Processor) passGlossary(lines []string) []string {
+	code := codeMask(lines)
+	defs := map[string]*termDef{}
+	used := map[string]bool{}
+	marker := false
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if isGlossaryMarker(line) {
+			marker = true
+		}
+		if m := termDefRe.FindStringSubmatch(line); m != nil {
+			key := termKey(m[1])
+			if _, ok := defs[key]; ok {
+				p.warnf("term-duplicate", i, m[1], "Term defined more than once: %s", m[1])
+				continue
+			}
+			defs[key] = &termDef{term: strings.TrimSpace(m[1]), text: m[2], line: i}
+		}
+		// links made by an earlier run count as usages
+		for _, m := range termLinkRe.FindAllStringSubmatch(line, -1) {
+			used[termKey(m[2])] = true
+		}
+	}
+	if len(defs) == 0 {
+		marker = false
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if marker && isGlossaryMarker(line) {
+			out.add(i, glossaryStart)
+			out.add(i, renderGlossary(defs)...)
+			out.add(i, glossaryEnd)
+			continue
+		}
+		if m := termDefRe.FindStringSubmatch(line); m != nil && defs[termKey(m[1])].line == i {
+			if !marker {
+				out.add(i, fmt.Sprintf(`<a name="%s"></a>`, termAnchor(m[1])))
+				out.add(i, line)
+			}
+			continue
+		}
+		line = p.linkTerms(line, i, defs, used)
+		out.add(i, line)
+	}
+
+	for _, def := range sortedTerms(defs) {
+		if !used[termKey(def.term)] {
+			p.warnf("term-unused", def.line, def.term, "Term defined but never used: %s", def.term)
+		}
+	}
+	return p.done(out)
+}
+
+// linkTerms returns line with each [term X] usage on it, line i of the
+// pass input, replaced by a link to the definition of X, recording X in
+// used.
+func (p *Processor) linkTerms(line string, i int, defs map[string]*termDef, used map[string]bool) string {
+	locs := termRefRe.FindAllStringSubmatchIndex(line, -1)
+	for j := len(locs) - 1; j >= 0; j-- {
+		loc := locs[j]
+		if loc[1] < len(line) && line[loc[1]] == ':' {
+			continue
+		}
+		term := line[loc[2]:loc[3]]
+		key := termKey(term)
+		if _, ok := defs[key]; !ok {
+			p.warnf("term-undefined", i, line[loc[0]:loc[1]], "Term used but never defined: %s", term)
+			p.fail(fmt.Sprintf("undefined term: %s", term))
+			continue
+		}
+		used[key] = true
+		link := fmt.Sprintf(`<a href="#%s">%s</a>`, termAnchor(term), term)
+		line = line[:loc[0]] + link + line[loc[1]:]
+	}
+	return line
+}
+
+// sortedTerms returns the definitions sorted alphabetically by term.
+func sortedTerms(defs map[string]*termDef) (sorted []*termDef) {
+	for _, def := range defs {
+		sorted = append(sorted, def)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return termKey(sorted[i].term) < termKey(sorted[j].term)
+	})
+	return
+}
+
+// renderGlossary returns the Glossary list, each entry carrying the
+// anchor of its term.
+func renderGlossary(defs map[string]*termDef) (lines []string) {
+	for _, def := range sortedTerms(defs) {
+		lines = append(lines, fmt.Sprintf(`- <a name="%s"></a>**%s**: %s`, termAnchor(def.term), def.term, def.text))
+	}
+	return
+}
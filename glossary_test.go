@@ -0,0 +1,78 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestGlossary(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"The [term API] returns [term json] to each [term Client].",
+		"# Glossary",
+		"[glossary]",
+		"[term JSON]: JavaScript Object Notation",
+		"[term API]: Application programming interface",
+		"[term client]: A program calling the API",
+		"[term REST]: Representational state transfer",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`The <a href="#term-api">API</a> returns <a href="#term-json">json</a> to each <a href="#term-client">Client</a>.`,
+		`<a name="sec2"></a>`,
+		"# 2. Glossary",
+		"<!-- glossary -->",
+		`- <a name="term-api"></a>**API**: Application programming interface`,
+		`- <a name="term-client"></a>**client**: A program calling the API`,
+		`- <a name="term-json"></a>**JSON**: JavaScript Object Notation`,
+		`- <a name="term-rest"></a>**REST**: Representational state transfer`,
+		"<!-- /glossary -->",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "term-unused" && warnings[0].Line == 8, "have %v", warnings)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	// stripping puts the definitions back for the next run
+	stripped := p.Strip(out)
+	want = []string{
+		"# Intro",
+		"The [term API] returns [term json] to each [term Client].",
+		"# Glossary",
+		"<!-- glossary -->",
+		"[term API]: Application programming interface",
+		"[term client]: A program calling the API",
+		"[term JSON]: JavaScript Object Notation",
+		"[term REST]: Representational state transfer",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, want), "\nwant: %q\nhave: %q", want, stripped)
+	again, err = p.Process(stripped)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, out), "\nwant: %q\nhave: %q", out, again)
+
+	// without a marker the definitions stay where they are
+	out, err = p.Process([]string{"Uses [term API].", "", "[term API]: Application programming interface"})
+	Ck(err)
+	want = []string{"Uses <a href=\"#term-api\">API</a>.", "", `<a name="term-api"></a>`, "[term API]: Application programming interface"}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	stripped = p.Strip(out)
+	want = []string{"Uses [term API].", "", "[term API]: Application programming interface"}
+	Tassert(t, reflect.DeepEqual(stripped, want), "\nwant: %q\nhave: %q", want, stripped)
+
+	_, err = p.Process([]string{"Uses [term SDK]."})
+	Tassert(t, err != nil, "undefined term not reported")
+	Tassert(t, p.Warnings()[0].Rule == "term-undefined" && p.Warnings()[0].Column == 6, "have %v", p.Warnings())
+}
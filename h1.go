@@ -0,0 +1,43 @@
+package markproc
+
+// Policies for documents with more than one top-level heading, for
+// Options.MultipleH1.
+const (
+	// H1Allow numbers every H1 as a top-level section.
+	H1Allow = "allow"
+	// H1Warn numbers them the same but warns about each H1 after the
+	// first.
+	H1Warn = "warn"
+	// H1Error makes processing fail if there is more than one H1.
+	H1Error = "error"
+	// H1Demote treats the first H1 as the document title and moves
+	// every later H1, with the headings under it, down one level.
+	H1Demote = "demote"
+)
+
+// H1Policies lists the valid values of Options.MultipleH1.
+var H1Policies = []string{H1Allow, H1Warn, H1Error, H1Demote}
+
+// h1Tracker follows the H1 headings of a document for the MultipleH1
+// policy.
+type h1Tracker struct {
+	policy   string
+	seen     bool
+	demoting bool
+}
+
+// level returns the level a heading at level should have under the
+// policy, and whether it is an H1 after the first.
+func (t *h1Tracker) level(level int) (newLevel int, extra bool) {
+	if level == 1 {
+		extra = t.seen
+		t.seen = true
+		if extra && t.policy == H1Demote {
+			t.demoting = true
+		}
+	}
+	if t.demoting {
+		level++
+	}
+	return level, extra
+}
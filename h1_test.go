@@ -0,0 +1,50 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestMultipleH1(t *testing.T) {
+	lines := []string{
+		"# Title",
+		"## Intro",
+		"# Part Two",
+		"## Details",
+	}
+
+	opts := DefaultOptions()
+	opts.MultipleH1 = H1Warn
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[5] == "# 2. Part Two", "have %q", out[5])
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "multiple-h1" && warnings[0].Line == 3, "have %v", warnings)
+
+	p.MultipleH1 = H1Error
+	_, err = p.Process(lines)
+	Tassert(t, err != nil, "multiple H1s not reported as an error")
+	Tassert(t, p.Warnings()[0].Severity == SeverityError, "have %v", p.Warnings())
+
+	p.MultipleH1 = H1Demote
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Title",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Intro",
+		`<a name="sec1_2"></a>`,
+		"## 1.2. Part Two",
+		`<a name="sec1_2_1"></a>`,
+		"### 1.2.1. Details",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+	Tassert(t, p.Outline(lines)[3].Number == "1.2.1", "outline not demoted: %v", p.Outline(lines))
+}
@@ -0,0 +1,178 @@
+package markproc
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Heading anchor forms for Options.HeadingAnchors.
+const (
+	// HeadingAnchorLine writes a heading's anchor as an <a name> tag on
+	// the line before it.
+	HeadingAnchorLine = "line"
+	// HeadingAnchorAttribute writes it as a {#anchor} attribute at the
+	// end of the heading, for renderers that take heading attributes
+	// and linters that reject lines holding only HTML.
+	HeadingAnchorAttribute = "attribute"
+	// HeadingAnchorHTML writes the heading itself as HTML carrying the
+	// anchor as its id, <h2 id="sec2_3">2.3 Design</h2>, with its code
+	// spans and emphasis rendered and its text escaped.  Headings with
+	// markdown links, images or autolinks keep the HeadingAnchorLine
+	// form, since their HTML couldn't be told apart from the links
+	// markproc writes when read back.
+	HeadingAnchorHTML = "html"
+)
+
+// HeadingAnchorForms lists the valid values of Options.HeadingAnchors,
+// "" meaning HeadingAnchorLine.
+var HeadingAnchorForms = []string{"", HeadingAnchorLine, HeadingAnchorAttribute, HeadingAnchorHTML}
+
+var (
+	// htmlHeadingRe matches a heading written as HTML with an id: the
+	// groups are the level, the id and the content.
+	htmlHeadingRe = regexp.MustCompile(`^\s*<h([1-6]) id="([^"]+)">(.*)</h[1-6]>\s*$`)
+	// htmlCodeRe, htmlStrongRe and htmlEmRe match the inline markup
+	// renderInline writes for code spans and emphasis.
+	htmlCodeRe   = regexp.MustCompile(`<code>(.*?)</code>`)
+	htmlStrongRe = regexp.MustCompile(`<strong>(.*?)</strong>`)
+	htmlEmRe     = regexp.MustCompile(`<em>(.*?)</em>`)
+	// headingLinkRe matches the markdown links, images and autolinks
+	// renderInline would write as HTML links and images.
+	headingLinkRe = regexp.MustCompile(`!?\[[^\]]*\]\([^)\s]+(?:\s+"[^"]*")?\)|<https?://[^<>\s]+>`)
+)
+
+// attachesHeadingAnchors reports whether p writes heading anchors in
+// the headings rather than on lines of their own.  Markdown and LaTeX
+// link styles put them at the end of the heading already.
+func (p *Processor) attachesHeadingAnchors() bool {
+	return (p.HeadingAnchors == HeadingAnchorAttribute || p.HeadingAnchors == HeadingAnchorHTML) &&
+		(p.LinkStyle == "" || p.LinkStyle == LinkHTML)
+}
+
+// placeHeadingAnchors returns lines, the processed document, with the
+// <a name> line before each heading moved into the heading in the form
+// p.HeadingAnchors selects.  In the HTML form a numbered heading given
+// an explicit {#id} becomes HTML too.  Setext headings keep their anchor
+// lines.
+func (p *Processor) placeHeadingAnchors(lines []string) []string {
+	if !p.attachesHeadingAnchors() {
+		return lines
+	}
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if m := anchorLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && i+1 < len(lines) && !code[i+1] && headerRegexp.MatchString(lines[i+1]) {
+			switch {
+			case p.HeadingAnchors == HeadingAnchorAttribute:
+				out.add(i, strings.TrimRight(lines[i+1], " ")+" {#"+m[1]+"}")
+			case hasMarkdownLink(lines[i+1]):
+				out.add(i, line, lines[i+1])
+			default:
+				out.add(i, headingHTML(lines[i+1], m[1]))
+			}
+			i++
+			continue
+		}
+		if h := p.numberedHeading(line); h != nil && p.HeadingAnchors == HeadingAnchorHTML && !hasMarkdownLink(line) {
+			if _, markers := headingMarkers(h[3]); headingID(markers) != "" {
+				out.add(i, headingHTML(strings.TrimRight(attrIDRe.ReplaceAllString(line, ""), " "), headingID(markers)))
+				continue
+			}
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// hasMarkdownLink reports whether line has a markdown link, image or
+// autolink outside its code spans.
+func hasMarkdownLink(line string) (found bool) {
+	outsideCode(line, func(s string) string {
+		found = found || headingLinkRe.MatchString(s)
+		return s
+	})
+	return
+}
+
+// headingHTML returns the markdown heading line as an HTML heading with
+// the id name.
+func headingHTML(line, name string) string {
+	m := headerRegexp.FindStringSubmatch(line)
+	level := min(len(m[1]), 6)
+	return fmt.Sprintf(`<h%d id="%s">%s</h%d>`, level, name, renderInline(strings.TrimRight(m[2], " #")), level)
+}
+
+// markdownHeading returns the content of an HTML heading written by
+// headingHTML as a markdown heading of the given level, undoing the
+// inline markup renderInline writes, other than links, which stay HTML.
+func markdownHeading(level int, content string) string {
+	content = htmlCodeRe.ReplaceAllStringFunc(content, func(span string) string {
+		code := html.UnescapeString(htmlCodeRe.FindStringSubmatch(span)[1])
+		if strings.Contains(code, "`") {
+			return "`` " + code + " ``"
+		}
+		return "`" + code + "`"
+	})
+	content = outsideCode(content, func(s string) string {
+		s = htmlStrongRe.ReplaceAllString(s, "**$1**")
+		s = htmlEmRe.ReplaceAllString(s, "*$1*")
+		var b strings.Builder
+		last := 0
+		for _, loc := range htmlTagRe.FindAllStringIndex(s, -1) {
+			b.WriteString(html.UnescapeString(s[last:loc[0]]))
+			b.WriteString(s[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(html.UnescapeString(s[last:]))
+		return b.String()
+	})
+	return strings.Repeat("#", level) + " " + content
+}
+
+// passHeadingAnchors undoes placeHeadingAnchors, so that the other
+// passes see the headings of a document processed before with
+// HeadingAnchors set as they wrote them.  An anchor on a numbered
+// heading is taken for the one markproc generated if it has the
+// generated name, and for an explicit ID otherwise; other HTML headings
+// are left alone.
+func (p *Processor) passHeadingAnchors(lines []string) []string {
+	out := p.newLineWriter(lines)
+	anchor := p.anchorNamer()
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		id, heading := "", line
+		m := htmlHeadingRe.FindStringSubmatch(line)
+		if m != nil {
+			id, heading = m[2], markdownHeading(int(m[1][0]-'0'), m[3])
+		} else if t := trailingIDRe.FindStringSubmatch(line); t != nil {
+			id, heading = t[1], trailingIDRe.ReplaceAllString(line, "")
+		}
+		h := p.numberedHeading(heading)
+		if h == nil {
+			out.add(i, line)
+			continue
+		}
+		title, _, _ := headingTitle(h[3])
+		name := anchor(strings.TrimSuffix(h[2], "."), title)
+		switch {
+		case id != "" && id == name:
+			out.add(i, fmt.Sprintf(`<a name="%s"></a>`, name), heading)
+		case m != nil:
+			out.add(i, heading+" {#"+id+"}")
+		default:
+			out.add(i, line)
+		}
+	}
+	return p.done(out)
+}
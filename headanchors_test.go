@@ -0,0 +1,131 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestHeadingAnchorsAttribute(t *testing.T) {
+	opts := DefaultOptions()
+	opts.HeadingAnchors = HeadingAnchorAttribute
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Top",
+		"See [sec dsgn] and [sec #goals].",
+		"## Design",
+		"## Goals {#goals}",
+		"```",
+		"## Code",
+		"```",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		"# 1. Top {#sec1}",
+		`See [<a href="#sec1_1">sec 1.1</a>] and [<a href="#goals">sec 1.2</a>].`,
+		"## 1.1. Design {#sec1_1}",
+		"## 1.2. Goals {#goals}",
+		"```",
+		"## Code",
+		"```",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := []string{
+		"# Top",
+		"See [sec desig] and [sec #goals].",
+		"## Design",
+		"## Goals {#goals}",
+		"```",
+		"## Code",
+		"```",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
+
+func TestHeadingAnchorsHTML(t *testing.T) {
+	opts := DefaultOptions()
+	opts.HeadingAnchors = HeadingAnchorHTML
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Top *now* & `x<y`",
+		"See [sec dsgn] and [sec #goals].",
+		"## Design",
+		"## Goals {#goals}",
+		`<h2 id="raw">Raw</h2>`,
+		`Back to <a href="#raw">Raw</a>.`,
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<h1 id="sec1">1. Top <em>now</em> &amp; <code>x&lt;y</code></h1>`,
+		`See [<a href="#sec1_1">sec 1.1</a>] and [<a href="#goals">sec 1.2</a>].`,
+		`<h2 id="sec1_1">1.1. Design</h2>`,
+		`<h2 id="goals">1.2. Goals</h2>`,
+		`<h2 id="raw">Raw</h2>`,
+		`Back to <a href="#raw">Raw</a>.`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := []string{
+		"# Top *now* & `x<y`",
+		"See [sec desig] and [sec #goals].",
+		"## Design",
+		"## Goals {#goals}",
+		`<h2 id="raw">Raw</h2>`,
+		`Back to <a href="#raw">Raw</a>.`,
+	}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
+
+func TestHeadingAnchorsHTMLLinks(t *testing.T) {
+	opts := DefaultOptions()
+	opts.HeadingAnchors = HeadingAnchorHTML
+	p := NewProcessor(opts)
+	lines := []string{
+		"# About [markproc](https://example.com/markproc)",
+		"## Logo ![logo](logo.png)",
+		"## Site <https://example.com>",
+		"## Syntax `[x](y)` {#syntax}",
+		"## Plain {#plain}",
+		`## Compare a < b & "c" {#cmp}`,
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. About [markproc](https://example.com/markproc)",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Logo ![logo](logo.png)",
+		`<a name="sec1_2"></a>`,
+		"## 1.2. Site <https://example.com>",
+		`<h2 id="syntax">1.3. Syntax <code>[x](y)</code></h2>`,
+		`<h2 id="plain">1.4. Plain</h2>`,
+		`<h2 id="cmp">1.5. Compare a &lt; b &amp; &#34;c&#34;</h2>`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+}
+
+func TestAnchorTargetsHeading(t *testing.T) {
+	have := anchorTargets(`<h2 id="sec2_3">2.3. Design</h2>`)
+	want := []string{"sec2_3"}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+}
@@ -0,0 +1,305 @@
+package markproc
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlLineRe matches a line holding nothing but HTML tags or
+	// comments, e.g. the anchors passMkHeads inserts, which are passed
+	// through as they are.
+	htmlLineRe = regexp.MustCompile(`^\s*(<[^<>]+>\s*)+$`)
+	htmlTagRe  = regexp.MustCompile(`<[^<>]+>`)
+	ruleLineRe = regexp.MustCompile(`^ {0,3}(-(\s*-){2,}|\*(\s*\*){2,}|_(\s*_){2,})\s*$`)
+	// listLineRe matches a list item, capturing its indentation,
+	// marker and text.
+	listLineRe  = regexp.MustCompile(`^(\s*)([-+*]|\d+[.)])\s+(.*)$`)
+	tableRowRe  = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	tableRuleRe = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?\s*)?$`)
+	// escapedRefRe matches a character reference html.EscapeString
+	// escaped.
+	escapedRefRe = regexp.MustCompile(`&amp;((?:\w+|#\d+|#[xX][0-9a-fA-F]+);)`)
+	// inlineRe matches the inline markup RenderHTML understands: code
+	// spans, autolinks, raw HTML, links and images, and emphasis.
+	inlineRe = regexp.MustCompile("(?P<code>``(.+?)``|`([^`]+)`)" +
+		`|<(?P<auto>https?://[^<>\s]+)>` +
+		`|(?P<raw></?[A-Za-z][^<>]*>|<!--.*?-->)` +
+		`|(?P<link>(!?)\[([^\]]*)\]\(([^)\s]+)(?:\s+"([^"]*)")?\))` +
+		`|\*\*(?P<strong>.+?)\*\*|__(?P<strong2>.+?)__` +
+		`|\*(?P<em>[^*\s](?:[^*]*[^*\s])?)\*|\b_(?P<em2>[^_\s](?:[^_]*[^_\s])?)_\b`)
+)
+
+// RenderHTML writes the processed lines as a standalone HTML document
+// titled title, or the text of the first heading if title is empty,
+// with css, if not empty, in its <style> element.  Raw
+// HTML in the lines, such as the anchors and links markproc makes, is
+// kept as it is.  Only common Markdown is understood: headings,
+// paragraphs, lists, block quotes, code, pipe tables, rules, links,
+// images and emphasis.
+func RenderHTML(w io.Writer, lines []string, title, css string) (err error) {
+	if title == "" {
+		title = firstHeading(lines)
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	if css != "" {
+		fmt.Fprintf(&b, "<style>\n%s\n</style>\n", strings.TrimRight(css, "\n"))
+	}
+	b.WriteString("</head>\n<body>\n")
+	renderBlocks(&b, lines[frontMatterEnd(lines):])
+	b.WriteString("</body>\n</html>\n")
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// firstHeading returns the text of the first heading in lines, without
+// its markup, or "".
+func firstHeading(lines []string) string {
+	code := codeMask(lines)
+	for i, line := range lines {
+		if m := headerRegexp.FindStringSubmatch(line); m != nil && !code[i] {
+			title, _ := headingMarkers(m[2])
+			return strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(title, "")))
+		}
+	}
+	return ""
+}
+
+// renderBlocks writes the block-level HTML for lines to b.
+func renderBlocks(b *strings.Builder, lines []string) {
+	code := codeMask(lines)
+	para := []string{}
+	flush := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(b, "<p>%s</p>\n", renderInline(strings.Join(para, "\n")))
+			para = para[:0]
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case code[i]:
+			flush()
+			i = renderCode(b, lines, code, i)
+		case strings.TrimSpace(line) == "":
+			flush()
+		case htmlLineRe.MatchString(line) || htmlHeadingRe.MatchString(line):
+			flush()
+			b.WriteString(strings.TrimSpace(line) + "\n")
+		case headerRegexp.MatchString(line):
+			flush()
+			m := headerRegexp.FindStringSubmatch(line)
+			level := min(len(m[1]), 6)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, renderInline(strings.TrimRight(m[2], " #")), level)
+		case ruleLineRe.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+		case strings.HasPrefix(strings.TrimSpace(line), ">"):
+			flush()
+			quote := []string{}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				text := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(text, " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quote)
+			b.WriteString("</blockquote>\n")
+		case len(para) == 0 && listLineRe.MatchString(line):
+			i = renderList(b, lines, code, i)
+		case len(para) == 0 && tableRowRe.MatchString(line) && i+1 < len(lines) && tableRuleRe.MatchString(lines[i+1]):
+			i = renderTable(b, lines, i)
+		default:
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	flush()
+}
+
+// renderCode writes the code block starting at lines[i] and returns the
+// index of its last line.
+func renderCode(b *strings.Builder, lines []string, code []bool, i int) int {
+	class := ""
+	fenced := false
+	if m := fenceRegexp.FindStringSubmatch(lines[i]); m != nil {
+		fenced = true
+		if lang := strings.Fields(m[2]); len(lang) > 0 {
+			class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(lang[0]))
+		}
+		i++
+	}
+	body := []string{}
+	for ; i < len(lines) && code[i]; i++ {
+		if fenced {
+			if fenceRegexp.MatchString(lines[i]) {
+				break
+			}
+			body = append(body, lines[i])
+			continue
+		}
+		line := strings.TrimPrefix(lines[i], "\t")
+		if len(line) == len(lines[i]) {
+			line = strings.TrimPrefix(line, "    ")
+		}
+		body = append(body, line)
+	}
+	if !fenced {
+		// trailing blank lines belong to the text after the block
+		for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+			body = body[:len(body)-1]
+		}
+		i--
+	}
+	fmt.Fprintf(b, "<pre><code%s>", class)
+	for _, line := range body {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderList writes the list starting at lines[i], with any lists
+// nested in it, and returns the index of its last line.
+func renderList(b *strings.Builder, lines []string, code []bool, i int) int {
+	type open struct {
+		indent int
+		tag    string
+	}
+	stack := []open{}
+	for ; i < len(lines); i++ {
+		m := listLineRe.FindStringSubmatch(lines[i])
+		if m == nil || code[i] {
+			if len(stack) > 0 && strings.TrimSpace(lines[i]) != "" && !code[i] &&
+				!headerRegexp.MatchString(lines[i]) && !htmlLineRe.MatchString(lines[i]) && !htmlHeadingRe.MatchString(lines[i]) {
+				// a continuation of the item's text
+				fmt.Fprintf(b, "\n%s", renderInline(strings.TrimSpace(lines[i])))
+				continue
+			}
+			break
+		}
+		indent := len(m[1])
+		tag := "ul"
+		if m[2][0] >= '0' && m[2][0] <= '9' {
+			tag = "ol"
+		}
+		for len(stack) > 0 && indent < stack[len(stack)-1].indent {
+			top := stack[len(stack)-1]
+			fmt.Fprintf(b, "</li>\n</%s>\n", top.tag)
+			stack = stack[:len(stack)-1]
+		}
+		switch {
+		case len(stack) == 0 || indent > stack[len(stack)-1].indent:
+			if len(stack) > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(b, "<%s>\n<li>", tag)
+			stack = append(stack, open{indent, tag})
+		default:
+			b.WriteString("</li>\n<li>")
+		}
+		b.WriteString(renderInline(m[3]))
+	}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		fmt.Fprintf(b, "</li>\n</%s>\n", top.tag)
+		stack = stack[:len(stack)-1]
+	}
+	return i - 1
+}
+
+// renderTable writes the pipe table starting at lines[i] and returns
+// the index of its last line.
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	cells := func(line string) []string {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+		fields := strings.Split(line, "|")
+		for j := range fields {
+			fields[j] = renderInline(strings.TrimSpace(fields[j]))
+		}
+		return fields
+	}
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for _, cell := range cells(lines[i]) {
+		fmt.Fprintf(b, "<th>%s</th>", cell)
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && tableRowRe.MatchString(lines[i]); i++ {
+		b.WriteString("<tr>")
+		for _, cell := range cells(lines[i]) {
+			fmt.Fprintf(b, "<td>%s</td>", cell)
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i - 1
+}
+
+// escapeInline returns text escaped for HTML, keeping the character
+// references in it, as markdown renders them.
+func escapeInline(text string) string {
+	return escapedRefRe.ReplaceAllString(html.EscapeString(text), "&$1")
+}
+
+// renderInline returns the HTML for the inline markup in text.  Text
+// escaped by the author or passSanitize isn't escaped again.
+func renderInline(text string) string {
+	var b strings.Builder
+	names := inlineRe.SubexpNames()
+	for text != "" {
+		m := inlineRe.FindStringSubmatchIndex(text)
+		if m == nil {
+			b.WriteString(escapeInline(text))
+			break
+		}
+		b.WriteString(escapeInline(text[:m[0]]))
+		group := func(name string) (string, bool) {
+			for j, n := range names {
+				if n == name && m[2*j] >= 0 {
+					return text[m[2*j]:m[2*j+1]], true
+				}
+			}
+			return "", false
+		}
+		sub := func(j int) string {
+			if m[2*j] < 0 {
+				return ""
+			}
+			return text[m[2*j]:m[2*j+1]]
+		}
+		if _, ok := group("code"); ok {
+			code := sub(2) + sub(3)
+			fmt.Fprintf(&b, "<code>%s</code>", html.EscapeString(strings.TrimSpace(code)))
+		} else if url, ok := group("auto"); ok {
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(url))
+		} else if raw, ok := group("raw"); ok {
+			b.WriteString(raw)
+		} else if _, ok := group("link"); ok {
+			image, label, href, title := sub(7) != "", sub(8), sub(9), sub(10)
+			attr := ""
+			if title != "" {
+				attr = fmt.Sprintf(` title="%s"`, html.EscapeString(title))
+			}
+			if image {
+				fmt.Fprintf(&b, `<img src="%s" alt="%s"%s>`, html.EscapeString(href), html.EscapeString(label), attr)
+			} else {
+				fmt.Fprintf(&b, `<a href="%s"%s>%s</a>`, html.EscapeString(href), attr, renderInline(label))
+			}
+		} else if s, ok := group("strong"); ok {
+			fmt.Fprintf(&b, "<strong>%s</strong>", renderInline(s))
+		} else if s, ok := group("strong2"); ok {
+			fmt.Fprintf(&b, "<strong>%s</strong>", renderInline(s))
+		} else if s, ok := group("em"); ok {
+			fmt.Fprintf(&b, "<em>%s</em>", renderInline(s))
+		} else if s, ok := group("em2"); ok {
+			fmt.Fprintf(&b, "<em>%s</em>", renderInline(s))
+		}
+		text = text[m[1]:]
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package markproc
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRenderHTML(t *testing.T) {
+	lines := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"Some *emphasis* and `a < b` code,",
+		`see [<a href="#sec2">sec 2</a>] and [docs](http://example.com "Docs").`,
+		"",
+		"- one",
+		"  - nested **bold**",
+		"- two",
+		"",
+		"```go",
+		"x := <-ch",
+		"```",
+		"",
+		"| A | B |",
+		"|---|---|",
+		"| 1 | 2 |",
+		"",
+		"> quoted",
+	}
+	var b strings.Builder
+	err := RenderHTML(&b, lines, "Spec", "body { margin: 0 }")
+	Ck(err)
+	want := `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Spec</title>
+<style>
+body { margin: 0 }
+</style>
+</head>
+<body>
+<a name="sec1"></a>
+<h1>1. Intro</h1>
+<p>Some <em>emphasis</em> and <code>a &lt; b</code> code,
+see [<a href="#sec2">sec 2</a>] and <a href="http://example.com" title="Docs">docs</a>.</p>
+<ul>
+<li>one
+<ul>
+<li>nested <strong>bold</strong></li>
+</ul>
+</li>
+<li>two</li>
+</ul>
+<pre><code class="language-go">x := &lt;-ch
+</code></pre>
+<table>
+<thead>
+<tr><th>A</th><th>B</th></tr>
+</thead>
+<tbody>
+<tr><td>1</td><td>2</td></tr>
+</tbody>
+</table>
+<blockquote>
+<p>quoted</p>
+</blockquote>
+</body>
+</html>
+`
+	Tassert(t, b.String() == want, "\nwant: %s\nhave: %s", want, b.String())
+}
+
+func TestFirstHeading(t *testing.T) {
+	lines := []string{"```", "# not a heading", "```", `## 1. A <em>Big</em> Deal <!-- nonum -->`}
+	have := firstHeading(lines)
+	Tassert(t, have == "1. A Big Deal", "have %q", have)
+}
@@ -0,0 +1,212 @@
+package markproc
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// includeRe matches an include directive, <!-- include: PATH -->
+	// or {{include PATH}}, either optionally followed by shift=N.
+	includeRe = regexp.MustCompile(`^\s*(?:<!--\s*include:\s*(\S+)(?:\s+shift=([+-]?\d+))?\s*-->|\{\{\s*include\s+([^\s}]+)(?:\s+shift=([+-]?\d+))?\s*\}\})\s*$`)
+	// atxHeadingRe matches the hashes of an ATX heading.
+	atxHeadingRe = regexp.MustCompile(`^(#{1,6})(\s|$)`)
+)
+
+// includeEnd returns the comment passInclude puts after the contents
+// of the file at path.
+func includeEnd(path string) string {
+	return fmt.Sprintf("<!-- /include: %s -->", path)
+}
+
+// include is one include directive.
+type include struct {
+	path  string
+	shift int
+}
+
+// parseInclude returns the include directive on line, if any.
+func parseInclude(line string) (inc include, ok bool) {
+	m := includeRe.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	inc.path, ok = m[1], true
+	shift := m[2]
+	if m[3] != "" {
+		inc.path, shift = m[3], m[4]
+	}
+	if shift != "" {
+		inc.shift, _ = strconv.Atoi(shift)
+	}
+	return
+}
+
+// String returns the directive as passInclude writes it.
+func (inc include) String() string {
+	if inc.shift != 0 {
+		return fmt.Sprintf("<!-- include: %s shift=%d -->", inc.path, inc.shift)
+	}
+	return fmt.Sprintf("<!-- include: %s -->", inc.path)
+}
+
+// passInclude replaces each include directive, <!-- include: PATH -->
+// or {{include PATH}}, with the contents of the file it names, read
+// from p.IncludeFS, so that the other passes see one document.  PATH is
+// relative to the directory of the including file, or to the root of
+// IncludeFS if it starts with a slash.  shift=N after the path moves
+// the headings of the included file N levels down, or up if N is
+// negative.  Included files may include others, up to
+// Limits.MaxIncludeDepth deep; an include cycle is an error.  The
+// contents are put between the directive, rewritten in the comment
+// form, and a <!-- /include: PATH --> comment so that a later run
+// replaces them with the current contents.  Warnings about included
+// lines give the line of the directive.
+func (p *Processor) passInclude(lines []string) []string {
+	out := p.newLineWriter(lines)
+	base := p.IncludePath
+	if p.project != nil {
+		base = p.project.current
+	}
+	base = filepath.ToSlash(base)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inc, ok := parseInclude(line)
+		if code[i] || !ok {
+			out.add(i, line)
+			continue
+		}
+		// a directive processed before is followed by the contents it
+		// included then
+		end := skipBlock(lines, i, includeEnd(inc.path))
+		included, ok := p.includeFile(i, inc, base, []string{base}, 0)
+		if !ok {
+			out.add(i, line)
+			continue
+		}
+		out.add(i, inc.String())
+		out.add(i, included...)
+		out.add(i, includeEnd(inc.path))
+		i = end
+	}
+	return p.done(out)
+}
+
+// includeFile returns the lines of the file inc, found on line i of
+// the input, names relative to the file from, with their own include
+// directives expanded and their headings shifted by shift plus
+// inc.shift levels.  stack holds the files including it, outermost
+// first.
+func (p *Processor) includeFile(i int, inc include, from string, stack []string, shift int) (lines []string, ok bool) {
+	name := path.Join(path.Dir(from), inc.path)
+	if strings.HasPrefix(inc.path, "/") {
+		name = path.Clean(strings.TrimPrefix(inc.path, "/"))
+	}
+	if !fs.ValidPath(name) {
+		p.warnf("include-invalid", i, inc.path, "Included file %s is outside the include root", inc.path)
+		p.fail("include failed")
+		return nil, false
+	}
+	for _, outer := range stack {
+		if path.Clean(outer) == name {
+			chain := append([]string{}, stack...)
+			if chain[0] == "" {
+				chain[0] = "the document"
+			}
+			p.warnf("include-cycle", i, inc.path, "Include cycle: %s", strings.Join(append(chain, name), " -> "))
+			p.fail("include failed")
+			return nil, false
+		}
+	}
+	if max := p.Limits.MaxIncludeDepth; max > 0 && len(stack) > max {
+		p.warnf("include-depth", i, inc.path, "Includes nested more than %d deep at %s", max, name)
+		p.fail("include failed")
+		return nil, false
+	}
+	f, err := p.IncludeFS.Open(name)
+	if err == nil {
+		lines, err = ReadLimited(f, p.Limits.MaxInputSize)
+		f.Close()
+	}
+	if err != nil {
+		p.warnf("include-missing", i, inc.path, "Can't include %s: %v", inc.path, err)
+		p.fail("include failed")
+		return nil, false
+	}
+
+	shift += inc.shift
+	lines = lines[frontMatterEnd(lines):]
+	code := codeMask(lines)
+	expanded := []string{}
+	for j, line := range lines {
+		if code[j] {
+			expanded = append(expanded, line)
+			continue
+		}
+		if nested, ok := parseInclude(line); ok {
+			sub, ok := p.includeFile(i, nested, name, append(stack, name), shift)
+			if !ok {
+				return nil, false
+			}
+			expanded = append(expanded, sub...)
+			continue
+		}
+		expanded = append(expanded, shiftHeading(line, shift))
+	}
+	return expanded, true
+}
+
+// passShiftHeadings moves every heading of the document p.ShiftHeadings
+// levels down, or up if it is negative, before the headings are
+// numbered.  Headings numbered by an earlier run were shifted then and
+// are left alone.
+func (p *Processor) passShiftHeadings(lines []string) []string {
+	out := make([]string, len(lines))
+	code := codeMask(lines)
+	appendix := p.appendixMask(lines)
+	for i, line := range lines {
+		if code[i] || p.prevNumbered(line, appendix[i]) != nil {
+			out[i] = line
+			continue
+		}
+		out[i] = shiftHeading(line, p.ShiftHeadings)
+	}
+	return out
+}
+
+// shiftHeading returns line with its level moved shift levels down if
+// it is an ATX heading, keeping it between H1 and H6.
+func shiftHeading(line string, shift int) string {
+	m := atxHeadingRe.FindStringSubmatch(line)
+	if m == nil || shift == 0 {
+		return line
+	}
+	level := len(m[1]) + shift
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	return strings.Repeat("#", level) + line[len(m[1]):]
+}
+
+// stripIncludes removes the contents passInclude put after each include
+// directive of lines, leaving the directive.
+func stripIncludes(lines []string) []string {
+	out := []string{}
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if inc, ok := parseInclude(lines[i]); ok && !code[i] {
+			i = skipBlock(lines, i, includeEnd(inc.path))
+		}
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestInclude(t *testing.T) {
+	files := fstest.MapFS{
+		"book/ch1.md":          {Data: []byte("---\ntitle: One\n---\n# Intro\nSee [sec dsgn].\n<!-- include: parts/design.md -->\n")},
+		"book/parts/design.md": {Data: []byte("# Design\n```\n# not a heading\n```\n")},
+		"book/loop.md":         {Data: []byte("<!-- include: loop2.md -->\n")},
+		"book/loop2.md":        {Data: []byte("{{include /book/loop.md}}\n")},
+	}
+	opts := DefaultOptions()
+	opts.IncludeFS = files
+	opts.IncludePath = "book/main.md"
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Book",
+		"{{include ch1.md shift=1}}",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Book",
+		"<!-- include: ch1.md shift=1 -->",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Intro",
+		`See [<a href="#sec1_2">sec 1.2</a>].`,
+		`<a name="sec1_2"></a>`,
+		"## 1.2. Design",
+		"```",
+		"# not a heading",
+		"```",
+		"<!-- /include: ch1.md -->",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	// included lines are reported at the directive
+	Tassert(t, p.lineOf(5) == 2, "want line 2, have %d", p.lineOf(5))
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+	stripped := p.Strip(out)
+	wantStripped := []string{"# Book", "<!-- include: ch1.md shift=1 -->"}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+
+	p.Stderr = io.Discard
+	for _, tc := range []struct {
+		line, rule string
+	}{
+		{"<!-- include: loop.md -->", "include-cycle"},
+		{"<!-- include: missing.md -->", "include-missing"},
+		{"<!-- include: ../../etc/passwd -->", "include-invalid"},
+	} {
+		_, err = p.Process([]string{tc.line})
+		Tassert(t, err != nil, "%s: want error", tc.line)
+		warnings := p.Warnings()
+		Tassert(t, len(warnings) == 1 && warnings[0].Rule == tc.rule, "%s: want one %s warning, have %v", tc.line, tc.rule, warnings)
+	}
+	Tassert(t, strings.Contains(p.Warnings()[0].Message, "outside the include root"), "have %q", p.Warnings()[0].Message)
+
+	p.Limits.MaxIncludeDepth = 1
+	_, err = p.Process([]string{"<!-- include: loop.md -->"})
+	Tassert(t, err != nil && p.Warnings()[0].Rule == "include-depth", "want include-depth error, have %v", p.Warnings())
+}
+
+func TestShiftHeading(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		shift int
+		want  string
+	}{
+		{"# Title", 1, "## Title"},
+		{"### Title", -1, "## Title"},
+		{"# Title", -3, "# Title"},
+		{"##### Title", 3, "###### Title"},
+		{"#hashtag", 1, "#hashtag"},
+		{"Text", 1, "Text"},
+	} {
+		got := shiftHeading(tc.line, tc.shift)
+		Tassert(t, got == tc.want, "shiftHeading(%q, %d): want %q, have %q", tc.line, tc.shift, tc.want, got)
+	}
+}
+
+func TestShiftHeadings(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ShiftHeadings = -1
+	p := NewProcessor(opts)
+	lines := []string{
+		"## Chapter",
+		"### Part",
+		"```",
+		"# comment",
+		"```",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Chapter",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Part",
+		"```",
+		"# comment",
+		"```",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+}
@@ -0,0 +1,314 @@
+package markproc
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	hrefRe = regexp.MustCompile(`<a href="([^"#]*)#([^"]+)">`)
+	// pageLinkRe matches a markdown link or href to another file of the
+	// project, without an anchor.
+	pageLinkRe = regexp.MustCompile(`(?:\]\(|<a href=")([^"#():/\s]+\.\w+)[")]`)
+)
+
+// Index is an in-memory index of the anchors defined and the links
+// made by each file of a project.  It is meant to be kept across
+// rebuilds: when one file changes, Update replaces only that file's
+// entries and reports which files have links that need re-verifying.
+// An Index is safe for concurrent use.
+type Index struct {
+	mu    sync.RWMutex
+	files map[string]*fileEntry
+	// aliases maps absolute hrefs to the files they go to.
+	aliases map[string]string
+}
+
+// fileEntry holds the indexed content of one processed file.
+type fileEntry struct {
+	// sum is the checksum of the lines indexed.
+	sum     [sha256.Size]byte
+	anchors map[string]bool
+	links   []Link
+	// pages holds the files linked to without an anchor.
+	pages []string
+	// result is what verifying the file last found, if it was verified
+	// since it was indexed.
+	result *verifyResult
+}
+
+// verifyResult holds what verifying one file of a project found, to be
+// reported again while neither the file nor the anchors its links point
+// to change.
+type verifyResult struct {
+	// sum is the checksum of the source the file was processed from.
+	sum      [sha256.Size]byte
+	errs     []error
+	warnings []Warning
+	stderr   []byte
+}
+
+// Link is a link found in a processed file.  File is empty for links
+// within the same file.  Line is the 1-based line of the processed file
+// the link is on.
+type Link struct {
+	File   string
+	Anchor string
+	Line   int
+}
+
+// NewIndex returns an empty Index.
+func NewIndex() *Index {
+	return &Index{files: map[string]*fileEntry{}, aliases: map[string]string{}}
+}
+
+// Alias records that links to href, e.g. a static site's permalink,
+// go to the file at path.
+func (x *Index) Alias(href, path string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.aliases[href] = path
+}
+
+// Update indexes the processed lines of the file at path, replacing any
+// previous entries for it.  It returns the files, path included, whose
+// links may resolve differently because anchors were added to or
+// removed from path.
+func (x *Index) Update(path string, lines []string) (affected []string) {
+	entry := &fileEntry{sum: linesSum(lines), anchors: map[string]bool{}}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		for _, name := range anchorTargets(line) {
+			entry.anchors[name] = true
+		}
+		for _, m := range hrefRe.FindAllStringSubmatch(line, -1) {
+			entry.links = append(entry.links, Link{File: m[1], Anchor: m[2], Line: i + 1})
+		}
+		for _, m := range pageLinkRe.FindAllStringSubmatch(line, -1) {
+			entry.pages = append(entry.pages, m[1])
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	changed := map[string]bool{}
+	if old, ok := x.files[path]; ok {
+		for name := range old.anchors {
+			if !entry.anchors[name] {
+				changed[name] = true
+			}
+		}
+	}
+	for name := range entry.anchors {
+		if old, ok := x.files[path]; !ok || !old.anchors[name] {
+			changed[name] = true
+		}
+	}
+	x.files[path] = entry
+	return x.affected(path, changed)
+}
+
+// Sync brings the index up to date with docs, the processed files of
+// a project: the files whose lines changed since they were indexed are
+// updated and the files no longer among docs removed, the others left
+// as they are.  It returns the files, sorted, whose links may resolve
+// differently.
+func (x *Index) Sync(docs []Document) (affected []string) {
+	set := map[string]bool{}
+	current := map[string]bool{}
+	for _, doc := range docs {
+		current[doc.Path] = true
+		x.mu.RLock()
+		old, ok := x.files[doc.Path]
+		x.mu.RUnlock()
+		if ok && old.sum == linesSum(doc.Lines) {
+			continue
+		}
+		for _, path := range x.Update(doc.Path, doc.Lines) {
+			set[path] = true
+		}
+	}
+	for _, path := range x.Files() {
+		if !current[path] {
+			for _, f := range x.Remove(path) {
+				set[f] = true
+			}
+		}
+	}
+	for path := range set {
+		if current[path] {
+			affected = append(affected, path)
+		}
+	}
+	sort.Strings(affected)
+	return
+}
+
+// verified returns what verifying the file at path found, if it was
+// verified since it was indexed and processed from source with the
+// checksum sum.
+func (x *Index) verified(path string, sum [sha256.Size]byte) (*verifyResult, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	entry, ok := x.files[path]
+	if !ok || entry.result == nil || entry.result.sum != sum {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// setVerified records what verifying the file at path found.
+func (x *Index) setVerified(path string, result *verifyResult) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if entry, ok := x.files[path]; ok {
+		entry.result = result
+	}
+}
+
+// Files returns the indexed files, sorted.
+func (x *Index) Files() (files []string) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for path := range x.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return
+}
+
+// linesSum returns the checksum of lines.
+func linesSum(lines []string) [sha256.Size]byte {
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// Remove drops the file at path from the index and returns the files
+// whose links pointed into it.
+func (x *Index) Remove(path string) (affected []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	old, ok := x.files[path]
+	if !ok {
+		return nil
+	}
+	affected = x.affected(path, old.anchors)
+	delete(x.files, path)
+	// path itself no longer needs verifying
+	for i, f := range affected {
+		if f == path {
+			affected = append(affected[:i], affected[i+1:]...)
+			break
+		}
+	}
+	return
+}
+
+// affected returns path plus the files linking to any of the named
+// anchors in path.  The caller must hold x.mu.
+func (x *Index) affected(path string, names map[string]bool) []string {
+	set := map[string]bool{path: true}
+	if len(names) > 0 {
+		for from, entry := range x.files {
+			for _, link := range entry.links {
+				if names[link.Anchor] && x.resolve(from, link.File) == path {
+					set[from] = true
+					break
+				}
+			}
+		}
+	}
+	files := []string{}
+	for f := range set {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// resolve returns the indexed path that a link from the file at from
+// to file refers to, or "" if there is none.  Links name files relative
+// to the linking file, and may use a different extension than the
+// indexed source, e.g. chapter2.html for chapter2.md.
+func (x *Index) resolve(from, file string) string {
+	if file == "" {
+		return from
+	}
+	if path, ok := x.aliases[file]; ok {
+		return path
+	}
+	want := stripExt(filepath.Join(filepath.Dir(from), file))
+	for path := range x.files {
+		if stripExt(path) == want {
+			return path
+		}
+	}
+	return ""
+}
+
+func stripExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// Broken returns the links in the file at path that don't resolve to
+// an anchor in the index.
+func (x *Index) Broken(path string) (broken []Link) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	entry, ok := x.files[path]
+	if !ok {
+		return nil
+	}
+	for _, link := range entry.links {
+		target, ok := x.files[x.resolve(path, link.File)]
+		if !ok || !target.anchors[link.Anchor] {
+			broken = append(broken, link)
+		}
+	}
+	return
+}
+
+// Unreachable returns the indexed files, sorted, that can't be reached
+// by following links from the file at root, e.g. pages no longer
+// linked from anywhere.
+func (x *Index) Unreachable(root string) (files []string) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	seen := map[string]bool{}
+	queue := []string{root}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		entry, ok := x.files[path]
+		if !ok || seen[path] {
+			continue
+		}
+		seen[path] = true
+		for _, link := range entry.links {
+			queue = append(queue, x.resolve(path, link.File))
+		}
+		for _, page := range entry.pages {
+			queue = append(queue, x.resolve(path, page))
+		}
+	}
+	for path := range x.files {
+		if !seen[path] {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return
+}
@@ -0,0 +1,69 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestIndex(t *testing.T) {
+	x := NewIndex()
+	x.Update("docs/a.md", []string{
+		`<a name="sec1"></a>`,
+		`# 1. A`,
+		`See [<a href="b.html#sec1">sec 1</a>].`,
+	})
+	x.Update("docs/b.md", []string{
+		`<a name="sec1"></a>`,
+		`# 1. B`,
+		`<a name="sec1_1"></a>`,
+		`## 1.1. B sub`,
+	})
+	x.Update("docs/c.md", []string{
+		`See [<a href="b.html#sec1_1">sec 1.1</a>].`,
+	})
+	Tassert(t, len(x.Broken("docs/a.md")) == 0, "have %v", x.Broken("docs/a.md"))
+	Tassert(t, len(x.Broken("docs/c.md")) == 0, "have %v", x.Broken("docs/c.md"))
+
+	// editing b without changing its anchors affects only b
+	affected := x.Update("docs/b.md", []string{
+		`<a name="sec1"></a>`,
+		`# 1. B renamed`,
+		`<a name="sec1_1"></a>`,
+		`## 1.1. B sub`,
+	})
+	Tassert(t, reflect.DeepEqual(affected, []string{"docs/b.md"}), "have %v", affected)
+
+	// removing an anchor affects the files linking to it
+	affected = x.Update("docs/b.md", []string{
+		`<a name="sec1"></a>`,
+		`# 1. B`,
+	})
+	Tassert(t, reflect.DeepEqual(affected, []string{"docs/b.md", "docs/c.md"}), "have %v", affected)
+	broken := x.Broken("docs/c.md")
+	Tassert(t, reflect.DeepEqual(broken, []Link{{File: "b.html", Anchor: "sec1_1", Line: 1}}), "have %v", broken)
+
+	affected = x.Remove("docs/b.md")
+	Tassert(t, reflect.DeepEqual(affected, []string{"docs/a.md"}), "have %v", affected)
+	Tassert(t, len(x.Broken("docs/a.md")) == 1, "have %v", x.Broken("docs/a.md"))
+}
+
+func TestIndexSync(t *testing.T) {
+	x := NewIndex()
+	docs := []Document{
+		{Path: "a.md", Lines: []string{`See <a href="b.html#x">x</a>.`}},
+		{Path: "b.md", Lines: []string{`<a name="x"></a>`}},
+		{Path: "c.md", Lines: []string{"C"}},
+	}
+	affected := x.Sync(docs)
+	Tassert(t, reflect.DeepEqual(affected, []string{"a.md", "b.md", "c.md"}), "have %v", affected)
+	affected = x.Sync(docs)
+	Tassert(t, len(affected) == 0, "unchanged files affected: %v", affected)
+
+	docs[1].Lines = []string{`<a name="y"></a>`}
+	affected = x.Sync(docs[:2])
+	Tassert(t, reflect.DeepEqual(affected, []string{"a.md", "b.md"}), "have %v", affected)
+	Tassert(t, reflect.DeepEqual(x.Files(), []string{"a.md", "b.md"}), "have %v", x.Files())
+	Tassert(t, len(x.Broken("a.md")) == 1, "have %v", x.Broken("a.md"))
+}
@@ -0,0 +1,140 @@
+package markproc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Line ending styles for writing documents.
+const (
+	// LineEndingAuto writes a document with the line endings it was
+	// read with.
+	LineEndingAuto = "auto"
+	// LineEndingLF ends each line with \n.
+	LineEndingLF = "lf"
+	// LineEndingCRLF ends each line with \r\n, as Windows editors do.
+	LineEndingCRLF = "crlf"
+)
+
+// LineEndings lists the valid line ending styles.
+var LineEndings = []string{LineEndingAuto, LineEndingLF, LineEndingCRLF}
+
+// bom is the UTF-8 encoding of the byte order mark some Windows editors
+// start files with.
+const bom = "\ufeff"
+
+// TextStyle records how a text file is encoded beyond its lines:
+// whether they end in \r\n and whether it starts with a byte order
+// mark.  ReadLines drops both, so that the passes see plain lines, and
+// WriteLines writes neither; ReadText and TextStyle.WriteLines keep
+// them.
+type TextStyle struct {
+	CRLF bool
+	BOM  bool
+}
+
+// DetectTextStyle returns the style of buf: CRLF if most of its line
+// endings are \r\n, and BOM if it starts with a byte order mark.
+func DetectTextStyle(buf []byte) (style TextStyle) {
+	lf := bytes.Count(buf, []byte("\n"))
+	crlf := bytes.Count(buf, []byte("\r\n"))
+	return TextStyle{CRLF: crlf > 0 && crlf*2 >= lf, BOM: bytes.HasPrefix(buf, []byte(bom))}
+}
+
+// WithLineEnding returns s with the line ending named by ending, one of
+// LineEndings, or s itself for LineEndingAuto.
+func (s TextStyle) WithLineEnding(ending string) TextStyle {
+	switch ending {
+	case LineEndingLF:
+		s.CRLF = false
+	case LineEndingCRLF:
+		s.CRLF = true
+	}
+	return s
+}
+
+// WriteLines writes lines to w in style s.
+func (s TextStyle) WriteLines(w io.Writer, lines []string) (err error) {
+	writer := bufio.NewWriter(w)
+	if s.BOM {
+		_, err = writer.WriteString(bom)
+		if err != nil {
+			return
+		}
+	}
+	eol := "\n"
+	if s.CRLF {
+		eol = "\r\n"
+	}
+	for _, line := range lines {
+		_, err = writer.WriteString(line + eol)
+		if err != nil {
+			return
+		}
+	}
+	return writer.Flush()
+}
+
+// ReadText is like ReadLimited, but also returns the style of the text,
+// so that the processed lines can be written back the same way.
+func ReadText(r io.Reader, maxSize int64) (lines []string, style TextStyle, err error) {
+	if maxSize > 0 {
+		r = &io.LimitedReader{R: r, N: maxSize + 1}
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	if maxSize > 0 && int64(len(buf)) > maxSize {
+		err = fmt.Errorf("input exceeds maximum size of %d bytes", maxSize)
+		return
+	}
+	lines, err = ReadLines(bytes.NewReader(buf))
+	return lines, DetectTextStyle(buf), err
+}
+
+// plainLines returns lines without a byte order mark at the start of
+// the first or a carriage return at the end of any, for documents not
+// read with ReadLines.  It returns lines itself if neither is there.
+func plainLines(lines []string) []string {
+	var out []string
+	for i, line := range lines {
+		clean := strings.TrimSuffix(line, "\r")
+		if i == 0 {
+			clean = strings.TrimPrefix(clean, bom)
+		}
+		if clean != line && out == nil {
+			out = append([]string{}, lines...)
+		}
+		if out != nil {
+			out[i] = clean
+		}
+	}
+	if out == nil {
+		return lines
+	}
+	return out
+}
+
+// scannedLine returns the line scanner read, line i of its input,
+// without the byte order mark the first may start with.
+func scannedLine(scanner *bufio.Scanner, i int) string {
+	if i == 0 {
+		return strings.TrimPrefix(scanner.Text(), bom)
+	}
+	return scanner.Text()
+}
+
+// newLineScanner returns a scanner of the lines of r.  Unlike
+// bufio.NewScanner's, it takes lines of any length, such as embedded
+// minified HTML or long table rows, rather than failing on those over
+// 64KB; Limits.MaxInputSize is what bounds the input.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, math.MaxInt)
+	return scanner
+}
@@ -0,0 +1,70 @@
+package markproc
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestReadTextCRLF(t *testing.T) {
+	in := "\ufeff# Top\r\nSee [sec dsgn].\r\n## Design\r\n"
+	lines, style, err := ReadText(strings.NewReader(in), 0)
+	Tassert(t, err == nil, "ReadText failed: %v", err)
+	want := []string{"# Top", "See [sec dsgn].", "## Design"}
+	Tassert(t, reflect.DeepEqual(lines, want), "\nwant: %q\nhave: %q", want, lines)
+	Tassert(t, style == TextStyle{CRLF: true, BOM: true}, "have %+v", style)
+
+	var b bytes.Buffer
+	err = style.WriteLines(&b, lines)
+	Tassert(t, err == nil, "WriteLines failed: %v", err)
+	Tassert(t, b.String() == in, "\nwant: %q\nhave: %q", in, b.String())
+
+	b.Reset()
+	err = style.WithLineEnding(LineEndingLF).WriteLines(&b, lines)
+	Tassert(t, err == nil, "WriteLines failed: %v", err)
+	wantLF := "\ufeff# Top\nSee [sec dsgn].\n## Design\n"
+	Tassert(t, b.String() == wantLF, "\nwant: %q\nhave: %q", wantLF, b.String())
+
+	_, _, err = ReadText(strings.NewReader(in), 10)
+	Tassert(t, err != nil, "ReadText ignored the size limit")
+
+	style = DetectTextStyle([]byte("a\nb\r\nc\n"))
+	Tassert(t, style == TextStyle{}, "have %+v", style)
+}
+
+func TestProcessCRLF(t *testing.T) {
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process([]string{"\ufeff# Top\r", "See [sec dsgn].\r", "## Design\r"})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Top",
+		`See [<a href="#sec1_1">sec 1.1</a>].`,
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Design",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+}
+
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("<td>x</td>", 1<<20/10)
+	in := "# Top\n" + long + "\nSee [sec dsgn].\n## Design\n"
+	lines, err := ReadLines(strings.NewReader(in))
+	Tassert(t, err == nil, "ReadLines failed: %v", err)
+	Tassert(t, len(lines) == 4 && lines[1] == long, "have %d lines", len(lines))
+
+	opts := StreamOptions()
+	p := NewProcessor(opts)
+	want, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(want) == 6 && want[2] == long, "have %d lines", len(want))
+
+	var b bytes.Buffer
+	err = p.ProcessStream(strings.NewReader(in), &b)
+	Tassert(t, err == nil, "ProcessStream failed: %v", err)
+	have := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	Tassert(t, reflect.DeepEqual(have, want), "ProcessStream output differs from Process")
+}
@@ -0,0 +1,67 @@
+package markproc
+
+import "strings"
+
+// lineWriter collects the output lines of a pass that inserts or
+// removes lines, recording for each the input line it came from so that
+// warnings can give locations in the original document.
+type lineWriter struct {
+	lines  []string
+	origin []int
+	// from holds the original line numbers of the pass's input lines.
+	from []int
+}
+
+// newLineWriter returns a lineWriter for a pass over lines.
+func (p *Processor) newLineWriter(lines []string) *lineWriter {
+	w := &lineWriter{lines: []string{}}
+	if len(p.origin) == len(lines) {
+		w.from = p.origin
+	}
+	return w
+}
+
+// add appends lines to the output, recording that they came from input
+// line i.
+func (w *lineWriter) add(i int, lines ...string) {
+	line := i + 1
+	if w.from != nil {
+		line = w.from[i]
+	}
+	for _, l := range lines {
+		w.lines = append(w.lines, l)
+		w.origin = append(w.origin, line)
+	}
+}
+
+// trimBlank removes the blank lines at the end of the output.
+func (w *lineWriter) trimBlank() {
+	for n := len(w.lines); n > 0 && strings.TrimSpace(w.lines[n-1]) == ""; n-- {
+		w.lines, w.origin = w.lines[:n-1], w.origin[:n-1]
+	}
+}
+
+// done records the origins of the output lines in p and returns them.
+func (p *Processor) done(w *lineWriter) []string {
+	p.origin = w.origin
+	return w.lines
+}
+
+// lineOf returns the 1-based line number in the original document of
+// line i of the current pass's input.
+func (p *Processor) lineOf(i int) int {
+	if i >= 0 && i < len(p.origin) {
+		return p.origin[i]
+	}
+	return i + 1
+}
+
+// identity returns the line origins of an unprocessed document of n
+// lines.
+func identity(n int) []int {
+	origin := make([]int, n)
+	for i := range origin {
+		origin[i] = i + 1
+	}
+	return origin
+}
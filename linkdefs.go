@@ -0,0 +1,88 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// linkDefRe matches a markdown link reference definition: the groups
+// are the ref and the destination, which may be followed by a title.
+var linkDefRe = regexp.MustCompile(`^\[(\w+)\]:\s+(<[^<>]*>|\S+)(?:\s+(?:"[^"]*"|'[^']*'|\([^()]*\)))?\s*$`)
+
+// The directives passLinkDefs puts around link definitions.
+const (
+	linkDefsStart = "<!-- markproc: link-defs -->"
+	linkDefsEnd   = "<!-- markproc: end-link-defs -->"
+)
+
+// isLinkDef reports whether line is a markdown link reference
+// definition, [foo]: https://example.com "Title", rather than a
+// bibliographic [ref]: definition.  With p.BibPrefix set, every
+// definition whose ref doesn't start with it is a link definition;
+// otherwise those giving nothing but a URL or path, and maybe a title,
+// are.
+func (p *Processor) isLinkDef(line string) bool {
+	if p.BibPrefix != "" {
+		m := extLinkRegexp.FindStringSubmatch(line)
+		return m != nil && !strings.HasPrefix(m[1], p.BibPrefix)
+	}
+	m := linkDefRe.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	dest := m[2]
+	return strings.HasPrefix(dest, "<") || strings.Contains(dest, "://") ||
+		strings.HasPrefix(dest, "mailto:") || strings.ContainsAny(dest[:1], "/.#")
+}
+
+// passLinkDefs brackets each run of link reference definitions with
+// <!-- markproc: link-defs --> and <!-- markproc: end-link-defs -->
+// directives, so that the other passes leave them to the renderer like
+// a code block instead of anchoring them as references.  The anchor an
+// earlier run put before one is removed.  unwrap removes the directives
+// again.
+func (p *Processor) passLinkDefs(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	in := false
+	for i, line := range lines {
+		if m := anchorLineRe.FindStringSubmatch(line); m != nil && !code[i] && i+1 < len(lines) && !code[i+1] &&
+			strings.HasPrefix(lines[i+1], "["+m[1]+"]:") && p.isLinkDef(lines[i+1]) {
+			continue
+		}
+		def := !code[i] && p.isLinkDef(line)
+		if def && !in {
+			out.add(i, linkDefsStart)
+		}
+		if !def && in {
+			out.add(i, linkDefsEnd)
+		}
+		in = def
+		out.add(i, line)
+	}
+	if in {
+		out.add(len(lines)-1, linkDefsEnd)
+	}
+	return p.done(out)
+}
+
+// linkDefs returns the refs lines defines with link reference
+// definitions, as bracketed by passLinkDefs.  [ref] references to them
+// are markdown's shortcut reference links, left for the renderer.
+func linkDefs(lines []string) map[string]bool {
+	refs := map[string]bool{}
+	in := false
+	for _, line := range lines {
+		switch line {
+		case linkDefsStart:
+			in = true
+		case linkDefsEnd:
+			in = false
+		default:
+			if m := extLinkRegexp.FindStringSubmatch(line); m != nil && in {
+				refs[m[1]] = true
+			}
+		}
+	}
+	return refs
+}
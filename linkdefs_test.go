@@ -0,0 +1,64 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestLinkDefs(t *testing.T) {
+	lines := []string{
+		"See [site] and [rfc1] for more.",
+		"",
+		`[site]: https://example.com "Example"`,
+		"[rfc1]: Request for Comments 1.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	have, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect := []string{
+		`See [site] and [<a href="#rfc1">rfc1</a>] for more.`,
+		"",
+		`[site]: https://example.com "Example"`,
+		`<a name="rfc1"></a>`,
+		"[rfc1]: Request for Comments 1.",
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+
+	// the anchor an earlier run put before a link definition goes
+	old := append([]string{}, expect...)
+	old[2] = `<a name="site"></a>`
+	old = append(old[:3], append([]string{`[site]: https://example.com "Example"`}, old[3:]...)...)
+	again, err := p.Process(old)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(expect, again), "\nwant: %q\nhave: %q", expect, again)
+
+	stripped := p.Strip(have)
+	Tassert(t, reflect.DeepEqual(lines, stripped), "\nwant: %q\nhave: %q", lines, stripped)
+
+	// with a BibPrefix only the refs starting with it are references
+	opts := DefaultOptions()
+	opts.BibPrefix = "rfc"
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	have, err = p.Process([]string{
+		"See [guide] and [rfc1] for more.",
+		"",
+		"[guide]: The style guide.",
+		"[rfc1]: https://www.rfc-editor.org/rfc/rfc1",
+	})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect = []string{
+		`See [guide] and [<a href="#rfc1">rfc1</a>] for more.`,
+		"",
+		"[guide]: The style guide.",
+		`<a name="rfc1"></a>`,
+		"[rfc1]: https://www.rfc-editor.org/rfc/rfc1",
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+}
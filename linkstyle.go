@@ -0,0 +1,239 @@
+package markproc
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Link styles for Options.LinkStyle.
+const (
+	// LinkHTML writes links and anchors as <a href> and <a name> tags.
+	LinkHTML = "html"
+	// LinkMarkdown writes links as [text](#anchor), the anchors of
+	// headings as a {#anchor} attribute at the end of the heading and
+	// other anchors as empty spans, []{#anchor}, for renderers and
+	// linters that reject raw HTML.
+	LinkMarkdown = "markdown"
+	// LinkLaTeX writes anchors as \label{...}, sec:2.3 for section 2.3,
+	// and links as \hyperref[label]{text}, or \href for links to other
+	// files, for Pandoc to LaTeX pipelines.
+	LinkLaTeX = "latex"
+)
+
+// LinkStyles lists the valid values of Options.LinkStyle, "" meaning
+// LinkHTML.
+var LinkStyles = []string{"", LinkHTML, LinkMarkdown, LinkLaTeX}
+
+var (
+	// htmlLinkRe matches an <a href> link: the groups are the href and
+	// the text.
+	htmlLinkRe = regexp.MustCompile(`<a href="([^"]*)">(.*?)</a>`)
+	// htmlAnchorRe matches an empty <a name> anchor.
+	htmlAnchorRe = regexp.MustCompile(`<a name="([^"]+)"></a>`)
+	// mdLinkRe matches a markdown link to an anchor, not an image: the
+	// groups are the character before it, the text and the href.
+	mdLinkRe = regexp.MustCompile(`(^|[^!\\])\[((?:[^\[\]\\]|\\.)*)\]\(([^()\s]*#[^()\s]+)\)`)
+	// mdAnchorRe matches an empty span anchor, []{#anchor}.
+	mdAnchorRe = regexp.MustCompile(`\[\]\{#([^}\s]+)\}`)
+	// trailingIDRe matches a {#anchor} attribute at the end of a line.
+	trailingIDRe = regexp.MustCompile(`\s*\{#([^}\s]+)\}\s*$`)
+	// mdEscaper escapes the characters that would end a link's text.
+	mdEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+	// mdUnescaper undoes mdEscaper.
+	mdUnescaper = strings.NewReplacer(`\\`, `\`, `\[`, `[`, `\]`, `]`)
+
+	// texLabelRe matches a \label.
+	texLabelRe = regexp.MustCompile(`\\label\{([^{}\s]+)\}`)
+	// trailingLabelRe matches a \label at the end of a line.
+	trailingLabelRe = regexp.MustCompile(`\s*\\label\{([^{}\s]+)\}\s*$`)
+	// texRefRe matches a \hyperref: the groups are the label and the
+	// text.
+	texRefRe = regexp.MustCompile(`\\hyperref\[([^\]\s]+)\]\{((?:[^{}\\]|\\.|\\[a-z]+\{\})*)\}`)
+	// texHrefRe matches a \href: the groups are the URL and the text.
+	texHrefRe = regexp.MustCompile(`\\href\{((?:[^{}\\]|\\.)*)\}\{((?:[^{}\\]|\\.|\\[a-z]+\{\})*)\}`)
+	// texEscaper escapes the characters LaTeX treats specially.
+	texEscaper = strings.NewReplacer(
+		`\`, `\textbackslash{}`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+		`&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`, `_`, `\_`, `{`, `\{`, `}`, `\}`,
+	)
+	// texUnescaper undoes texEscaper.
+	texUnescaper = strings.NewReplacer(
+		`\textbackslash{}`, `\`, `\textasciitilde{}`, `~`, `\textasciicircum{}`, `^`,
+		`\&`, `&`, `\%`, `%`, `\$`, `$`, `\#`, `#`, `\_`, `_`, `\{`, `{`, `\}`, `}`,
+	)
+)
+
+// styleLinks returns lines, the processed document, with its links and
+// anchors rewritten in p.LinkStyle.  It runs after verification, which
+// reads the HTML form.
+func (p *Processor) styleLinks(lines []string) []string {
+	switch p.LinkStyle {
+	case LinkMarkdown:
+	case LinkLaTeX:
+		return p.latexLinks(lines)
+	default:
+		return lines
+	}
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if m := anchorLineRe.FindStringSubmatch(line); m != nil && i+1 < len(lines) && !code[i+1] && headerRegexp.MatchString(lines[i+1]) {
+			out.add(i, markdownLinks(lines[i+1])+" {#"+m[1]+"}")
+			i++
+			continue
+		}
+		out.add(i, markdownLinks(line))
+	}
+	return p.done(out)
+}
+
+// markdownLinks returns line with its <a href> links and <a name>
+// anchors in markdown.
+func markdownLinks(line string) string {
+	line = htmlLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+		m := htmlLinkRe.FindStringSubmatch(link)
+		return fmt.Sprintf("[%s](%s)", mdEscaper.Replace(m[2]), m[1])
+	})
+	return htmlAnchorRe.ReplaceAllString(line, "[]{#$1}")
+}
+
+// passHTMLLinks undoes styleLinks, so that the other passes see the
+// links and anchors of a document processed before with LinkMarkdown
+// or LinkLaTeX as they wrote them.  A {#anchor} attribute on a numbered
+// heading is taken for the anchor markproc generated if it has the
+// generated name, and for an explicit ID otherwise.
+func (p *Processor) passHTMLLinks(lines []string) []string {
+	if p.LinkStyle == LinkLaTeX {
+		return p.passUnlatexLinks(lines)
+	}
+	out := p.newLineWriter(lines)
+	anchor := p.anchorNamer()
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		line = mdLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+			m := mdLinkRe.FindStringSubmatch(link)
+			return fmt.Sprintf(`%s<a href="%s">%s</a>`, m[1], m[3], mdUnescaper.Replace(m[2]))
+		})
+		line = mdAnchorRe.ReplaceAllString(line, `<a name="$1"></a>`)
+		if h := p.numberedHeading(line); h != nil {
+			title, _, _ := headingTitle(h[3])
+			name := anchor(strings.TrimSuffix(h[2], "."), title)
+			if m := trailingIDRe.FindStringSubmatch(line); m != nil && m[1] == name {
+				out.add(i, fmt.Sprintf(`<a name="%s"></a>`, name), trailingIDRe.ReplaceAllString(line, ""))
+				continue
+			}
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// latexLinks returns lines, the processed document, with its links and
+// anchors written as LaTeX.
+func (p *Processor) latexLinks(lines []string) []string {
+	code := codeMask(lines)
+	// labels maps the anchor names of numbered headings to their labels
+	labels := map[string]string{}
+	for i, line := range lines {
+		if m := anchorLineRe.FindStringSubmatch(line); m != nil && !code[i] && i+1 < len(lines) && !code[i+1] {
+			if h := p.numberedHeading(lines[i+1]); h != nil {
+				labels[m[1]] = "sec:" + strings.TrimSuffix(h[2], ".")
+			}
+		}
+	}
+	label := func(name string) string {
+		if l, ok := labels[name]; ok {
+			return l
+		}
+		return name
+	}
+
+	out := p.newLineWriter(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if m := anchorLineRe.FindStringSubmatch(line); m != nil && i+1 < len(lines) && !code[i+1] && headerRegexp.MatchString(lines[i+1]) {
+			out.add(i, latexLine(lines[i+1], label)+` \label{`+label(m[1])+`}`)
+			i++
+			continue
+		}
+		out.add(i, latexLine(line, label))
+	}
+	return p.done(out)
+}
+
+// latexLine returns line with its <a href> links and <a name> anchors
+// in LaTeX, naming each anchor with label.
+func latexLine(line string, label func(string) string) string {
+	line = htmlLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+		m := htmlLinkRe.FindStringSubmatch(link)
+		text := texEscaper.Replace(html.UnescapeString(m[2]))
+		if strings.HasPrefix(m[1], "#") {
+			return fmt.Sprintf(`\hyperref[%s]{%s}`, label(m[1][1:]), text)
+		}
+		return fmt.Sprintf(`\href{%s}{%s}`, strings.ReplaceAll(m[1], "#", `\#`), text)
+	})
+	return htmlAnchorRe.ReplaceAllStringFunc(line, func(anchor string) string {
+		return `\label{` + label(htmlAnchorRe.FindStringSubmatch(anchor)[1]) + `}`
+	})
+}
+
+// passUnlatexLinks undoes latexLinks.
+func (p *Processor) passUnlatexLinks(lines []string) []string {
+	code := codeMask(lines)
+	// names maps the labels of numbered headings to their anchor names
+	names := map[string]string{}
+	anchor := p.anchorNamer()
+	for i, line := range lines {
+		if h := p.numberedHeading(line); h != nil && !code[i] {
+			number := strings.TrimSuffix(h[2], ".")
+			title, _, _ := headingTitle(trailingLabelRe.ReplaceAllString(h[3], ""))
+			names["sec:"+number] = anchor(number, title)
+		}
+	}
+	name := func(label string) string {
+		if n, ok := names[label]; ok {
+			return n
+		}
+		return label
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		line = texRefRe.ReplaceAllStringFunc(line, func(ref string) string {
+			m := texRefRe.FindStringSubmatch(ref)
+			return fmt.Sprintf(`<a href="#%s">%s</a>`, name(m[1]), escapeText(texUnescaper.Replace(m[2])))
+		})
+		line = texHrefRe.ReplaceAllStringFunc(line, func(ref string) string {
+			m := texHrefRe.FindStringSubmatch(ref)
+			return fmt.Sprintf(`<a href="%s">%s</a>`, strings.ReplaceAll(m[1], `\#`, "#"), escapeText(texUnescaper.Replace(m[2])))
+		})
+		if m := trailingLabelRe.FindStringSubmatch(line); m != nil && p.numberedHeading(line) != nil {
+			out.add(i, fmt.Sprintf(`<a name="%s"></a>`, name(m[1])), trailingLabelRe.ReplaceAllString(line, ""))
+			continue
+		}
+		line = texLabelRe.ReplaceAllStringFunc(line, func(label string) string {
+			return fmt.Sprintf(`<a name="%s"></a>`, name(texLabelRe.FindStringSubmatch(label)[1]))
+		})
+		out.add(i, line)
+	}
+	return p.done(out)
+}
@@ -0,0 +1,114 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestLinkStyleMarkdown(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LinkStyle = LinkMarkdown
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Top",
+		"See [sec dsgn], [ref] and [link](other.md#x) ![img](#x).",
+		"## Design",
+		"## Goals {#goals}",
+		"Back to [#goals].",
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		"[ref]: A thing.",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		"# 1. Top {#sec1}",
+		"See [[sec 1.1](#sec1_1)], [[ref](#ref)] and [link](other.md#x) ![img](#x).",
+		"## 1.1. Design {#sec1_1}",
+		"## 1.2. Goals {#goals}",
+		"Back to [[sec 1.2](#goals)].",
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		"[]{#ref}",
+		"[ref]: A thing.",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := []string{
+		"# Top",
+		"See [sec desig], [ref] and [link](other.md#x) ![img](#x).",
+		"## Design",
+		"## Goals {#goals}",
+		"Back to [sec #goals].",
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		"[ref]: A thing.",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	have := markdownLinks(`<a name="a"></a>x <a href="#b">c [d]</a>`)
+	want := `[]{#a}x [c \[d\]](#b)`
+	Tassert(t, have == want, "\nwant: %q\nhave: %q", want, have)
+}
+
+func TestLinkStyleLaTeX(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LinkStyle = LinkLaTeX
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Top",
+		"See [sec dsgn], [ref] and <a href=\"other.md#x\">50% & more</a>.",
+		"## Design",
+		"## Goals {#goals}",
+		"Back to [#goals].",
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		"[ref]: A thing.",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`# 1. Top \label{sec:1}`,
+		`See [\hyperref[sec:1.1]{sec 1.1}], [\hyperref[ref]{ref}] and \href{other.md\#x}{50\% \& more}.`,
+		`## 1.1. Design \label{sec:1.1}`,
+		"## 1.2. Goals {#goals}",
+		`Back to [\hyperref[goals]{sec 1.2}].`,
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		`\label{ref}`,
+		"[ref]: A thing.",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	stripped := p.Strip(out)
+	wantStripped := []string{
+		"# Top",
+		`See [sec desig], [ref] and \href{other.md\#x}{50\% \& more}.`,
+		"## Design",
+		"## Goals {#goals}",
+		"Back to [sec #goals].",
+		"```",
+		`<a href="#x">code</a>`,
+		"```",
+		"[ref]: A thing.",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, wantStripped), "\nwant: %q\nhave: %q", wantStripped, stripped)
+}
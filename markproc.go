@@ -0,0 +1,1433 @@
+// Package markproc is a preprocessor for Markdown documents.  It
+// numbers section headings, inserts anchors for headings and
+// bibliographic references, turns references into links, and verifies
+// that every link has exactly one target.
+package markproc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options selects the passes run by a Processor.
+type Options struct {
+	// IncludeFS, if not nil, holds the files named by include
+	// directives, which are replaced by their contents before any
+	// other pass; see IncludePath.
+	IncludeFS fs.FS
+	// IncludePath is the path in IncludeFS of the document, which the
+	// paths of its include directives are relative to.  Empty means
+	// the root.  ProcessProject uses the path of each Document.
+	IncludePath string
+	// ShiftHeadings moves every heading this many levels down, or up
+	// if it is negative, before numbering, e.g. 1 to make the #
+	// headings of a chapter ## headings of a book.  Levels stay
+	// between 1 and 6.
+	ShiftHeadings int
+	// Sanitize escapes raw HTML in the input before any other pass.
+	Sanitize bool
+	// NormalizeAnchors rewrites hand-written <a name> tags split across
+	// lines into the single-line form.
+	NormalizeAnchors bool
+	// ConvertAnchors rewrites hand-written <a id> and heading {#id}
+	// anchors into the <a name> form markproc emits.
+	ConvertAnchors bool
+	// MkExterns inserts an anchor before each [ref]: definition.
+	MkExterns bool
+	// MkHeads numbers section headings and inserts their anchors.
+	MkHeads bool
+	// MkTOC replaces [toc] and <!-- toc --> markers with a table of
+	// contents.
+	MkTOC bool
+	// StartNumber is the number of the first section, e.g. "4" to
+	// number a chapter's top-level sections from 4, or "2.3" to start
+	// at subsection 2.3.  Empty means 1.
+	StartNumber string
+	// NumberFormat gives the style of section numbers at each level,
+	// e.g. "I.A.1.a": "1" numbers 1, 2, 3, "I" and "i" use upper and
+	// lower case roman numerals and "A" and "a" upper and lower case
+	// letters.  Deeper levels are numbered 1, 2, 3.  Empty means 1 at
+	// every level.  Anchor names follow the numbers.
+	NumberFormat string
+	// NumberSuffix is put between section numbers and titles in
+	// headings, tables of contents and related section lists, e.g.
+	// ".0" for "# 1.0 Title".  Empty means "."; NoNumberSuffix means
+	// nothing, "# 1  Title", with two spaces in headings.  Numbered
+	// headings are recognized with any of these.
+	NumberSuffix string
+	// StartState, if not nil, is where the previous document's
+	// numbering stopped, as returned by Processor.NumberState, and
+	// numbering carries on from there instead of from StartNumber.
+	StartState *NumberState
+	// TOCDepth limits the table of contents to headings at this level
+	// or above.  Zero means no limit.
+	TOCDepth int
+	// LinkExterns turns [ref] references into links.
+	LinkExterns bool
+	// ExternDisplay makes [ref] links show the text given by a
+	// display: field in the ref's definition, e.g. "RFC 2119" for
+	// [rfc2119], and removes the field from the definition.
+	ExternDisplay bool
+	// CiteStyle, if set, renders [ref] citations in one of the
+	// CiteStyles and replaces a [bibliography] marker line with the
+	// formatted reference definitions.
+	CiteStyle string
+	// CitedIn appends to each [ref]: definition, and its entry in the
+	// formatted bibliography, back-links to the sections citing it.
+	CitedIn bool
+	// HideDefinitions removes the [ref]: definition lines when
+	// CiteStyle is set and the document has a [bibliography] list to
+	// show them instead.
+	HideDefinitions bool
+	// MkEquations numbers display-math blocks and turns [eq ...]
+	// references into links to them.
+	MkEquations bool
+	// LinkHeads turns [sec ...] references into links.
+	LinkHeads bool
+	// LinkTerms turns [term X] usages into links to their [term X]:
+	// definitions and fills in a [glossary] marker.
+	LinkTerms bool
+	// MkFigures numbers the figures and tables captioned with a
+	// "Figure: ..." or "Table: ..." line and fills in [lof] and [lot]
+	// markers with lists of them.
+	MkFigures bool
+	// Footnotes, if set, renumbers [^1] footnotes in order of first
+	// use and moves their definitions to the end of the document or of
+	// sections, verifying each footnote is defined; see
+	// FootnotePlacements.
+	Footnotes string
+	// BibPrefix, if set, marks the refs of bibliographic [ref]:
+	// definitions, e.g. "ref-"; other definitions are link definitions,
+	// left for the renderer.  Otherwise definitions giving only a URL
+	// are link definitions.
+	BibPrefix string
+	// Blockquotes processes the text of blockquotes like the rest of
+	// the document: by default references in quoted text are left
+	// alone.  Headings in blockquotes are never numbered.
+	Blockquotes bool
+	// Setext, if set, numbers setext headings, Title over a line of =
+	// or -, and keeps them in setext style or writes them as ATX
+	// headings; see SetextStyles.
+	Setext string
+	// Conditions are the names <!-- if: NAME --> blocks test, e.g.
+	// internal or draft: a block is kept if its condition holds for
+	// them and removed otherwise.  SelectProfiles sets them.
+	Conditions []string
+	// DocVersion and DocDate, if set, are the version and date of the
+	// document, e.g. from git describe, stamped in place of each
+	// <!-- markproc: version --> directive.
+	DocVersion, DocDate string
+	// VersionFooter also stamps them in a footer at the end of the
+	// document, and of each page Split makes of it.
+	VersionFooter bool
+	// Requirements, if set, is the prefix of requirement IDs, e.g.
+	// "REQ" for [REQ-042]: a line starting with a tag defines the
+	// requirement, other tags link to it, and a [requirements] marker
+	// is filled in with a Requirements Traceability table.
+	Requirements string
+	// MkIndex turns [[index:term]] and [text]{.idx} marks into
+	// comments and fills in an [index] marker with the marked terms,
+	// linking to the sections they are marked in.
+	MkIndex bool
+	// ExpandAcronyms spells out the first use of each acronym defined
+	// as [acro API]: Application Programming Interface.
+	ExpandAcronyms bool
+	// LinkAcronyms links later uses of each acronym to its definition.
+	LinkAcronyms bool
+	// AcronymList gathers the acronym definitions into a sorted List of
+	// Acronyms.
+	AcronymList bool
+	// Abbreviations is a project dictionary of abbreviations whose
+	// first use in each section links to the section or URL defining
+	// them.
+	Abbreviations []Abbreviation
+	// AbbrTitles wraps the later uses of an Abbreviation with a Title
+	// in a section in <abbr title="..."> elements.
+	AbbrTitles bool
+	// SelfRefText, if set, replaces a [sec ...] reference to the
+	// section it appears in, e.g. with "this section", instead of
+	// linking it.
+	SelfRefText string
+	// RefDirection adds "above" or "below" to a [sec ...] reference
+	// to an earlier or later section of the same file.
+	RefDirection bool
+	// AppendixAfter, if set, is the title of the last heading before
+	// the appendices.  Top-level sections after it, or after an
+	// <!-- appendix --> line, are lettered A, B, ... instead.
+	AppendixAfter string
+	// MultipleH1 selects what to do with a document that has more than
+	// one H1; see H1Policies.
+	MultipleH1 string
+	// RequiredOutline, if not nil, lists sections every document must
+	// have, checked by Verify.  ProcessProject doesn't check it.
+	RequiredOutline OutlineTemplate
+	// FrozenOutline, if not nil, is the outline of a previous release.
+	// Verify reports sections whose number has changed since then as
+	// set by FreezeNumbers.  ProcessProject doesn't check it.
+	FrozenOutline []Section
+	// FreezeNumbers is FreezeWarn or FreezeError.
+	FreezeNumbers string
+	// RequireOwners makes Verify check that every top-level section
+	// has an <!-- owner: ... --> line; see Processor.Owners.
+	// ProcessProject doesn't check it.
+	RequireOwners bool
+	// StrictExpiry makes the sections Verify finds have passed the date
+	// of their <!-- expires: YYYY-MM-DD --> line fail verification
+	// rather than just warning about them; see Processor.Expirations.
+	StrictExpiry bool
+	// Today is the date expiry dates are compared with, as YYYY-MM-DD,
+	// or empty for the current date.
+	Today string
+	// CheckURLs makes Verify request each http and https URL linked to
+	// and warn about those that are dead or redirect elsewhere.
+	CheckURLs bool
+	// URLPolicy is URLWarn or URLError, what to do about dead links
+	// with CheckURLs.
+	URLPolicy string
+	// URLChecker, if not nil, checks the URLs for CheckURLs, sharing
+	// its results across documents.  Otherwise each document gets a
+	// new one with Limits.NetworkTimeout.
+	URLChecker *URLChecker
+	// FixHeadingGaps raises a heading that skips a level, e.g. an H4
+	// straight after an H2, to one level below the heading before it.
+	FixHeadingGaps bool
+	// A11y adds accessibility checks to Verify: one H1, descriptive
+	// link text and image alt text.
+	A11y bool
+	// Rules are user-defined checks Verify runs over each document.
+	Rules []Rule
+	// Verify checks that every link has exactly one target.
+	Verify bool
+	// LinkStyle selects how links and anchors are written; see
+	// LinkStyles.
+	LinkStyle string
+	// HeadingAnchors selects where the anchors of headings are written;
+	// see HeadingAnchorForms.
+	HeadingAnchors string
+	// WarnOrphans makes Verify also warn about each [ref]: definition
+	// nothing cites and each anchor nothing links to.
+	WarnOrphans bool
+	// AnchorStyle selects how section anchors are named; see
+	// AnchorSecnum, AnchorGitHub and AnchorCustom.
+	AnchorStyle string
+	// Matcher resolves [sec ...] and [eq ...] references to their
+	// targets.  If nil, InsertionMatcher is used.
+	Matcher Matcher
+	// Choose, if not nil, is asked which section an ambiguous [sec ...]
+	// reference means instead of failing; see Chooser.
+	Choose Chooser
+	// SecRefFormat is the text of the links made from [sec ...]
+	// references, e.g. "Section {number}" or "§ {number}"; {number} is
+	// replaced by the section number and {title} by the heading title.
+	// It doesn't affect anchor names.  DefaultOptions sets "sec
+	// {number}".
+	SecRefFormat string
+	// EqRefFormat is the text of the links made from [eq ...]
+	// references, like SecRefFormat.  DefaultOptions sets "eq
+	// {number}".
+	EqRefFormat string
+	// AnchorFormat is the anchor name template for AnchorCustom.
+	// {number} is replaced by the section number with dots turned into
+	// AnchorSeparator and {slug} by the slugged heading title.
+	AnchorFormat string
+	// AnchorPrefix starts AnchorSecnum anchor names, e.g. "s" or
+	// "section-".  Empty means "sec".
+	AnchorPrefix string
+	// AnchorSeparator replaces the dots of section and equation numbers
+	// in anchor names, e.g. "-" for renderers that reject underscores.
+	// Empty means "_".
+	AnchorSeparator string
+	// AnchorSlug appends the slugged heading title to AnchorSecnum
+	// anchor names, e.g. sec1_2_design-goals.
+	AnchorSlug bool
+	// FrontMatterConfig lets settings under a markproc key in a
+	// document's YAML front matter override these options for that
+	// document; see Options.Set for the setting names.
+	FrontMatterConfig bool
+	// Jobs is the number of files of a project ProcessProject links
+	// and verifies at once.  Zero or one means one at a time.  A
+	// Matcher must be safe for concurrent use when it is more than
+	// one.
+	Jobs int
+	// ProjectLinkExt replaces the extension of the target file in links
+	// between the files of a project.
+	ProjectLinkExt string
+	// Permalink, if set, links to the other files of a project with
+	// absolute URLs in a static site's permalink scheme instead, e.g.
+	// "/{path}/"; see permalink.
+	Permalink string
+	// RefShortcodes, if set, links to the other files of a project
+	// with a static site generator's shortcode instead, leaving the
+	// site to resolve the URL; see RefShortcodeStyles.
+	RefShortcodes string
+	// SiteRoot is the directory Permalink and RefShortcodes take paths
+	// relative to, e.g. Hugo's content directory.
+	SiteRoot string
+	// Limits guards against oversized or hostile input.
+	Limits Limits
+}
+
+// Limits bounds the resources markproc will spend on a document.  A
+// zero value for any field means no limit.
+type Limits struct {
+	// MaxInputSize is the largest document, in bytes, that will be read.
+	MaxInputSize int64
+	// MaxIncludeDepth bounds nesting of included files.
+	MaxIncludeDepth int
+	// NetworkTimeout bounds each network request made while processing.
+	NetworkTimeout time.Duration
+}
+
+// DefaultLimits are the limits used by the command line tool.
+var DefaultLimits = Limits{
+	MaxIncludeDepth: 10,
+	NetworkTimeout:  10 * time.Second,
+}
+
+// DefaultOptions returns the options used by the command line tool:
+// every pass except sanitizing is enabled.
+func DefaultOptions() Options {
+	return Options{
+		NormalizeAnchors: true,
+		MkExterns:        true,
+		MkHeads:          true,
+		MkTOC:            true,
+		LinkExterns:      true,
+		LinkHeads:        true,
+		LinkTerms:        true,
+		MkIndex:          true,
+		Setext:           SetextKeep,
+		MkEquations:      true,
+		ExpandAcronyms:   true,
+		Verify:           true,
+		AnchorStyle:      AnchorSecnum,
+		MultipleH1:       H1Allow,
+		SecRefFormat:     "sec {number}",
+		EqRefFormat:      "eq {number}",
+		ProjectLinkExt:   ".html",
+		Limits:           DefaultLimits,
+	}
+}
+
+// Processor runs the passes selected by its Options over a document.
+type Processor struct {
+	Options
+	// Stderr receives warnings.  NewProcessor sets it to os.Stderr.
+	Stderr io.Writer
+	// RefLog, if not nil, is checked for [sec ...] references whose
+	// heading has been reworded since the last run, and updated with
+	// the headings they resolve to now.
+	RefLog RefLog
+	// AnchorMap, if not nil, gives sections the anchors it records for
+	// them, has aliases inserted for the numbers they had before, and
+	// is updated with their anchors and numbers now.
+	AnchorMap *AnchorMap
+	// Index, if not nil, is the index of the project's anchors and
+	// links ProcessProject verifies cross-file links with.  Keeping one
+	// across calls, as watch mode does, makes each call re-index only
+	// the files whose output changed and re-verify only those and the
+	// files linking to anchors they added or removed.
+	Index *Index
+	// failure describes the first problem found by a pass that it
+	// can't fix, or is empty.
+	failure string
+	// warnings collects the problems reported by the last Process call.
+	warnings []Warning
+	// timings records how long each pass of the last Process call took.
+	timings []PassTiming
+	// project holds the cross-file state while processing a project.
+	project *project
+	// origin holds, for each line of the document as it passes through
+	// the passes, the line of the input it came from.
+	origin []int
+	// input holds the unprocessed lines of the current document.
+	input []string
+	// bib holds the reference definitions of the current document.
+	bib *bibliography
+	// numbers numbered the headings of the last document.
+	numbers *numberer
+	// choices records the targets Choose picked in the last document.
+	choices []Choice
+	// refs records the [sec ...] references resolved in the last
+	// document.
+	refs []Reference
+}
+
+// Warning is a problem found while processing a document.  Rule names
+// the check that found it, e.g. "heading-gap" or "undefined-target",
+// and Severity is SeverityError for problems that make processing
+// fail.  Line and Column are the 1-based position in the input document
+// the problem was found at, or zero if unknown.  File is set only when
+// processing a project.
+type Warning struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+}
+
+// Warning severities.
+const (
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// errorRules lists the rules whose warnings always make processing
+// fail.
+var errorRules = map[string]bool{
+	"sec-unresolved":     true,
+	"sec-ambiguous":      true,
+	"duplicate-target":   true,
+	"undefined-target":   true,
+	"outline-missing":    true,
+	"outline-misplaced":  true,
+	"term-undefined":     true,
+	"req-duplicate":      true,
+	"req-undefined":      true,
+	"if-unbalanced":      true,
+	"footnote-undefined": true,
+	"eq-unresolved":      true,
+	"eq-ambiguous":       true,
+	"owner-missing":      true,
+	"include-invalid":    true,
+	"include-cycle":      true,
+	"include-depth":      true,
+	"include-missing":    true,
+	"snippet-missing":    true,
+}
+
+// NewProcessor returns a Processor that runs the passes selected by opts.
+func NewProcessor(opts Options) *Processor {
+	return &Processor{Options: opts, Stderr: os.Stderr}
+}
+
+// Process runs the selected passes over lines and returns the
+// processed lines.  The returned error is non-nil if verification
+// failed or a reference could not be resolved; the processed lines are
+// returned either way.
+func (p *Processor) Process(lines []string) (out []string, err error) {
+	p.reset()
+	lines = plainLines(lines)
+	p.origin = identity(len(lines))
+	p.input = lines
+	defer p.applyFrontMatter(lines)()
+	lines = p.runPasses(lines, false)
+	lines = p.runPasses(lines, true)
+	if p.Verify {
+		err = p.timedVerify(lines)
+	}
+	if err == nil && p.failure != "" {
+		err = fmt.Errorf("%s", p.failure)
+	}
+	return p.finish(lines), err
+}
+
+// finish returns lines, the processed document, as it is written out:
+// with its links and anchors in p.LinkStyle, its setext headings
+// restored, its heading anchors placed as p.HeadingAnchors selects and
+// its blockquotes and link definitions unwrapped.
+func (p *Processor) finish(lines []string) []string {
+	return p.unwrap(p.placeHeadingAnchors(p.setextHeadings(p.styleLinks(lines))))
+}
+
+// applyFrontMatter applies the settings in the front matter of lines to
+// p if p.FrontMatterConfig is set, and returns a function that restores
+// the previous settings.
+func (p *Processor) applyFrontMatter(lines []string) (restore func()) {
+	saved := p.Options
+	restore = func() { p.Options = saved }
+	if !p.FrontMatterConfig {
+		return
+	}
+	for _, setting := range frontMatterConfig(lines) {
+		err := p.Options.Set(setting.key, setting.value)
+		if err != nil {
+			p.warnf("front-matter", setting.line, setting.key, "%v", err)
+		}
+	}
+	return
+}
+
+// reset clears the results of any previous Process call.
+func (p *Processor) reset() {
+	p.origin = nil
+	p.input = nil
+	p.bib = nil
+	p.numbers = nil
+	p.choices = []Choice{}
+	p.refs = []Reference{}
+	p.failure = ""
+	p.warnings = []Warning{}
+	p.timings = []PassTiming{}
+}
+
+// pass is one transformation of a document.
+type pass struct {
+	name    string
+	enabled bool
+	run     func([]string) []string
+	// linking is set for passes that turn references into links; in a
+	// project they run only after every file has been numbered.
+	linking bool
+}
+
+// passes returns the transformation passes in the order they run.
+func (p *Processor) passes() []pass {
+	return []pass{
+		{"quotes", !p.Blockquotes, p.passQuotes, false},
+		{"linkdefs", true, p.passLinkDefs, false},
+		{"setext", p.Setext != "", p.passSetext, false},
+		{"htmllinks", p.LinkStyle == LinkMarkdown || p.LinkStyle == LinkLaTeX, p.passHTMLLinks, false},
+		{"headanchors", p.attachesHeadingAnchors(), p.passHeadingAnchors, false},
+		{"include", p.IncludeFS != nil, p.passInclude, false},
+		{"snippets", p.IncludeFS != nil, p.passSnippets, false},
+		{"conditions", true, p.passConditions, false},
+		{"version", p.stampsVersion(), p.passVersion, false},
+		{"shift", p.ShiftHeadings != 0, p.passShiftHeadings, false},
+		{"sanitize", p.Sanitize, p.passSanitize, false},
+		{"normalize", p.NormalizeAnchors, p.passNormalizeAnchors, false},
+		{"convert", p.ConvertAnchors, p.passConvertAnchors, false},
+		{"strip", p.MkHeads || p.MkExterns || p.MkTOC || p.CiteStyle != "" || p.LinkTerms || p.ExpandAcronyms || p.MkEquations || p.LinkHeads || p.CitedIn || p.MkIndex || p.MkFigures || p.Requirements != "", p.passStrip, false},
+		{"mkexterns", p.MkExterns, p.passMkExterns, false},
+		{"mkheads", p.MkHeads, p.passMkHeads, false},
+		{"mktoc", p.MkTOC, p.passMkTOC, false},
+		{"mkeqs", p.MkEquations, p.passMkEquations, false},
+		{"mkfigures", p.MkFigures, p.passMkFigures, false},
+		{"footnotes", p.Footnotes != "", p.passFootnotes, false},
+		{"mkindex", p.MkIndex, p.passMkIndex, false},
+		{"citedin", p.CitedIn, p.passCitedIn, true},
+		{"linkexterns", p.LinkExterns, p.passLinkExterns, true},
+		{"related", p.LinkHeads, p.passRelated, true},
+		{"linkheads", p.LinkHeads, p.passLinkHeads, true},
+		{"linkeqs", p.MkEquations, p.passLinkEquations, true},
+		{"bibliography", p.CiteStyle != "", p.passBibliography, true},
+		{"glossary", p.LinkTerms, p.passGlossary, true},
+		{"acronyms", p.ExpandAcronyms, p.passAcronyms, true},
+		{"abbreviations", len(p.Abbreviations) > 0, p.passAbbreviations, true},
+		{"requirements", p.Requirements != "", p.passRequirements, true},
+	}
+}
+
+// runPasses runs the enabled linking or non-linking passes over lines,
+// recording their timings.
+func (p *Processor) runPasses(lines []string, linking bool) []string {
+	for _, pass := range p.passes() {
+		if !pass.enabled || pass.linking != linking {
+			continue
+		}
+		start := time.Now()
+		lines = pass.run(lines)
+		p.timings = append(p.timings, PassTiming{Pass: pass.name, Duration: time.Since(start)})
+	}
+	return lines
+}
+
+// timedVerify runs verify over lines, recording its timing.
+func (p *Processor) timedVerify(lines []string) (err error) {
+	start := time.Now()
+	err = p.verify(lines)
+	p.timings = append(p.timings, PassTiming{Pass: "verify", Duration: time.Since(start)})
+	if err != nil {
+		err = fmt.Errorf("Verification error: %w", err)
+	}
+	return
+}
+
+// PassTiming records how long one pass took.
+type PassTiming struct {
+	Pass     string        `json:"pass"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Timings returns how long each pass took during the last Process call,
+// in the order the passes ran.
+func (p *Processor) Timings() []PassTiming {
+	return p.timings
+}
+
+// Warnings returns the problems found by the last Process call.
+func (p *Processor) Warnings() []Warning {
+	return p.warnings
+}
+
+// severity returns the severity of warnings for rule.
+func (p *Processor) severity(rule string) string {
+	if severity := p.ruleSeverity(rule); severity != "" {
+		return severity
+	}
+	switch {
+	case errorRules[rule],
+		rule == "multiple-h1" && p.MultipleH1 == H1Error,
+		rule == "number-changed" && p.FreezeNumbers == FreezeError,
+		rule == "section-expired" && p.StrictExpiry,
+		rule == "url-dead" && p.URLPolicy == URLError:
+		return SeverityError
+	}
+	return SeverityWarning
+}
+
+// fail records that processing has failed for the reason given, unless
+// it has already failed.
+func (p *Processor) fail(reason string) {
+	if p.failure == "" {
+		p.failure = reason
+	}
+}
+
+// warnf records a warning for rule about text found on line i of the
+// current pass's input, and writes it to p.Stderr.  i is -1 if the
+// warning has no single location; text locates the column and may be
+// empty.
+func (p *Processor) warnf(rule string, i int, text, format string, args ...interface{}) {
+	line := 0
+	if i >= 0 {
+		line = p.lineOf(i)
+	}
+	p.warnfLine(rule, line, text, format, args...)
+}
+
+// warnfLine records a warning like warnf, found on the 1-based line of
+// the input.
+func (p *Processor) warnfLine(rule string, line int, text, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	p.recordLine(rule, line, text, msg)
+	fmt.Fprintf(p.Stderr, "Warning: %s\n", msg)
+}
+
+// record records a warning like warnf, without writing it anywhere.
+func (p *Processor) record(rule string, i int, text, msg string) {
+	line := 0
+	if i >= 0 {
+		line = p.lineOf(i)
+	}
+	p.recordLine(rule, line, text, msg)
+}
+
+// recordLine records a warning like record, found on the 1-based line
+// of the input, or zero if it has no single location.
+func (p *Processor) recordLine(rule string, line int, text, msg string) {
+	w := Warning{Rule: rule, Severity: p.severity(rule), Line: line, Message: msg}
+	if line > 0 && text != "" && line <= len(p.input) {
+		w.Column = strings.Index(p.input[line-1], text) + 1
+	}
+	if p.project != nil {
+		w.File = p.project.current
+	}
+	p.warnings = append(p.warnings, w)
+}
+
+type Target struct {
+	Name         string
+	Heading      string
+	Number       string
+	HeadingLower string
+	// File is the project file holding the target, or "" when not
+	// processing a project.
+	File string
+	// ID is the name given by the heading's {#id} attribute, if any,
+	// which [sec #id] and [#id] references resolve to.
+	ID string
+	// Duplicates holds the later sections with the same title, in
+	// document order.  A reference matching the title resolves to the
+	// one nearest it; see nearest.
+	Duplicates []Target
+}
+
+// addTarget adds target to targets under key, as a duplicate of the
+// target already there if any.
+func addTarget(targets map[string]Target, key string, target Target) {
+	first, ok := targets[key]
+	if !ok {
+		targets[key] = target
+		return
+	}
+	dups := target.Duplicates
+	target.Duplicates = nil
+	first.Duplicates = append(append(first.Duplicates, target), dups...)
+	targets[key] = first
+}
+
+// all returns t followed by its duplicates.
+func (t Target) all() []Target {
+	return append([]Target{t}, t.Duplicates...)
+}
+
+// nearest returns whichever of t and its duplicates shares the most
+// leading number components with the section numbered current, the
+// earliest on a tie.
+func (t Target) nearest(current string) Target {
+	best, most := t, -1
+	for _, c := range t.all() {
+		n := 0
+		a, b := strings.Split(c.Number, "."), strings.Split(current, ".")
+		for n < len(a) && n < len(b) && a[n] == b[n] {
+			n++
+		}
+		if n > most {
+			best, most = c, n
+		}
+	}
+	return best
+}
+
+// numbered returns whichever of t and its duplicates is numbered
+// number, or t if none is.
+func (t Target) numbered(number string) Target {
+	for _, c := range t.all() {
+		if c.Number == number {
+			return c
+		}
+	}
+	return t
+}
+
+var (
+	// refRegexp matches a [ref] reference, which isn't followed by a
+	// colon, as a definition is, or a parenthesis, as a markdown link's
+	// text is.
+	refRegexp     = regexp.MustCompile(`\[(\w+)\][^:(]`)
+	extLinkRegexp = regexp.MustCompile(`^\[(\w+)\]:\s+`)
+	// displayFieldRe matches the display: field of a [ref]:
+	// definition, quoted or running to the end of the line.
+	displayFieldRe = regexp.MustCompile(`\bdisplay:\s*(?:"([^"]*)"|(\S.*?))\s*$`)
+	headerRegexp   = regexp.MustCompile(`^(#+)\s+(.+)`)
+	// unnumberedRe matches the markers that opt a heading out of
+	// numbering: Pandoc's {-} and {.unnumbered}, or <!-- nonum -->,
+	// also written as a directive, <!-- markproc: nonum -->.
+	unnumberedRe     = regexp.MustCompile(`(\{-\}|\{\.unnumbered\}|<!--\s*(?:markproc:\s*)?nonum\s*-->)\s*$`)
+	sectionRefRegexp = regexp.MustCompile(`\[sec\s+([^\]]+)\]`)
+	// sectionNumberRe matches a [sec ...] reference that may be a section
+	// number: digits, or letters and digits with a dot, e.g. 2.3, A.1 or
+	// IV.
+	sectionNumberRe = regexp.MustCompile(`^(?:\d+(?:\.\w+)*\.?|\w+(?:\.\w+)+\.?|\w+\.)$`)
+	// idRefRe matches a [#id] reference to the heading with that {#id}
+	// attribute, but not a link or link definition.
+	idRefRe = regexp.MustCompile(`\[#([^\]\s]+)\](?:[^:(\[]|$)`)
+	// taskBoxRe matches the checkbox of a task list item, e.g. "- [x] ".
+	taskBoxRe = regexp.MustCompile(`^\s*([-+*]|\d+[.)])\s+\[[ xX]\]\s`)
+	// commentRe matches an HTML comment on one line.
+	commentRe = regexp.MustCompile(`<!--.*?-->`)
+)
+
+// ReadLines reads all lines from r, dropping \r\n line endings and a
+// byte order mark; see TextStyle.
+func ReadLines(r io.Reader) (lines []string, err error) {
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	err = scanner.Err()
+	return plainLines(lines), err
+}
+
+// ReadLimited is like ReadLines, but fails if r holds more than maxSize
+// bytes.  A maxSize of zero means no limit.
+func ReadLimited(r io.Reader, maxSize int64) (lines []string, err error) {
+	if maxSize <= 0 {
+		return ReadLines(r)
+	}
+	lr := &io.LimitedReader{R: r, N: maxSize + 1}
+	lines, err = ReadLines(lr)
+	if err == nil && lr.N == 0 {
+		err = fmt.Errorf("input exceeds maximum size of %d bytes", maxSize)
+	}
+	return
+}
+
+// WriteLines writes lines to w, each terminated by a newline.
+func WriteLines(w io.Writer, lines []string) (err error) {
+	writer := bufio.NewWriter(w)
+	for _, line := range lines {
+		_, err = writer.WriteString(line + "\n")
+		if err != nil {
+			return
+		}
+	}
+	return writer.Flush()
+}
+
+func generateSectionNumber(level int, number int, parentNumber string) string {
+	if parentNumber == "" {
+		return fmt.Sprintf("%d", number)
+	}
+	return fmt.Sprintf("%s.%d", parentNumber, number)
+}
+
+func (p *Processor) passLinkExterns(lines []string) []string {
+	newLines := []string{}
+	displays := p.project.externDisplays()
+	if displays == nil {
+		displays = externDisplays(lines)
+	}
+	links := linkDefs(lines)
+	var bib *bibliography
+	if p.CiteStyle != "" {
+		bib = p.docBibliography(lines)
+	}
+	// inBib is set within a References list made by an earlier run,
+	// whose [1] labels aren't citations
+	inBib := false
+	code := codeMask(lines)
+	for i, line := range lines {
+		switch line {
+		case bibStart:
+			inBib = true
+		case bibEnd:
+			inBib = false
+		}
+		if code[i] || inBib {
+			newLines = append(newLines, line)
+			continue
+		}
+		if p.ExternDisplay && extLinkRegexp.MatchString(line) {
+			line = strings.TrimRight(displayFieldRe.ReplaceAllString(line, ""), " \t")
+		}
+		line = outsideCode(line, func(line string) string {
+			return outsideComments(line, func(line string) string {
+				return p.linkExterns(line, displays, links, bib)
+			})
+		})
+		newLines = append(newLines, line)
+	}
+	return newLines
+}
+
+// citation is a [ref] reference found by citations: its start and end
+// in the line, and the ref.
+type citation struct {
+	start, end int
+	ref        string
+}
+
+// citations returns the [ref] references of line.  What refRegexp
+// matches but markdown takes for something else is left out: the
+// checkbox of a task list item, "- [x] ", the alt text of an image,
+// ![alt][img], and the text and label of a reference link, [text][label]
+// or [text][], as in a [![badge][img]][link] badge.
+func citations(line string) (cites []citation) {
+	box := len(taskBoxRe.FindString(line))
+	for _, m := range refRegexp.FindAllStringSubmatchIndex(line, -1) {
+		start, end := m[0], m[3]+1
+		if start < box || line[end] == '[' || start > 0 && strings.ContainsRune(`!]\`, rune(line[start-1])) {
+			continue
+		}
+		cites = append(cites, citation{start, end, line[m[2]:m[3]]})
+	}
+	return
+}
+
+// linkExterns returns line with its [ref] references linked, except
+// those to the link definitions links.
+func (p *Processor) linkExterns(line string, displays map[string]string, links map[string]bool, bib *bibliography) string {
+	var b strings.Builder
+	last := 0
+	for _, c := range citations(line) {
+		if links[c.ref] {
+			continue
+		}
+		text := c.ref
+		if display, ok := displays[c.ref]; ok && p.ExternDisplay {
+			text = escapeText(display)
+		}
+		href := p.fileHref(p.project.externFile(c.ref)) + "#" + c.ref
+		// use an HTML link, not a markdown link
+		link := fmt.Sprintf(`[<a href="%s">%s</a>]`, href, text)
+		if cite := bib.citation(c.ref, href, p.CiteStyle); cite != "" {
+			link = cite
+		}
+		b.WriteString(line[last:c.start])
+		b.WriteString(link)
+		last = c.end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// outsideComments returns line with f applied to each part of it that
+// isn't an HTML comment.
+func outsideComments(line string, f func(string) string) string {
+	var b strings.Builder
+	for {
+		loc := commentRe.FindStringIndex(line)
+		if loc == nil {
+			b.WriteString(f(line))
+			return b.String()
+		}
+		b.WriteString(f(line[:loc[0]]))
+		b.WriteString(line[loc[0]:loc[1]])
+		line = line[loc[1]:]
+	}
+}
+
+// externDisplays returns the display text given by the display: field
+// of each [ref]: definition in lines that has one.
+func externDisplays(lines []string) map[string]string {
+	displays := map[string]string{}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		extMatch := extLinkRegexp.FindStringSubmatch(line)
+		if len(extMatch) == 0 {
+			continue
+		}
+		if m := displayFieldRe.FindStringSubmatch(line); m != nil {
+			displays[extMatch[1]] = m[1] + m[2]
+		}
+	}
+	return displays
+}
+
+func (p *Processor) passMkExterns(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if extMatch := extLinkRegexp.FindStringSubmatch(line); len(extMatch) > 0 {
+			ref := extMatch[1]
+			// insert the anchor link before the reference
+			newLine := fmt.Sprintf(`<a name="%s"></a>`, ref)
+			out.add(i, newLine)
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+func (p *Processor) passMkHeads(lines []string) []string {
+	out := p.newLineWriter(lines)
+	numbers := p.project.numberer()
+	if numbers == nil {
+		numbers = p.startNumberer()
+	}
+	p.numbers = numbers
+	heads := p.newHeadNumberer(numbers)
+	code := codeMask(lines)
+	// aliased holds the titles of the sections given aliases
+	aliased := map[string]bool{}
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if h := heads.next(line); h != nil {
+			if h.extra {
+				switch p.MultipleH1 {
+				case H1Warn:
+					p.warnf("multiple-h1", i, "#", "More than one H1: %s", h.Title+h.markers)
+				case H1Error:
+					p.warnf("multiple-h1", i, "#", "More than one H1: %s", h.Title+h.markers)
+					p.fail("more than one H1")
+				}
+			}
+			if h.gap {
+				p.warnf("heading-gap", i, "#", "Header level gap up: %s", h.Title+h.markers)
+			}
+
+			// Insert the anchors of the numbers the section had
+			// before, then the anchor link, unless its {#id}
+			// attribute makes the anchor
+			if p.AnchorMap != nil {
+				numbered := safeAnchorName(p.sectionAnchor(h.Number, h.Title))
+				for _, alias := range p.AnchorMap.aliases(h.Title, h.Anchor, numbered, aliased) {
+					out.add(i, fmt.Sprintf(`<a name="%s"></a>`, alias))
+				}
+			}
+			if p.emitsHeadAnchors() && headingID(h.markers) == "" {
+				out.add(i, fmt.Sprintf(`<a name="%s"></a>`, h.Anchor))
+			}
+
+			// Insert the section number after the header hashes
+			line = fmt.Sprintf("%s %s%s", strings.Repeat("#", h.Level), p.headingNumber(h.Number), h.Title+h.markers)
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// headNumberer numbers the headings of a document one line at a time,
+// the way passMkHeads does.
+type headNumberer struct {
+	p       *Processor
+	numbers *numberer
+	h1s     *h1Tracker
+	gaps    *levelFixer
+	anchor  func(number, title string) string
+}
+
+// newHeadNumberer returns a headNumberer continuing from numbers.
+func (p *Processor) newHeadNumberer(numbers *numberer) *headNumberer {
+	return &headNumberer{
+		p:       p,
+		numbers: numbers,
+		h1s:     &h1Tracker{policy: p.MultipleH1},
+		gaps:    &levelFixer{fix: p.FixHeadingGaps},
+		anchor:  p.anchorNamer(),
+	}
+}
+
+// next returns the numbered heading line is, or nil if it isn't one.
+// It must be called for each line outside code blocks in turn.  The
+// heading's Line is left unset.
+func (hn *headNumberer) next(line string) *heading {
+	if isAppendixMarker(line) {
+		hn.numbers.startAppendix()
+	}
+	if counts, ok := resetDirective(line); ok {
+		hn.numbers.counts, hn.numbers.appendix = counts, false
+	}
+	m := headerRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	if isUnnumbered(m[2]) {
+		hn.gaps.keep(len(m[1]), len(m[1]))
+		return nil
+	}
+	level, extra := hn.h1s.level(len(m[1]))
+	level, gap := hn.gaps.level(level)
+	number := hn.numbers.next(level)
+	title, markers := headingMarkers(m[2])
+	anchor := hn.anchor(number, title)
+	if id := headingID(markers); id != "" {
+		anchor = id
+	}
+	h := &heading{
+		Section: Section{Level: level, Number: number, Title: title, Anchor: anchor},
+		markers: markers,
+		extra:   extra,
+		gap:     gap,
+	}
+	if hn.p.precedesAppendix(title) {
+		hn.numbers.startAppendix()
+	}
+	return h
+}
+
+// isUnnumbered reports whether a heading with title has opted out of
+// numbering and anchor generation.
+func isUnnumbered(title string) bool {
+	return unnumberedRe.MatchString(title)
+}
+
+// numberer assigns hierarchical section numbers to headings.
+type numberer struct {
+	counts []int
+	// appendix is set once top-level sections are lettered.
+	appendix bool
+	// format is the style of the numbers at each level.
+	format numberFormat
+}
+
+// next returns the section number, e.g. "1.2.3", of the next heading
+// at level.
+func (n *numberer) next(level int) string {
+	// Extend counts slice if current level exceeds its length
+	for len(n.counts) < level {
+		n.counts = append(n.counts, 0)
+	}
+
+	// Increment the current level's count
+	n.counts[level-1]++
+
+	// Reset counts for deeper levels
+	for i := level; i < len(n.counts); i++ {
+		n.counts[i] = 0
+	}
+
+	// Build the section number string
+	parts := []string{}
+	for i := 0; i < level; i++ {
+		if i == 0 && n.appendix && n.counts[0] > 0 {
+			parts = append(parts, appendixLetter(n.counts[0]))
+			continue
+		}
+		parts = append(parts, n.format.format(i+1, n.counts[i]))
+	}
+	return strings.Join(parts, ".")
+}
+
+// sectionTargets returns the numbered headings of lines, which come
+// from file, keyed by their lowercased titles, later headings with the
+// same title as an earlier one being its Duplicates.
+func (p *Processor) sectionTargets(lines []string, file string) map[string]Target {
+	sectionTargets := map[string]Target{}
+	anchor := p.anchorNamer()
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if headerMatch := p.numberedHeading(line); len(headerMatch) > 0 {
+			number := headerMatch[2]
+			number = strings.TrimSuffix(number, ".")
+			text, id, _ := headingTitle(headerMatch[3])
+			lowerText := strings.ToLower(text)
+			name := anchor(number, text)
+			if id != "" {
+				name = id
+			}
+			addTarget(sectionTargets, lowerText, Target{Name: name, Heading: text, Number: number, HeadingLower: lowerText, File: file, ID: id})
+		}
+	}
+	return sectionTargets
+}
+
+func (p *Processor) passLinkHeads(lines []string) []string {
+	newLines := []string{}
+	sectionTargets := p.project.sectionTargets()
+	if sectionTargets == nil {
+		sectionTargets = p.sectionTargets(lines, "")
+	}
+
+	// number of the section the current line is in
+	currentNumber := ""
+	// titles maps each lowercased heading title to its first line
+	titles := map[string]int{}
+
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			newLines = append(newLines, line)
+			continue
+		}
+		if headerMatch := p.numberedHeading(line); len(headerMatch) > 0 {
+			currentNumber = strings.TrimSuffix(headerMatch[2], ".")
+			title, _, _ := headingTitle(headerMatch[3])
+			if j, ok := titles[strings.ToLower(title)]; ok {
+				p.warnf("duplicate-heading", i, title, "Heading %q on line %d has the same title as line %d; references to it resolve to the nearer one", title, p.lineOf(i), p.lineOf(j))
+			} else {
+				titles[strings.ToLower(title)] = i
+			}
+		}
+		newLines = append(newLines, outsideCode(line, func(line string) string {
+			return p.linkHeads(line, i, sectionTargets, currentNumber)
+		}))
+	}
+
+	return newLines
+}
+
+// linkHeads returns line i of the pass input, which is in section
+// currentNumber, with its [sec ...] references linked to sectionTargets.
+func (p *Processor) linkHeads(line string, i int, sectionTargets map[string]Target, currentNumber string) string {
+	for _, match := range sectionRefRegexp.FindAllStringSubmatch(line, -1) {
+		acronym := match[1]
+		if strings.HasPrefix(acronym, "#") {
+			line = strings.Replace(line, match[0], p.linkHeadID(i, match[0], acronym[1:], sectionTargets, currentNumber), -1)
+			continue
+		}
+		if link, ok := p.linkHeadNumber(i, match[0], acronym, sectionTargets, currentNumber); ok {
+			line = strings.Replace(line, match[0], link, -1)
+			continue
+		}
+		insertionOnly := p.matcher().Match(acronym, keys(sectionTargets))
+		if len(insertionOnly) > 1 && p.Choose != nil {
+			if key, ok := p.choose(i, acronym, insertionOnly, sectionTargets); ok {
+				insertionOnly = []string{key}
+			}
+		}
+
+		switch len(insertionOnly) {
+		case 0:
+			p.warnf("sec-unresolved", i, match[0], "[sec %s] no fuzzy match found", acronym)
+			p.fail("unresolved references")
+		case 1:
+			target := sectionTargets[insertionOnly[0]].nearest(currentNumber)
+			line = strings.Replace(line, match[0], p.linkHead(i, match[0], acronym, target, currentNumber), -1)
+		default:
+			msg := fmt.Sprintf("[sec %s] multiple fuzzy matches found:", acronym)
+			msg += candidates(acronym, insertionOnly, func(key string) string { return sectionTargets[key].Heading })
+			p.warnf("sec-ambiguous", i, match[0], "%s", msg)
+			p.fail("unresolved references")
+		}
+	}
+	return idRefRe.ReplaceAllStringFunc(line, func(match string) string {
+		id := idRefRe.FindStringSubmatch(match)[1]
+		ref := "[#" + id + "]"
+		return p.linkHeadID(i, ref, id, sectionTargets, currentNumber) + match[len(ref):]
+	})
+}
+
+// linkHead returns what replaces ref, a reference to target found on
+// line i as acronym: a link to target.
+func (p *Processor) linkHead(i int, ref, acronym string, target Target, currentNumber string) string {
+	p.RefLog.check(p, i, acronym, target.Heading)
+	p.recordRef(i, ref, target)
+	if target.Number == currentNumber {
+		p.warnf("sec-self-ref", i, ref, "%s refers to the section it appears in (%s)", ref, target.Number)
+		if p.SelfRefText != "" {
+			return p.SelfRefText
+		}
+	}
+	href := p.fileHref(target.File)
+	anchorLink := fmt.Sprintf(`<a href="%s#%s">%s</a>`, href, target.Name, p.secRefText(target))
+	if p.RefDirection && href == "" {
+		anchorLink += direction(p.numberFormat(), currentNumber, target.Number)
+	}
+	return fmt.Sprintf("[%s]", anchorLink)
+}
+
+// linkHeadNumber returns what replaces ref, a [sec ...] reference found
+// on line i, if number, the text of the reference, is a section number:
+// a link to the section with that number.  A number of digits with no
+// such section is reported and left as ref; anything else not naming a
+// section isn't taken for a number, so ok is false and it is matched
+// against titles instead.
+func (p *Processor) linkHeadNumber(i int, ref, number string, sectionTargets map[string]Target, currentNumber string) (link string, ok bool) {
+	if !sectionNumberRe.MatchString(number) {
+		return "", false
+	}
+	number = strings.TrimSuffix(number, ".")
+	for _, t := range sectionTargets {
+		if target := t.numbered(number); target.Number == number {
+			return p.linkHead(i, ref, number, target, currentNumber), true
+		}
+	}
+	if number[0] < '0' || number[0] > '9' {
+		return "", false
+	}
+	p.warnf("sec-unresolved", i, ref, "%s no section is numbered %s", ref, number)
+	p.fail("unresolved references")
+	return ref, true
+}
+
+// linkHeadID returns what replaces ref, a [sec #id] or [#id] reference
+// found on line i: a link to the section whose heading has that {#id}
+// attribute, or ref itself if there is none.  IDs are matched exactly,
+// so such references survive renumbering and retitling.
+func (p *Processor) linkHeadID(i int, ref, id string, sectionTargets map[string]Target, currentNumber string) string {
+	for _, t := range sectionTargets {
+		for _, target := range t.all() {
+			if target.ID == id {
+				return p.linkHead(i, ref, "#"+id, target, currentNumber)
+			}
+		}
+	}
+	p.warnf("sec-unresolved", i, ref, "%s no section has ID %s", ref, id)
+	p.fail("unresolved references")
+	return ref
+}
+
+// refText returns the text of a link to target following format, or
+// fallback if format is empty, with the title HTML-escaped.
+func refText(format, fallback string, target Target) string {
+	if format == "" {
+		format = fallback
+	}
+	r := strings.NewReplacer("{number}", target.Number, "{title}", escapeText(target.Heading))
+	return r.Replace(format)
+}
+
+// refTextRe returns a regexp matching the link texts refText makes
+// from format and fallback.
+func refTextRe(format, fallback string) *regexp.Regexp {
+	if format == "" {
+		format = fallback
+	}
+	r := strings.NewReplacer(
+		regexp.QuoteMeta("{number}"), `[\dA-Z][\d.]*`,
+		regexp.QuoteMeta("{title}"), `.+`,
+	)
+	return regexp.MustCompile("^" + r.Replace(regexp.QuoteMeta(format)) + "$")
+}
+
+// secRefText returns the text of a link to target, following
+// p.SecRefFormat.
+func (p *Processor) secRefText(target Target) string {
+	return refText(p.SecRefFormat, "sec {number}", target)
+}
+
+// secRefTextRe returns a regexp matching the text of links made from
+// [sec ...] references.
+func (p *Processor) secRefTextRe() *regexp.Regexp {
+	return refTextRe(p.SecRefFormat, "sec {number}")
+}
+
+// direction returns " above" or " below" depending on whether section
+// number to comes before or after section number from in document order,
+// or "" if they are the same section, written in format f.  An empty
+// from, for text before the first heading, comes before every section.
+func direction(f numberFormat, from, to string) string {
+	a, b := strings.Split(from, "."), strings.Split(to, ".")
+	if from == "" {
+		a = nil
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x := f.ordinal(i+1, a[i])
+		y := f.ordinal(i+1, b[i])
+		if x != y {
+			if y < x {
+				return " above"
+			}
+			return " below"
+		}
+	}
+	switch {
+	case len(b) < len(a):
+		// an enclosing section starts before its subsections
+		return " above"
+	case len(b) > len(a):
+		return " below"
+	}
+	return ""
+}
+
+// VerifyErrors is the error verification returns, listing every
+// problem it found.
+type VerifyErrors []error
+
+func (e VerifyErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems:\n  %s", len(e), strings.Join(msgs, "\n  "))
+}
+
+func (e VerifyErrors) Unwrap() []error {
+	return e
+}
+
+// lineError is a problem found on line i of the pass input.
+type lineError struct {
+	i   int
+	err error
+}
+
+// sortLineErrors returns the errors of errs, which are each in line
+// order, in line order, prefixed with their line in the input.
+func (p *Processor) sortLineErrors(errs ...[]lineError) (sorted []error) {
+	all := []lineError{}
+	for _, e := range errs {
+		all = append(all, e...)
+	}
+	sort.SliceStable(all, func(a, b int) bool { return all[a].i < all[b].i })
+	for _, e := range all {
+		sorted = append(sorted, fmt.Errorf("line %d: %w", p.lineOf(e.i), e.err))
+	}
+	return
+}
+
+// verify runs every check selected for lines, the processed document,
+// returning a VerifyErrors listing the problems they found, or nil.
+func (p *Processor) verify(lines []string) (err error) {
+	var errs VerifyErrors
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.RequiredOutline != nil && p.project == nil {
+		check(p.verifyOutline())
+	}
+	if p.FrozenOutline != nil && p.project == nil {
+		check(p.verifyFrozen())
+	}
+	if p.RequireOwners && p.project == nil {
+		check(p.verifyOwners())
+	}
+	check(p.verifyExpiry())
+	if p.CheckURLs {
+		check(p.verifyURLs())
+	}
+	if p.A11y {
+		p.verifyA11y(lines)
+	}
+	if p.Rules != nil {
+		check(p.verifyRules())
+	}
+	if p.WarnOrphans {
+		p.verifyOrphans(lines)
+	}
+	errs = append(errs, p.verifyTargets(lines)...)
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+// verifyTargets checks, in one pass over the processed lines, that no
+// anchor name is defined twice and that every link within the document
+// points to an anchor it defines.  It records a warning for each
+// problem and returns them all in document order, each with its line
+// in the input.
+func (p *Processor) verifyTargets(lines []string) (errs []error) {
+	// defined maps each anchor name to the line defining it
+	defined := map[string]int{}
+	// generated holds the heading anchors the renderer makes with
+	// GitHub style anchors
+	generated := map[string]bool{}
+	type link struct {
+		name string
+		i    int
+	}
+	links := []link{}
+	var slug func(string) string
+	if p.AnchorStyle == AnchorGitHub {
+		slug = githubSlugger()
+	}
+
+	var duplicates, undefined []lineError
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		for _, name := range anchorTargets(line) {
+			if j, ok := defined[name]; ok {
+				msg := fmt.Sprintf("Duplicate target found: #%s, first defined on line %d", name, p.lineOf(j))
+				p.record("duplicate-target", i, name, msg)
+				duplicates = append(duplicates, lineError{i, errors.New(msg)})
+				continue
+			}
+			defined[name] = i
+		}
+		if slug != nil {
+			if m := headerRegexp.FindStringSubmatch(line); m != nil {
+				title, _ := tocExcluded(m[2])
+				generated[slug(title)] = true
+			}
+		}
+		for _, m := range localHrefRe.FindAllStringSubmatch(line, -1) {
+			links = append(links, link{name: m[1], i: i})
+		}
+	}
+
+	for _, l := range links {
+		if _, ok := defined[l.name]; !ok && !generated[l.name] {
+			msg := fmt.Sprintf("Link points to an undefined target: #%s", l.name)
+			p.record("undefined-target", l.i, l.name, msg)
+			undefined = append(undefined, lineError{l.i, errors.New(msg)})
+		}
+	}
+	return p.sortLineErrors(duplicates, undefined)
+}
+
+// keys returns the keys of m, sorted, so that matchers see the
+// candidates in the same order on every run.
+func keys(m map[string]Target) []string {
+	s := make([]string, 0, len(m))
+	for key := range m {
+		s = append(s, key)
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,570 @@
+package markproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestPassMkExterns(t *testing.T) {
+	lines := []string{
+		"[ref1]: A bibliographic reference.",
+		"No externs here.",
+	}
+	expectedLines := []string{
+		`<a name="ref1"></a>`,
+		`[ref1]: A bibliographic reference.`,
+		"No externs here.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passMkExterns(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passMkExterns failed:\nwant: %v\nhave: %v", expectedLines, result)
+	}
+}
+
+func TestPassMkHeads(t *testing.T) {
+	lines := []string{
+		"# Top-Level Header",
+		"This is a paragraph.",
+		"## Sub-Level Header",
+	}
+	expectedLines := []string{
+		`<a name="sec1"></a>`,
+		`# 1. Top-Level Header`,
+		"This is a paragraph.",
+		`<a name="sec1_1"></a>`,
+		`## 1.1. Sub-Level Header`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passMkHeads(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passMkHeads failed:\nwant: %v\nhave: %v", expectedLines, result)
+	}
+}
+
+func TestPassLinkExterns(t *testing.T) {
+	lines := []string{
+		"This is a [reference] to something.",
+		"This is a [sec fooee] reference.",
+		"No refs here.",
+	}
+	expectedLines := []string{
+		"This is a [<a href=\"#reference\">reference</a>] to something.",
+		"This is a [sec fooee] reference.",
+		"No refs here.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passLinkExterns(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passLinkExterns failed:\nwant: %v\nhave: %v", expectedLines, result)
+	}
+}
+
+func TestPassLinkExternsMarkdownSyntax(t *testing.T) {
+	lines := []string{
+		"- [x] done, see [reference].",
+		"- [ ] not done",
+		"![logo] and ![alt][logo] are images, [reference] isn't.",
+		"[![build][badge]][ci] is a badge.",
+		"A [reference link][reference], a [collapsed][] one.",
+		`An escaped \[reference] isn't one either.`,
+	}
+	expectedLines := []string{
+		"- [x] done, see [<a href=\"#reference\">reference</a>].",
+		"- [ ] not done",
+		"![logo] and ![alt][logo] are images, [<a href=\"#reference\">reference</a>] isn't.",
+		"[![build][badge]][ci] is a badge.",
+		"A [reference link][reference], a [collapsed][] one.",
+		`An escaped \[reference] isn't one either.`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passLinkExterns(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passLinkExterns failed:\nwant: %q\nhave: %q", expectedLines, result)
+	}
+}
+
+func TestPassLinkHeads(t *testing.T) {
+	lines := []string{
+		"This is a [reference] to something.",
+		"This is a [sec fooee] reference.",
+		"No refs here.",
+		`<a name="sec1"></a>`,
+		`## 1. Title`,
+		`<a name="sec2_3"></a>`,
+		`## 2.3. Fun Object Overtone`,
+		`<a name="sec7_9">`,
+		`</a>## 7.9. Something`,
+	}
+	expectedLines := []string{
+		"This is a [reference] to something.",
+		"This is a [<a href=\"#sec2_3\">sec 2.3</a>] reference.",
+		"No refs here.",
+		`<a name="sec1"></a>`,
+		`## 1. Title`,
+		`<a name="sec2_3"></a>`,
+		`## 2.3. Fun Object Overtone`,
+		`<a name="sec7_9">`,
+		`</a>## 7.9. Something`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passLinkHeads(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("\nwant: %v\nhave: %v", expectedLines, result)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	lines := []string{
+		`<a name="sec1"></a>`,
+		`# 1. Title`,
+		`<a href="#sec1">link to title</a>`,
+		`<a href="#missing">link to missing</a>`,
+		`<a name="sec1"></a>`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	err := p.verify(lines)
+	Tassert(t, err != nil, "verify did not catch any errors")
+	var problems VerifyErrors
+	Tassert(t, errors.As(err, &problems) && len(problems) == 2, "have %v", err)
+	Tassert(t, problems[0].Error() == "line 4: Link points to an undefined target: #missing", "have %v", problems[0])
+	Tassert(t, problems[1].Error() == "line 5: Duplicate target found: #sec1, first defined on line 1", "have %v", problems[1])
+	have := []string{}
+	for _, w := range p.Warnings() {
+		have = append(have, fmt.Sprintf("%d %s", w.Line, w.Message))
+	}
+	want := []string{
+		"5 Duplicate target found: #sec1, first defined on line 1",
+		"4 Link points to an undefined target: #missing",
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+
+	lines = []string{
+		`<a name="sec1"></a>`,
+		`# 1. Title`,
+		`<a href="#sec1">link to title</a>`,
+		`<a href="http://example.com">link to example</a>`,
+	}
+
+	err = p.verify(lines)
+	Tassert(t, err == nil, "verify failed: %v", err)
+
+	// anchors split across lines are still targets
+	lines = []string{
+		`<a name="sec7_9">`,
+		`</a>## 7.9. Something`,
+		`<a href="#sec7_9">link to something</a>`,
+	}
+	err = p.verify(lines)
+	Tassert(t, err == nil, "verify failed: %v", err)
+}
+
+func TestMarkdownPreprocessor(t *testing.T) {
+	input := `# A Top-Level Header
+
+This is the first section.
+
+## A Sub-Level Header
+
+This is a reference to the Section One heading [sec top].
+
+Reference to the anchor below [ref1].
+
+## References
+
+[ref1]: A bibliographic reference.`
+
+	expectedOutput := `<a name="sec1"></a>
+# 1. A Top-Level Header
+
+This is the first section.
+
+<a name="sec1_1"></a>
+## 1.1. A Sub-Level Header
+
+This is a reference to the Section One heading [<a href="#sec1">sec 1</a>].
+
+Reference to the anchor below [<a href="#ref1">ref1</a>].
+
+<a name="sec1_2"></a>
+## 1.2. References
+
+<a name="ref1"></a>
+[ref1]: A bibliographic reference.`
+
+	cmd := exec.Command("go", "run", "./cmd/markproc")
+	cmd.Stdin = bytes.NewReader([]byte(input))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("cmd.Run() failed with %s\n", err)
+	}
+
+	actualOutput := string(output)
+	// line-by-line comparison
+	wantLines := strings.Split(expectedOutput, "\n")
+	haveLines := strings.Split(actualOutput, "\n")
+	for i, want := range wantLines {
+		if i >= len(haveLines) {
+			t.Errorf("output is shorter than expected")
+			break
+		}
+		if want != haveLines[i] {
+			t.Errorf("line %d:\nwant: %q\nhave: %q", i, want, haveLines[i])
+		}
+	}
+}
+
+func TestComplexSectionStructure(t *testing.T) {
+	// Read input file
+	input, err := os.ReadFile("testdata/sections-in.md")
+	if err != nil {
+		t.Fatalf("Failed to read sections-in.md: %v", err)
+	}
+	inputLines := strings.Split(string(input), "\n")
+
+	// Process input with passMkHeads
+	p := NewProcessor(DefaultOptions())
+	outputLines := p.passMkHeads(inputLines)
+
+	// XXX temporarily write output to file for debugging
+	err = os.WriteFile("/tmp/sections-out.md", []byte(strings.Join(outputLines, "\n")), 0644)
+	Ck(err)
+
+	// Read expected output
+	expected, err := os.ReadFile("testdata/sections-out.md")
+	if err != nil {
+		t.Fatalf("Failed to read sections-out.md: %v", err)
+	}
+	expectedLines := strings.Split(string(expected), "\n")
+
+	// Compare output with expected
+	if len(outputLines) != len(expectedLines) {
+		t.Errorf("Output length (%d) does not match expected length (%d)", len(outputLines), len(expectedLines))
+	}
+	for i := 0; i < len(expectedLines); i++ {
+		want := expectedLines[i]
+		have := outputLines[i]
+		// Pf("have: %q\n", have)
+		if have != want {
+			t.Fatalf("Mismatch at line %d:\nwant: %q\nhave: %q", i+1, want, have)
+		}
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	input := "line one\nline two\n"
+
+	lines, err := ReadLimited(strings.NewReader(input), int64(len(input)))
+	Tassert(t, err == nil, "input at the limit rejected: %v", err)
+	Tassert(t, len(lines) == 2, "want 2 lines, have %d", len(lines))
+
+	_, err = ReadLimited(strings.NewReader(input), int64(len(input)-1))
+	Tassert(t, err != nil, "oversized input accepted")
+
+	lines, err = ReadLimited(strings.NewReader(input), 0)
+	Tassert(t, err == nil && len(lines) == 2, "unlimited read failed: %v", err)
+}
+
+func TestProcess(t *testing.T) {
+	lines := []string{
+		"# Title",
+		"See [ref1] and [sec ttl].",
+		"[ref1]: A reference.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[1] == "# 1. Title", "have %q", out[1])
+	Tassert(t, out[2] == `See [<a href="#ref1">ref1</a>] and [<a href="#sec1">sec 1</a>].`, "have %q", out[2])
+
+	passes := []string{}
+	for _, pt := range p.Timings() {
+		passes = append(passes, pt.Pass)
+	}
+	Tassert(t, reflect.DeepEqual(passes, []string{"quotes", "linkdefs", "setext", "conditions", "normalize", "strip", "mkexterns", "mkheads", "mktoc", "mkeqs", "mkindex", "linkexterns", "related", "linkheads", "linkeqs", "glossary", "acronyms", "verify"}), "have %v", passes)
+
+	// disabling passes leaves their constructs alone
+	opts := DefaultOptions()
+	opts.MkHeads = false
+	opts.LinkHeads = false
+	p = NewProcessor(opts)
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[0] == "# Title", "have %q", out[0])
+	Tassert(t, out[1] == `See [<a href="#ref1">ref1</a>] and [sec ttl].`, "have %q", out[1])
+
+	// an unresolvable reference is reported as an error
+	p = NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{"# Title", "See [sec nowhere]."})
+	Tassert(t, err != nil, "unresolved reference not reported")
+}
+
+func TestSelfReference(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"## Scope",
+		"As [sec scp] says, see [sec intr].",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[4] == `As [<a href="#sec1_1">sec 1.1</a>] says, see [<a href="#sec1">sec 1</a>].`, "have %q", out[4])
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "sec-self-ref", "have %v", warnings)
+
+	p.SelfRefText = "this section"
+	out, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[4] == `As this section says, see [<a href="#sec1">sec 1</a>].`, "have %q", out[4])
+}
+
+func TestWarningLines(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"Some text.",
+		"### Deep",
+		"See [sec nowhere].",
+		"[ref1]: A reference.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	_, err := p.Process(lines)
+	Tassert(t, err != nil, "unresolved reference not reported")
+	have := map[string]int{}
+	for _, w := range p.Warnings() {
+		have[w.Rule] = w.Line
+	}
+	want := map[string]int{"heading-gap": 3, "sec-unresolved": 4}
+	Tassert(t, reflect.DeepEqual(have, want), "want %v, have %v", want, have)
+
+	w := p.Warnings()[1]
+	Tassert(t, w.Column == 5 && w.Severity == SeverityError, "have %v", w)
+}
+
+func TestRefDirection(t *testing.T) {
+	lines := []string{
+		"See [sec dsgn].",
+		"# Intro",
+		"## Scope",
+		"As [sec intr] says, see [sec dsgn].",
+		"# Design",
+	}
+
+	opts := DefaultOptions()
+	opts.RefDirection = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[0] == `See [<a href="#sec2">sec 2</a> below].`, "have %q", out[0])
+	Tassert(t, out[5] == `As [<a href="#sec1">sec 1</a> above] says, see [<a href="#sec2">sec 2</a> below].`, "have %q", out[5])
+
+	for _, c := range []struct{ from, to, want string }{
+		{"1.3", "4.2", " below"},
+		{"4.2", "1.3", " above"},
+		{"1", "1.2", " below"},
+		{"1.2", "1", " above"},
+		{"10", "9", " above"},
+		{"2", "2", ""},
+	} {
+		have := direction(nil, c.from, c.to)
+		Tassert(t, have == c.want, "%s -> %s: want %q, have %q", c.from, c.to, c.want, have)
+	}
+}
+
+func TestAppendix(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"See [sec glsr].",
+		"<!-- appendix -->",
+		"# Glossary",
+		"## Terms",
+		"# Changes",
+	}
+
+	opts := DefaultOptions()
+	opts.RefDirection = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		`See [<a href="#secA">sec A</a> below].`,
+		"<!-- appendix -->",
+		`<a name="secA"></a>`,
+		"# A. Glossary",
+		`<a name="secA_1"></a>`,
+		"## A.1. Terms",
+		`<a name="secB"></a>`,
+		"# B. Changes",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	opts.AppendixAfter = "intro"
+	p = NewProcessor(opts)
+	sections := p.Outline([]string{"# Intro", "# Glossary", "## Terms"})
+	numbers := []string{}
+	for _, s := range sections {
+		numbers = append(numbers, s.Number)
+	}
+	Tassert(t, reflect.DeepEqual(numbers, []string{"1", "A", "A.1"}), "have %v", numbers)
+
+	Tassert(t, appendixLetter(27) == "AA", "have %q", appendixLetter(27))
+}
+
+func TestLetterTitles(t *testing.T) {
+	lines := []string{
+		"# C. Elegans Research",
+		"## A. Thaliana",
+		"<!-- appendix -->",
+		"# Glossary",
+	}
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. C. Elegans Research",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. A. Thaliana",
+		"<!-- appendix -->",
+		`<a name="secA"></a>`,
+		"# A. Glossary",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+}
+
+func TestUnnumberedHeadings(t *testing.T) {
+	lines := []string{
+		"# Preface {-}",
+		"# Intro",
+		"## Notes <!-- nonum -->",
+		"## Scope",
+		"# Index {.unnumbered}",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		"# Preface {-}",
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"## Notes <!-- nonum -->",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Scope",
+		"# Index {.unnumbered}",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	Tassert(t, len(p.Outline(lines)) == 2, "have %v", p.Outline(lines))
+}
+
+func TestExternDisplay(t *testing.T) {
+	lines := []string{
+		"See [rfc2119] and [ref1].",
+		`[rfc2119]: Key words for use in RFCs. display: "RFC 2119"`,
+		"[ref1]: A reference.",
+	}
+
+	opts := DefaultOptions()
+	opts.ExternDisplay = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`See [<a href="#rfc2119">RFC 2119</a>] and [<a href="#ref1">ref1</a>].`,
+		`<a name="rfc2119"></a>`,
+		"[rfc2119]: Key words for use in RFCs.",
+		`<a name="ref1"></a>`,
+		"[ref1]: A reference.",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	displays := externDisplays([]string{"[x]: Foo. display: Bar Baz "})
+	Tassert(t, displays["x"] == "Bar Baz", "have %q", displays["x"])
+}
+
+func TestSectionNumberRefs(t *testing.T) {
+	lines := []string{
+		"# Top",
+		"See [sec 1.2], [sec 1.2.] and [sec A.1].",
+		"## One",
+		"## Two",
+		"<!-- appendix -->",
+		"# Extra",
+		"## Notes",
+	}
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := `See [<a href="#sec1_2">sec 1.2</a>], [<a href="#sec1_2">sec 1.2</a>] and [<a href="#secA_1">sec A.1</a>].`
+	Tassert(t, out[2] == want, "\nwant: %q\nhave: %q", want, out[2])
+
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{"# Top", "See [sec 3.1]."})
+	Tassert(t, err != nil, "want error for a missing section number")
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "sec-unresolved" && strings.Contains(warnings[0].Message, "no section is numbered 3.1"), "have %v", warnings)
+}
+
+func TestDuplicateHeadings(t *testing.T) {
+	lines := []string{
+		"# Alpha",
+		"## Notes",
+		"See [sec nts].",
+		"# Beta",
+		"## Notes",
+		"See [sec nts] and [sec 1.1].",
+	}
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Alpha",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Notes",
+		`See [<a href="#sec1_1">sec 1.1</a>].`,
+		`<a name="sec2"></a>`,
+		"# 2. Beta",
+		`<a name="sec2_1"></a>`,
+		"## 2.1. Notes",
+		`See [<a href="#sec2_1">sec 2.1</a>] and [<a href="#sec1_1">sec 1.1</a>].`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+	warnings := []Warning{}
+	for _, w := range p.Warnings() {
+		if w.Rule == "duplicate-heading" {
+			warnings = append(warnings, w)
+		}
+	}
+	Tassert(t, len(warnings) == 1 && warnings[0].Line == 5 && strings.Contains(warnings[0].Message, "line 2"), "have %+v", warnings)
+}
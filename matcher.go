@@ -0,0 +1,222 @@
+package markproc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stevegt/fuzzy"
+)
+
+// Matcher resolves the text of a [sec ...] or [eq ...] reference to the
+// keys, lowercased titles, of the targets it may mean.  A reference
+// resolves only if exactly one key is returned.  Options.Matcher
+// selects the Matcher; teams can supply their own, e.g. one comparing
+// embeddings.
+type Matcher interface {
+	Match(ref string, keys []string) []string
+}
+
+// InsertionMatcher, the default, matches the keys that ref abbreviates,
+// i.e. that can be made from it by inserting characters only, or also
+// substituting up to MaxSubstitutions of them to tolerate typos.  A key
+// equal to ref isn't matched.
+type InsertionMatcher struct {
+	MaxSubstitutions int
+}
+
+func (m InsertionMatcher) Match(ref string, keys []string) (matches []string) {
+	for _, fm := range fuzzy.Match(strings.ToLower(ref), keys) {
+		if fm.Insertions+fm.Substitutions > 0 && fm.Substitutions <= m.MaxSubstitutions && fm.Deletions == 0 {
+			matches = append(matches, fm.Original)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// LevenshteinMatcher matches the keys closest to ref by edit distance,
+// if they are at most MaxDistance edits away, which tolerates typos.
+type LevenshteinMatcher struct {
+	MaxDistance int
+}
+
+func (m LevenshteinMatcher) Match(ref string, keys []string) (matches []string) {
+	best := m.MaxDistance + 1
+	for _, fm := range fuzzy.Match(strings.ToLower(ref), keys) {
+		distance := fm.Insertions + fm.Deletions + fm.Substitutions
+		switch {
+		case distance < best:
+			best = distance
+			matches = []string{fm.Original}
+		case distance == best:
+			matches = append(matches, fm.Original)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// TokenSetMatcher compares the sets of words in ref and each key,
+// ignoring their order, and matches the keys sharing the largest
+// fraction of words with ref if that is at least Threshold.  The
+// fraction is the Dice coefficient: twice the shared words over the
+// words in both.
+type TokenSetMatcher struct {
+	Threshold float64
+}
+
+func (m TokenSetMatcher) Match(ref string, keys []string) (matches []string) {
+	words := wordSet(strings.ToLower(ref))
+	best := m.Threshold
+	for _, key := range keys {
+		keyWords := wordSet(key)
+		shared := 0
+		for w := range words {
+			if keyWords[w] {
+				shared++
+			}
+		}
+		if len(words)+len(keyWords) == 0 {
+			continue
+		}
+		score := 2 * float64(shared) / float64(len(words)+len(keyWords))
+		switch {
+		case score > best:
+			best = score
+			matches = []string{key}
+		case score == best && shared > 0:
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// ExactMatcher matches the key equal to ref, ignoring case and
+// surrounding space.
+type ExactMatcher struct{}
+
+func (ExactMatcher) Match(ref string, keys []string) (matches []string) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	for _, key := range keys {
+		if key == ref {
+			matches = append(matches, key)
+		}
+	}
+	return
+}
+
+// PrefixMatcher matches the keys starting with ref, ignoring case.
+type PrefixMatcher struct{}
+
+func (PrefixMatcher) Match(ref string, keys []string) (matches []string) {
+	ref = strings.ToLower(ref)
+	for _, key := range keys {
+		if strings.HasPrefix(key, ref) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// SubstringMatcher matches the keys containing ref, ignoring case.
+type SubstringMatcher struct{}
+
+func (SubstringMatcher) Match(ref string, keys []string) (matches []string) {
+	ref = strings.ToLower(ref)
+	for _, key := range keys {
+		if strings.Contains(key, ref) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// WordMatcher matches the keys in which each word of ref, in order,
+// starts a word, e.g. "dist cons" matches "distributed consensus
+// protocols".
+type WordMatcher struct{}
+
+func (WordMatcher) Match(ref string, keys []string) (matches []string) {
+	words := strings.Fields(strings.ToLower(ref))
+	if len(words) == 0 {
+		return
+	}
+	for _, key := range keys {
+		next := 0
+		for _, w := range strings.Fields(key) {
+			if next < len(words) && strings.HasPrefix(w, words[next]) {
+				next++
+			}
+		}
+		if next == len(words) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+// Matchers maps the names accepted by the matcher setting to Matchers.
+var Matchers = map[string]Matcher{
+	"insertion":   InsertionMatcher{},
+	"levenshtein": LevenshteinMatcher{MaxDistance: 2},
+	"token-set":   TokenSetMatcher{Threshold: 0.5},
+	"exact":       ExactMatcher{},
+	"prefix":      PrefixMatcher{},
+	"substring":   SubstringMatcher{},
+	"words":       WordMatcher{},
+}
+
+// maxEditsOption sets the number of edits tolerated by the insertion
+// and levenshtein matchers.
+func maxEditsOption() option {
+	return option{func(o *Options, value string) (err error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+		if n < 0 {
+			return fmt.Errorf("%d is negative", n)
+		}
+		switch o.Matcher.(type) {
+		case nil, InsertionMatcher:
+			o.Matcher = InsertionMatcher{MaxSubstitutions: n}
+		case LevenshteinMatcher:
+			o.Matcher = LevenshteinMatcher{MaxDistance: n}
+		default:
+			return fmt.Errorf("only the insertion and levenshtein matchers allow edits")
+		}
+		return
+	}}
+}
+
+// candidates lists the keys ref matched, for a warning that it is
+// ambiguous: the heading of each, as given by heading, with its edit
+// distance from ref, closest first.
+func candidates(ref string, keys []string, heading func(key string) string) (list string) {
+	distances := map[string]int{}
+	for _, fm := range fuzzy.Match(strings.ToLower(ref), keys) {
+		distances[fm.Original] = fm.Insertions + fm.Deletions + fm.Substitutions
+	}
+	sorted := append([]string{}, keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return distances[sorted[i]] < distances[sorted[j]]
+	})
+	for _, key := range sorted {
+		list += fmt.Sprintf("\n  %s (distance %d)", heading(key), distances[key])
+	}
+	return
+}
+
+// matcher returns the Matcher selected by p.Matcher.
+func (p *Processor) matcher() Matcher {
+	if p.Matcher == nil {
+		return InsertionMatcher{}
+	}
+	return p.Matcher
+}
@@ -0,0 +1,74 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestMatchers(t *testing.T) {
+	keys := []string{"design goals", "design", "security considerations"}
+	cases := []struct {
+		m    Matcher
+		ref  string
+		want []string
+	}{
+		{InsertionMatcher{}, "dgoals", []string{"design goals"}},
+		{InsertionMatcher{}, "design", []string{"design goals"}},
+		{InsertionMatcher{}, "desgin", nil},
+		{LevenshteinMatcher{MaxDistance: 2}, "desgin", []string{"design"}},
+		{LevenshteinMatcher{MaxDistance: 2}, "secrity", nil},
+		{TokenSetMatcher{Threshold: 0.5}, "goals design", []string{"design goals"}},
+		{TokenSetMatcher{Threshold: 0.5}, "considerations", []string{"security considerations"}},
+		{TokenSetMatcher{Threshold: 0.5}, "overview", nil},
+		{InsertionMatcher{MaxSubstitutions: 1}, "dezign", []string{"design", "design goals"}},
+		{InsertionMatcher{MaxSubstitutions: 1}, "dezigm goalz", nil},
+		{ExactMatcher{}, "Design", []string{"design"}},
+		{ExactMatcher{}, "desig", nil},
+		{PrefixMatcher{}, "desig", []string{"design", "design goals"}},
+		{PrefixMatcher{}, "goals", nil},
+		{SubstringMatcher{}, "goal", []string{"design goals"}},
+		{WordMatcher{}, "sec cons", []string{"security considerations"}},
+		{WordMatcher{}, "cons sec", nil},
+	}
+	for _, c := range cases {
+		have := c.m.Match(c.ref, keys)
+		Tassert(t, reflect.DeepEqual(have, c.want), "%T %q: want %q, have %q", c.m, c.ref, c.want, have)
+	}
+
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	Ck(opts.Set("matcher", "levenshtein"))
+	out, err := NewProcessor(opts).Process([]string{"# Design", "See [sec desgin]."})
+	Ck(err)
+	Tassert(t, out[2] == `See [<a href="#sec1">sec 1</a>].`, "have %q", out[2])
+	Tassert(t, opts.Set("matcher", "psychic") != nil, "unknown matcher accepted")
+
+	Ck(opts.Set("max-edits", "1"))
+	Tassert(t, opts.Matcher == LevenshteinMatcher{MaxDistance: 1}, "have %#v", opts.Matcher)
+	Ck(opts.Set("matcher", "words"))
+	Tassert(t, opts.Set("max-edits", "1") != nil, "edits allowed for the words matcher")
+}
+
+func TestAmbiguityDistances(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err := p.Process([]string{"# Design goals", "# Design", "See [sec dsg]."})
+	Tassert(t, err != nil, "want an error for an ambiguous reference")
+	want := "[sec dsg] multiple fuzzy matches found:\n  Design (distance 3)\n  Design goals (distance 9)"
+	msg := p.Warnings()[0].Message
+	Tassert(t, msg == want, "\nwant: %q\nhave: %q", want, msg)
+}
+
+func TestKeysSorted(t *testing.T) {
+	targets := map[string]Target{"security": {}, "design goals": {}, "abstract": {}, "design": {}}
+	want := []string{"abstract", "design", "design goals", "security"}
+	for i := 0; i < 10; i++ {
+		have := keys(targets)
+		Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+	}
+}
@@ -0,0 +1,88 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// loneAnchorOpenRe matches a line holding nothing but an opening
+	// <a name> tag.
+	loneAnchorOpenRe = regexp.MustCompile(`^\s*<a\s+name="([^"]+)"\s*>\s*$`)
+	// anchorIDRe matches an <a> tag whose only attribute is id.
+	anchorIDRe = regexp.MustCompile(`<a\s+id="([^"]+)"\s*>`)
+	// headingIDRe matches a heading ending in a {#id} attribute.
+	headingIDRe = regexp.MustCompile(`^(#+\s+.*?)\s*\{#([^}\s]+)\}\s*$`)
+)
+
+// passNormalizeAnchors rewrites hand-written anchors whose closing tag
+// was split onto the next line, e.g.
+//
+//	<a name="sec7_9">
+//	</a>## 7.9. Something
+//
+// into the single-line form markproc itself emits, so that whatever
+// followed the closing tag (here a heading) is seen by later passes.
+func (p *Processor) passNormalizeAnchors(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := loneAnchorOpenRe.FindStringSubmatch(line)
+		if code[i] || m == nil || i+1 >= len(lines) || code[i+1] {
+			out.add(i, line)
+			continue
+		}
+		next := strings.TrimLeft(lines[i+1], " \t")
+		if !strings.HasPrefix(next, "</a>") {
+			out.add(i, line)
+			continue
+		}
+		out.add(i, fmt.Sprintf(`<a name="%s"></a>`, m[1]))
+		if rest := strings.TrimPrefix(next, "</a>"); rest != "" {
+			out.add(i+1, rest)
+		}
+		i++
+	}
+	return p.done(out)
+}
+
+// passConvertAnchors rewrites <a id="x"> tags into <a name="x"> tags,
+// and moves a {#x} attribute at the end of a heading into an
+// <a name="x"></a> tag on the line before it.  Anchors whose names
+// could break out of the name attribute are left alone.
+func (p *Processor) passConvertAnchors(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		line = anchorIDRe.ReplaceAllStringFunc(line, func(tag string) string {
+			name := anchorIDRe.FindStringSubmatch(tag)[1]
+			if !p.safeAnchor(i, name) {
+				return tag
+			}
+			return fmt.Sprintf(`<a name="%s">`, name)
+		})
+		if m := headingIDRe.FindStringSubmatch(line); m != nil && p.safeAnchor(i, m[2]) {
+			out.add(i, fmt.Sprintf(`<a name="%s"></a>`, m[2]))
+			line = m[1]
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
+
+// safeAnchor reports whether name, an anchor name found on line i of
+// the pass input, is safe to write into a name attribute, warning if
+// it isn't.
+func (p *Processor) safeAnchor(i int, name string) bool {
+	if anchorNameRe.MatchString(name) {
+		return true
+	}
+	p.warnf("anchor-unsafe", i, name, "Anchor name %q has characters not allowed in anchor names; left unconverted", name)
+	return false
+}
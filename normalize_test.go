@@ -0,0 +1,58 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPassNormalizeAnchors(t *testing.T) {
+	lines := []string{
+		`<a name="sec7_9">`,
+		`</a>## 7.9. Something`,
+		`  <a name="ref1" >`,
+		`</a>`,
+		`<a name="keep">`,
+		`Text that is linked</a>`,
+		`<a name="sec1"></a>`,
+	}
+	expectedLines := []string{
+		`<a name="sec7_9"></a>`,
+		`## 7.9. Something`,
+		`<a name="ref1"></a>`,
+		`<a name="keep">`,
+		`Text that is linked</a>`,
+		`<a name="sec1"></a>`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passNormalizeAnchors(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passNormalizeAnchors failed:\nwant: %q\nhave: %q", expectedLines, result)
+	}
+}
+
+func TestPassConvertAnchors(t *testing.T) {
+	lines := []string{
+		`<a id="intro"></a>`,
+		`## Design Goals {#goals}`,
+		"```",
+		`## In code {#nope}`,
+		"```",
+		`Text with <a id="span">a span</a>.`,
+	}
+	expectedLines := []string{
+		`<a name="intro"></a>`,
+		`<a name="goals"></a>`,
+		`## Design Goals`,
+		"```",
+		`## In code {#nope}`,
+		"```",
+		`Text with <a name="span">a span</a>.`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passConvertAnchors(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passConvertAnchors failed:\nwant: %q\nhave: %q", expectedLines, result)
+	}
+}
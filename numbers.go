@@ -0,0 +1,103 @@
+package markproc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NumberState is where section numbering stopped at the end of a
+// document, so that the next document, e.g. the next chapter, can
+// carry on from there.
+type NumberState struct {
+	// Counts holds the number of the last heading at each level, e.g.
+	// [3 2] after section 3.2.
+	Counts []int `json:"counts"`
+	// Appendix is set once top-level sections are lettered.
+	Appendix bool `json:"appendix,omitempty"`
+}
+
+// ReadNumberState reads a NumberState written by Write.  A missing file
+// is not an error; it yields nil, so numbering starts afresh.
+func ReadNumberState(path string) (state *NumberState, err error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	state = &NumberState{}
+	err = json.Unmarshal(buf, state)
+	return
+}
+
+// Write writes state to the file at path as JSON.
+func (state *NumberState) Write(path string) (err error) {
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// NumberState returns where numbering stopped at the end of the last
+// Process or ProcessProject call, or nil if headings weren't numbered.
+func (p *Processor) NumberState() *NumberState {
+	if p.numbers == nil {
+		return nil
+	}
+	return &NumberState{
+		Counts:   append([]int{}, p.numbers.counts...),
+		Appendix: p.numbers.appendix,
+	}
+}
+
+// parseStartNumber returns the counts a numberer starts from so that
+// the first heading at the level of number, e.g. "4" or "2.3", gets
+// that number.
+func parseStartNumber(number string) (counts []int, err error) {
+	for _, part := range strings.Split(number, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("start number %q is not like 4 or 2.3", number)
+		}
+		counts = append(counts, n)
+	}
+	counts[len(counts)-1]--
+	return
+}
+
+func startNumberOption() option {
+	return option{func(o *Options, value string) (err error) {
+		if value != "" {
+			_, err = parseStartNumber(value)
+		}
+		if err == nil {
+			o.StartNumber = value
+		}
+		return
+	}}
+}
+
+// startNumberer returns the numberer for the first heading of a
+// document: continuing from p.StartState if set, else starting at
+// p.StartNumber.  An invalid StartNumber is ignored.
+func (p *Processor) startNumberer() *numberer {
+	if p.StartState != nil {
+		return &numberer{
+			counts:   append([]int{}, p.StartState.Counts...),
+			appendix: p.StartState.Appendix,
+			format:   p.numberFormat(),
+		}
+	}
+	counts, err := parseStartNumber(p.StartNumber)
+	if p.StartNumber == "" || err != nil {
+		counts = nil
+	}
+	return &numberer{counts: counts, format: p.numberFormat()}
+}
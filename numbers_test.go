@@ -0,0 +1,55 @@
+package markproc
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestStartNumber(t *testing.T) {
+	opts := DefaultOptions()
+	err := opts.Set("start-number", "4")
+	Tassert(t, err == nil, "Set failed: %v", err)
+	p := NewProcessor(opts)
+	out, err := p.Process([]string{"# Storage", "## Disks", "# Network"})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec4"></a>`,
+		"# 4. Storage",
+		`<a name="sec4_1"></a>`,
+		"## 4.1. Disks",
+		`<a name="sec5"></a>`,
+		"# 5. Network",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	state := p.NumberState()
+	wantState := &NumberState{Counts: []int{5, 0}}
+	Tassert(t, reflect.DeepEqual(state, wantState), "want %v, have %v", wantState, state)
+
+	// the next chapter carries on where this one stopped
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	err = state.Write(path)
+	Tassert(t, err == nil, "Write failed: %v", err)
+	p.StartState, err = ReadNumberState(path)
+	Tassert(t, err == nil, "ReadNumberState failed: %v", err)
+	out, err = p.Process([]string{"# Security"})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[1] == "# 6. Security", "want 6, have %q", out[1])
+
+	missing, err := ReadNumberState(filepath.Join(t.TempDir(), "none.json"))
+	Tassert(t, err == nil && missing == nil, "want nil state for a missing file, have %v, %v", missing, err)
+
+	p.StartState = nil
+	p.StartNumber = "2.3"
+	out, err = p.Process([]string{"## Caching", "# Next"})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, out[1] == "## 2.3. Caching" && out[3] == "# 3. Next", "have %q", out)
+
+	for _, bad := range []string{"0", "x", "1..2", "-1"} {
+		err = opts.Set("start-number", bad)
+		Tassert(t, err != nil, "want error for start number %q", bad)
+	}
+}
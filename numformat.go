@@ -0,0 +1,241 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NoNumberSuffix is the Options.NumberSuffix that puts nothing but
+// space between a section number and its title in headings, two spaces
+// so that the number can be told from a title starting with a number
+// or capital, e.g. "# 1  2001 A Space Odyssey".  Renderers show the
+// two as one.
+const NoNumberSuffix = "none"
+
+// numberRes caches the regexps returned by numberedRe and
+// prevNumberedRe for each number format and suffix.
+var numberRes sync.Map
+
+// numberFormat gives the style of section numbers at each level: "1"
+// for 1, 2, 3, "I" and "i" for upper and lower case roman numerals and
+// "A" and "a" for upper and lower case letters.
+type numberFormat []string
+
+// parseNumberFormat parses a format like "I.A.1.a" giving the style of
+// each level in turn.
+func parseNumberFormat(s string) (f numberFormat, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, style := range strings.Split(s, ".") {
+		switch style {
+		case "1", "I", "i", "A", "a":
+			f = append(f, style)
+		default:
+			return nil, fmt.Errorf("number format %q: unknown style %q; want 1, I, i, A or a", s, style)
+		}
+	}
+	return
+}
+
+// numberFormat returns p.NumberFormat parsed.  An invalid format is
+// ignored.
+func (p *Processor) numberFormat() numberFormat {
+	f, _ := parseNumberFormat(p.NumberFormat)
+	return f
+}
+
+// style returns the style of numbers at the 1-based level; levels
+// below those f gives are numbered 1, 2, 3.
+func (f numberFormat) style(level int) string {
+	if level > len(f) {
+		return "1"
+	}
+	return f[level-1]
+}
+
+// format returns n written in the style of level.
+func (f numberFormat) format(level, n int) string {
+	switch f.style(level) {
+	case "I":
+		return roman(n)
+	case "i":
+		return strings.ToLower(roman(n))
+	case "A":
+		return appendixLetter(n)
+	case "a":
+		return strings.ToLower(appendixLetter(n))
+	}
+	return strconv.Itoa(n)
+}
+
+// ordinal returns a number giving the document order of component, a
+// component of a section number at level, like sectionOrdinal.
+func (f numberFormat) ordinal(level int, component string) int {
+	switch f.style(level) {
+	case "I", "i":
+		if n, ok := parseRoman(strings.ToUpper(component)); ok {
+			return n
+		}
+	case "A", "a":
+		if n := sectionOrdinal(strings.ToUpper(component)); n > 1<<20 {
+			return n - 1<<20
+		}
+	}
+	return sectionOrdinal(component)
+}
+
+// romanDigits lists the values of roman numerals, largest first.
+var romanDigits = []struct {
+	value  int
+	digits string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+	{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+	{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+// roman returns n as an upper case roman numeral.
+func roman(n int) string {
+	s := ""
+	for _, d := range romanDigits {
+		for n >= d.value {
+			s += d.digits
+			n -= d.value
+		}
+	}
+	return s
+}
+
+// parseRoman returns the value of the upper case roman numeral s.
+func parseRoman(s string) (n int, ok bool) {
+	rest := s
+	for _, d := range romanDigits {
+		for strings.HasPrefix(rest, d.digits) {
+			n += d.value
+			rest = rest[len(d.digits):]
+		}
+	}
+	return n, s != "" && rest == "" && roman(n) == s
+}
+
+// numberSuffix returns the text p puts after section numbers.
+func (p *Processor) numberSuffix() string {
+	switch p.NumberSuffix {
+	case "":
+		return "."
+	case NoNumberSuffix:
+		return ""
+	}
+	return p.NumberSuffix
+}
+
+// numberText returns section number as p writes it in headings, e.g.
+// "1.2." or "1.2".
+func (p *Processor) numberText(number string) string {
+	return number + p.numberSuffix()
+}
+
+// numberPatterns returns the patterns matching the first and later
+// components of section numbers p writes.
+func (p *Processor) numberPatterns() (first, rest string) {
+	if p.NumberFormat != "" {
+		return `(?:\d+|[A-Za-z]+)`, `(?:\d+|[A-Za-z]+)`
+	}
+	return `(?:\d+|[A-Z]+)`, `\d+`
+}
+
+// cachedRe returns the regexp compiled from pattern, compiling it only
+// the first time.
+func cachedRe(pattern string) *regexp.Regexp {
+	if re, ok := numberRes.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	numberRes.Store(pattern, re)
+	return re
+}
+
+// headingNumber returns section number as p writes it in headings,
+// with the space before the title: two spaces if there is no suffix.
+func (p *Processor) headingNumber(number string) string {
+	if p.numberSuffix() == "" {
+		return number + "  "
+	}
+	return p.numberText(number) + " "
+}
+
+// numberedRe returns the regexp matching a numbered heading with any of
+// the number suffixes, ".", ".0" or none, as well as p's: the groups
+// are the hashes, the number, the suffix and the title.  A number
+// without a suffix has to be followed by two spaces, as headingNumber
+// writes it, so that e.g. the year in "# 2001 A Space Odyssey" isn't
+// taken for one.
+func (p *Processor) numberedRe() *regexp.Regexp {
+	first, rest := p.numberPatterns()
+	suffixes := `\.0|\.`
+	if suffix := p.numberSuffix(); suffix != "" {
+		suffixes += `|` + regexp.QuoteMeta(suffix)
+	}
+	return cachedRe(`^(#+)\s+(` + first + `(?:\.` + rest + `)*?)(?:(` + suffixes + `)\s+|\s\s+)(\S.*)`)
+}
+
+// numberedHeading returns the hashes, number and title of line, at
+// indices 1 to 3, if it is a numbered heading, or nil.  A number
+// starting with a letter needs a suffix, unless p writes none, so that
+// e.g. "# A Title" isn't taken for appendix A.
+func (p *Processor) numberedHeading(line string) []string {
+	m := p.numberedRe().FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	number, suffix := m[2], m[3]
+	if suffix == "" && p.numberSuffix() != "" && (number[0] < '0' || number[0] > '9') {
+		return nil
+	}
+	return []string{m[0], m[1], number, m[4]}
+}
+
+// prevNumberedRe returns the regexp matching the headings numbered by an
+// earlier run with p's options: the groups are the hashes and the
+// title.
+func (p *Processor) prevNumberedRe() *regexp.Regexp {
+	if p.NumberFormat == "" && p.numberSuffix() == "." {
+		return prevNumberRe
+	}
+	first, rest := p.numberPatterns()
+	delim := regexp.QuoteMeta(p.numberSuffix()) + `\s+`
+	if p.numberSuffix() == "" {
+		delim = `\s\s+`
+	}
+	return cachedRe(`^(#+)\s+` + first + `(?:\.` + rest + `)*` + delim + `(\S.*)`)
+}
+
+// prevNumbered returns the hashes and title of line, at indices 1 and
+// 2, if it is a heading numbered by an earlier run, or nil.  Unless p
+// has a NumberFormat, a number starting with a letter is only taken for
+// one in the appendices, where inAppendix is set, so that e.g. "# C.
+// Elegans Research" keeps its first word.
+func (p *Processor) prevNumbered(line string, inAppendix bool) []string {
+	m := p.prevNumberedRe().FindStringSubmatch(line)
+	if m == nil || inAppendix || p.NumberFormat != "" {
+		return m
+	}
+	if number := strings.TrimLeft(line[len(m[1]):], " \t"); number[0] < '0' || number[0] > '9' {
+		return nil
+	}
+	return m
+}
+
+func numberFormatOption() option {
+	return option{func(o *Options, value string) (err error) {
+		_, err = parseNumberFormat(value)
+		if err == nil {
+			o.NumberFormat = value
+		}
+		return
+	}}
+}
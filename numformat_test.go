@@ -0,0 +1,142 @@
+package markproc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestNumberFormat(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RefDirection = true
+	err := opts.Set("number-format", "I.A.1.a")
+	Tassert(t, err == nil, "Set failed: %v", err)
+	p := NewProcessor(opts)
+	lines := []string{
+		"# Parts",
+		"## Overview",
+		"See [sec scp].",
+		"### Scope",
+		"#### Limits",
+		"##### Notes",
+		"# Second",
+		"# Third",
+		"# Fourth",
+		"See [sec prts].",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="secI"></a>`,
+		"# I. Parts",
+		`<a name="secI_A"></a>`,
+		"## I.A. Overview",
+		`See [<a href="#secI_A_1">sec I.A.1</a> below].`,
+		`<a name="secI_A_1"></a>`,
+		"### I.A.1. Scope",
+		`<a name="secI_A_1_a"></a>`,
+		"#### I.A.1.a. Limits",
+		`<a name="secI_A_1_a_1"></a>`,
+		"##### I.A.1.a.1. Notes",
+		`<a name="secII"></a>`,
+		"# II. Second",
+		`<a name="secIII"></a>`,
+		"# III. Third",
+		`<a name="secIV"></a>`,
+		"# IV. Fourth",
+		`See [<a href="#secI">sec I</a> above].`,
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+
+	err = opts.Set("number-format", "I.x")
+	Tassert(t, err != nil, "want error for an unknown style")
+}
+
+func TestRoman(t *testing.T) {
+	for n, s := range map[int]string{1: "I", 4: "IV", 9: "IX", 14: "XIV", 40: "XL", 1994: "MCMXCIV"} {
+		Tassert(t, roman(n) == s, "roman(%d): want %s, have %s", n, s, roman(n))
+		m, ok := parseRoman(s)
+		Tassert(t, ok && m == n, "parseRoman(%s): want %d, have %d", s, n, m)
+	}
+	_, ok := parseRoman("IIII")
+	Tassert(t, !ok, "IIII is not a roman numeral")
+}
+
+func TestNumberSuffix(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"[toc]",
+		"## Scope",
+		"See [sec scp].",
+	}
+	for _, tc := range []struct {
+		suffix, heading, sub, toc string
+	}{
+		{"", "# 1. Intro", "## 1.1. Scope", `- <a href="#sec1">1. Intro</a>`},
+		{NoNumberSuffix, "# 1  Intro", "## 1.1  Scope", `- <a href="#sec1">1 Intro</a>`},
+		{".0", "# 1.0 Intro", "## 1.1.0 Scope", `- <a href="#sec1">1.0 Intro</a>`},
+	} {
+		opts := DefaultOptions()
+		opts.NumberSuffix = tc.suffix
+		p := NewProcessor(opts)
+		out, err := p.Process(lines)
+		Tassert(t, err == nil, "%q: Process failed: %v", tc.suffix, err)
+		want := []string{
+			`<a name="sec1"></a>`,
+			tc.heading,
+			tocStart,
+			tc.toc,
+			`  - <a href="#sec1_1">` + strings.Replace(tc.sub[3:], "  ", " ", 1) + "</a>",
+			tocEnd,
+			`<a name="sec1_1"></a>`,
+			tc.sub,
+			`See [<a href="#sec1_1">sec 1.1</a>].`,
+		}
+		Tassert(t, reflect.DeepEqual(out, want), "%q:\nwant: %q\nhave: %q", tc.suffix, want, out)
+
+		again, err := p.Process(out)
+		Tassert(t, err == nil, "%q: Process failed: %v", tc.suffix, err)
+		Tassert(t, reflect.DeepEqual(again, want), "%q:\nwant: %q\nhave: %q", tc.suffix, want, again)
+
+		// the targets are found whatever suffix the headings have
+		targets := NewProcessor(DefaultOptions()).sectionTargets(out, "")
+		Tassert(t, targets["scope"].Number == "1.1", "%q: want 1.1, have %q", tc.suffix, targets["scope"].Number)
+	}
+}
+
+func TestNoNumberSuffixTitles(t *testing.T) {
+	lines := []string{
+		"# A Tale of Two Cities",
+		"## API Design",
+		"## 2001 A Space Odyssey",
+		"# C. Elegans Research",
+	}
+	opts := DefaultOptions()
+	opts.NumberSuffix = NoNumberSuffix
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1  A Tale of Two Cities",
+		`<a name="sec1_1"></a>`,
+		"## 1.1  API Design",
+		`<a name="sec1_2"></a>`,
+		"## 1.2  2001 A Space Odyssey",
+		`<a name="sec2"></a>`,
+		"# 2  C. Elegans Research",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+}
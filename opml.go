@@ -0,0 +1,110 @@
+package markproc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// outlineNode is a section with the sections nested in it.
+type outlineNode struct {
+	Section
+	children []*outlineNode
+}
+
+// sectionTree nests sections, as returned by Outline, by level.
+func sectionTree(sections []Section) (roots []*outlineNode) {
+	// stack holds the enclosing sections of the current one
+	stack := []*outlineNode{}
+	for _, sec := range sections {
+		node := &outlineNode{Section: sec}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= sec.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		}
+		stack = append(stack, node)
+	}
+	return
+}
+
+// opmlOutline is an OPML <outline> element.
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Anchor   string         `xml:"anchor,attr,omitempty"`
+	Children []*opmlOutline `xml:"outline"`
+}
+
+// WriteOPML writes sections, as returned by Outline, to w as an OPML
+// 2.0 outline titled title, or the first section's title if empty,
+// each heading an <outline> element nested in the one of the section
+// enclosing it.  The text of each element is the numbered title, e.g.
+// "2.3 Design", and its anchor attribute the section's anchor.
+func WriteOPML(w io.Writer, sections []Section, title string) (err error) {
+	if title == "" && len(sections) > 0 {
+		title = sections[0].Title
+	}
+	var convert func(nodes []*outlineNode) []*opmlOutline
+	convert = func(nodes []*outlineNode) (out []*opmlOutline) {
+		for _, n := range nodes {
+			out = append(out, &opmlOutline{Text: n.Number + " " + n.Title, Anchor: n.Anchor, Children: convert(n.children)})
+		}
+		return
+	}
+	doc := struct {
+		XMLName xml.Name       `xml:"opml"`
+		Version string         `xml:"version,attr"`
+		Title   string         `xml:"head>title"`
+		Body    []*opmlOutline `xml:"body>outline"`
+	}{Version: "2.0", Title: title, Body: convert(sectionTree(sections))}
+	return writeXML(w, doc)
+}
+
+// mindmapNode is a Freeplane or FreeMind <node> element.
+type mindmapNode struct {
+	Text     string         `xml:"TEXT,attr"`
+	Link     string         `xml:"LINK,attr,omitempty"`
+	Children []*mindmapNode `xml:"node"`
+}
+
+// WriteMindmap writes sections, as returned by Outline, to w as a
+// Freeplane mind map, also read by FreeMind, with title, or the first
+// section's title if empty, at its root and a node for each heading.
+// Each node links to the section's anchor.
+func WriteMindmap(w io.Writer, sections []Section, title string) (err error) {
+	if title == "" && len(sections) > 0 {
+		title = sections[0].Title
+	}
+	var convert func(nodes []*outlineNode) []*mindmapNode
+	convert = func(nodes []*outlineNode) (out []*mindmapNode) {
+		for _, n := range nodes {
+			out = append(out, &mindmapNode{Text: n.Number + " " + n.Title, Link: "#" + n.Anchor, Children: convert(n.children)})
+		}
+		return
+	}
+	doc := struct {
+		XMLName xml.Name     `xml:"map"`
+		Version string       `xml:"version,attr"`
+		Root    *mindmapNode `xml:"node"`
+	}{Version: "1.0.1", Root: &mindmapNode{Text: title, Children: convert(sectionTree(sections))}}
+	return writeXML(w, doc)
+}
+
+// writeXML writes v to w as an indented XML document.
+func writeXML(w io.Writer, v interface{}) (err error) {
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(v)
+	if err != nil {
+		return
+	}
+	_, err = io.WriteString(w, "\n")
+	return
+}
@@ -0,0 +1,52 @@
+package markproc
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestWriteOPML(t *testing.T) {
+	p := NewProcessor(DefaultOptions())
+	sections := p.Outline([]string{
+		"# Intro",
+		"## Goals & Scope",
+		"### Non-goals",
+		"# Design",
+	})
+	var b bytes.Buffer
+	Ck(WriteOPML(&b, sections, ""))
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head>
+    <title>Intro</title>
+  </head>
+  <body>
+    <outline text="1 Intro" anchor="sec1">
+      <outline text="1.1 Goals &amp; Scope" anchor="sec1_1">
+        <outline text="1.1.1 Non-goals" anchor="sec1_1_1"></outline>
+      </outline>
+    </outline>
+    <outline text="2 Design" anchor="sec2"></outline>
+  </body>
+</opml>
+`
+	Tassert(t, b.String() == want, "\nwant: %s\nhave: %s", want, b.String())
+
+	b.Reset()
+	Ck(WriteMindmap(&b, sections, "Spec"))
+	want = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.0.1">
+  <node TEXT="Spec">
+    <node TEXT="1 Intro" LINK="#sec1">
+      <node TEXT="1.1 Goals &amp; Scope" LINK="#sec1_1">
+        <node TEXT="1.1.1 Non-goals" LINK="#sec1_1_1"></node>
+      </node>
+    </node>
+    <node TEXT="2 Design" LINK="#sec2"></node>
+  </node>
+</map>
+`
+	Tassert(t, b.String() == want, "\nwant: %s\nhave: %s", want, b.String())
+}
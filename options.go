@@ -0,0 +1,174 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// option describes one setting that can be changed by name, e.g. from
+// a document's front matter.
+type option struct {
+	set func(o *Options, value string) error
+}
+
+func boolOption(field func(o *Options) *bool) option {
+	return option{func(o *Options, value string) (err error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return
+		}
+		*field(o) = b
+		return
+	}}
+}
+
+func intOption(field func(o *Options) *int) option {
+	return option{func(o *Options, value string) (err error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+		*field(o) = n
+		return
+	}}
+}
+
+func stringOption(field func(o *Options) *string) option {
+	return option{func(o *Options, value string) error {
+		*field(o) = value
+		return nil
+	}}
+}
+
+func matcherOption() option {
+	return option{func(o *Options, value string) error {
+		m, ok := Matchers[value]
+		if !ok {
+			return fmt.Errorf("unknown matcher %q", value)
+		}
+		o.Matcher = m
+		return nil
+	}}
+}
+
+// options maps setting names, which match the command line flags where
+// there is one, to Options fields.
+var options = map[string]option{
+	"sanitize":          boolOption(func(o *Options) *bool { return &o.Sanitize }),
+	"normalize-anchors": boolOption(func(o *Options) *bool { return &o.NormalizeAnchors }),
+	"convert-anchors":   boolOption(func(o *Options) *bool { return &o.ConvertAnchors }),
+	"mk-externs":        boolOption(func(o *Options) *bool { return &o.MkExterns }),
+	"shift-headings":    intOption(func(o *Options) *int { return &o.ShiftHeadings }),
+	"mk-heads":          boolOption(func(o *Options) *bool { return &o.MkHeads }),
+	"toc":               boolOption(func(o *Options) *bool { return &o.MkTOC }),
+	"start-number":      startNumberOption(),
+	"number-format":     numberFormatOption(),
+	"number-suffix":     stringOption(func(o *Options) *string { return &o.NumberSuffix }),
+	"toc-depth":         intOption(func(o *Options) *int { return &o.TOCDepth }),
+	"link-externs":      boolOption(func(o *Options) *bool { return &o.LinkExterns }),
+	"cite-style":        stringOption(func(o *Options) *string { return &o.CiteStyle }),
+	"freeze-policy":     stringOption(func(o *Options) *string { return &o.FreezeNumbers }),
+	"hide-definitions":  boolOption(func(o *Options) *bool { return &o.HideDefinitions }),
+	"cited-in":          boolOption(func(o *Options) *bool { return &o.CitedIn }),
+	"extern-display":    boolOption(func(o *Options) *bool { return &o.ExternDisplay }),
+	"link-terms":        boolOption(func(o *Options) *bool { return &o.LinkTerms }),
+	"index":             boolOption(func(o *Options) *bool { return &o.MkIndex }),
+	"expand-acronyms":   boolOption(func(o *Options) *bool { return &o.ExpandAcronyms }),
+	"link-acronyms":     boolOption(func(o *Options) *bool { return &o.LinkAcronyms }),
+	"acronym-list":      boolOption(func(o *Options) *bool { return &o.AcronymList }),
+	"equations":         boolOption(func(o *Options) *bool { return &o.MkEquations }),
+	"figures":           boolOption(func(o *Options) *bool { return &o.MkFigures }),
+	"footnotes":         stringOption(func(o *Options) *string { return &o.Footnotes }),
+	"setext":            stringOption(func(o *Options) *string { return &o.Setext }),
+	"blockquotes":       boolOption(func(o *Options) *bool { return &o.Blockquotes }),
+	"bib-prefix":        stringOption(func(o *Options) *string { return &o.BibPrefix }),
+	"requirements":      stringOption(func(o *Options) *string { return &o.Requirements }),
+	"doc-version":       stringOption(func(o *Options) *string { return &o.DocVersion }),
+	"doc-date":          stringOption(func(o *Options) *string { return &o.DocDate }),
+	"version-footer":    boolOption(func(o *Options) *bool { return &o.VersionFooter }),
+	"link-heads":        boolOption(func(o *Options) *bool { return &o.LinkHeads }),
+	"self-ref-text":     stringOption(func(o *Options) *string { return &o.SelfRefText }),
+	"appendix-after":    stringOption(func(o *Options) *string { return &o.AppendixAfter }),
+	"multiple-h1":       stringOption(func(o *Options) *string { return &o.MultipleH1 }),
+	"ref-direction":     boolOption(func(o *Options) *bool { return &o.RefDirection }),
+	"fix-heading-gaps":  boolOption(func(o *Options) *bool { return &o.FixHeadingGaps }),
+	"a11y":              boolOption(func(o *Options) *bool { return &o.A11y }),
+	"require-owners":    boolOption(func(o *Options) *bool { return &o.RequireOwners }),
+	"strict-expiry":     boolOption(func(o *Options) *bool { return &o.StrictExpiry }),
+	"today":             stringOption(func(o *Options) *string { return &o.Today }),
+	"check-urls":        boolOption(func(o *Options) *bool { return &o.CheckURLs }),
+	"url-policy":        stringOption(func(o *Options) *string { return &o.URLPolicy }),
+	"verify":            boolOption(func(o *Options) *bool { return &o.Verify }),
+	"warn-orphans":      boolOption(func(o *Options) *bool { return &o.WarnOrphans }),
+	"anchor-style":      stringOption(func(o *Options) *string { return &o.AnchorStyle }),
+	"link-style":        stringOption(func(o *Options) *string { return &o.LinkStyle }),
+	"heading-anchors":   stringOption(func(o *Options) *string { return &o.HeadingAnchors }),
+	"matcher":           matcherOption(),
+	"max-edits":         maxEditsOption(),
+	"sec-ref-format":    stringOption(func(o *Options) *string { return &o.SecRefFormat }),
+	"eq-ref-format":     stringOption(func(o *Options) *string { return &o.EqRefFormat }),
+	"anchor-format":     stringOption(func(o *Options) *string { return &o.AnchorFormat }),
+	"anchor-prefix":     stringOption(func(o *Options) *string { return &o.AnchorPrefix }),
+	"anchor-separator":  stringOption(func(o *Options) *string { return &o.AnchorSeparator }),
+	"anchor-slug":       boolOption(func(o *Options) *bool { return &o.AnchorSlug }),
+	"link-ext":          stringOption(func(o *Options) *string { return &o.ProjectLinkExt }),
+	"permalink":         stringOption(func(o *Options) *string { return &o.Permalink }),
+	"ref-shortcodes":    stringOption(func(o *Options) *string { return &o.RefShortcodes }),
+	"site-root":         stringOption(func(o *Options) *string { return &o.SiteRoot }),
+}
+
+// Set changes the setting named key, e.g. "toc-depth", to value.
+func (o *Options) Set(key, value string) (err error) {
+	opt, ok := options[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	err = opt.set(o, value)
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+	return
+}
+
+// RefKinds maps each kind of reference, as written in [KIND ...], to
+// the setting naming the template of its link text.
+var RefKinds = map[string]string{
+	"sec": "sec-ref-format",
+	"eq":  "eq-ref-format",
+}
+
+// SetRefTemplate sets the link text template of a kind of reference
+// from spec, KIND=TEMPLATE, e.g. "eq=Equation ({number})", or just
+// TEMPLATE for [sec ...] references.  In the template {number} is
+// replaced by the target's number and {title} by its title.
+func (o *Options) SetRefTemplate(spec string) (err error) {
+	kind, template := "sec", spec
+	if i := strings.Index(spec, "="); i > 0 && refKindRe.MatchString(spec[:i]) {
+		kind, template = spec[:i], spec[i+1:]
+	}
+	key, ok := RefKinds[kind]
+	if !ok {
+		kinds := []string{}
+		for k := range RefKinds {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		return fmt.Errorf("unknown reference kind %q; known kinds are %s", kind, strings.Join(kinds, ", "))
+	}
+	return o.Set(key, template)
+}
+
+// refKindRe matches the KIND of a KIND=TEMPLATE reference template.
+var refKindRe = regexp.MustCompile(`^[a-z]+$`)
+
+// OptionNames returns the names accepted by Set, sorted.
+func OptionNames() (names []string) {
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
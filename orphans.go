@@ -0,0 +1,54 @@
+package markproc
+
+import "strings"
+
+// verifyOrphans warns about each [ref]: definition of lines, the
+// processed document, that nothing cites, and each anchor line, such as
+// those passMkHeads inserts, that nothing links to.  Projects aren't
+// checked, as links from other files aren't seen.
+func (p *Processor) verifyOrphans(lines []string) {
+	if p.project != nil {
+		return
+	}
+	code := codeMask(lines)
+	// linked holds the names linked to or cited
+	linked := map[string]bool{}
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		// the back-links of a definition don't link to its citations
+		line = citedInRe.ReplaceAllString(line, "")
+		for _, m := range localHrefRe.FindAllStringSubmatch(line, -1) {
+			linked[m[1]] = true
+		}
+		if extLinkRegexp.MatchString(line) {
+			continue
+		}
+		for _, c := range citations(line + " ") {
+			linked[c.ref] = true
+		}
+	}
+
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if m := extLinkRegexp.FindStringSubmatch(line); m != nil {
+			if !linked[m[1]] {
+				p.warnf("ref-unused", i, m[1], "Reference defined but never cited: %s", m[1])
+			}
+			continue
+		}
+		m := anchorLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		// aliases are kept for links from outside
+		if m == nil || linked[m[1]] || p.AnchorMap.isAlias(m[1]) {
+			continue
+		}
+		// a definition's anchor is reported as an unused reference
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "["+m[1]+"]:") {
+			continue
+		}
+		p.warnf("anchor-orphan", i, m[1], "Nothing links to anchor #%s", m[1])
+	}
+}
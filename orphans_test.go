@@ -0,0 +1,39 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestWarnOrphans(t *testing.T) {
+	opts := DefaultOptions()
+	opts.WarnOrphans = true
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	lines := []string{
+		"# Intro",
+		"See [used] and [sec dtls].",
+		"## Details",
+		"## Unlinked",
+		"```",
+		"[code]: not a definition",
+		"```",
+		"[used]: Cited.",
+		"[spare]: Never cited.",
+	}
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have := []string{}
+	for _, w := range p.Warnings() {
+		have = append(have, w.Rule+": "+w.Message)
+	}
+	want := []string{
+		"anchor-orphan: Nothing links to anchor #sec1",
+		"anchor-orphan: Nothing links to anchor #sec1_2",
+		"ref-unused: Reference defined but never cited: spare",
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+}
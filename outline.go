@@ -0,0 +1,97 @@
+package markproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Policies for renumbered sections, for Options.FreezeNumbers.
+const (
+	// FreezeWarn warns about each renumbered section.
+	FreezeWarn = "warn"
+	// FreezeError also makes verification fail.
+	FreezeError = "error"
+)
+
+// Section describes one numbered heading of a document.
+type Section struct {
+	Level  int    `json:"level"`
+	Number string `json:"number"`
+	Title  string `json:"title"`
+	Anchor string `json:"anchor"`
+	// Line is the 1-based line number of the heading in the input.
+	Line int `json:"line"`
+}
+
+// Outline returns the sections of the unprocessed document lines,
+// numbered the same way the MkHeads pass numbers them.  Headings marked
+// <!-- toc-exclude --> are numbered but left out.
+func (p *Processor) Outline(lines []string) (sections []Section) {
+	sections = []Section{}
+	for _, h := range p.headings(lines) {
+		if !hasMarker(h.markers, "toc-exclude") {
+			sections = append(sections, h.Section)
+		}
+	}
+	return
+}
+
+// heading is a numbered heading of a document with the marker comments
+// at its end, e.g. <!-- toc-exclude -->.
+type heading struct {
+	Section
+	markers string
+	// extra is set for an H1 after the first, and gap for a heading
+	// that skipped a level.
+	extra, gap bool
+}
+
+// headings returns every numbered heading of the unprocessed document
+// lines.
+func (p *Processor) headings(lines []string) (headings []heading) {
+	heads := p.newHeadNumberer(p.startNumberer())
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if h := heads.next(line); h != nil {
+			h.Line = i + 1
+			headings = append(headings, *h)
+		}
+	}
+	return
+}
+
+// ReadOutline reads an outline written as JSON, e.g. by the /outline
+// API or the outline subcommand.
+func ReadOutline(r io.Reader) (sections []Section, err error) {
+	err = json.NewDecoder(r).Decode(&sections)
+	return
+}
+
+// verifyFrozen compares the section numbers of the input document with
+// p.FrozenOutline, the outline of a previous release, warning about
+// each section whose number has changed unless its heading is marked
+// <!-- renumbered -->.  Sections are matched by title.
+func (p *Processor) verifyFrozen() (err error) {
+	previous := map[string]string{}
+	for _, sec := range p.FrozenOutline {
+		previous[strings.ToLower(sec.Title)] = sec.Number
+	}
+	changed := 0
+	for _, h := range p.headings(p.input) {
+		number, ok := previous[strings.ToLower(h.Title)]
+		if !ok || number == h.Number || hasMarker(h.markers, "renumbered") {
+			continue
+		}
+		p.warnfLine("number-changed", h.Line, h.Title, "Section %s was numbered %s in the previous release, now %s", h.Title, number, h.Number)
+		changed++
+	}
+	if changed > 0 && p.FreezeNumbers == FreezeError {
+		err = fmt.Errorf("%d sections renumbered since the previous release", changed)
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package markproc
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestFreezeNumbers(t *testing.T) {
+	previous := []string{
+		"# Intro",
+		"# Design",
+		"# Security",
+	}
+	buf, err := json.Marshal(NewProcessor(DefaultOptions()).Outline(previous))
+	Ck(err)
+	frozen, err := ReadOutline(strings.NewReader(string(buf)))
+	Ck(err)
+
+	opts := DefaultOptions()
+	opts.FrozenOutline = frozen
+	opts.FreezeNumbers = FreezeWarn
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+
+	// appending a section keeps the numbers
+	_, err = p.Process(append(previous, "# Appendix"))
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+
+	// inserting one renumbers the sections after it
+	inserted := []string{"# Intro", "# Goals", "# Design", "# Security <!-- renumbered -->"}
+	_, err = p.Process(inserted)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "number-changed" && warnings[0].Line == 3, "have %v", warnings)
+
+	p.FreezeNumbers = FreezeError
+	_, err = p.Process(inserted)
+	Tassert(t, err != nil, "renumbering not reported as an error")
+	Tassert(t, p.Warnings()[0].Severity == SeverityError, "have %v", p.Warnings())
+}
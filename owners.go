@@ -0,0 +1,90 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ownerRe matches an <!-- owner: @team-infra --> line naming the owners
+// of the section it is in.
+var ownerRe = regexp.MustCompile(`^\s*<!--\s*owner:\s*(.*?)\s*-->\s*$`)
+
+// SectionOwners lists the owners of one numbered section.
+type SectionOwners struct {
+	Number string   `json:"number"`
+	Title  string   `json:"title"`
+	Anchor string   `json:"anchor"`
+	Line   int      `json:"line"`
+	Owners []string `json:"owners"`
+	// Inherited is set when the owners are those of an enclosing
+	// section, or of the document if given before the first heading.
+	Inherited bool `json:"inherited,omitempty"`
+}
+
+// Owners returns the owners of each numbered section of the
+// unprocessed document lines, given by <!-- owner: @a @b --> lines in
+// the section.  A section without its own owners has those of the
+// section enclosing it, or of an owner line before the first heading.
+func (p *Processor) Owners(lines []string) (owners []SectionOwners) {
+	owners = []SectionOwners{}
+	headings := p.headings(lines)
+	docOwners := []string{}
+	code := codeMask(lines)
+	h := -1
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		for h+1 < len(headings) && headings[h+1].Line == i+1 {
+			h++
+			sec := headings[h].Section
+			owners = append(owners, SectionOwners{Number: sec.Number, Title: sec.Title, Anchor: sec.Anchor, Line: sec.Line, Owners: []string{}})
+		}
+		m := ownerRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		names := strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if h < 0 {
+			docOwners = append(docOwners, names...)
+		} else {
+			owners[h].Owners = append(owners[h].Owners, names...)
+		}
+	}
+
+	// stack holds the enclosing sections of the current one
+	stack := []int{}
+	for j := range owners {
+		level := headings[j].Level
+		for len(stack) > 0 && headings[stack[len(stack)-1]].Level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(owners[j].Owners) == 0 {
+			if len(stack) > 0 {
+				owners[j].Owners = owners[stack[len(stack)-1]].Owners
+			} else {
+				owners[j].Owners = docOwners
+			}
+			owners[j].Inherited = len(owners[j].Owners) > 0
+		}
+		stack = append(stack, j)
+	}
+	return
+}
+
+// verifyOwners checks that every top-level section of the input
+// document has an owner.
+func (p *Processor) verifyOwners() (err error) {
+	missing := 0
+	for _, sec := range p.Owners(p.input) {
+		if len(sec.Owners) == 0 && !strings.Contains(sec.Number, ".") {
+			p.recordLine("owner-missing", sec.Line, sec.Title, fmt.Sprintf("Section %s %s has no owner", sec.Number, sec.Title))
+			missing++
+		}
+	}
+	if missing > 0 {
+		err = fmt.Errorf("%d top-level sections without an owner", missing)
+	}
+	return
+}
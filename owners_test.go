@@ -0,0 +1,52 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestOwners(t *testing.T) {
+	lines := []string{
+		"<!-- owner: @docs -->",
+		"# Intro",
+		"## Scope",
+		"# Storage",
+		"<!-- owner: @team-infra, @alice -->",
+		"## Disks",
+		"<!-- owner: @bob -->",
+		"## Backups",
+		"```",
+		"<!-- owner: @not-an-owner -->",
+		"```",
+	}
+	p := NewProcessor(DefaultOptions())
+	have := p.Owners(lines)
+	want := []SectionOwners{
+		{Number: "1", Title: "Intro", Anchor: "sec1", Line: 2, Owners: []string{"@docs"}, Inherited: true},
+		{Number: "1.1", Title: "Scope", Anchor: "sec1_1", Line: 3, Owners: []string{"@docs"}, Inherited: true},
+		{Number: "2", Title: "Storage", Anchor: "sec2", Line: 4, Owners: []string{"@team-infra", "@alice"}},
+		{Number: "2.1", Title: "Disks", Anchor: "sec2_1", Line: 6, Owners: []string{"@bob"}},
+		{Number: "2.2", Title: "Backups", Anchor: "sec2_2", Line: 8, Owners: []string{"@team-infra", "@alice"}, Inherited: true},
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %+v\nhave: %+v", want, have)
+
+	opts := DefaultOptions()
+	opts.RequireOwners = true
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err := p.Process(lines[1:])
+	Tassert(t, err != nil, "want error for a top-level section without owner")
+	missing := []string{}
+	for _, w := range p.Warnings() {
+		if w.Rule == "owner-missing" {
+			missing = append(missing, w.Message)
+		}
+	}
+	Tassert(t, reflect.DeepEqual(missing, []string{"Section 1 Intro has no owner"}), "have %q", missing)
+
+	_, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+}
@@ -0,0 +1,164 @@
+package markproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Profile is a named bundle of settings, selected with the profile
+// setting, e.g. "profile: ietf" in a document's front matter or the
+// -profile flag, so that a team can switch numbering, anchors, output
+// and lint rules together.  See SelectProfiles.
+type Profile struct {
+	Description string `json:"description,omitempty"`
+	// Settings maps setting names, as accepted by Options.Set, to
+	// values.
+	Settings map[string]string `json:"settings"`
+	// Rules are added to Options.Rules.
+	Rules []Rule `json:"rules,omitempty"`
+	// Output is the format the command line tool writes, "markdown",
+	// the default, or "html".
+	Output string `json:"output,omitempty"`
+}
+
+// Profiles holds the profiles the profile setting can select.  It
+// starts with the built-in ietf, github and book profiles; add to it,
+// e.g. with ReadProfiles, before processing.
+var Profiles = map[string]Profile{
+	"ietf": {
+		Description: "RFC style: Section 2.3 references, section-2.3 anchors, numeric citations",
+		Settings: map[string]string{
+			"anchor-style":     AnchorSecnum,
+			"anchor-prefix":    "section-",
+			"anchor-separator": ".",
+			"sec-ref-format":   "Section {number}",
+			"cite-style":       CiteNumeric,
+			"extern-display":   "true",
+			"toc-depth":        "3",
+			"fix-heading-gaps": "true",
+		},
+		Rules: []Rule{
+			{Name: "bare-url", Pattern: `(^|\s)https?://`, Scope: "outside References", Severity: SeverityWarning, Message: "Cite URLs with a [REF] definition"},
+		},
+	},
+	"github": {
+		Description: "GitHub rendering: GitHub heading anchors, title link text, accessibility checks",
+		Settings: map[string]string{
+			"anchor-style":   AnchorGitHub,
+			"sec-ref-format": "{title}",
+			"toc-depth":      "3",
+			"a11y":           "true",
+		},
+	},
+	"book": {
+		Description: "Long-form HTML output: author-year citations, a list of acronyms, no unfinished text",
+		Settings: map[string]string{
+			"sec-ref-format": "Section {number}",
+			"eq-ref-format":  "Equation ({number})",
+			"cite-style":     CiteAuthorYear,
+			"acronym-list":   "true",
+			"toc-depth":      "2",
+			"ref-direction":  "true",
+		},
+		Rules: []Rule{
+			{Name: "unfinished", Pattern: `\b(TODO|FIXME|XXX)\b`, Severity: SeverityError, Message: "Unfinished text"},
+		},
+		Output: "html",
+	},
+}
+
+func init() {
+	// registered here, since applying a profile sets other options
+	options["profile"] = option{func(o *Options, value string) error {
+		return o.SelectProfiles(value)
+	}}
+}
+
+// SelectProfiles selects the profiles in names, a comma-separated
+// list, e.g. "internal,draft", in order: each name is added to
+// o.Conditions, selecting the conditional content written for it, and
+// a name in Profiles also changes the settings it names and adds its
+// rules.
+func (o *Options) SelectProfiles(names string) (err error) {
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := Profiles[name]; ok {
+			err = o.ApplyProfile(name)
+			if err != nil {
+				return
+			}
+		}
+		// copy, so that Options copied from o keep their own
+		// conditions
+		o.Conditions = append(o.Conditions[:len(o.Conditions):len(o.Conditions)], name)
+	}
+	return
+}
+
+// ApplyProfile changes the settings named by the profile name in
+// Profiles and adds its rules.
+func (o *Options) ApplyProfile(name string) (err error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q; known profiles are %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	keys := []string{}
+	for key := range profile.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		err = o.Set(key, profile.Settings[key])
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	// copy, so that Options copied from o keep their own rules
+	o.Rules = append(o.Rules[:len(o.Rules):len(o.Rules)], profile.Rules...)
+	return
+}
+
+// ProfileNames returns the names of Profiles, sorted.
+func ProfileNames() (names []string) {
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ReadProfiles reads profiles written as a JSON object mapping names to
+// profiles, e.g.
+//
+//	{"handbook": {"settings": {"toc-depth": "2", "a11y": "true"},
+//	  "rules": [{"name": "no-todo", "pattern": "TODO", "message": "Unfinished"}],
+//	  "output": "html"}}
+func ReadProfiles(r io.Reader) (profiles map[string]Profile, err error) {
+	err = json.NewDecoder(r).Decode(&profiles)
+	if err != nil {
+		return
+	}
+	for name, profile := range profiles {
+		for key := range profile.Settings {
+			if _, ok := options[key]; !ok || key == "profile" {
+				return nil, fmt.Errorf("profile %s: unknown setting %q", name, key)
+			}
+		}
+		err = compileRules(profile.Rules)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		switch profile.Output {
+		case "", "markdown", "html":
+		default:
+			return nil, fmt.Errorf("profile %s: unknown output %q", name, profile.Output)
+		}
+	}
+	return
+}
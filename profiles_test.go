@@ -0,0 +1,57 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestProfiles(t *testing.T) {
+	opts := DefaultOptions()
+	err := opts.ApplyProfile("ietf")
+	Tassert(t, err == nil, "ApplyProfile failed: %v", err)
+	Tassert(t, opts.AnchorPrefix == "section-" && opts.SecRefFormat == "Section {number}" && opts.CiteStyle == CiteNumeric, "have %+v", opts)
+	Tassert(t, len(opts.Rules) == 1 && opts.Rules[0].Name == "bare-url", "have %+v", opts.Rules)
+	Tassert(t, opts.ApplyProfile("nonesuch") != nil, "want error for an unknown profile")
+
+	// a list of profiles selects conditional content for each name
+	opts = DefaultOptions()
+	Ck(opts.SelectProfiles("ietf,internal"))
+	Tassert(t, opts.AnchorPrefix == "section-", "have %+v", opts)
+	Tassert(t, reflect.DeepEqual(opts.Conditions, []string{"ietf", "internal"}), "have %q", opts.Conditions)
+
+	// front matter can select a profile for one document
+	opts = DefaultOptions()
+	opts.FrontMatterConfig = true
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	lines := []string{
+		"---",
+		"markproc: {profile: book}",
+		"---",
+		"# Intro",
+		"TODO: write this.",
+		"# Design",
+		"See [sec intr].",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err != nil, "want error from the book profile's unfinished rule")
+	Tassert(t, out[len(out)-1] == `See [<a href="#sec1">Section 1</a> above].`, "have %q", out)
+	Tassert(t, len(p.Rules) == 0 && p.SecRefFormat == "sec {number}", "profile leaked: %+v", p.Options)
+
+	profiles, err := ReadProfiles(strings.NewReader(`{"handbook": {"settings": {"toc-depth": "2"}, "rules": [{"name": "no-todo", "pattern": "TODO", "message": "Unfinished"}], "output": "html"}}`))
+	Tassert(t, err == nil, "ReadProfiles failed: %v", err)
+	Tassert(t, profiles["handbook"].Output == "html" && profiles["handbook"].Rules[0].re != nil, "have %+v", profiles)
+	for _, bad := range []string{
+		`{"x": {"settings": {"no-such-setting": "1"}}}`,
+		`{"x": {"settings": {"profile": "ietf"}}}`,
+		`{"x": {"rules": [{"name": "r", "pattern": "("}]}}`,
+		`{"x": {"output": "pdf"}}`,
+	} {
+		_, err = ReadProfiles(strings.NewReader(bad))
+		Tassert(t, err != nil, "%s: want error", bad)
+	}
+}
@@ -0,0 +1,290 @@
+package markproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Document is one file of a multi-file project.
+type Document struct {
+	Path  string
+	Lines []string
+}
+
+// project holds the state shared by the files of a project while it is
+// processed.  Its methods may be called on a nil *project, in which
+// case they behave as for a single document.
+type project struct {
+	// numbers carries section numbering from one file to the next.
+	numbers *numberer
+	// current is the path of the file being processed.
+	current string
+	// sections holds the numbered headings of every file.
+	sections map[string]Target
+	// externs maps each [ref]: definition to the file defining it.
+	externs map[string]string
+	// displays holds the display text of every file's definitions.
+	displays map[string]string
+	// bib holds the definitions of every file when citations are
+	// styled.
+	bib *bibliography
+}
+
+// numberer returns the numberer for the next file's headings, or nil
+// for a single document.
+func (pr *project) numberer() *numberer {
+	if pr == nil {
+		return nil
+	}
+	return pr.numbers
+}
+
+// sectionTargets returns the numbered headings of every file, or nil
+// for a single document.
+func (pr *project) sectionTargets() map[string]Target {
+	if pr == nil {
+		return nil
+	}
+	return pr.sections
+}
+
+// externFile returns the file defining ref, or "" if it is undefined or
+// not processing a project.
+func (pr *project) externFile(ref string) string {
+	if pr == nil {
+		return ""
+	}
+	return pr.externs[ref]
+}
+
+// externDisplays returns the display text of the definitions in every
+// file, or nil for a single document.
+func (pr *project) externDisplays() map[string]string {
+	if pr == nil {
+		return nil
+	}
+	return pr.displays
+}
+
+// fileHref returns the href prefix for a link from the file being
+// processed to a target in file: empty for the same file, and otherwise
+// the relative path to file with its extension replaced by
+// p.ProjectLinkExt.
+func (p *Processor) fileHref(file string) string {
+	if p.project == nil || file == "" || file == p.project.current {
+		return ""
+	}
+	if href, ok := p.siteHref(file); ok {
+		return href
+	}
+	rel, err := filepath.Rel(filepath.Dir(p.project.current), file)
+	if err != nil {
+		rel = file
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + p.ProjectLinkExt
+	return filepath.ToSlash(rel)
+}
+
+// ProcessProject processes docs as the chapters of a single document.
+// Sections are numbered continuously from one file to the next, and
+// [sec ...] and [ref] references resolve across files, linking to
+// other files as e.g. chapter2.html#sec3_2.  The processed documents
+// are returned even if the error is non-nil.
+func (p *Processor) ProcessProject(docs []Document) (out []Document, err error) {
+	p.reset()
+	p.project = &project{
+		numbers:  p.startNumberer(),
+		sections: map[string]Target{},
+		externs:  map[string]string{},
+		displays: map[string]string{},
+	}
+	defer func() { p.project = nil }()
+	defer func() {
+		for i := range out {
+			out[i].Lines = p.finish(out[i].Lines)
+		}
+	}()
+
+	// origins holds the input line of each processed line of each doc
+	origins := make([][]int, len(docs))
+	for i, doc := range docs {
+		p.project.current = doc.Path
+		doc.Lines = plainLines(doc.Lines)
+		p.origin = identity(len(doc.Lines))
+		p.input = doc.Lines
+		lines := p.runPasses(doc.Lines, false)
+		origins[i] = p.origin
+		out = append(out, Document{Path: doc.Path, Lines: lines})
+	}
+
+	for _, doc := range out {
+		for key, target := range p.sectionTargets(doc.Lines, doc.Path) {
+			addTarget(p.project.sections, key, target)
+		}
+		code := codeMask(doc.Lines)
+		for i, line := range doc.Lines {
+			if code[i] {
+				continue
+			}
+			if extMatch := extLinkRegexp.FindStringSubmatch(line); len(extMatch) > 0 {
+				p.project.externs[extMatch[1]] = doc.Path
+			}
+		}
+		for ref, display := range externDisplays(doc.Lines) {
+			p.project.displays[ref] = display
+		}
+	}
+
+	if p.CiteStyle != "" {
+		all := [][]string{}
+		for _, doc := range out {
+			all = append(all, doc.Lines)
+		}
+		p.project.bib = newBibliography(all, p.CiteStyle)
+		if p.HideDefinitions && p.project.bib.marker >= 0 {
+			// the definitions' anchors move to the formatted list
+			for ref := range p.project.externs {
+				p.project.externs[ref] = out[p.project.bib.marker].Path
+			}
+		}
+	}
+
+	// the linking passes only read the targets collected above, so the
+	// files can be linked concurrently
+	p.forEachFile(out, func(i int, q *Processor) {
+		q.origin = origins[i]
+		q.input = docs[i].Lines
+		out[i].Lines = q.runPasses(out[i].Lines, true)
+		origins[i] = q.origin
+	})
+
+	if p.Verify {
+		index := p.Index
+		if index == nil {
+			index = NewIndex()
+		}
+		for _, doc := range out {
+			if href, ok := p.siteHref(doc.Path); ok {
+				index.Alias(href, doc.Path)
+			}
+		}
+		// only the files whose links may resolve differently are
+		// verified again, the others reporting what they did before
+		affected := map[string]bool{}
+		for _, path := range index.Sync(out) {
+			affected[path] = true
+		}
+		errs := make([]VerifyErrors, len(out))
+		p.forEachFile(out, func(i int, q *Processor) {
+			doc := out[i]
+			sum := linesSum(docs[i].Lines)
+			stderr := q.Stderr.(*bytes.Buffer)
+			if result, ok := index.verified(doc.Path, sum); ok && !affected[doc.Path] {
+				errs[i] = append(errs[i], result.errs...)
+				q.warnings = append(q.warnings, result.warnings...)
+				stderr.Write(result.stderr)
+				return
+			}
+			warned, written := len(q.warnings), stderr.Len()
+			q.origin = origins[i]
+			q.input = docs[i].Lines
+			var found VerifyErrors
+			if errors.As(q.timedVerify(doc.Lines), &found) {
+				for _, ferr := range found {
+					errs[i] = append(errs[i], fmt.Errorf("%s: %w", doc.Path, ferr))
+				}
+			}
+			for _, link := range index.Broken(doc.Path) {
+				if link.File == "" {
+					// same-file links were checked by verify
+					continue
+				}
+				msg := fmt.Sprintf("Link points to an undefined target: %s#%s", link.File, link.Anchor)
+				q.record("undefined-target", link.Line-1, link.Anchor, msg)
+				errs[i] = append(errs[i], fmt.Errorf("%s: line %d: %s", doc.Path, q.lineOf(link.Line-1), msg))
+			}
+			index.setVerified(doc.Path, &verifyResult{
+				sum:      sum,
+				errs:     errs[i],
+				warnings: append([]Warning{}, q.warnings[warned:]...),
+				stderr:   append([]byte{}, stderr.Bytes()[written:]...),
+			})
+		})
+		var all VerifyErrors
+		for _, ferrs := range errs {
+			all = append(all, ferrs...)
+		}
+		if len(all) > 0 {
+			err = fmt.Errorf("Verification error: %w", all)
+		}
+		if len(out) > 1 {
+			// the first file is the project's index
+			for _, path := range index.Unreachable(out[0].Path) {
+				p.project.current = path
+				p.warnfLine("unreachable-doc", 0, "", "%s is not linked from %s or any file it links to", path, out[0].Path)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	if p.failure != "" {
+		err = fmt.Errorf("%s", p.failure)
+	}
+	return
+}
+
+// forEachFile calls f for each of docs, the files of the project, with
+// a Processor of its own, running up to p.Jobs calls at once.  The
+// warnings, timings and failures of the calls are added to p's in the
+// order of docs.  A RefLog can't be shared, so with one set the files
+// are taken one at a time.
+func (p *Processor) forEachFile(docs []Document, f func(i int, q *Processor)) {
+	procs := make([]*Processor, len(docs))
+	for i, doc := range docs {
+		pr := *p.project
+		pr.current = doc.Path
+		procs[i] = &Processor{
+			Options:  p.Options,
+			Stderr:   &bytes.Buffer{},
+			RefLog:   p.RefLog,
+			project:  &pr,
+			warnings: []Warning{},
+		}
+	}
+
+	jobs := p.Jobs
+	if jobs < 1 || p.RefLog != nil {
+		jobs = 1
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i, procs[i])
+			}
+		}()
+	}
+	for i := range docs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, q := range procs {
+		p.warnings = append(p.warnings, q.warnings...)
+		p.timings = append(p.timings, q.timings...)
+		if p.failure == "" {
+			p.failure = q.failure
+		}
+		io.Copy(p.Stderr, q.Stderr.(*bytes.Buffer))
+	}
+}
@@ -0,0 +1,181 @@
+package markproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestProcessProject(t *testing.T) {
+	docs := []Document{
+		{Path: "chapters/one.md", Lines: []string{
+			"# Introduction",
+			"See [sec dsgn] and [ref1].",
+		}},
+		{Path: "chapters/two.md", Lines: []string{
+			"# Design",
+			"## Goals",
+			"Back to [sec intro].",
+		}},
+		{Path: "refs/bib.md", Lines: []string{
+			"[ref1]: A reference.",
+		}},
+	}
+
+	p := NewProcessor(DefaultOptions())
+	out, err := p.ProcessProject(docs)
+	Tassert(t, err == nil, "ProcessProject failed: %v", err)
+
+	want := [][]string{
+		{
+			`<a name="sec1"></a>`,
+			"# 1. Introduction",
+			`See [<a href="two.html#sec2">sec 2</a>] and [<a href="../refs/bib.html#ref1">ref1</a>].`,
+		},
+		{
+			`<a name="sec2"></a>`,
+			"# 2. Design",
+			`<a name="sec2_1"></a>`,
+			"## 2.1. Goals",
+			`Back to [<a href="one.html#sec1">sec 1</a>].`,
+		},
+		{
+			`<a name="ref1"></a>`,
+			"[ref1]: A reference.",
+		},
+	}
+	for i := range want {
+		Tassert(t, reflect.DeepEqual(out[i].Lines, want[i]), "%s:\nwant: %q\nhave: %q", out[i].Path, want[i], out[i].Lines)
+	}
+
+	// a cross-file link to a missing file fails verification
+	docs = []Document{
+		{Path: "a.md", Lines: []string{`See <a href="missing.html#sec1">sec 1</a>.`}},
+		{Path: "b.md", Lines: []string{"# B", `See <a href="#nowhere">x</a>.`}},
+	}
+	p.Stderr = io.Discard
+	_, err = p.ProcessProject(docs)
+	Tassert(t, err != nil, "broken cross-file link not reported")
+	// every file's problems are reported
+	var problems VerifyErrors
+	Tassert(t, errors.As(err, &problems), "have %v", err)
+	have := []string{}
+	for _, e := range problems {
+		have = append(have, e.Error())
+	}
+	wantErrs := []string{
+		"a.md: line 1: Link points to an undefined target: missing.html#sec1",
+		"b.md: line 2: Link points to an undefined target: #nowhere",
+	}
+	Tassert(t, reflect.DeepEqual(have, wantErrs), "\nwant: %q\nhave: %q", wantErrs, have)
+}
+
+func TestUnreachableDocs(t *testing.T) {
+	docs := []Document{
+		{Path: "index.md", Lines: []string{
+			"# Contents",
+			"See [sec intr] and the [word list](glossary.md).",
+		}},
+		{Path: "intro.md", Lines: []string{"# Introduction"}},
+		{Path: "glossary.md", Lines: []string{"# Glossary"}},
+		{Path: "orphan.md", Lines: []string{"# Old notes", "Back to [sec cnts]."}},
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	_, err := p.ProcessProject(docs)
+	Tassert(t, err == nil, "ProcessProject failed: %v", err)
+	want := []Warning{{
+		Rule:     "unreachable-doc",
+		Severity: SeverityWarning,
+		File:     "orphan.md",
+		Message:  "orphan.md is not linked from index.md or any file it links to",
+	}}
+	Tassert(t, reflect.DeepEqual(p.Warnings(), want), "\nwant: %v\nhave: %v", want, p.Warnings())
+}
+
+func TestProcessProjectJobs(t *testing.T) {
+	docs := []Document{}
+	for i := 0; i < 20; i++ {
+		docs = append(docs, Document{Path: fmt.Sprintf("ch%02d.md", i), Lines: []string{
+			fmt.Sprintf("# Topic %c", 'A'+i),
+			fmt.Sprintf("See [sec tpc%c], [sec nosuch] and [ref%d].", 'A'+(i+1)%20, i),
+			fmt.Sprintf("[ref%d]: Reference %d.", i, i),
+		}})
+	}
+	run := func(jobs int) ([]Document, []Warning, string, error) {
+		opts := DefaultOptions()
+		opts.Jobs = jobs
+		p := NewProcessor(opts)
+		var stderr bytes.Buffer
+		p.Stderr = &stderr
+		out, err := p.ProcessProject(docs)
+		return out, p.Warnings(), stderr.String(), err
+	}
+	wantOut, wantWarnings, wantStderr, wantErr := run(1)
+	Tassert(t, wantErr != nil && len(wantWarnings) == 20, "have %v, %d warnings", wantErr, len(wantWarnings))
+	for i := 0; i < 5; i++ {
+		out, warnings, stderr, err := run(8)
+		Tassert(t, reflect.DeepEqual(out, wantOut), "output differs with 8 jobs")
+		Tassert(t, reflect.DeepEqual(warnings, wantWarnings), "\nwant: %v\nhave: %v", wantWarnings, warnings)
+		Tassert(t, stderr == wantStderr, "\nwant: %s\nhave: %s", wantStderr, stderr)
+		Tassert(t, err.Error() == wantErr.Error(), "want %v, have %v", wantErr, err)
+	}
+}
+
+func TestProcessProjectIndex(t *testing.T) {
+	docs := []Document{
+		{Path: "one.md", Lines: []string{"# Introduction", "See [sec dsgn]."}},
+		{Path: "two.md", Lines: []string{"# Design", "Back to [sec intro]."}},
+		{Path: "three.md", Lines: []string{"# Notes", "Nothing to see."}},
+	}
+	p := NewProcessor(DefaultOptions())
+	p.Index = NewIndex()
+	_, err := p.ProcessProject(docs)
+	Tassert(t, err == nil, "ProcessProject failed: %v", err)
+	entries := map[string]*fileEntry{}
+	for path, entry := range p.Index.files {
+		entries[path] = entry
+	}
+
+	// a rebuild after one file changes re-indexes only that file
+	docs[2].Lines = []string{"# Notes", "Something to see."}
+	_, err = p.ProcessProject(docs)
+	Tassert(t, err == nil, "ProcessProject failed: %v", err)
+	for _, path := range []string{"one.md", "two.md"} {
+		Tassert(t, p.Index.files[path] == entries[path], "%s re-indexed", path)
+	}
+	Tassert(t, p.Index.files["three.md"] != entries["three.md"], "three.md not re-indexed")
+	verified := func() (n int) {
+		for _, timing := range p.Timings() {
+			if timing.Pass == "verify" {
+				n++
+			}
+		}
+		return
+	}
+	Tassert(t, verified() == 1, "%d files re-verified", verified())
+
+	// the files not re-verified still report what they did before
+	docs[0].Lines = []string{"# Introduction", "See [sec dsgn].", "<a name=\"sec1\"></a>"}
+	_, err = p.ProcessProject(docs)
+	Tassert(t, err != nil, "duplicate anchor not reported")
+	docs[2].Lines = []string{"# Notes", "Something else to see."}
+	_, err = p.ProcessProject(docs)
+	Tassert(t, err != nil, "duplicate anchor in an unchanged file not reported")
+	Tassert(t, verified() == 1, "%d files re-verified", verified())
+	Tassert(t, len(p.Warnings()) > 0 && p.Warnings()[0].File == "one.md", "have %v", p.Warnings())
+	docs[0].Lines = []string{"# Introduction", "See [sec dsgn]."}
+
+	// links into a file that changes are re-resolved
+	docs[1].Lines = []string{"# Design", "Back to [sec intro].", `Old <a href="three.html#sec3">notes</a>.`}
+	docs = docs[:2]
+	_, err = p.ProcessProject(docs)
+	Tassert(t, err != nil, "link to a removed file not reported")
+	Tassert(t, reflect.DeepEqual(p.Index.Files(), []string{"one.md", "two.md"}), "have %v", p.Index.Files())
+}
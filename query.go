@@ -0,0 +1,260 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sections returns the sections of the document lines like Outline,
+// whether or not they have been processed: if the document has
+// numbered headings, e.g. "## 2.3. Design", they are taken as they are
+// and Line gives their place in the processed document.
+func (p *Processor) Sections(lines []string) (sections []Section) {
+	if !p.processed(lines) {
+		return p.Outline(lines)
+	}
+	code := codeMask(lines)
+	sections = []Section{}
+	anchor := p.anchorNamer()
+	for i, line := range lines {
+		m := p.numberedHeading(line)
+		if code[i] || m == nil {
+			continue
+		}
+		number := strings.TrimSuffix(m[2], ".")
+		title, id, excluded := headingTitle(m[3])
+		if excluded {
+			continue
+		}
+		name := anchor(number, title)
+		if id != "" {
+			name = id
+		}
+		sections = append(sections, Section{Level: len(m[1]), Number: number, Title: title, Anchor: name, Line: i + 1})
+	}
+	return
+}
+
+// processed reports whether the document lines have numbered headings
+// outside code blocks, as processed documents do.
+func (p *Processor) processed(lines []string) bool {
+	code := codeMask(lines)
+	for i, line := range lines {
+		if !code[i] && p.numberedHeading(line) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Query selects sections by their fields.  See ParseQuery.
+type Query struct {
+	// any holds alternatives, each matching when all its conditions
+	// do.
+	any [][]condition
+}
+
+// condition compares one field of a section with a value.
+type condition struct {
+	field, op, value string
+	re               *regexp.Regexp
+	n                int
+}
+
+// queryFields lists the fields of a Section a query can name.
+var queryFields = map[string]string{
+	"level":   "level",
+	"number":  "number",
+	"title":   "title",
+	"heading": "title",
+	"anchor":  "anchor",
+	"line":    "line",
+}
+
+// queryTokenRe matches one token of a query: a quoted string, a
+// /regexp/, an operator or a word.
+var queryTokenRe = regexp.MustCompile(`^(?:"(?:[^"\\]|\\.)*"|'[^']*'|/(?:[^/\\]|\\.)*/|=~|!~|==|!=|<=|>=|&&|\|\||[~=<>]|[^\s"'/=!<>~&|]+)`)
+
+// ParseQuery parses a query selecting sections, e.g.
+//
+//	heading ~ "overtone" and level <= 2
+//
+// A condition compares a field, one of level, number, title (or
+// heading), anchor and line, with a value, quoted or not.  = and !=
+// compare text ignoring case, ~ and !~ match a regular expression
+// ignoring case, and <, <=, > and >= compare level or line.  Conditions
+// are joined with "and" and "or", "and" binding tighter.  The
+// JSONPath-style filter $[?(@.level == 2 && @.title =~ /design/)] over
+// the JSON outline is accepted too, and an empty query, $ or $[*]
+// selects every section.
+func ParseQuery(expr string) (q *Query, err error) {
+	expr = strings.TrimSpace(expr)
+	switch expr {
+	case "", "$", "$[*]", "$.*", "*":
+		return &Query{any: [][]condition{nil}}, nil
+	}
+	if strings.HasPrefix(expr, "$") {
+		inner := strings.TrimPrefix(expr, "$")
+		if !strings.HasPrefix(inner, "[?(") || !strings.HasSuffix(inner, ")]") {
+			return nil, fmt.Errorf("unsupported selector %q; want $[?(CONDITION)]", expr)
+		}
+		expr = inner[3 : len(inner)-2]
+	}
+
+	tokens := []string{}
+	for rest := strings.TrimSpace(expr); rest != ""; rest = strings.TrimSpace(rest) {
+		tok := queryTokenRe.FindString(rest)
+		if tok == "" {
+			return nil, fmt.Errorf("unexpected %q in query", rest)
+		}
+		tokens = append(tokens, tok)
+		rest = rest[len(tok):]
+	}
+
+	q = &Query{}
+	var all []condition
+	for len(tokens) > 0 {
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("incomplete condition %q; want FIELD OP VALUE", strings.Join(tokens, " "))
+		}
+		c, err := newCondition(tokens[0], tokens[1], tokens[2])
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, c)
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			break
+		}
+		switch strings.ToLower(tokens[0]) {
+		case "and", "&&":
+		case "or", "||":
+			q.any = append(q.any, all)
+			all = nil
+		default:
+			return nil, fmt.Errorf("want and or or, not %q", tokens[0])
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("query ends with a dangling operator")
+		}
+	}
+	q.any = append(q.any, all)
+	return
+}
+
+// newCondition returns the condition comparing field with value, as
+// they appear in a query.
+func newCondition(field, op, value string) (c condition, err error) {
+	name, ok := queryFields[strings.ToLower(strings.TrimPrefix(field, "@."))]
+	if !ok {
+		return c, fmt.Errorf("unknown field %q; want level, number, title, anchor or line", field)
+	}
+	c = condition{field: name, op: op}
+	switch op {
+	case "==":
+		c.op = "="
+	case "=~":
+		c.op = "~"
+	}
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return c, fmt.Errorf("bad string %s in query", value)
+		}
+		value = unquoted
+	case strings.HasPrefix(value, "'"), strings.HasPrefix(value, "/"):
+		value = value[1 : len(value)-1]
+	}
+	c.value = value
+
+	switch c.op {
+	case "~", "!~":
+		c.re, err = regexp.Compile("(?i)" + value)
+		if err != nil {
+			return c, fmt.Errorf("bad regular expression %q in query: %w", value, err)
+		}
+	case "<", "<=", ">", ">=":
+		if name != "level" && name != "line" {
+			return c, fmt.Errorf("%s can't be compared with %s; only level and line can", field, op)
+		}
+		c.n, err = strconv.Atoi(value)
+		if err != nil {
+			return c, fmt.Errorf("%s %s %s: want a number", field, op, value)
+		}
+	case "=", "!=":
+	default:
+		return c, fmt.Errorf("unknown operator %q; want =, !=, ~, !~, <, <=, > or >=", op)
+	}
+	return
+}
+
+// Match reports whether q selects sec.
+func (q *Query) Match(sec Section) bool {
+	for _, all := range q.any {
+		matched := true
+		for _, c := range all {
+			if !c.match(sec) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the sections q selects, in order.
+func (q *Query) Select(sections []Section) (selected []Section) {
+	selected = []Section{}
+	for _, sec := range sections {
+		if q.Match(sec) {
+			selected = append(selected, sec)
+		}
+	}
+	return
+}
+
+func (c condition) match(sec Section) bool {
+	n := 0
+	text := ""
+	switch c.field {
+	case "level":
+		n = sec.Level
+		text = strconv.Itoa(n)
+	case "line":
+		n = sec.Line
+		text = strconv.Itoa(n)
+	case "number":
+		text = sec.Number
+	case "title":
+		text = sec.Title
+	case "anchor":
+		text = sec.Anchor
+	}
+	switch c.op {
+	case "=":
+		return strings.EqualFold(text, c.value)
+	case "!=":
+		return !strings.EqualFold(text, c.value)
+	case "~":
+		return c.re.MatchString(text)
+	case "!~":
+		return !c.re.MatchString(text)
+	case "<":
+		return n < c.n
+	case "<=":
+		return n <= c.n
+	case ">":
+		return n > c.n
+	case ">=":
+		return n >= c.n
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestQuery(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"## Overtones of the fiddle",
+		"# Design",
+		"## Harmonic overtone series",
+		"### Detail",
+	}
+	p := NewProcessor(DefaultOptions())
+	sections := p.Sections(lines)
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{`heading ~ "overtone"`, []string{"1.1", "2.1"}},
+		{`heading ~ overtone and level > 1 and number != 1.1`, []string{"2.1"}},
+		{`title = design or anchor = sec2_1_1`, []string{"2", "2.1.1"}},
+		{`line >= 4`, []string{"2.1", "2.1.1"}},
+		{`title !~ '^(intro|design)$'`, []string{"1.1", "2.1", "2.1.1"}},
+		{`$[?(@.level == 2 && @.title =~ /harmonic/)]`, []string{"2.1"}},
+		{`$[*]`, []string{"1", "1.1", "2", "2.1", "2.1.1"}},
+	}
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		Tassert(t, err == nil, "%s: %v", c.query, err)
+		have := []string{}
+		for _, sec := range q.Select(sections) {
+			have = append(have, sec.Number)
+		}
+		Tassert(t, reflect.DeepEqual(have, c.want), "%s\nwant: %q\nhave: %q", c.query, c.want, have)
+	}
+
+	for _, bad := range []string{`title ~`, `size = 3`, `title < 3`, `level = 1 and`, `level = 1 level = 2`, `title ~ "("`, `$.sections[0]`} {
+		_, err := ParseQuery(bad)
+		Tassert(t, err != nil, "%s: want error", bad)
+	}
+
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	q, err := ParseQuery(`title ~ detail`)
+	Ck(err)
+	have := q.Select(p.Sections(out))
+	want := []Section{{Level: 3, Number: "2.1.1", Title: "Detail", Anchor: "sec2_1_1", Line: 10}}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %+v\nhave: %+v", want, have)
+}
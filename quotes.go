@@ -0,0 +1,76 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// quoteRe matches a line of a blockquote.
+var quoteRe = regexp.MustCompile(`^ {0,3}>`)
+
+// quoteScanner tracks whether successive lines of a document, outside
+// code blocks, are part of a blockquote: lines starting with > and the
+// lines continuing a paragraph in one without a > of their own.
+type quoteScanner struct {
+	in bool
+}
+
+// inQuote reports whether line, the next line of the document, is part
+// of a blockquote.
+func (s *quoteScanner) inQuote(line string) bool {
+	switch {
+	case quoteRe.MatchString(line):
+		s.in = true
+	case strings.TrimSpace(line) == "", headerRegexp.MatchString(line),
+		fenceRegexp.MatchString(line), listItemRegexp.MatchString(line):
+		s.in = false
+	}
+	return s.in
+}
+
+// passQuotes brackets each blockquote with <!-- markproc: quote --> and
+// <!-- markproc: end-quote --> directives, so that the other passes
+// leave it alone like a code block: headings and references in quoted
+// text are quoted, not part of the document.  unwrap removes the
+// directives again.
+func (p *Processor) passQuotes(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	quotes := &quoteScanner{}
+	in := false
+	for i, line := range lines {
+		quoted := !code[i] && quotes.inQuote(line)
+		if quoted && !in {
+			out.add(i, quoteStart)
+		}
+		if !quoted && in {
+			out.add(i, quoteEnd)
+		}
+		in = quoted
+		out.add(i, line)
+	}
+	if in {
+		out.add(len(lines)-1, quoteEnd)
+	}
+	return p.done(out)
+}
+
+// The directives passQuotes puts around blockquotes.
+const (
+	quoteStart = "<!-- markproc: quote -->"
+	quoteEnd   = "<!-- markproc: end-quote -->"
+)
+
+// unwrap returns lines, the processed document, without the directives
+// passQuotes and passLinkDefs added.
+func (p *Processor) unwrap(lines []string) []string {
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		switch line {
+		case quoteStart, quoteEnd, linkDefsStart, linkDefsEnd:
+		default:
+			out.add(i, line)
+		}
+	}
+	return p.done(out)
+}
@@ -0,0 +1,62 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestBlockquotes(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"",
+		"> # Quoted heading",
+		"> As [rfc1] says,",
+		"see [sec intr].",
+		"",
+		"- [x] cite [rfc1] here",
+		"",
+		"[rfc1]: Request for Comments 1.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	have, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"",
+		"> # Quoted heading",
+		"> As [rfc1] says,",
+		"see [sec intr].",
+		"",
+		`- [x] cite [<a href="#rfc1">rfc1</a>] here`,
+		"",
+		`<a name="rfc1"></a>`,
+		"[rfc1]: Request for Comments 1.",
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+
+	// reprocessing gives the same output
+	again, err := p.Process(have)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(expect, again), "\nwant: %q\nhave: %q", expect, again)
+
+	stripped := p.Strip(have)
+	Tassert(t, reflect.DeepEqual(lines, stripped), "\nwant: %q\nhave: %q", lines, stripped)
+
+	// -blockquotes links the quoted references, but doesn't number the
+	// quoted heading
+	opts := DefaultOptions()
+	opts.Blockquotes = true
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	have, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, have[3] == "> # Quoted heading", "have %q", have[3])
+	Tassert(t, have[4] == `> As [<a href="#rfc1">rfc1</a>] says,`, "have %q", have[4])
+	Tassert(t, have[5] == `see [<a href="#sec1">sec 1</a>].`, "have %q", have[5])
+}
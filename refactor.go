@@ -0,0 +1,95 @@
+package markproc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Edit is a change made to a line of a document.
+type Edit struct {
+	Path string
+	// Line is the 1-based line changed.
+	Line     int
+	Old, New string
+}
+
+// RenameHeading renames the section titled oldTitle to newTitle in
+// docs, the source files of a project or a single document, and
+// rewrites each [sec ...] reference resolving to it to an abbreviation
+// of newTitle that resolves to it alone.  If the heading has already
+// been renamed by hand, only the references are rewritten.  References
+// by number or ID are left alone, as they still resolve; one with no
+// abbreviation of newTitle to replace it is left too, with a
+// rename-unresolved warning.  Titles are compared ignoring case.
+func (p *Processor) RenameHeading(docs []Document, oldTitle, newTitle string) (out []Document, edits []Edit, err error) {
+	p.reset()
+	defer func() { p.project = nil }()
+	oldKey, newKey := strings.ToLower(oldTitle), strings.ToLower(newTitle)
+
+	// before and after hold the keys of the sections before and after
+	// the rename
+	before, after := []string{}, []string{}
+	found := false
+	for _, doc := range docs {
+		for _, h := range p.headings(doc.Lines) {
+			key := strings.ToLower(h.Title)
+			switch key {
+			case oldKey:
+				found = true
+				before, after = append(before, oldKey), append(after, newKey)
+			case newKey:
+				found = true
+				before, after = append(before, oldKey), append(after, newKey)
+			default:
+				before, after = append(before, key), append(after, key)
+			}
+		}
+	}
+	if !found {
+		return docs, nil, fmt.Errorf("no heading is titled %q or %q", oldTitle, newTitle)
+	}
+	abbrev, abbrevOK := abbreviate(p.matcher(), newKey, after)
+
+	for _, doc := range docs {
+		p.project = &project{current: doc.Path}
+		p.input = doc.Lines
+		lines := append([]string{}, doc.Lines...)
+		code := codeMask(lines)
+		for i, line := range lines {
+			if code[i] {
+				continue
+			}
+			if m := headerRegexp.FindStringSubmatch(line); m != nil {
+				text := m[2]
+				if h := p.numberedHeading(line); h != nil {
+					text = h[3]
+				}
+				if title, _, _ := headingTitle(text); strings.ToLower(title) == oldKey {
+					lines[i] = strings.Replace(line, title, newTitle, 1)
+				}
+			} else {
+				lines[i] = outsideCode(line, func(part string) string {
+					return sectionRefRegexp.ReplaceAllStringFunc(part, func(ref string) string {
+						text := sectionRefRegexp.FindStringSubmatch(ref)[1]
+						if strings.HasPrefix(text, "#") || sectionNumberRe.MatchString(text) {
+							return ref
+						}
+						if matches := p.matcher().Match(text, before); len(matches) != 1 || matches[0] != oldKey {
+							return ref
+						}
+						if !abbrevOK {
+							p.warnfLine("rename-unresolved", i+1, ref, "%s refers to %q, but no abbreviation picks out %q alone", ref, oldTitle, newTitle)
+							return ref
+						}
+						return "[sec " + abbrev + "]"
+					})
+				})
+			}
+			if lines[i] != line {
+				edits = append(edits, Edit{Path: doc.Path, Line: i + 1, Old: line, New: lines[i]})
+			}
+		}
+		out = append(out, Document{Path: doc.Path, Lines: lines})
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRenameHeading(t *testing.T) {
+	docs := []Document{
+		{Path: "a.md", Lines: []string{
+			"# Intro",
+			"See [sec dsgn], [sec 2] and `[sec dsgn]`.",
+			"## Design goals <!-- toc-exclude -->",
+		}},
+		{Path: "b.md", Lines: []string{
+			"# Details",
+			"Back to [sec desgoals].",
+		}},
+	}
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, edits, err := p.RenameHeading(docs, "design goals", "Architecture")
+	Tassert(t, err == nil, "RenameHeading failed: %v", err)
+	want := [][]string{
+		{
+			"# Intro",
+			"See [sec architectur], [sec 2] and `[sec dsgn]`.",
+			"## Architecture <!-- toc-exclude -->",
+		},
+		{
+			"# Details",
+			"Back to [sec architectur].",
+		},
+	}
+	for i := range want {
+		Tassert(t, reflect.DeepEqual(out[i].Lines, want[i]), "%s:\nwant: %q\nhave: %q", out[i].Path, want[i], out[i].Lines)
+	}
+	Tassert(t, len(edits) == 3 && edits[2].Path == "b.md" && edits[2].Line == 2, "have %v", edits)
+
+	_, _, err = p.RenameHeading(docs, "Nowhere", "Somewhere")
+	Tassert(t, err != nil, "want error")
+
+	// a heading already renamed by hand has its references updated
+	docs[0].Lines[2] = "## Architecture"
+	out, _, err = p.RenameHeading(docs, "Design goals", "Architecture")
+	Tassert(t, err == nil, "RenameHeading failed: %v", err)
+	Tassert(t, out[1].Lines[1] == "Back to [sec architectur].", "have %q", out[1].Lines[1])
+}
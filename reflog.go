@@ -0,0 +1,75 @@
+package markproc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// RefLog records, for each [sec ...] reference of a document, the text
+// of the heading it resolved to.  Kept between runs, e.g. in a sidecar
+// file, it lets a later run tell when a referenced heading has been
+// reworded enough that the reference may no longer fit its context.
+type RefLog map[string]string
+
+// ReadRefLog reads a RefLog written by Write.  A missing file is not an
+// error; it yields an empty RefLog.
+func ReadRefLog(path string) (log RefLog, err error) {
+	log = RefLog{}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return log, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(buf, &log)
+	return
+}
+
+// Write writes log to the file at path as JSON.
+func (log RefLog) Write(path string) (err error) {
+	buf, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// check compares the heading a reference resolves to now with the one
+// it resolved to before, warning if it has changed substantially, and
+// then records the new heading.
+func (log RefLog) check(p *Processor, i int, ref, heading string) {
+	if log == nil {
+		return
+	}
+	if prev, ok := log[ref]; ok && headingChanged(prev, heading) {
+		p.warnf("sec-stale", i, "[sec "+ref+"]", "[sec %s] now resolves to %q, which was %q when last processed", ref, heading, prev)
+	}
+	log[ref] = heading
+}
+
+// headingChanged reports whether a and b, ignoring case, share fewer
+// than half of their distinct words.
+func headingChanged(a, b string) bool {
+	wa, wb := wordSet(a), wordSet(b)
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	union := len(wa) + len(wb) - shared
+	return shared*2 < union
+}
+
+// wordSet returns the distinct lower-cased words of s.
+func wordSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		set[w] = true
+	}
+	return set
+}
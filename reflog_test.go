@@ -0,0 +1,48 @@
+package markproc
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRefLog(t *testing.T) {
+	lines := []string{
+		"# Design Goals",
+		"# Scope",
+		"See [sec dgoals].",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	p.RefLog = RefLog{}
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+	Tassert(t, reflect.DeepEqual(p.RefLog, RefLog{"dgoals": "Design Goals"}), "have %v", p.RefLog)
+
+	path := filepath.Join(t.TempDir(), "doc.md.refs.json")
+	err = p.RefLog.Write(path)
+	Ck(err)
+	log, err := ReadRefLog(path)
+	Ck(err)
+	Tassert(t, reflect.DeepEqual(log, p.RefLog), "have %v", log)
+
+	// a small edit to the heading goes unremarked
+	p.RefLog = log
+	_, err = p.Process([]string{"# Design Goals Revisited", "# Scope", "See [sec dgoals]."})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+
+	// a rewording is reported once
+	_, err = p.Process([]string{"# Deferred Goodies and Other Loose Ends", "# Scope", "See [sec dgoals]."})
+	Tassert(t, err == nil, "Process failed: %v", err)
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 1 && warnings[0].Rule == "sec-stale" && warnings[0].Line == 3, "have %v", warnings)
+
+	log, err = ReadRefLog(filepath.Join(t.TempDir(), "missing.json"))
+	Tassert(t, err == nil && len(log) == 0, "missing log: %v %v", log, err)
+}
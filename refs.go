@@ -0,0 +1,71 @@
+package markproc
+
+import "strings"
+
+// Reference is a [sec ...] or [#id] reference resolved during the last
+// Process call.
+type Reference struct {
+	// Line and Column are the 1-based position of the reference in the
+	// input document; Column is zero if it isn't known.
+	Line, Column int
+	// Text is the reference as written, e.g. "[sec dsgn]".
+	Text   string
+	Target Target
+}
+
+// References returns the references resolved during the last Process
+// call, for editors to jump from a reference to its section.
+func (p *Processor) References() []Reference {
+	return p.refs
+}
+
+// recordRef records that ref, found on line i of the pass input,
+// resolved to target.
+func (p *Processor) recordRef(i int, ref string, target Target) {
+	r := Reference{Line: p.lineOf(i), Text: ref, Target: target}
+	if r.Line >= 1 && r.Line <= len(p.input) {
+		r.Column = strings.Index(p.input[r.Line-1], ref) + 1
+	}
+	p.refs = append(p.refs, r)
+}
+
+// Completion is reference text for a section of a document, as an
+// editor may offer it inside [sec ...].
+type Completion struct {
+	// Text resolves to Section alone: an abbreviation of its title if
+	// there is one, else its explicit ID or number.
+	Text    string
+	Section Section
+}
+
+// Completions returns the reference text for each section of the
+// document lines, processed or not.
+func (p *Processor) Completions(lines []string) (completions []Completion) {
+	sections := p.Sections(lines)
+	keys := make([]string, len(sections))
+	for i, s := range sections {
+		keys[i] = strings.ToLower(s.Title)
+	}
+	ids := map[string]bool{}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if m := headerRegexp.FindStringSubmatch(line); m != nil && !code[i] {
+			if _, id, _ := headingTitle(m[2]); id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	completions = []Completion{}
+	for i, s := range sections {
+		text, ok := abbreviate(p.matcher(), keys[i], keys)
+		switch {
+		case ok:
+		case ids[s.Anchor]:
+			text = "#" + s.Anchor
+		default:
+			text = s.Number
+		}
+		completions = append(completions, Completion{Text: text, Section: s})
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package markproc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestReferences(t *testing.T) {
+	p := NewProcessor(DefaultOptions())
+	lines := []string{
+		"# Intro",
+		"See [sec dsgn] and [#goals].",
+		"## Design",
+		"## Goals {#goals}",
+	}
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have := []string{}
+	for _, r := range p.References() {
+		have = append(have, fmt.Sprintf("%d:%d %s %s", r.Line, r.Column, r.Text, r.Target.Number))
+	}
+	want := []string{"2:5 [sec dsgn] 1.1", "2:20 [#goals] 1.2"}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+
+	have = []string{}
+	for _, c := range p.Completions(lines) {
+		have = append(have, c.Section.Number+" "+c.Text)
+	}
+	want = []string{"1 intr", "1.1 desig", "1.2 goal"}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+}
@@ -0,0 +1,125 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// relatedRe matches a <!-- related: sec consensus, sec gossip --> line
+// naming the sections related to the one it is in.
+var relatedRe = regexp.MustCompile(`^\s*<!--\s*related:\s*(.*?)\s*-->\s*$`)
+
+// The comments passRelated puts around a list of related sections.
+const (
+	relatedStart = "<!-- related -->"
+	relatedEnd   = "<!-- /related -->"
+)
+
+// passRelated adds a "Related sections" list under each numbered
+// heading of a section with a <!-- related: sec X, sec Y --> line, and
+// under the heading of each section it names, so that every relation
+// shows up at both ends.  Each entry is resolved like a [sec ...]
+// reference, a heading title also matching itself; relations with
+// sections in other files of a project are listed only in the section
+// naming them.  It must run after passMkHeads.
+func (p *Processor) passRelated(lines []string) []string {
+	targets := p.project.sectionTargets()
+	if targets == nil {
+		targets = p.sectionTargets(lines, "")
+	}
+
+	// related maps the key of each section of the document to the keys
+	// of the sections related to it, in order
+	related := map[string][]string{}
+	relate := func(from, to string) {
+		for _, key := range related[from] {
+			if key == to {
+				return
+			}
+		}
+		related[from] = append(related[from], to)
+	}
+
+	code := codeMask(lines)
+	current := ""
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if m := p.numberedHeading(line); m != nil {
+			title, _ := tocExcluded(m[3])
+			current = strings.ToLower(title)
+			continue
+		}
+		m := relatedRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if current == "" {
+			p.warnf("related-unplaced", i, "related", "Related sections given before the first numbered heading")
+			continue
+		}
+		for _, ref := range strings.Split(m[1], ",") {
+			ref = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ref), "sec "))
+			if ref == "" {
+				continue
+			}
+			key, ok := p.resolveRelated(i, ref, targets)
+			if !ok || key == current {
+				continue
+			}
+			relate(current, key)
+			if target := targets[key]; p.fileHref(target.File) == "" {
+				relate(key, current)
+			}
+		}
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		out.add(i, line)
+		if code[i] {
+			continue
+		}
+		m := p.numberedHeading(line)
+		if m == nil {
+			continue
+		}
+		title, _ := tocExcluded(m[3])
+		rel := related[strings.ToLower(title)]
+		if len(rel) == 0 {
+			continue
+		}
+		// the comments let a later run find and remove the list
+		out.add(i, relatedStart, "Related sections:", "")
+		for _, key := range rel {
+			target := targets[key]
+			out.add(i, fmt.Sprintf(`- <a href="%s#%s">%s %s</a>`, p.fileHref(target.File), target.Name, p.numberText(target.Number), escapeText(target.Heading)))
+		}
+		out.add(i, relatedEnd)
+	}
+	return p.done(out)
+}
+
+// resolveRelated returns the key of the section ref, an entry of a
+// related line i, names, warning as for a [sec ...] reference if it
+// names none or more than one.
+func (p *Processor) resolveRelated(i int, ref string, targets map[string]Target) (key string, ok bool) {
+	if _, ok = targets[strings.ToLower(ref)]; ok {
+		return strings.ToLower(ref), true
+	}
+	matches := p.matcher().Match(ref, keys(targets))
+	switch len(matches) {
+	case 0:
+		p.warnf("sec-unresolved", i, ref, "related sec %s: no fuzzy match found", ref)
+	case 1:
+		return matches[0], true
+	default:
+		msg := fmt.Sprintf("related sec %s: multiple fuzzy matches found:", ref)
+		msg += candidates(ref, matches, func(key string) string { return targets[key].Heading })
+		p.warnf("sec-ambiguous", i, ref, "%s", msg)
+	}
+	p.fail("unresolved references")
+	return "", false
+}
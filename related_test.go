@@ -0,0 +1,62 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRelated(t *testing.T) {
+	lines := []string{
+		"# Consensus protocols",
+		"<!-- related: sec gossip, sec Replication -->",
+		"Text.",
+		"# Gossip",
+		"# Replication",
+		"<!-- related: sec cp -->",
+	}
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Consensus protocols",
+		relatedStart,
+		"Related sections:",
+		"",
+		`- <a href="#sec2">2. Gossip</a>`,
+		`- <a href="#sec3">3. Replication</a>`,
+		relatedEnd,
+		"<!-- related: sec gossip, sec Replication -->",
+		"Text.",
+		`<a name="sec2"></a>`,
+		"# 2. Gossip",
+		relatedStart,
+		"Related sections:",
+		"",
+		`- <a href="#sec1">1. Consensus protocols</a>`,
+		relatedEnd,
+		`<a name="sec3"></a>`,
+		"# 3. Replication",
+		relatedStart,
+		"Related sections:",
+		"",
+		`- <a href="#sec1">1. Consensus protocols</a>`,
+		relatedEnd,
+		"<!-- related: sec cp -->",
+	}
+	Tassert(t, reflect.DeepEqual(out, want), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, want), "\nwant: %q\nhave: %q", want, again)
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, lines), "\nwant: %q\nhave: %q", lines, stripped)
+
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{"# Gossip", "<!-- related: sec nothing -->"})
+	Tassert(t, err != nil, "want error for an unresolved related section")
+	Tassert(t, p.Warnings()[0].Rule == "sec-unresolved" && p.Warnings()[0].Line == 2, "have %+v", p.Warnings())
+}
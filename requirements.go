@@ -0,0 +1,212 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The comments passRequirements puts around a Requirements
+// Traceability table.
+const (
+	reqStart = "<!-- requirements -->"
+	reqEnd   = "<!-- /requirements -->"
+)
+
+// isRequirementsMarker reports whether line asks for a Requirements
+// Traceability table.
+func isRequirementsMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[requirements]", reqStart:
+		return true
+	}
+	return isDirective(line, "requirements")
+}
+
+// reqAnchor returns the anchor name of the definition of requirement
+// id, e.g. req-042.
+func reqAnchor(id string) string {
+	return safeAnchorName(strings.ToLower(id))
+}
+
+// reqID returns the pattern matching the IDs of requirements, e.g.
+// REQ-042 or REQ-SEC-1.2 with p.Requirements set to REQ.
+func (p *Processor) reqID() string {
+	return regexp.QuoteMeta(p.Requirements) + `-\w+(?:[.-]\w+)*`
+}
+
+// reqRefRe returns the regexp matching a [REQ-042] tag: the group is
+// the ID.
+func (p *Processor) reqRefRe() *regexp.Regexp {
+	return cachedRe(`\[(` + p.reqID() + `)\]`)
+}
+
+// reqDefRe returns the regexp matching the definition of a
+// requirement, a line or list item starting with its tag, maybe
+// anchored by an earlier run: the groups are the list marker, the ID
+// and the text.
+func (p *Processor) reqDefRe() *regexp.Regexp {
+	return cachedRe(`^(\s*(?:[-*+]\s+|\d+[.)]\s+)?)(?:<a name="[^"]*"></a>)?\[(` + p.reqID() + `)\]\s+(.*)$`)
+}
+
+// reqLinkRe returns the regexp matching a reference passRequirements
+// linked: the groups are the anchor and the ID.
+func (p *Processor) reqLinkRe() *regexp.Regexp {
+	return cachedRe(`\[<a href="#([^"]+)">(` + p.reqID() + `)</a>\]`)
+}
+
+// reqMarkupRe matches the anchor of a requirement definition or a link
+// to one that passRequirements made, whatever the prefix of its tags:
+// the groups are the anchor, the ID and the prefix.
+var reqMarkupRe = regexp.MustCompile(`(?:<a name="([^"]+)"></a>\[|\[<a href="#([^"]+)">)((\w+)-\w+(?:[.-]\w+)*)[\]<]`)
+
+// requirementsPrefix returns the prefix of the requirement tags
+// passRequirements anchored or linked in lines, or "" if there are
+// none.
+func requirementsPrefix(lines []string) string {
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		for _, m := range reqMarkupRe.FindAllStringSubmatch(line, -1) {
+			if m[1]+m[2] == reqAnchor(m[3]) {
+				return m[4]
+			}
+		}
+	}
+	return ""
+}
+
+// reqDef is the definition of a requirement.
+type reqDef struct {
+	id, text string
+	// line is the index of the definition in the pass input.
+	line int
+	// section is the section holding the definition, if any.
+	section *Section
+}
+
+// unlinkRequirements returns line with the anchors and links
+// passRequirements added removed again.
+func (p *Processor) unlinkRequirements(line string) string {
+	line = p.reqLinkRe().ReplaceAllStringFunc(line, func(link string) string {
+		m := p.reqLinkRe().FindStringSubmatch(link)
+		if m[1] != reqAnchor(m[2]) {
+			return link
+		}
+		return "[" + m[2] + "]"
+	})
+	if m := p.reqDefRe().FindStringSubmatch(line); m != nil {
+		line = fmt.Sprintf("%s[%s] %s", m[1], m[2], m[3])
+	}
+	return line
+}
+
+// passRequirements anchors each requirement, a line or list item
+// starting with its tag, e.g. "[REQ-042] The server MUST ...", links
+// every other [REQ-042] to it, and replaces each [requirements] or
+// <!-- requirements --> marker line with a Requirements Traceability
+// table of the requirements in document order, giving the section and
+// text of each, between <!-- requirements --> and
+// <!-- /requirements --> comments.  It warns about, and fails on, a
+// requirement defined twice and a reference to one never defined.
+// Tags start with p.Requirements and a hyphen.  It must run after
+// passMkHeads.
+func (p *Processor) passRequirements(lines []string) []string {
+	code := codeMask(lines)
+	unlinked := make([]string, len(lines))
+	for i, line := range lines {
+		unlinked[i] = line
+		if !code[i] {
+			unlinked[i] = p.unlinkRequirements(line)
+		}
+	}
+
+	sections := p.Sections(lines)
+	defs := map[string]*reqDef{}
+	order := []*reqDef{}
+	var section *Section
+	for i, line := range unlinked {
+		for len(sections) > 0 && sections[0].Line <= i+1 {
+			section, sections = &sections[0], sections[1:]
+		}
+		m := p.reqDefRe().FindStringSubmatch(line)
+		if code[i] || m == nil {
+			continue
+		}
+		if _, ok := defs[m[2]]; ok {
+			p.warnf("req-duplicate", i, m[2], "Requirement defined more than once: %s", m[2])
+			p.fail(fmt.Sprintf("duplicate requirement: %s", m[2]))
+			continue
+		}
+		def := &reqDef{id: m[2], text: m[3], line: i, section: section}
+		defs[def.id] = def
+		order = append(order, def)
+	}
+
+	out := p.newLineWriter(lines)
+	for i := 0; i < len(unlinked); i++ {
+		line := unlinked[i]
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if isRequirementsMarker(line) {
+			if strings.TrimSpace(line) == reqStart {
+				i = skipBlock(unlinked, i, reqEnd)
+			}
+			out.add(i, reqStart)
+			out.add(i, p.renderRequirements(order)...)
+			out.add(i, reqEnd)
+			continue
+		}
+		prefix := ""
+		if m := p.reqDefRe().FindStringSubmatch(line); m != nil && defs[m[2]].line == i {
+			prefix = fmt.Sprintf(`%s<a name="%s"></a>[%s] `, m[1], reqAnchor(m[2]), m[2])
+			line = m[3]
+		}
+		out.add(i, prefix+p.linkRequirements(line, i, defs))
+	}
+	return p.done(out)
+}
+
+// linkRequirements returns line, line i of the pass input, with each
+// [REQ-042] outside code spans linked to the definition of the
+// requirement.
+func (p *Processor) linkRequirements(line string, i int, defs map[string]*reqDef) string {
+	return outsideCode(line, func(s string) string {
+		locs := p.reqRefRe().FindAllStringSubmatchIndex(s, -1)
+		for j := len(locs) - 1; j >= 0; j-- {
+			loc := locs[j]
+			if loc[1] < len(s) && strings.ContainsRune("([:", rune(s[loc[1]])) {
+				continue
+			}
+			id := s[loc[2]:loc[3]]
+			if _, ok := defs[id]; !ok {
+				p.warnf("req-undefined", i, s[loc[0]:loc[1]], "Requirement referenced but never defined: %s", id)
+				p.fail(fmt.Sprintf("undefined requirement: %s", id))
+				continue
+			}
+			s = fmt.Sprintf(`%s[<a href="#%s">%s</a>]%s`, s[:loc[0]], reqAnchor(id), id, s[loc[1]:])
+		}
+		return s
+	})
+}
+
+// renderRequirements returns the rows of the Requirements Traceability
+// table of defs, linking each ID to its definition and each section to
+// its heading.
+func (p *Processor) renderRequirements(defs []*reqDef) []string {
+	rows := []string{"| ID | Section | Requirement |", "| --- | --- | --- |"}
+	for _, def := range defs {
+		section := ""
+		if def.section != nil {
+			section = fmt.Sprintf(`<a href="#%s">%s %s</a>`, def.section.Anchor,
+				p.numberText(def.section.Number), escapeText(def.section.Title))
+		}
+		rows = append(rows, fmt.Sprintf(`| <a href="#%s">%s</a> | %s | %s |`, reqAnchor(def.id), def.id, section,
+			strings.ReplaceAll(def.text, "|", `\|`)))
+	}
+	return rows
+}
@@ -0,0 +1,65 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRequirements(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"[requirements]",
+		"# Server",
+		"- [REQ-1] The server MUST log | rotate.",
+		"- [REQ-2.1] The server SHOULD retry, see [REQ-1].",
+		"",
+		"Both [REQ-1] and [REQ-2.1] apply, not `[REQ-3]`.",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	opts.Requirements = "REQ"
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"<!-- requirements -->",
+		"| ID | Section | Requirement |",
+		"| --- | --- | --- |",
+		`| <a href="#req-1">REQ-1</a> | <a href="#sec2">2. Server</a> | The server MUST log \| rotate. |`,
+		`| <a href="#req-2.1">REQ-2.1</a> | <a href="#sec2">2. Server</a> | The server SHOULD retry, see [REQ-1]. |`,
+		"<!-- /requirements -->",
+		`<a name="sec2"></a>`,
+		"# 2. Server",
+		`- <a name="req-1"></a>[REQ-1] The server MUST log | rotate.`,
+		`- <a name="req-2.1"></a>[REQ-2.1] The server SHOULD retry, see [<a href="#req-1">REQ-1</a>].`,
+		"",
+		"Both [<a href=\"#req-1\">REQ-1</a>] and [<a href=\"#req-2.1\">REQ-2.1</a>] apply, not `[REQ-3]`.",
+	}
+	Tassert(t, reflect.DeepEqual(want, out), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(out, again), "\nwant: %q\nhave: %q", out, again)
+
+	stripped := p.Strip(out)
+	want = append([]string{"# Intro", "<!-- requirements -->"}, lines[2:]...)
+	Tassert(t, reflect.DeepEqual(want, stripped), "\nwant: %q\nhave: %q", want, stripped)
+
+	// the markup is found without being told the prefix
+	opts.Requirements = ""
+	stripped = NewProcessor(opts).Strip(out)
+	Tassert(t, reflect.DeepEqual(want, stripped), "\nwant: %q\nhave: %q", want, stripped)
+
+	_, err = p.Process([]string{"[REQ-1] Once.", "[REQ-1] Twice.", "See [REQ-9]."})
+	Tassert(t, err != nil, "duplicate and undefined requirements not reported")
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 2, "have %v", warnings)
+	Tassert(t, warnings[0].Rule == "req-duplicate" && warnings[0].Line == 2, "have %v", warnings[0])
+	Tassert(t, warnings[1].Rule == "req-undefined" && warnings[1].Line == 3 && warnings[1].Column == 5, "have %v", warnings[1])
+}
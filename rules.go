@@ -0,0 +1,176 @@
+package markproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// codeSpanRe matches an inline code span.
+var codeSpanRe = regexp.MustCompile("`+[^`]*`+")
+
+// Rule is a check defined by the user rather than built in, e.g. to
+// forbid "click here" as link text.  Lines matching Pattern are
+// reported with Message, except in code blocks and inline code.
+//
+// Scope limits the rule to part of the document: "inside TITLE" checks
+// only the section with the heading TITLE and its subsections, and
+// "outside TITLE" everything else.  An empty Scope checks the whole
+// document.  Severity is SeverityWarning, the default, or
+// SeverityError to make verification fail.
+type Rule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`
+	Scope    string `json:"scope,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+
+	re *regexp.Regexp
+}
+
+// ReadRules reads rules written as a JSON array, e.g.
+//
+//	[{"name": "bare-url", "pattern": "(^|\\s)https?://",
+//	  "scope": "outside References",
+//	  "message": "Cite URLs with a [REF] definition"}]
+func ReadRules(r io.Reader) (rules []Rule, err error) {
+	err = json.NewDecoder(r).Decode(&rules)
+	if err != nil {
+		return
+	}
+	err = compileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// compileRules checks rules read from JSON, compiling their patterns
+// and defaulting their severity.
+func compileRules(rules []Rule) (err error) {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i+1)
+		}
+		rule.re, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		switch rule.Severity {
+		case "":
+			rule.Severity = SeverityWarning
+		case SeverityWarning, SeverityError:
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q", rule.Name, rule.Severity)
+		}
+		if _, _, err = rule.scope(); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// scope splits the rule's Scope into whether it checks inside or
+// outside the section, and the section's title.
+func (rule *Rule) scope() (inside bool, title string, err error) {
+	if rule.Scope == "" {
+		return false, "", nil
+	}
+	where, title, _ := strings.Cut(rule.Scope, " ")
+	title = strings.TrimSpace(title)
+	switch {
+	case title == "":
+	case where == "inside":
+		return true, title, nil
+	case where == "outside":
+		return false, title, nil
+	}
+	return false, "", fmt.Errorf("rule %s: scope must be \"inside TITLE\" or \"outside TITLE\", not %q", rule.Name, rule.Scope)
+}
+
+// ruleSeverity returns the severity of the user-defined rule named
+// name, or "" if there is none.
+func (p *Processor) ruleSeverity(name string) string {
+	for _, rule := range p.Rules {
+		if rule.Name == name {
+			return rule.Severity
+		}
+	}
+	return ""
+}
+
+// sectionMask returns, for each line, whether it is in a section whose
+// heading is title, or in one of its subsections.  Headings are
+// compared without their numbers and marker comments, ignoring case.
+func (p *Processor) sectionMask(lines []string, title string) []bool {
+	mask := make([]bool, len(lines))
+	code := codeMask(lines)
+	appendix := p.appendixMask(lines)
+	// level is the level of the matching heading, or 0 outside it
+	level := 0
+	for i, line := range lines {
+		if !code[i] {
+			if m := headerRegexp.FindStringSubmatch(line); m != nil {
+				if level > 0 && len(m[1]) <= level {
+					level = 0
+				}
+				heading := m[2]
+				if num := p.prevNumbered(line, appendix[i]); num != nil {
+					heading = num[2]
+				}
+				heading, _ = headingMarkers(heading)
+				if level == 0 && strings.EqualFold(strings.TrimSpace(heading), title) {
+					level = len(m[1])
+				}
+			}
+		}
+		mask[i] = level > 0
+	}
+	return mask
+}
+
+// verifyRules checks the input document against p.Rules, recording a
+// warning for each match.
+func (p *Processor) verifyRules() (err error) {
+	code := codeMask(p.input)
+	errors := 0
+	for _, rule := range p.Rules {
+		re := rule.re
+		if re == nil {
+			// not read by ReadRules
+			re, err = regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+		}
+		inside, title, _ := rule.scope()
+		var mask []bool
+		if title != "" {
+			mask = p.sectionMask(p.input, title)
+		}
+		for i, line := range p.input {
+			if code[i] || mask != nil && mask[i] != inside {
+				continue
+			}
+			// blank out inline code, keeping the columns
+			line = codeSpanRe.ReplaceAllStringFunc(line, func(s string) string {
+				return strings.Repeat(" ", len(s))
+			})
+			loc := re.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			p.warnfLine(rule.Name, i+1, line[loc[0]:loc[1]], "%s", rule.Message)
+			if rule.Severity == SeverityError {
+				errors++
+			}
+		}
+	}
+	if errors > 0 {
+		err = fmt.Errorf("%d lines break custom rules", errors)
+	}
+	return
+}
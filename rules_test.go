@@ -0,0 +1,54 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestRules(t *testing.T) {
+	config := `[
+		{"name": "click-here", "pattern": "(?i)\\[click here\\]", "message": "Use descriptive link text"},
+		{"name": "bare-url", "pattern": "https?://", "scope": "outside References",
+		 "severity": "error", "message": "Cite URLs with a [REF] definition"}
+	]`
+	rules, err := ReadRules(strings.NewReader(config))
+	Ck(err)
+
+	lines := []string{
+		"# Intro",
+		"See http://example.com or [Click here](http://example.com).",
+		"Use `curl http://localhost` to test.",
+		"```",
+		"http://example.com",
+		"```",
+		"# References",
+		"## Online",
+		"- http://example.com",
+	}
+	opts := DefaultOptions()
+	opts.Rules = rules
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err = p.Process(lines)
+	Tassert(t, err != nil, "error rule not reported")
+	want := []Warning{
+		{Rule: "click-here", Severity: SeverityWarning, Line: 2, Column: 27, Message: "Use descriptive link text"},
+		{Rule: "bare-url", Severity: SeverityError, Line: 2, Column: 5, Message: "Cite URLs with a [REF] definition"},
+	}
+	Tassert(t, reflect.DeepEqual(p.Warnings(), want), "\nwant: %v\nhave: %v", want, p.Warnings())
+
+	// warnings alone don't make processing fail
+	opts.Rules = rules[:1]
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err = p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(p.Warnings(), want[:1]), "\nwant: %v\nhave: %v", want[:1], p.Warnings())
+
+	_, err = ReadRules(strings.NewReader(`[{"name": "x", "pattern": "a", "scope": "near Intro"}]`))
+	Tassert(t, err != nil, "bad scope accepted")
+}
@@ -0,0 +1,190 @@
+package markproc
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlTagRegexp = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	safeAnchorRe  = regexp.MustCompile(`^(<a (name|href)="#?[\w\-.]+">|</a>)$`)
+	// openTagRe matches the start of a tag left open at the end of a
+	// line.
+	openTagRe = regexp.MustCompile(`</?[a-zA-Z][^<>]*$`)
+	// mdDestRe matches a markdown link or image: the group is its
+	// target.
+	mdDestRe = regexp.MustCompile(`\]\(\s*(<[^>]*>|(?:[^()\s]|\([^()\s]*\))+)`)
+	// linkDefDestRe matches a link definition: the group is its target.
+	linkDefDestRe = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*(\S+)`)
+	scriptOpenRe  = regexp.MustCompile(`(?i)<script\b`)
+	scriptCloseRe = regexp.MustCompile(`(?i)</script\s*>`)
+	// charRefRe matches an HTML character reference, e.g. &amp; or
+	// &#8212;, at the start of a string.
+	charRefRe = regexp.MustCompile(`^&(?:\w+|#\d+|#[xX][0-9a-fA-F]+);`)
+	// anchorNameRe matches the anchor names markproc writes.
+	anchorNameRe = regexp.MustCompile(`^[\p{L}\p{N}_.:-]+$`)
+)
+
+// escapeText returns s, a heading title or other document text to be
+// put in an <a> element markproc writes, with the characters that
+// could end the element or an attribute early escaped.  Character
+// references already in s are left alone, so that text escaped by the
+// author isn't escaped twice.
+func escapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '"':
+			b.WriteString("&quot;")
+		case '&':
+			if charRefRe.MatchString(s[i:]) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString("&amp;")
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// safeAnchorName returns name, an anchor name made from the options,
+// with each character anchorNameRe doesn't allow replaced by a hyphen,
+// so that no setting can break out of the name attribute.
+func safeAnchorName(name string) string {
+	if anchorNameRe.MatchString(name) {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		if anchorNameRe.MatchString(string(r)) {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// passSanitize neutralizes raw HTML in untrusted input.  Script
+// elements are removed along with their content, and every other tag
+// except the plain anchors markproc itself reads and writes is escaped
+// so that it renders as text, including a tag left open at the end of
+// a line and closed on a later one.  This also disarms event handler
+// attributes, since they can only appear inside a tag.  The targets of
+// markdown links, images and link definitions using javascript:,
+// vbscript: or data: URLs are replaced with #; autolinks to them are
+// escaped like tags.  Code blocks and code spans are left alone, since
+// renderers already show their content as text.
+func (p *Processor) passSanitize(lines []string) []string {
+	newLines := []string{}
+	inScript, inTag := false, false
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] && !inScript {
+			newLines = append(newLines, line)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			inTag = false
+		}
+		line = outsideCode(unsafeURLs(line), func(s string) string {
+			s, inScript = stripScripts(s, inScript)
+			if inTag {
+				// the rest of a tag opened on an earlier line, in which
+				// a < would start a tag of its own
+				end := strings.IndexByte(s, '>')
+				if end < 0 {
+					return strings.ReplaceAll(s, "<", "&lt;")
+				}
+				inTag = false
+				return strings.ReplaceAll(s[:end], "<", "&lt;") + "&gt;" + escapeTags(s[end+1:], &inTag)
+			}
+			return escapeTags(s, &inTag)
+		})
+		newLines = append(newLines, line)
+	}
+	return newLines
+}
+
+// escapeTags returns s with each tag but the safe anchors escaped.  A
+// tag still open at the end of s is escaped too, and *open set.
+func escapeTags(s string, open *bool) string {
+	s = htmlTagRegexp.ReplaceAllStringFunc(s, func(tag string) string {
+		if safeAnchorRe.MatchString(tag) {
+			return tag
+		}
+		return "&lt;" + tag[1:]
+	})
+	if loc := openTagRe.FindStringIndex(s); loc != nil {
+		s = s[:loc[0]] + "&lt;" + s[loc[0]+1:]
+		*open = true
+	}
+	return s
+}
+
+// unsafeURLs returns line with the targets of the markdown links and
+// images and the link definition on it, outside code spans, replaced
+// with # if they use a scheme that runs code or embeds content.
+func unsafeURLs(line string) string {
+	return outsideCode(line, func(s string) string {
+		for _, re := range []*regexp.Regexp{mdDestRe, linkDefDestRe} {
+			s = re.ReplaceAllStringFunc(s, func(link string) string {
+				m := re.FindStringSubmatchIndex(link)
+				if !unsafeURL(link[m[2]:m[3]]) {
+					return link
+				}
+				return link[:m[2]] + "#" + link[m[3]:]
+			})
+		}
+		return s
+	})
+}
+
+// unsafeURL reports whether url, a link target as written, uses the
+// javascript:, vbscript: or data: scheme, however it is cased, escaped
+// or padded.
+func unsafeURL(url string) bool {
+	url = html.UnescapeString(strings.Trim(url, "<>"))
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(url))
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.HasPrefix(url, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripScripts removes script elements from line.  inScript reports
+// whether the line starts inside a script element left open by a
+// previous line; the returned flag reports the same for the next line.
+func stripScripts(line string, inScript bool) (string, bool) {
+	var out strings.Builder
+	for {
+		if inScript {
+			loc := scriptCloseRe.FindStringIndex(line)
+			if loc == nil {
+				return out.String(), true
+			}
+			line = line[loc[1]:]
+			inScript = false
+			continue
+		}
+		loc := scriptOpenRe.FindStringIndex(line)
+		if loc == nil {
+			out.WriteString(line)
+			return out.String(), false
+		}
+		out.WriteString(line[:loc[0]])
+		line = line[loc[0]:]
+		inScript = true
+	}
+}
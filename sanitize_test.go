@@ -0,0 +1,168 @@
+package markproc
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPassSanitize(t *testing.T) {
+	lines := []string{
+		`Text with <b onclick="steal()">bold</b> markup.`,
+		`Before <script>alert(1)</script> after.`,
+		`<SCRIPT type="text/javascript">`,
+		`var x = "<a href=x>";`,
+		`</script>Trailing text.`,
+		`<a name="ref1"></a>`,
+		`See [<a href="#ref1">ref1</a>].`,
+		`<a href="javascript:alert(1)">click</a>`,
+		`Plain text, 1 < 2 and 3 > 2.`,
+	}
+	expectedLines := []string{
+		`Text with &lt;b onclick="steal()">bold&lt;/b> markup.`,
+		`Before  after.`,
+		``,
+		``,
+		`Trailing text.`,
+		`<a name="ref1"></a>`,
+		`See [<a href="#ref1">ref1</a>].`,
+		`&lt;a href="javascript:alert(1)">click</a>`,
+		`Plain text, 1 < 2 and 3 > 2.`,
+	}
+
+	p := NewProcessor(DefaultOptions())
+	result := p.passSanitize(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passSanitize failed:\nwant: %q\nhave: %q", expectedLines, result)
+	}
+}
+
+func TestEscapeGenerated(t *testing.T) {
+	for in, want := range map[string]string{
+		`Fish & "Chips" <b>`:  `Fish &amp; &quot;Chips&quot; &lt;b&gt;`,
+		`A &amp; B &#8212; C`: `A &amp; B &#8212; C`,
+		`AT&T`:                `AT&amp;T`,
+	} {
+		if have := escapeText(in); have != want {
+			t.Errorf("escapeText(%q):\nwant: %q\nhave: %q", in, want, have)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.SecRefFormat = "{title}"
+	opts.AnchorPrefix = `s"><script>`
+	opts.ConvertAnchors = true
+	lines := []string{
+		"[toc]",
+		`# Fish & "Chips" <b>`,
+		"See [sec fc].",
+		`## Notes {#a"onclick="x}`,
+	}
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := []string{
+		tocStart,
+		`- <a href="#s---script-1">1. Fish &amp; &quot;Chips&quot; &lt;b&gt;</a>`,
+		`  - <a href="#s---script-1_1">1.1. Notes {#a&quot;onclick=&quot;x}</a>`,
+		tocEnd,
+		`<a name="s---script-1"></a>`,
+		`# 1. Fish & "Chips" <b>`,
+		`See [<a href="#s---script-1">Fish &amp; &quot;Chips&quot; &lt;b&gt;</a>].`,
+		`<a name="s---script-1_1"></a>`,
+		`## 1.1. Notes {#a"onclick="x}`,
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Process failed:\nwant: %q\nhave: %q", want, out)
+	}
+	if p.Warnings()[0].Rule != "anchor-unsafe" {
+		t.Errorf("want an anchor-unsafe warning, have %+v", p.Warnings())
+	}
+}
+
+func TestSanitizeBypasses(t *testing.T) {
+	lines := []string{
+		`<img src=x`,
+		`onerror=alert(1)> after`,
+		``,
+		`<details open`,
+		`ontoggle="x()"`,
+		`>Summary</details>`,
+		``,
+		"Use `<b>` or ``<script>x</script>`` in code.",
+		`[x](javascript:alert(1)) and ![y]( JaVaScRiPt:alert(1) ) and [z](<vbscript:msgbox>)`,
+		`[ok](https://example.com) and [e](java&#x09;script:x) and [d](data:text/html,x)`,
+		"`[c](javascript:kept)` <javascript:alert(1)>",
+		`[def]: data:text/html;base64,PHNjcmlwdD4=`,
+		``,
+		`text <x`,
+		`<img src=x onerror=alert(1)>`,
+		``,
+		`text <x`,
+		`<b`,
+		`<img src=x onerror=alert(1) x=>`,
+	}
+	want := []string{
+		`&lt;img src=x`,
+		`onerror=alert(1)&gt; after`,
+		``,
+		`&lt;details open`,
+		`ontoggle="x()"`,
+		`&gt;Summary&lt;/details>`,
+		``,
+		"Use `<b>` or ``<script>x</script>`` in code.",
+		`[x](#) and ![y]( # ) and [z](#)`,
+		`[ok](https://example.com) and [e](#) and [d](#)`,
+		"`[c](javascript:kept)` &lt;javascript:alert(1)>",
+		`[def]: #`,
+		``,
+		`text &lt;x`,
+		`&lt;img src=x onerror=alert(1)&gt;`,
+		``,
+		`text &lt;x`,
+		`&lt;b`,
+		`&lt;img src=x onerror=alert(1) x=&gt;`,
+	}
+	p := NewProcessor(DefaultOptions())
+	have := p.passSanitize(lines)
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("passSanitize failed:\nwant: %q\nhave: %q", want, have)
+	}
+}
+
+func TestSanitizeRenderedOnce(t *testing.T) {
+	lines := []string{"# A <b> & C", "", "Text <i> & &lt; x."}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	opts.Sanitize = true
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	var b bytes.Buffer
+	if err := RenderHTML(&b, out, "", ""); err != nil {
+		t.Fatalf("RenderHTML failed: %v", err)
+	}
+	for _, want := range []string{"<h1>1. A &lt;b&gt; &amp; C</h1>", "<p>Text &lt;i&gt; &amp; &lt; x.</p>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("RenderHTML: want %q in\n%s", want, b.String())
+		}
+	}
+
+	opts.HeadingAnchors = HeadingAnchorHTML
+	p = NewProcessor(opts)
+	out, err = p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := []string{`<h1 id="sec1">1. A &lt;b&gt; &amp; C</h1>`, "", "Text &lt;i> & &lt; x."}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Process failed:\nwant: %q\nhave: %q", want, out)
+	}
+}
@@ -0,0 +1,94 @@
+package markproc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ProcessResponse is the JSON body returned by the /process endpoint.
+type ProcessResponse struct {
+	Markdown    string    `json:"markdown"`
+	Diagnostics []Warning `json:"diagnostics"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// NewHandler returns an http.Handler serving the markproc API:
+//
+//	POST /process  markdown in, ProcessResponse out
+//	POST /outline  markdown in, []Section out (GET with a body also works)
+//
+// Each request is handled by a fresh Processor configured with opts,
+// and request bodies larger than opts.Limits.MaxInputSize are rejected.
+func NewHandler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		lines, ok := readRequest(w, r, opts.Limits)
+		if !ok {
+			return
+		}
+		p := NewProcessor(opts)
+		p.Stderr = io.Discard
+		out, err := p.Process(lines)
+
+		var buf bytes.Buffer
+		err2 := WriteLines(&buf, out)
+		if err2 != nil {
+			http.Error(w, err2.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := ProcessResponse{
+			Markdown:    buf.String(),
+			Diagnostics: p.Warnings(),
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		writeJSON(w, resp)
+	})
+	mux.HandleFunc("/outline", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		lines, ok := readRequest(w, r, opts.Limits)
+		if !ok {
+			return
+		}
+		writeJSON(w, NewProcessor(opts).Outline(lines))
+	})
+	return mux
+}
+
+// readRequest reads the markdown document in the body of r, writing an
+// error response to w and returning false if that fails.
+func readRequest(w http.ResponseWriter, r *http.Request, limits Limits) (lines []string, ok bool) {
+	body := r.Body
+	if limits.MaxInputSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, limits.MaxInputSize)
+	}
+	lines, err := ReadLines(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return lines, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
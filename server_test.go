@@ -0,0 +1,52 @@
+package markproc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestHandler(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Limits.MaxInputSize = 1024
+	srv := httptest.NewServer(NewHandler(opts))
+	defer srv.Close()
+
+	doc := "# Title\n\nSee [sec nowhere].\n\n## Sub\n"
+
+	resp, err := http.Post(srv.URL+"/process", "text/markdown", strings.NewReader(doc))
+	Ck(err)
+	defer resp.Body.Close()
+	Tassert(t, resp.StatusCode == http.StatusOK, "status %d", resp.StatusCode)
+	var pr ProcessResponse
+	err = json.NewDecoder(resp.Body).Decode(&pr)
+	Ck(err)
+	Tassert(t, strings.Contains(pr.Markdown, "# 1. Title\n"), "have %q", pr.Markdown)
+	Tassert(t, pr.Error != "", "unresolved reference not reported")
+	Tassert(t, len(pr.Diagnostics) == 1, "have %q", pr.Diagnostics)
+
+	resp, err = http.Post(srv.URL+"/outline", "text/markdown", strings.NewReader(doc))
+	Ck(err)
+	defer resp.Body.Close()
+	var sections []Section
+	err = json.NewDecoder(resp.Body).Decode(&sections)
+	Ck(err)
+	Tassert(t, len(sections) == 2, "have %v", sections)
+	Tassert(t, sections[1].Number == "1.1" && sections[1].Anchor == "sec1_1", "have %v", sections[1])
+	Tassert(t, sections[1].Line == 5, "have %v", sections[1])
+
+	big := strings.Repeat("x", 2048)
+	resp, err = http.Post(srv.URL+"/process", "text/markdown", strings.NewReader(big))
+	Ck(err)
+	defer resp.Body.Close()
+	Tassert(t, resp.StatusCode == http.StatusRequestEntityTooLarge, "status %d", resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/process")
+	Ck(err)
+	defer resp.Body.Close()
+	Tassert(t, resp.StatusCode == http.StatusMethodNotAllowed, "status %d", resp.StatusCode)
+}
@@ -0,0 +1,131 @@
+package markproc
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Setext heading styles for Options.Setext.
+const (
+	// SetextKeep numbers setext headings, Title over a line of = or -,
+	// and writes them in setext style again.
+	SetextKeep = "keep"
+	// SetextATX numbers setext headings and writes them as ATX
+	// headings, # Title and ## Title.
+	SetextATX = "atx"
+)
+
+// SetextStyles lists the valid values of Options.Setext, "" meaning
+// setext headings are left alone like any other paragraph.
+var SetextStyles = []string{"", SetextKeep, SetextATX}
+
+var (
+	// setextUnderlineRe matches the line under a setext heading: the
+	// group is the run of = or -.
+	setextUnderlineRe = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	// setextMarkerRe matches the marker passSetext leaves on the
+	// headings it converts, for setextHeadings to write them in setext
+	// style again.
+	setextMarkerRe = regexp.MustCompile(`\s*<!--\s*(?:markproc:\s*)?setext\s*-->`)
+	// listStartRe matches heading text that would start an ordered
+	// list if written as a setext heading, e.g. a section number: the
+	// groups are the number, its delimiter and what follows.
+	listStartRe = regexp.MustCompile(`^(\d+)([.)])(\s|$)`)
+	// escapedListStartRe matches the same text with its delimiter
+	// escaped.
+	escapedListStartRe = regexp.MustCompile(`^(\d+)\\([.)])(\s|$)`)
+)
+
+// setextLevel returns the level of the setext heading whose text is
+// line i of lines, 1 for = and 2 for -, or 0 if it isn't one.  Only a
+// heading of a single line is recognized, preceded by a blank line, a
+// code block, an anchor line or another heading.
+func setextLevel(lines []string, code []bool, i int) int {
+	if code[i] || i+1 >= len(lines) || code[i+1] {
+		return 0
+	}
+	m := setextUnderlineRe.FindStringSubmatch(lines[i+1])
+	if m == nil {
+		return 0
+	}
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "",
+		strings.HasPrefix(line, "    "), strings.HasPrefix(line, "\t"),
+		strings.ContainsAny(trimmed[:1], "><|"),
+		headerRegexp.MatchString(line),
+		listItemRegexp.MatchString(line),
+		fenceRegexp.MatchString(line),
+		extLinkRegexp.MatchString(line),
+		setextUnderlineRe.MatchString(line):
+		return 0
+	}
+	if i > 0 && !code[i-1] {
+		prev := lines[i-1]
+		if strings.TrimSpace(prev) != "" && !anchorLineRe.MatchString(strings.TrimSpace(prev)) &&
+			!headerRegexp.MatchString(prev) && !setextUnderlineRe.MatchString(prev) {
+			return 0
+		}
+	}
+	if m[1][0] == '=' {
+		return 1
+	}
+	return 2
+}
+
+// passSetext turns setext headings into ATX headings, so that the other
+// passes number and link them like any other.  With SetextKeep each is
+// marked <!-- setext --> for setextHeadings, and a section number
+// escaped by an earlier run, 1\. Title, is unescaped.
+func (p *Processor) passSetext(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		level := setextLevel(lines, code, i)
+		if level == 0 {
+			out.add(i, lines[i])
+			continue
+		}
+		title, markers := headingMarkers(strings.TrimSpace(lines[i]))
+		title = escapedListStartRe.ReplaceAllString(title, "$1$2$3")
+		if p.Setext == SetextKeep && !setextMarkerRe.MatchString(markers) {
+			markers = " <!-- setext -->" + markers
+		}
+		out.add(i, strings.Repeat("#", level)+" "+title+markers)
+		i++
+	}
+	return p.done(out)
+}
+
+// setextHeadings returns lines, the processed document, with the
+// headings passSetext marked written in setext style again, underlined
+// to their width.  A section number that would start an ordered list is
+// escaped, and a heading shifted below level 2 stays an ATX heading.
+func (p *Processor) setextHeadings(lines []string) []string {
+	if p.Setext != SetextKeep {
+		return lines
+	}
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i, line := range lines {
+		m := headerRegexp.FindStringSubmatch(line)
+		if code[i] || m == nil || !setextMarkerRe.MatchString(line) {
+			out.add(i, line)
+			continue
+		}
+		text := strings.TrimSpace(setextMarkerRe.ReplaceAllString(m[2], ""))
+		if len(m[1]) > 2 {
+			out.add(i, m[1]+" "+text)
+			continue
+		}
+		text = listStartRe.ReplaceAllString(text, `$1\$2$3`)
+		underline := "="
+		if len(m[1]) == 2 {
+			underline = "-"
+		}
+		out.add(i, text, strings.Repeat(underline, max(3, utf8.RuneCountInString(text))))
+	}
+	return p.done(out)
+}
@@ -0,0 +1,103 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestSetext(t *testing.T) {
+	lines := []string{
+		"Intro",
+		"=====",
+		"",
+		"Some text.",
+		"More text",
+		"---------",
+		"",
+		"Design",
+		"---",
+		"",
+		"See [sec desig].",
+		"",
+		"- item",
+		"---",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	have, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect := []string{
+		`<a name="sec1"></a>`,
+		`1\. Intro`,
+		"=========",
+		"",
+		"Some text.",
+		"More text",
+		"---------",
+		"",
+		`<a name="sec1_1"></a>`,
+		"1.1. Design",
+		"-----------",
+		"",
+		`See [<a href="#sec1_1">sec 1.1</a>].`,
+		"",
+		"- item",
+		"---",
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+
+	// reprocessing gives the same output
+	again, err := p.Process(have)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(expect, again), "\nwant: %q\nhave: %q", expect, again)
+
+	stripped := p.Strip(have)
+	source := []string{
+		"Intro",
+		"=====",
+		"",
+		"Some text.",
+		"More text",
+		"---------",
+		"",
+		"Design",
+		"------",
+		"",
+		"See [sec desig].",
+		"",
+		"- item",
+		"---",
+	}
+	Tassert(t, reflect.DeepEqual(source, stripped), "\nwant: %q\nhave: %q", source, stripped)
+
+	opts := DefaultOptions()
+	opts.Setext = SetextATX
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	have, err = p.Process(lines[:9])
+	Tassert(t, err == nil, "Process failed: %v", err)
+	expect = []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"",
+		"Some text.",
+		"More text",
+		"---------",
+		"",
+		`<a name="sec1_1"></a>`,
+		"## 1.1. Design",
+	}
+	Tassert(t, reflect.DeepEqual(expect, have), "\nwant: %q\nhave: %q", expect, have)
+
+	// with Setext unset they are left alone
+	opts.Setext = ""
+	p = NewProcessor(opts)
+	p.Stderr = io.Discard
+	have, err = p.Process(lines[:2])
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(lines[:2], have), "have: %q", have)
+}
@@ -0,0 +1,68 @@
+package markproc
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Shortcode styles for Options.RefShortcodes.
+const (
+	// ShortcodeHugo links to other files as {{% relref path %}}.
+	ShortcodeHugo = "hugo"
+	// ShortcodeJekyll links to other files as {% link path %}.
+	ShortcodeJekyll = "jekyll"
+)
+
+// RefShortcodeStyles lists the valid values of Options.RefShortcodes,
+// "" meaning none.
+var RefShortcodeStyles = []string{"", ShortcodeHugo, ShortcodeJekyll}
+
+// sitePath returns file, a file of the project, relative to
+// p.SiteRoot with forward slashes.
+func (p *Processor) sitePath(file string) string {
+	if p.SiteRoot != "" {
+		if rel, err := filepath.Rel(p.SiteRoot, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// siteHref returns the href of file, another file of the project, as a
+// static site links to it: a shortcode if p.RefShortcodes is set, else
+// p.Permalink with its {path}, {dir} and {name} filled in.  ok is false
+// if neither is set, leaving links relative.
+func (p *Processor) siteHref(file string) (href string, ok bool) {
+	rel := p.sitePath(file)
+	switch p.RefShortcodes {
+	case ShortcodeHugo:
+		return fmt.Sprintf("{{%% relref %s %%}}", rel), true
+	case ShortcodeJekyll:
+		return fmt.Sprintf("{%% link %s %%}", rel), true
+	}
+	if p.Permalink == "" {
+		return "", false
+	}
+	return permalink(p.Permalink, rel), true
+}
+
+// permalink fills in template for the file at rel, a slash-separated
+// path relative to the site's root: {path} is rel without its
+// extension, or its directory for an index page, index.md or
+// _index.md, {dir} is its directory and {name} its name without the
+// extension.
+func permalink(template, rel string) string {
+	dir, name := path.Split(stripExt(rel))
+	dir = strings.TrimSuffix(dir, "/")
+	page := stripExt(rel)
+	if name == "index" || name == "_index" {
+		page = dir
+	}
+	href := strings.NewReplacer("{path}", page, "{dir}", dir, "{name}", name).Replace(template)
+	for strings.Contains(href, "//") {
+		href = strings.ReplaceAll(href, "//", "/")
+	}
+	return href
+}
@@ -0,0 +1,66 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestPermalink(t *testing.T) {
+	tests := []struct {
+		template, rel, want string
+	}{
+		{"/{path}/", "docs/setup.md", "/docs/setup/"},
+		{"/{path}/", "docs/_index.md", "/docs/"},
+		{"/{path}/", "_index.md", "/"},
+		{"/{dir}/{name}.html", "setup.md", "/setup.html"},
+	}
+	for _, tt := range tests {
+		have := permalink(tt.template, tt.rel)
+		Tassert(t, have == tt.want, "%q %q:\nwant: %q\nhave: %q", tt.template, tt.rel, tt.want, have)
+	}
+}
+
+func TestSiteLinks(t *testing.T) {
+	docs := []Document{
+		{Path: "content/_index.md", Lines: []string{
+			"+++",
+			`title = "Home"`,
+			"+++",
+			"# Home",
+			"See [sec stup].",
+		}},
+		{Path: "content/docs/setup.md", Lines: []string{
+			"---",
+			"title: Setup",
+			"---",
+			"# Setup",
+		}},
+	}
+	tests := []struct {
+		option, value, href string
+	}{
+		{"permalink", "/{path}/", "/docs/setup/"},
+		{"ref-shortcodes", ShortcodeHugo, "{{% relref docs/setup.md %}}"},
+		{"ref-shortcodes", ShortcodeJekyll, "{% link docs/setup.md %}"},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.SiteRoot = "content"
+		Ck(opts.Set(tt.option, tt.value))
+		p := NewProcessor(opts)
+		out, err := p.ProcessProject(docs)
+		Tassert(t, err == nil, "%s: ProcessProject failed: %v", tt.value, err)
+		want := []string{
+			"+++",
+			`title = "Home"`,
+			"+++",
+			`<a name="sec1"></a>`,
+			"# 1. Home",
+			`See [<a href="` + tt.href + `#sec2">sec 2</a>].`,
+		}
+		Tassert(t, reflect.DeepEqual(out[0].Lines, want), "%s:\nwant: %q\nhave: %q", tt.value, want, out[0].Lines)
+		Tassert(t, len(p.Warnings()) == 0, "%s: have %v", tt.value, p.Warnings())
+	}
+}
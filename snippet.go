@@ -0,0 +1,262 @@
+package markproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// snippetRe matches a snippet directive,
+	// <!-- snippet: PATH#SELECTOR sha=CHECKSUM -->: the groups are the
+	// path, the selector and the checksum, the last two optional.
+	snippetRe = regexp.MustCompile(`^\s*<!--\s*snippet:\s*([^\s#]+)(?:#(\S+))?(?:\s+sha=([0-9a-f]+))?\s*-->\s*$`)
+	// snippetLinesRe matches a selector of lines, L10-L20, L10-20 or
+	// L10: the groups are the first and last line.
+	snippetLinesRe = regexp.MustCompile(`^L(\d+)(?:-L?(\d+))?$`)
+	// regionStartRe and regionEndRe match the lines around a named
+	// region of a source file, e.g. "// #region passes" and
+	// "// #endregion", in any language's comments: the group is the
+	// name.
+	regionStartRe = regexp.MustCompile(`#region\s+([\w.-]+)`)
+	regionEndRe   = regexp.MustCompile(`#endregion\b\s*([\w.-]*)`)
+	// fenceRunRe matches a run of backticks at the start of a line.
+	fenceRunRe = regexp.MustCompile("^\\s*(`{3,})")
+)
+
+// snippetLangs maps source file extensions to the info string of the
+// fenced code block a snippet of one goes in, where they differ.
+var snippetLangs = map[string]string{
+	".py":  "python",
+	".rb":  "ruby",
+	".rs":  "rust",
+	".js":  "javascript",
+	".ts":  "typescript",
+	".sh":  "sh",
+	".yml": "yaml",
+	".md":  "markdown",
+}
+
+// snippet is one snippet directive.
+type snippet struct {
+	path, selector, sha string
+}
+
+// parseSnippet returns the snippet directive on line, if any.
+func parseSnippet(line string) (s snippet, ok bool) {
+	m := snippetRe.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	return snippet{path: m[1], selector: m[2], sha: m[3]}, true
+}
+
+// target returns the path and selector of s as written in directives.
+func (s snippet) target() string {
+	if s.selector == "" {
+		return s.path
+	}
+	return s.path + "#" + s.selector
+}
+
+// String returns the directive as passSnippets writes it.
+func (s snippet) String() string {
+	if s.sha != "" {
+		return fmt.Sprintf("<!-- snippet: %s sha=%s -->", s.target(), s.sha)
+	}
+	return fmt.Sprintf("<!-- snippet: %s -->", s.target())
+}
+
+// snippetEnd returns the comment passSnippets puts after the code block
+// of the snippet s.
+func snippetEnd(s snippet) string {
+	return fmt.Sprintf("<!-- /snippet: %s -->", s.target())
+}
+
+// snippetSum returns the checksum of the lines of a snippet, the first
+// 8 bytes of their SHA-256 in hex.
+func snippetSum(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// passSnippets replaces each snippet directive,
+// <!-- snippet: main.go#region=passes -->, with a fenced code block of
+// the part of the source file it names, read from p.IncludeFS like an
+// included file: the whole file, the lines L10-L20, or the lines
+// between "#region passes" and "#endregion" comment lines, dedented.
+// The block goes between the directive and a <!-- /snippet: PATH -->
+// comment, so that a later run replaces it with the current code, and
+// the directive records the checksum of the code, sha=..., the first
+// time.  A missing file, region or line is an error; code that no
+// longer matches the recorded checksum is warned about until the
+// checksum is removed, so that text describing the old code gets
+// reviewed.
+func (p *Processor) passSnippets(lines []string) []string {
+	out := p.newLineWriter(lines)
+	base := p.IncludePath
+	if p.project != nil {
+		base = p.project.current
+	}
+	base = filepath.ToSlash(base)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		s, ok := parseSnippet(line)
+		if code[i] || !ok {
+			out.add(i, line)
+			continue
+		}
+		end := skipBlock(lines, i, snippetEnd(s))
+		snip, ok := p.readSnippet(i, s, base)
+		if !ok {
+			out.add(i, line)
+			continue
+		}
+		sum := snippetSum(snip)
+		if s.sha == "" {
+			s.sha = sum
+		} else if s.sha != sum {
+			p.warnf("snippet-stale", i, s.target(), "Snippet %s changed since its checksum was recorded; review the text and remove sha=%s", s.target(), s.sha)
+		}
+		lang := strings.TrimPrefix(path.Ext(s.path), ".")
+		if l, ok := snippetLangs[path.Ext(s.path)]; ok {
+			lang = l
+		}
+		fence := "```"
+		for _, l := range snip {
+			if m := fenceRunRe.FindStringSubmatch(l); m != nil && len(m[1]) >= len(fence) {
+				fence = m[1] + "`"
+			}
+		}
+		out.add(i, s.String(), fence+lang)
+		out.add(i, snip...)
+		out.add(i, fence, snippetEnd(s))
+		i = end
+	}
+	return p.done(out)
+}
+
+// readSnippet returns the lines of the snippet s, found on line i of
+// the input, in the file named relative to the file from.
+func (p *Processor) readSnippet(i int, s snippet, from string) (lines []string, ok bool) {
+	name := path.Join(path.Dir(from), s.path)
+	if strings.HasPrefix(s.path, "/") {
+		name = path.Clean(strings.TrimPrefix(s.path, "/"))
+	}
+	if !fs.ValidPath(name) {
+		p.warnf("include-invalid", i, s.path, "Snippet file %s is outside the include root", s.path)
+		p.fail("snippet failed")
+		return nil, false
+	}
+	f, err := p.IncludeFS.Open(name)
+	if err == nil {
+		lines, err = ReadLimited(f, p.Limits.MaxInputSize)
+		f.Close()
+	}
+	if err != nil {
+		p.warnf("snippet-missing", i, s.path, "Can't read snippet file %s: %v", s.path, err)
+		p.fail("snippet failed")
+		return nil, false
+	}
+
+	switch {
+	case s.selector == "":
+		return lines, true
+	case strings.HasPrefix(s.selector, "region="):
+		region := strings.TrimPrefix(s.selector, "region=")
+		// depth counts the regions open in the one wanted
+		start, depth := -1, 0
+		for j, line := range lines {
+			if m := regionStartRe.FindStringSubmatch(line); m != nil {
+				if start < 0 && m[1] == region {
+					start = j + 1
+				} else if start >= 0 {
+					depth++
+				}
+				continue
+			}
+			if m := regionEndRe.FindStringSubmatch(line); m != nil && start >= 0 {
+				if depth == 0 && (m[1] == "" || m[1] == region) {
+					return dedent(withoutRegionMarkers(lines[start:j])), true
+				}
+				depth--
+			}
+		}
+		p.warnf("snippet-missing", i, s.target(), "Snippet file %s has no region %s", s.path, region)
+	default:
+		m := snippetLinesRe.FindStringSubmatch(s.selector)
+		if m == nil {
+			p.warnf("snippet-missing", i, s.target(), "Unknown snippet selector %s; use L10-L20 or region=NAME", s.selector)
+			break
+		}
+		first, _ := strconv.Atoi(m[1])
+		last := first
+		if m[2] != "" {
+			last, _ = strconv.Atoi(m[2])
+		}
+		if first < 1 || last < first || last > len(lines) {
+			p.warnf("snippet-missing", i, s.target(), "Snippet file %s has no lines %d to %d", s.path, first, last)
+			break
+		}
+		return dedent(lines[first-1 : last]), true
+	}
+	p.fail("snippet failed")
+	return nil, false
+}
+
+// withoutRegionMarkers returns lines without the markers of regions
+// nested in a region.
+func withoutRegionMarkers(lines []string) (out []string) {
+	out = []string{}
+	for _, line := range lines {
+		if !regionStartRe.MatchString(line) && !regionEndRe.MatchString(line) {
+			out = append(out, line)
+		}
+	}
+	return
+}
+
+// dedent returns lines with the indentation they all share removed.
+func dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	out := make([]string, len(lines))
+	for j, line := range lines {
+		out[j] = strings.TrimPrefix(line, prefix)
+	}
+	return out
+}
+
+// stripSnippets removes the code blocks passSnippets put after each
+// snippet directive of lines, leaving the directive.
+func stripSnippets(lines []string) []string {
+	out := []string{}
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if s, ok := parseSnippet(lines[i]); ok && !code[i] {
+			i = skipBlock(lines, i, snippetEnd(s))
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestSnippets(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\t// #region loop\n\tfor {\n\t\t// #region body\n\t\trun()\n\t\t// #endregion\n\t}\n\t// #endregion\n}\n"
+	files := fstest.MapFS{"doc/main.go": {Data: []byte(src)}}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	opts.IncludeFS = files
+	opts.IncludePath = "doc/guide.md"
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	lines := []string{
+		"<!-- snippet: main.go#region=loop -->",
+		"<!-- snippet: main.go#L3-L3 -->",
+	}
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	loop := []string{"for {", "\trun()", "}"}
+	main := []string{"func main() {"}
+	want := []string{
+		"<!-- snippet: main.go#region=loop sha=" + snippetSum(loop) + " -->",
+		"```go",
+		"for {",
+		"\trun()",
+		"}",
+		"```",
+		"<!-- /snippet: main.go#region=loop -->",
+		"<!-- snippet: main.go#L3-L3 sha=" + snippetSum(main) + " -->",
+		"```go",
+		"func main() {",
+		"```",
+		"<!-- /snippet: main.go#L3-L3 -->",
+	}
+	Tassert(t, reflect.DeepEqual(want, out), "\nwant: %q\nhave: %q", want, out)
+
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(out, again), "\nwant: %q\nhave: %q", out, again)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+
+	stripped := p.Strip(out)
+	Tassert(t, reflect.DeepEqual(stripped, []string{want[0], want[7]}), "have %q", stripped)
+
+	// changed code is warned about until its checksum is removed
+	files["doc/main.go"] = &fstest.MapFile{Data: []byte(src[:len(src)-2] + "\texit()\n}\n")}
+	again, err = p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(out, again), "\nwant: %q\nhave: %q", out, again)
+	Tassert(t, len(p.Warnings()) == 0, "have %v", p.Warnings())
+	files["doc/main.go"] = &fstest.MapFile{Data: []byte("package main\n\nfunc Main() {\n")}
+	again, err = p.Process(out)
+	Tassert(t, err != nil, "missing region not reported")
+	warnings := p.Warnings()
+	Tassert(t, len(warnings) == 2, "have %v", warnings)
+	Tassert(t, warnings[0].Rule == "snippet-missing" && warnings[0].Line == 1, "have %v", warnings[0])
+	Tassert(t, warnings[1].Rule == "snippet-stale" && warnings[1].Line == 8, "have %v", warnings[1])
+	Tassert(t, len(again) == len(out) && again[9] == "func Main() {", "have %q", again)
+}
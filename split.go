@@ -0,0 +1,127 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mdLocalLinkRe matches the target of a markdown link to an anchor in
+// the same document: the group is the anchor.
+var mdLocalLinkRe = regexp.MustCompile(`\]\(#([^()\s]+)\)`)
+
+// Split divides lines, a processed document, into the pages of a
+// multi-page site, the inverse of ProcessProject: a page for each
+// numbered section at level or above, named after its number and
+// title, e.g. 2-design.md, and an index page, index.md, holding what
+// comes before the first of them followed, unless that has a table of
+// contents, by a list of the pages.  Links to anchors on another page
+// are rewritten to link to that page, e.g. 2-design.html#sec2_1, with
+// p.ProjectLinkExt as the extension.  Every page ends with the footer
+// of a document processed with VersionFooter.
+func (p *Processor) Split(lines []string, level int) (pages []Document) {
+	code := codeMask(lines)
+	// starts holds the first line of each page after the index,
+	// including the anchor lines before its heading
+	starts := []int{}
+	titles := []string{}
+	for i, line := range lines {
+		m := p.numberedHeading(line)
+		if code[i] || m == nil || len(m[1]) > level {
+			continue
+		}
+		start := i
+		for start > 0 && !code[start-1] && anchorLineRe.MatchString(strings.TrimSpace(lines[start-1])) {
+			start--
+		}
+		title, _, _ := headingTitle(m[3])
+		starts = append(starts, start)
+		titles = append(titles, p.numberText(strings.TrimSuffix(m[2], "."))+" "+title)
+	}
+
+	pages = []Document{{Path: "index.md"}}
+	for _, title := range titles {
+		pages = append(pages, Document{Path: githubSlug(title) + ".md"})
+	}
+	// page returns the page holding line i
+	page := func(i int) int {
+		n := 0
+		for n < len(starts) && starts[n] <= i {
+			n++
+		}
+		return n
+	}
+	// anchors maps each anchor to the page defining it
+	anchors := map[string]int{}
+	for i, line := range lines {
+		n := page(i)
+		pages[n].Lines = append(pages[n].Lines, line)
+		if !code[i] {
+			for _, name := range anchorTargets(line) {
+				anchors[name] = n
+			}
+		}
+	}
+	// with AnchorGitHub the renderer generates the heading anchors
+	for _, sec := range p.Sections(lines) {
+		if _, ok := anchors[sec.Anchor]; !ok {
+			anchors[sec.Anchor] = page(sec.Line - 1)
+		}
+	}
+
+	saved := p.project
+	defer func() { p.project = saved }()
+	for n := range pages {
+		p.project = &project{current: pages[n].Path}
+		href := func(name string) string {
+			if target, ok := anchors[name]; ok && target != n {
+				return p.fileHref(pages[target].Path) + "#" + name
+			}
+			return "#" + name
+		}
+		code := codeMask(pages[n].Lines)
+		for i, line := range pages[n].Lines {
+			if code[i] {
+				continue
+			}
+			line = localHrefRe.ReplaceAllStringFunc(line, func(link string) string {
+				return `<a href="` + href(localHrefRe.FindStringSubmatch(link)[1]) + `">`
+			})
+			line = mdLocalLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+				return "](" + href(mdLocalLinkRe.FindStringSubmatch(link)[1]) + ")"
+			})
+			pages[n].Lines[i] = line
+		}
+	}
+
+	if len(titles) > 0 && !hasTOC(pages[0].Lines) {
+		p.project = &project{current: pages[0].Path}
+		if len(pages[0].Lines) > 0 && strings.TrimSpace(pages[0].Lines[len(pages[0].Lines)-1]) != "" {
+			pages[0].Lines = append(pages[0].Lines, "")
+		}
+		for n, title := range titles {
+			pages[0].Lines = append(pages[0].Lines,
+				fmt.Sprintf(`- <a href="%s">%s</a>`, p.fileHref(pages[n+1].Path), title))
+		}
+	}
+
+	// the last page has the document's footer already
+	if f := footer(lines); f != nil {
+		for n := range pages[:len(pages)-1] {
+			pages[n].Lines = append(pages[n].Lines, f...)
+		}
+	}
+	return
+}
+
+// hasTOC reports whether lines, a processed document, has a table of
+// contents.
+func hasTOC(lines []string) bool {
+	code := codeMask(lines)
+	for i, line := range lines {
+		if !code[i] && strings.TrimSpace(line) == tocStart {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestSplit(t *testing.T) {
+	in := []string{
+		"Preamble.",
+		"",
+		"# Overview",
+		"",
+		"See [sec dtls] and [sec ovrv].",
+		"",
+		"# Design",
+		"",
+		"## Details",
+		"",
+		"Back to [sec ovrv], [code](#sec1).",
+		"",
+		"```",
+		`<a href="#sec1">`,
+		"```",
+	}
+	want := []Document{
+		{Path: "index.md", Lines: []string{
+			"Preamble.",
+			"",
+			`- <a href="1-overview.html">1. Overview</a>`,
+			`- <a href="2-design.html">2. Design</a>`,
+		}},
+		{Path: "1-overview.md", Lines: []string{
+			`<a name="sec1"></a>`,
+			"# 1. Overview",
+			"",
+			`See [<a href="2-design.html#sec2_1">sec 2.1</a>] and [<a href="#sec1">sec 1</a>].`,
+			"",
+		}},
+		{Path: "2-design.md", Lines: []string{
+			`<a name="sec2"></a>`,
+			"# 2. Design",
+			"",
+			`<a name="sec2_1"></a>`,
+			"## 2.1. Details",
+			"",
+			`Back to [<a href="1-overview.html#sec1">sec 1</a>], [code](1-overview.html#sec1).`,
+			"",
+			"```",
+			`<a href="#sec1">`,
+			"```",
+		}},
+	}
+
+	p := NewProcessor(DefaultOptions())
+	p.Stderr = io.Discard
+	out, err := p.Process(in)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have := p.Split(out, 1)
+	Tassert(t, reflect.DeepEqual(want, have), "\nwant: %q\nhave: %q", want, have)
+
+	// a table of contents in the preamble takes the place of the list
+	// of pages, its links rewritten
+	p.ProjectLinkExt = ".md"
+	out, err = p.Process(append([]string{"[toc]"}, in[2:]...))
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have = p.Split(out, 2)
+	Tassert(t, len(have) == 4, "have %q", have)
+	wantIndex := []string{
+		"<!-- toc -->",
+		`- <a href="1-overview.md#sec1">1. Overview</a>`,
+		`- <a href="2-design.md#sec2">2. Design</a>`,
+		`  - <a href="21-details.md#sec2_1">2.1. Details</a>`,
+		"<!-- /toc -->",
+	}
+	Tassert(t, reflect.DeepEqual(wantIndex, have[0].Lines), "\nwant: %q\nhave: %q", wantIndex, have[0].Lines)
+}
@@ -0,0 +1,191 @@
+package markproc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WordsPerMinute is the reading speed the reading times of a
+// CitationStats report assume.
+const WordsPerMinute = 200
+
+// RefStat records how often a bibliographic reference is cited.
+type RefStat struct {
+	Name      string   `json:"name"`
+	Line      int      `json:"line"`
+	Citations int      `json:"citations"`
+	CitedIn   []string `json:"cited_in"`
+}
+
+// SectionStat records how many citations, [sec ...] references and
+// words appear in a section's body, not counting its subsections.
+type SectionStat struct {
+	Heading   string `json:"heading"`
+	Level     int    `json:"level"`
+	Line      int    `json:"line"`
+	Citations int    `json:"citations"`
+	SecRefs   int    `json:"sec_refs"`
+	Words     int    `json:"words"`
+	// ReadingMinutes is how long the body takes to read at
+	// WordsPerMinute, rounded up.
+	ReadingMinutes int `json:"reading_minutes"`
+}
+
+// CitationStats is the citation count and coverage report produced by
+// Citations, with the size of the document and of each section.
+type CitationStats struct {
+	Refs     []RefStat     `json:"refs"`
+	Sections []SectionStat `json:"sections"`
+	Uncited  []SectionStat `json:"uncited_sections"`
+	// Headings counts the headings at each level, from 1 to 6.
+	Headings       [6]int `json:"headings"`
+	Words          int    `json:"words"`
+	ReadingMinutes int    `json:"reading_minutes"`
+	// Expiring lists the expiry dates of sections, earliest first,
+	// when set from Processor.Expirations.
+	Expiring []SectionExpiry `json:"expiring,omitempty"`
+}
+
+// Citations counts citations of each [ref]: definition in the
+// unprocessed input lines and lists the sections that cite nothing.
+// Sections that hold reference definitions are not expected to cite
+// anything and are left out of the uncited list.  Words are counted
+// outside code blocks and HTML comments, headings included in the
+// document's total but not in their sections'.
+func Citations(lines []string) (stats CitationStats) {
+	refIndex := map[string]int{}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if extMatch := extLinkRegexp.FindStringSubmatch(line); len(extMatch) > 0 {
+			ref := extMatch[1]
+			if _, ok := refIndex[ref]; ok {
+				continue
+			}
+			refIndex[ref] = len(stats.Refs)
+			stats.Refs = append(stats.Refs, RefStat{Name: ref, Line: i + 1, CitedIn: []string{}})
+		}
+	}
+
+	definesRefs := []bool{}
+	current := -1
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		headerMatch := headerRegexp.FindStringSubmatch(line)
+		text := line
+		if len(headerMatch) > 0 {
+			text = headerMatch[2]
+		}
+		words := len(strings.Fields(commentRe.ReplaceAllString(text, " ")))
+		stats.Words += words
+		if len(headerMatch) > 0 {
+			stats.Headings[min(len(headerMatch[1]), 6)-1]++
+			stats.Sections = append(stats.Sections, SectionStat{
+				Heading: headerMatch[2],
+				Level:   len(headerMatch[1]),
+				Line:    i + 1,
+			})
+			definesRefs = append(definesRefs, false)
+			current = len(stats.Sections) - 1
+			continue
+		}
+		if current >= 0 {
+			sec := &stats.Sections[current]
+			sec.Words += words
+			outsideCode(line, func(s string) string {
+				sec.SecRefs += len(sectionRefRegexp.FindAllString(s, -1))
+				return s
+			})
+			if extLinkRegexp.MatchString(line) {
+				definesRefs[current] = true
+			}
+		}
+		for _, c := range citations(line) {
+			idx, ok := refIndex[c.ref]
+			if !ok {
+				continue
+			}
+			rs := &stats.Refs[idx]
+			rs.Citations++
+			if current < 0 {
+				continue
+			}
+			heading := stats.Sections[current].Heading
+			stats.Sections[current].Citations++
+			if len(rs.CitedIn) == 0 || rs.CitedIn[len(rs.CitedIn)-1] != heading {
+				rs.CitedIn = append(rs.CitedIn, heading)
+			}
+		}
+	}
+
+	stats.ReadingMinutes = readingMinutes(stats.Words)
+	for i := range stats.Sections {
+		stats.Sections[i].ReadingMinutes = readingMinutes(stats.Sections[i].Words)
+	}
+
+	stats.Uncited = []SectionStat{}
+	for i, sec := range stats.Sections {
+		if sec.Citations == 0 && !definesRefs[i] {
+			stats.Uncited = append(stats.Uncited, sec)
+		}
+	}
+
+	// most-cited first so over-cited sources stand out
+	sort.SliceStable(stats.Refs, func(i, j int) bool {
+		return stats.Refs[i].Citations > stats.Refs[j].Citations
+	})
+	return
+}
+
+// readingMinutes returns how many minutes reading words takes at
+// WordsPerMinute, rounded up.
+func readingMinutes(words int) int {
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// WriteText writes a human-readable version of the report to w.
+func (stats CitationStats) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Words: %d, about %d min to read\n", stats.Words, stats.ReadingMinutes)
+	fmt.Fprintf(w, "Headings:")
+	for level, n := range stats.Headings {
+		if n > 0 {
+			fmt.Fprintf(w, " H%d %d", level+1, n)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Sections:\n")
+	fmt.Fprintf(w, "  %6s %5s %4s %5s %4s  %s\n", "words", "min", "refs", "cites", "line", "heading")
+	for _, sec := range stats.Sections {
+		fmt.Fprintf(w, "  %6d %5d %4d %5d %4d  %s%s\n", sec.Words, sec.ReadingMinutes, sec.SecRefs, sec.Citations,
+			sec.Line, strings.Repeat("  ", sec.Level-1), sec.Heading)
+	}
+	fmt.Fprintf(w, "References:\n")
+	for _, rs := range stats.Refs {
+		fmt.Fprintf(w, "  %-20s %3d  (line %d)\n", rs.Name, rs.Citations, rs.Line)
+	}
+	fmt.Fprintf(w, "Sections citing nothing:\n")
+	for _, sec := range stats.Uncited {
+		fmt.Fprintf(w, "  %s (line %d)\n", sec.Heading, sec.Line)
+	}
+	if len(stats.Expiring) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Expiring sections:\n")
+	for _, e := range stats.Expiring {
+		when := fmt.Sprintf("in %d days", e.Days)
+		if e.Expired() {
+			when = fmt.Sprintf("expired %d days ago", -e.Days)
+		}
+		title := "(document)"
+		if e.Number != "" {
+			title = e.Number + " " + e.Title
+		}
+		fmt.Fprintf(w, "  %s %-20s %s (line %d)\n", e.Expires, when, title, e.Line)
+	}
+}
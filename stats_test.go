@@ -0,0 +1,60 @@
+package markproc
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestCitationStats(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"See [ref1] and [ref2] for details.",
+		"## Background",
+		"Nothing cited here.",
+		"## Method",
+		"Again [ref1] and [undefined] here.",
+		"## References",
+		"[ref1]: First reference.",
+		"[ref2]: Second reference.",
+		"[ref3]: Never cited.",
+	}
+
+	stats := Citations(lines)
+
+	Tassert(t, len(stats.Refs) == 3, "want 3 refs, have %d", len(stats.Refs))
+	want := map[string]int{"ref1": 2, "ref2": 1, "ref3": 0}
+	for _, rs := range stats.Refs {
+		Tassert(t, rs.Citations == want[rs.Name], "%s: want %d citations, have %d", rs.Name, want[rs.Name], rs.Citations)
+	}
+	Tassert(t, stats.Refs[0].Name == "ref1", "most-cited ref should sort first, have %s", stats.Refs[0].Name)
+	Tassert(t, len(stats.Refs[0].CitedIn) == 2, "ref1 cited in %v", stats.Refs[0].CitedIn)
+
+	Tassert(t, len(stats.Uncited) == 1, "want 1 uncited section, have %v", stats.Uncited)
+	Tassert(t, stats.Uncited[0].Heading == "Background", "have %v", stats.Uncited[0])
+	Tassert(t, stats.Uncited[0].Line == 3, "have line %d", stats.Uncited[0].Line)
+}
+
+func TestSectionSizes(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"One two three, see [sec mthd].",
+		"<!-- not counted -->",
+		"```",
+		"not counted either",
+		"```",
+		"## Method",
+		strings.TrimSpace(strings.Repeat("word ", 250)),
+		"Back to [sec intr] and `[sec code]`.",
+	}
+
+	stats := Citations(lines)
+
+	Tassert(t, stats.Headings == [6]int{1, 1}, "have %v", stats.Headings)
+	Tassert(t, len(stats.Sections) == 2, "have %v", stats.Sections)
+	intro, method := stats.Sections[0], stats.Sections[1]
+	Tassert(t, intro.Words == 6 && intro.SecRefs == 1 && intro.ReadingMinutes == 1, "have %+v", intro)
+	Tassert(t, method.Words == 257 && method.SecRefs == 1 && method.ReadingMinutes == 2, "have %+v", method)
+	Tassert(t, stats.Words == 265 && stats.ReadingMinutes == 2, "have %d words, %d min", stats.Words, stats.ReadingMinutes)
+}
@@ -0,0 +1,326 @@
+package markproc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// localHrefRe matches a link to an anchor in the same document.
+var localHrefRe = regexp.MustCompile(`<a href="#([^"]+)">`)
+
+// StreamOptions returns DefaultOptions with the passes ProcessStream
+// doesn't support turned off.
+func StreamOptions() Options {
+	opts := DefaultOptions()
+	opts.NormalizeAnchors = false
+	opts.MkTOC = false
+	opts.MkEquations = false
+	opts.LinkTerms = false
+	opts.MkIndex = false
+	opts.Setext = ""
+	opts.ExpandAcronyms = false
+	return opts
+}
+
+// streamUnsupported returns the names of the enabled settings that
+// ProcessStream doesn't support.
+func (p *Processor) streamUnsupported() (names []string) {
+	for name, enabled := range map[string]bool{
+		"include":             p.IncludeFS != nil,
+		"shift-headings":      p.ShiftHeadings != 0,
+		"sanitize":            p.Sanitize,
+		"normalize-anchors":   p.NormalizeAnchors,
+		"convert-anchors":     p.ConvertAnchors,
+		"toc":                 p.MkTOC,
+		"equations":           p.MkEquations,
+		"figures":             p.MkFigures,
+		"footnotes":           p.Footnotes != "",
+		"setext":              p.Setext != "",
+		"cite-style":          p.CiteStyle != "",
+		"cited-in":            p.CitedIn,
+		"link-style":          p.LinkStyle == LinkMarkdown || p.LinkStyle == LinkLaTeX,
+		"heading-anchors":     p.attachesHeadingAnchors(),
+		"link-terms":          p.LinkTerms,
+		"requirements":        p.Requirements != "",
+		"doc-version":         p.stampsVersion(),
+		"index":               p.MkIndex,
+		"expand-acronyms":     p.ExpandAcronyms,
+		"abbreviations":       len(p.Abbreviations) > 0,
+		"front-matter-config": p.FrontMatterConfig,
+		"require-outline":     p.RequiredOutline != nil,
+		"freeze":              p.FrozenOutline != nil,
+		"require-owners":      p.RequireOwners,
+		"strict-expiry":       p.StrictExpiry,
+		"check-urls":          p.CheckURLs,
+		"a11y":                p.A11y,
+		"rules":               p.Rules != nil,
+	} {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// streamTargets is what the first reading of a streamed document
+// collects: everything about it but its text.
+type streamTargets struct {
+	// frontMatter is the number of front matter lines.
+	frontMatter int
+	// headings are the numbered headings, in order, when MkHeads is
+	// set.
+	headings []heading
+	sections map[string]Target
+	displays map[string]string
+	// links holds the refs of link definitions, left for the renderer.
+	links map[string]bool
+	// anchors holds the link targets the output will have.
+	anchors map[string]bool
+	// duplicates holds the duplicate targets found.
+	duplicates []lineError
+}
+
+// ProcessStream processes the document read from r like Process,
+// writing the result to w one line at a time, so that memory use grows
+// with the number of headings and references rather than with the
+// size of the document.  r is read twice: once to number the headings
+// and collect the link targets, then again to transform and write each
+// line.  Only heading numbering and anchors, [ref] and [sec ...]
+// linking and the link checks of Verify are supported; ProcessStream
+// fails before reading if any other pass is enabled, see
+// StreamOptions.  The input must not have been processed before.
+func (p *Processor) ProcessStream(r io.ReadSeeker, w io.Writer) (err error) {
+	p.reset()
+	if names := p.streamUnsupported(); len(names) > 0 {
+		return fmt.Errorf("not supported when streaming: %s", strings.Join(names, ", "))
+	}
+	frontMatter, err := streamFrontMatterEnd(r)
+	if err != nil {
+		return
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return
+	}
+	t, err := p.collectStream(r, frontMatter)
+	if err != nil {
+		return
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return
+	}
+
+	out := bufio.NewWriter(w)
+	emit := func(line string) {
+		if err == nil {
+			_, err = out.WriteString(line + "\n")
+		}
+	}
+	var undefined []lineError
+	checkLinks := func(i int, line string) {
+		if !p.Verify {
+			return
+		}
+		for _, m := range localHrefRe.FindAllStringSubmatch(line, -1) {
+			if !t.anchors[m[1]] {
+				msg := fmt.Sprintf("Link points to an undefined target: #%s", m[1])
+				p.record("undefined-target", i, m[1], msg)
+				undefined = append(undefined, lineError{i, errors.New(msg)})
+			}
+		}
+	}
+
+	scanner := newLineScanner(r)
+	code := &codeScanner{prevBlank: true}
+	quotes := &quoteScanner{}
+	heads := t.headings
+	currentNumber := ""
+	for i := 0; scanner.Scan() && err == nil; i++ {
+		line := scannedLine(scanner, i)
+		if i < t.frontMatter || code.inCode(line) || (!p.Blockquotes && quotes.inQuote(line)) || p.isLinkDef(line) {
+			emit(line)
+			continue
+		}
+		if p.MkExterns {
+			if m := extLinkRegexp.FindStringSubmatch(line); m != nil {
+				emit(fmt.Sprintf(`<a name="%s"></a>`, m[1]))
+			}
+		}
+		if p.MkHeads && len(heads) > 0 && heads[0].Line == i+1 {
+			h := heads[0]
+			heads = heads[1:]
+			p.warnHeading(i, h)
+			if p.emitsHeadAnchors() && headingID(h.markers) == "" {
+				emit(fmt.Sprintf(`<a name="%s"></a>`, h.Anchor))
+			}
+			line = fmt.Sprintf("%s %s%s", strings.Repeat("#", h.Level), p.headingNumber(h.Number), h.Title+h.markers)
+		}
+		if m := p.numberedHeading(line); m != nil {
+			currentNumber = strings.TrimSuffix(m[2], ".")
+		}
+		if p.LinkExterns {
+			if p.ExternDisplay && extLinkRegexp.MatchString(line) {
+				line = strings.TrimRight(displayFieldRe.ReplaceAllString(line, ""), " \t")
+			}
+			line = outsideCode(line, func(line string) string {
+				return outsideComments(line, func(line string) string {
+					return p.linkExterns(line, t.displays, t.links, nil)
+				})
+			})
+		}
+		if p.LinkHeads {
+			line = outsideCode(line, func(line string) string {
+				return p.linkHeads(line, i, t.sections, currentNumber)
+			})
+		}
+		checkLinks(i, line)
+		emit(line)
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		return
+	}
+	if errs := p.sortLineErrors(t.duplicates, undefined); len(errs) > 0 {
+		return fmt.Errorf("Verification error: %w", VerifyErrors(errs))
+	}
+	if p.failure != "" {
+		err = fmt.Errorf("%s", p.failure)
+	}
+	return
+}
+
+// warnHeading reports the problems passMkHeads reports with heading h,
+// line i of the input.
+func (p *Processor) warnHeading(i int, h heading) {
+	if h.extra {
+		switch p.MultipleH1 {
+		case H1Warn:
+			p.warnf("multiple-h1", i, "#", "More than one H1: %s", h.Title+h.markers)
+		case H1Error:
+			p.warnf("multiple-h1", i, "#", "More than one H1: %s", h.Title+h.markers)
+			p.fail("more than one H1")
+		}
+	}
+	if h.gap {
+		p.warnf("heading-gap", i, "#", "Header level gap up: %s", h.Title+h.markers)
+	}
+}
+
+// streamFrontMatterEnd returns the number of lines of the front
+// matter at the start of r, reading no further than its end.
+func streamFrontMatterEnd(r io.Reader) (n int, err error) {
+	scanner := newLineScanner(r)
+	for i := 0; scanner.Scan(); i++ {
+		line := strings.TrimRight(scannedLine(scanner, i), " \t")
+		if i == 0 && line != "---" {
+			break
+		}
+		if i > 0 && (line == "---" || line == "...") {
+			return i + 1, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// collectStream reads the document from r, which starts with
+// frontMatter lines of front matter, numbering its headings and
+// collecting its link targets for ProcessStream.
+func (p *Processor) collectStream(r io.Reader, frontMatter int) (t *streamTargets, err error) {
+	t = &streamTargets{
+		frontMatter: frontMatter,
+		sections:    map[string]Target{},
+		displays:    map[string]string{},
+		links:       map[string]bool{},
+		anchors:     map[string]bool{},
+	}
+	// defined maps each anchor name to the line defining it
+	defined := map[string]int{}
+	addAnchor := func(i int, name string) {
+		if j, ok := defined[name]; ok && p.Verify {
+			msg := fmt.Sprintf("Duplicate target found: #%s, first defined on line %d", name, j+1)
+			p.record("duplicate-target", i, name, msg)
+			t.duplicates = append(t.duplicates, lineError{i, errors.New(msg)})
+		}
+		if _, ok := defined[name]; !ok {
+			defined[name] = i
+		}
+		t.anchors[name] = true
+	}
+
+	heads := p.newHeadNumberer(p.startNumberer())
+	slug := githubSlugger()
+	code := &codeScanner{prevBlank: true}
+	quotes := &quoteScanner{}
+	scanner := newLineScanner(r)
+	for i := 0; scanner.Scan(); i++ {
+		line := scannedLine(scanner, i)
+		if i < frontMatter || code.inCode(line) || (!p.Blockquotes && quotes.inQuote(line)) {
+			continue
+		}
+		if p.isLinkDef(line) {
+			t.links[extLinkRegexp.FindStringSubmatch(line)[1]] = true
+			continue
+		}
+		for _, name := range anchorTargets(line) {
+			addAnchor(i, name)
+		}
+		if m := extLinkRegexp.FindStringSubmatch(line); m != nil {
+			if d := displayFieldRe.FindStringSubmatch(line); d != nil {
+				t.displays[m[1]] = d[1] + d[2]
+			}
+			if p.MkExterns {
+				addAnchor(i, m[1])
+			}
+		}
+		// text is the heading's text in the output
+		text := ""
+		if m := headerRegexp.FindStringSubmatch(line); m != nil {
+			text, _ = tocExcluded(m[2])
+		}
+		if !p.MkHeads {
+			if m := p.numberedHeading(line); m != nil {
+				number := strings.TrimSuffix(m[2], ".")
+				title, id, _ := headingTitle(m[3])
+				name := heads.anchor(number, title)
+				if id != "" {
+					name = id
+				}
+				t.addSection(number, title, name, id)
+			}
+		} else if h := heads.next(line); h != nil {
+			h.Line = i + 1
+			t.headings = append(t.headings, *h)
+			t.addSection(h.Number, h.Title, h.Anchor, headingID(h.markers))
+			text = p.numberText(h.Number) + " " + h.Title
+			if p.emitsHeadAnchors() && headingID(h.markers) == "" {
+				addAnchor(i, h.Anchor)
+			}
+		}
+		// With GitHub style anchors the renderer generates a target
+		// for every heading
+		if p.AnchorStyle == AnchorGitHub && text != "" {
+			t.anchors[slug(text)] = true
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// addSection records the section numbered number, with the {#id}
+// attribute id if any, as a [sec ...] target.
+func (t *streamTargets) addSection(number, title, anchor, id string) {
+	key := strings.ToLower(title)
+	addTarget(t.sections, key, Target{Name: anchor, Heading: title, Number: number, HeadingLower: key, ID: id})
+}
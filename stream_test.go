@@ -0,0 +1,64 @@
+package markproc
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestProcessStream(t *testing.T) {
+	lines := []string{
+		"---",
+		"# a YAML comment, not a heading",
+		"title: Spec",
+		"---",
+		"# Intro",
+		"See [sec dsgn] and [rfc2119] <!-- not [rfc2119] here -->.",
+		"",
+		"    # indented code, not a heading",
+		"",
+		"## Scope {-}",
+		"# Design",
+		`<a name="custom"></a>See <a href="#custom">here</a>.`,
+		"```",
+		"[sec nothing]",
+		"```",
+		"[rfc2119]: Key words. display: \"RFC 2119\"",
+	}
+	for _, style := range AnchorStyles {
+		opts := StreamOptions()
+		opts.AnchorStyle = style
+		opts.AnchorFormat = "s{number}-{slug}"
+		opts.ExternDisplay = true
+		p := NewProcessor(opts)
+		p.Stderr = io.Discard
+		want, err := p.Process(lines)
+		Tassert(t, err == nil, "%s: Process failed: %v", style, err)
+
+		var b bytes.Buffer
+		err = p.ProcessStream(strings.NewReader(strings.Join(lines, "\n")+"\n"), &b)
+		Tassert(t, err == nil, "%s: ProcessStream failed: %v", style, err)
+		have := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		Tassert(t, reflect.DeepEqual(have, want), "%s:\nwant: %q\nhave: %q", style, want, have)
+	}
+
+	p := NewProcessor(StreamOptions())
+	p.Stderr = io.Discard
+	var b bytes.Buffer
+	err := p.ProcessStream(strings.NewReader("# Intro\nSee [sec nothing] and <a href=\"#nowhere\">x</a>.\n"), &b)
+	Tassert(t, err != nil, "want error")
+	rules := []string{}
+	for _, w := range p.Warnings() {
+		rules = append(rules, w.Rule)
+	}
+	Tassert(t, reflect.DeepEqual(rules, []string{"sec-unresolved", "undefined-target"}), "have %v", rules)
+	Tassert(t, strings.HasPrefix(b.String(), "<a name=\"sec1\"></a>\n# 1. Intro\n"), "have %q", b.String())
+
+	p = NewProcessor(DefaultOptions())
+	err = p.ProcessStream(strings.NewReader("# Intro\n"), &b)
+	Tassert(t, err != nil && strings.Contains(err.Error(), "expand-acronyms, index, link-terms"), "have %v", err)
+}
@@ -0,0 +1,287 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// prevNumberRe matches a heading numbered by an earlier run, e.g.
+	// "## 1.2. Title" or "# A. Title".
+	prevNumberRe = regexp.MustCompile(`^(#+)\s+(?:\d+|[A-Z]+)(?:\.\d+)*\.\s+(.+)`)
+	// anchorLineRe matches a line holding nothing but an anchor as
+	// passMkHeads and passMkExterns insert them.
+	anchorLineRe = regexp.MustCompile(`^<a name="([^"]+)"></a>$`)
+	// madeLinkRe matches a link made by passLinkExterns or
+	// passLinkHeads, in brackets or, for author-year citations, in
+	// parentheses.
+	madeLinkRe = regexp.MustCompile(`([\[(])<a href="([^"#]*)#([^"]+)">([^<]*)</a>([\])])`)
+	// refNameRe matches the name of a [ref]: definition.
+	refNameRe = regexp.MustCompile(`^\w+$`)
+)
+
+// Strip undoes processing, turning markproc's output back into
+// editable source: the links made from [ref], [sec ...], [eq ...] and
+// [term X] references become references again, expanded acronyms are
+// shortened, uses of p's Abbreviations are unlinked, and the heading
+// numbers, equation tags, anchors, tables of contents, related section
+// lists, bibliographies, version stamps, included files and code
+// snippets it inserted are removed, as are the anchors, links and
+// traceability tables of requirements, whose prefix is found from
+// them if p's Requirements is unset.  A [sec ...] or
+// [eq ...] link becomes a reference abbreviating its target's title,
+// and is left alone if its target is in another file or no
+// abbreviation picks it out.  p's AnchorStyle and SecRefFormat must be
+// the ones the document was processed with.
+func (p *Processor) Strip(lines []string) []string {
+	if !p.Blockquotes {
+		lines = p.passQuotes(lines)
+	}
+	lines = p.passLinkDefs(lines)
+	if p.Setext != "" {
+		lines = p.passSetext(lines)
+	}
+	if p.LinkStyle == LinkMarkdown || p.LinkStyle == LinkLaTeX {
+		lines = p.passHTMLLinks(lines)
+	}
+	if p.attachesHeadingAnchors() {
+		lines = p.passHeadingAnchors(lines)
+	}
+	lines = stripIncludes(lines)
+	lines = stripSnippets(lines)
+	lines = stripVersion(lines)
+	targets := p.sectionTargets(lines, "")
+	titles := map[string]string{}
+	for key, target := range targets {
+		titles[target.Name] = key
+	}
+	lines = unexpandAcronyms(lines)
+	lines = p.unlinkAbbreviations(lines)
+	eqTargets := p.equationTargets(lines)
+	equations := map[string]string{}
+	for key, target := range eqTargets {
+		equations[target.Name] = key
+	}
+	secText := p.secRefTextRe()
+	code := codeMask(lines)
+	unlinked := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if !code[i] {
+			line = termLinkRe.ReplaceAllString(line, "[term $2]")
+			line = madeLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+				m := madeLinkRe.FindStringSubmatch(link)
+				file, anchor, text := m[2], m[3], m[4]
+				if key, ok := equations[anchor]; ok && file == "" {
+					if abbrev, ok := abbreviate(p.matcher(), key, keys(eqTargets)); ok {
+						return fmt.Sprintf("[eq %s]", abbrev)
+					}
+					return link
+				}
+				if !secText.MatchString(text) {
+					if !refNameRe.MatchString(anchor) {
+						return link
+					}
+					return fmt.Sprintf("[%s]", anchor)
+				}
+				key, ok := titles[anchor]
+				if file != "" || !ok {
+					return link
+				}
+				if id := targets[key].ID; id != "" {
+					return fmt.Sprintf("[sec #%s]", id)
+				}
+				abbrev, ok := abbreviate(p.matcher(), key, keys(targets))
+				if !ok {
+					return link
+				}
+				return fmt.Sprintf("[sec %s]", abbrev)
+			})
+		}
+		unlinked = append(unlinked, line)
+	}
+
+	opts := p.Options
+	opts.MkHeads, opts.MkExterns, opts.MkTOC, opts.MkEquations = true, true, true, true
+	opts.LinkTerms, opts.ExpandAcronyms, opts.LinkHeads, opts.CitedIn = true, true, true, true
+	opts.MkIndex, opts.MkFigures = true, true
+	if opts.CiteStyle == "" {
+		opts.CiteStyle = CiteNumeric
+	}
+	if opts.Requirements == "" {
+		opts.Requirements = requirementsPrefix(unlinked)
+	}
+	return p.finish(NewProcessor(opts).passStrip(unlinked))
+}
+
+// abbreviate returns an abbreviation of the section key, a lowercased
+// heading title, that m resolves to it alone.
+func abbreviate(m Matcher, key string, keys []string) (abbrev string, ok bool) {
+	abbrev = strings.Replace(key, " ", "", -1)
+	if abbrev == key {
+		// an exact match isn't taken as an abbreviation
+		_, size := utf8.DecodeLastRuneInString(key)
+		abbrev = key[:len(key)-size]
+	}
+	matches := m.Match(abbrev, keys)
+	if abbrev == "" || len(matches) != 1 || matches[0] != key {
+		return "", false
+	}
+	return abbrev, true
+}
+
+// passStrip undoes what an earlier run of the passes that are about to
+// run again added to the document, so that processing markproc's own
+// output gives the same result as processing the original: heading
+// numbers and the anchors before numbered headings, and with an
+// AnchorMap their aliases, for MkHeads,
+// definition anchors for MkExterns, LinkTerms and ExpandAcronyms,
+// equation tags and anchors for MkEquations, the bodies of tables of
+// contents, bibliographies and glossaries for MkTOC, CiteStyle and
+// LinkTerms, a Glossary's entries becoming [term X]: definitions
+// again when the document has no others, Lists of Acronyms, whose
+// entries become [acro X]: definitions again, for ExpandAcronyms,
+// related section lists for LinkHeads, the lists of citing
+// sections after definitions for CitedIn, the Index for MkIndex, whose
+// marks become [[index:term]] and [text]{.idx} again, and the numbers
+// of captions and lists of figures and tables for MkFigures, and the
+// anchors, links and traceability tables of Requirements.
+func (p *Processor) passStrip(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	hasDefs, hasTerms := false, false
+	for i, line := range lines {
+		if !code[i] && extLinkRegexp.MatchString(line) {
+			hasDefs = true
+		}
+		if !code[i] && termDefRe.MatchString(line) {
+			hasTerms = true
+		}
+	}
+	appendix := p.appendixMask(lines)
+	// math marks the lines of display-math blocks
+	math := make([]bool, len(lines))
+	for i := range lines {
+		for j := i; j <= mathBlockEnd(lines, code, i); j++ {
+			math[j] = true
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if math[i] && p.MkEquations {
+			tagged := eqTagRe.ReplaceAllString(line, "$1")
+			if tagged != line && strings.TrimSpace(tagged) == "" {
+				continue
+			}
+			line = tagged
+		}
+		if code[i] {
+			out.add(i, line)
+			continue
+		}
+		if m := anchorLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && p.MkEquations &&
+			strings.HasPrefix(m[1], "eq") && i+1 < len(lines) && mathBlockEnd(lines, code, i+1) >= 0 {
+			continue
+		}
+		if m := anchorLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && i+1 < len(lines) && !code[i+1] {
+			next := lines[i+1]
+			if p.MkHeads && p.prevNumbered(next, appendix[i+1]) != nil {
+				continue
+			}
+			if p.MkHeads && p.AnchorMap.isAlias(m[1]) && p.beforeNumbered(lines, code, appendix, i+1) {
+				continue
+			}
+			if p.MkExterns && strings.HasPrefix(next, fmt.Sprintf("[%s]:", m[1])) {
+				continue
+			}
+			if p.LinkTerms && termDefRe.MatchString(next) && m[1] == termAnchor(termDefRe.FindStringSubmatch(next)[1]) {
+				continue
+			}
+			if p.ExpandAcronyms && acroDefRe.MatchString(next) && m[1] == acroAnchor(acroDefRe.FindStringSubmatch(next)[1]) {
+				continue
+			}
+		}
+		if p.CitedIn && extLinkRegexp.MatchString(line) {
+			line = citedInRe.ReplaceAllString(line, "")
+		}
+		if p.MkIndex {
+			line = unmarkIndex(line)
+		}
+		if p.MkFigures {
+			line = unnumberCaption(line)
+		}
+		if p.Requirements != "" {
+			line = p.unlinkRequirements(line)
+		}
+		if line == relatedStart && p.LinkHeads {
+			i = skipBlock(lines, i, relatedEnd)
+			continue
+		}
+		if line == acroStart && p.ExpandAcronyms {
+			// a List of Acronyms goes back to the definitions it was
+			// made from
+			end := skipBlock(lines, i, acroEnd)
+			for j := i + 1; j < end; j++ {
+				if m := acroEntryRe.FindStringSubmatch(lines[j]); m != nil {
+					out.add(j, fmt.Sprintf("[acro %s]: %s", m[1], m[2]))
+				}
+			}
+			i = end
+			continue
+		}
+		if m := p.prevNumbered(line, appendix[i]); m != nil && p.MkHeads {
+			line = fmt.Sprintf("%s %s", m[1], m[2])
+		}
+		out.add(i, line)
+		switch {
+		case line == tocStart && p.MkTOC:
+			i = skipBlock(lines, i, tocEnd)
+		case line == bibStart && p.CiteStyle != "" && hasDefs:
+			i = skipBlock(lines, i, bibEnd)
+		case line == glossaryStart && p.LinkTerms && hasTerms:
+			i = skipBlock(lines, i, glossaryEnd)
+		case line == glossaryStart && p.LinkTerms:
+			// the definitions were moved into the Glossary: put them
+			// back after its marker
+			end := skipBlock(lines, i, glossaryEnd)
+			for j := i + 1; j < end; j++ {
+				if m := glossaryEntryRe.FindStringSubmatch(lines[j]); m != nil {
+					out.add(j, fmt.Sprintf("[term %s]: %s", m[1], m[2]))
+				}
+			}
+			i = end
+		case line == indexStart && p.MkIndex:
+			i = skipBlock(lines, i, indexEnd)
+		case line == lofStart && p.MkFigures:
+			i = skipBlock(lines, i, lofEnd)
+		case line == lotStart && p.MkFigures:
+			i = skipBlock(lines, i, lotEnd)
+		case line == reqStart && p.Requirements != "":
+			i = skipBlock(lines, i, reqEnd)
+		}
+	}
+	return p.done(out)
+}
+
+// skipBlock returns the index of the line holding end after lines[i],
+// or i if there is none.
+func skipBlock(lines []string, i int, end string) int {
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == end {
+			return j
+		}
+	}
+	return i
+}
+
+// beforeNumbered reports whether the lines from i are anchor lines
+// followed by a numbered heading.
+func (p *Processor) beforeNumbered(lines []string, code, appendix []bool, i int) bool {
+	for ; i < len(lines) && !code[i]; i++ {
+		if !anchorLineRe.MatchString(strings.TrimSpace(lines[i])) {
+			return p.prevNumbered(lines[i], appendix[i]) != nil
+		}
+	}
+	return false
+}
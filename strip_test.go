@@ -0,0 +1,122 @@
+package markproc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestIdempotent(t *testing.T) {
+	lines := []string{
+		"[toc]",
+		"# Intro",
+		"See [ref1] and [sec dsgn].",
+		"## Scope",
+		"# Design",
+		"# Preface {-}",
+		"# 2024 Plans",
+		"## References",
+		"[bibliography]",
+		"[ref1]: Knuth, D. (1984). Literate Programming.",
+	}
+
+	opts := DefaultOptions()
+	opts.CiteStyle = CiteNumeric
+	p := NewProcessor(opts)
+	once, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	twice, err := p.Process(once)
+	Tassert(t, err == nil, "second Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(once, twice), "\nonce:  %q\ntwice: %q", once, twice)
+
+	// a heading renamed between runs is renumbered and relisted
+	for i, line := range once {
+		if line == "# 2. Design" {
+			once[i] = "# 2. Architecture"
+		}
+	}
+	twice, err = p.Process(once)
+	Tassert(t, err == nil, "second Process failed: %v", err)
+	Tassert(t, twice[3] == `- <a href="#sec2">2. Architecture</a>`, "have %q", twice[3])
+}
+
+func TestStrip(t *testing.T) {
+	source := []string{
+		"[toc]",
+		"# Intro",
+		"See [ref1] and [sec dgoals].",
+		"# Design Goals",
+		"As [sec intr] says.",
+		"[ref1]: A reference.",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	processed, err := p.Process(source)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	stripped := p.Strip(processed)
+	want := []string{
+		"<!-- toc -->",
+		"# Intro",
+		"See [ref1] and [sec designgoals].",
+		"# Design Goals",
+		"As [sec intr] says.",
+		"[ref1]: A reference.",
+	}
+	Tassert(t, reflect.DeepEqual(stripped, want), "\nwant: %q\nhave: %q", want, stripped)
+
+	again, err := p.Process(stripped)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, reflect.DeepEqual(again, processed), "\nwant: %q\nhave: %q", processed, again)
+}
+
+func TestSecRefFormat(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"See [sec dsgn].",
+		"# Design",
+	}
+	for _, format := range []string{"Section {number}", "§ {number}", "{number}節", "{number} ({title})"} {
+		opts := DefaultOptions()
+		opts.MkTOC = false
+		opts.SecRefFormat = format
+		p := NewProcessor(opts)
+		out, err := p.Process(lines)
+		Ck(err)
+		text := strings.NewReplacer("{number}", "2", "{title}", "Design").Replace(format)
+		want := fmt.Sprintf(`See [<a href="#sec2">%s</a>].`, text)
+		Tassert(t, out[2] == want, "\nwant: %q\nhave: %q", want, out[2])
+		// anchors are unaffected
+		Tassert(t, out[3] == `<a name="sec2"></a>`, "have %q", out[3])
+
+		stripped := p.Strip(out)
+		Tassert(t, stripped[1] == "See [sec desig].", "%s: have %q", format, stripped[1])
+	}
+}
+
+func TestRefTemplates(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"See [sec dsgn] and [eq enrgy].",
+		"# Design",
+		"<!-- eq energy -->",
+		"$$ E = mc^2 $$",
+	}
+	opts := DefaultOptions()
+	opts.MkTOC = false
+	Ck(opts.SetRefTemplate("Section {number} ({title})"))
+	Ck(opts.SetRefTemplate("eq=Equation ({number})"))
+	p := NewProcessor(opts)
+	out, err := p.Process(lines)
+	Ck(err)
+	want := `See [<a href="#sec2">Section 2 (Design)</a>] and [<a href="#eq2_1">Equation (2.1)</a>].`
+	Tassert(t, out[2] == want, "\nwant: %q\nhave: %q", want, out[2])
+
+	stripped := p.Strip(out)
+	Tassert(t, stripped[1] == "See [sec desig] and [eq energ].", "have %q", stripped[1])
+
+	err = opts.SetRefTemplate("fig=Figure {number}")
+	Tassert(t, err != nil, "want error for an unknown reference kind")
+}
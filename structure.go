@@ -0,0 +1,122 @@
+package markproc
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SectionRef is a [sec ...] reference into a section.
+type SectionRef struct {
+	// Line is the 1-based line of the reference in the input.
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// SectionInfo is a section of a document with the lines it spans and
+// the references pointing into it.
+type SectionInfo struct {
+	Section
+	// EndLine is the 1-based line of the last line of the section,
+	// subsections included.
+	EndLine int          `json:"end_line"`
+	RefsIn  []SectionRef `json:"refs_in"`
+}
+
+// Structure returns the sections of the document lines as Sections
+// does, whether or not they have been processed, each with the lines
+// from its heading to the next heading at its level or above, and the
+// [sec ...] references resolving to it, in document order.
+func (p *Processor) Structure(lines []string) (infos []SectionInfo) {
+	infos = []SectionInfo{}
+	var heads []heading
+	processed := p.processed(lines)
+	if processed {
+		for _, sec := range p.Sections(lines) {
+			heads = append(heads, heading{Section: sec})
+		}
+	} else {
+		heads = p.headings(lines)
+	}
+	for i, h := range heads {
+		if hasMarker(h.markers, "toc-exclude") {
+			continue
+		}
+		end := len(lines)
+		for _, next := range heads[i+1:] {
+			if next.Level <= h.Level {
+				end = next.Line - 1
+				break
+			}
+		}
+		infos = append(infos, SectionInfo{Section: h.Section, EndLine: end, RefsIn: []SectionRef{}})
+	}
+
+	if processed {
+		// the references were made links to the sections' anchors
+		code := codeMask(lines)
+		for i, line := range lines {
+			if code[i] {
+				continue
+			}
+			for _, m := range madeLinkRe.FindAllStringSubmatch(line, -1) {
+				for j := range infos {
+					if m[2] == "" && infos[j].Anchor == m[3] {
+						infos[j].RefsIn = append(infos[j].RefsIn, SectionRef{Line: i + 1, Text: m[1] + m[4] + m[5]})
+						break
+					}
+				}
+			}
+		}
+		return
+	}
+
+	opts := p.Options
+	opts.Verify, opts.CheckURLs = false, false
+	q := NewProcessor(opts)
+	q.Stderr = io.Discard
+	q.Process(lines)
+	for _, ref := range q.References() {
+		for i := range infos {
+			if infos[i].Number == ref.Target.Number && infos[i].Title == ref.Target.Heading {
+				infos[i].RefsIn = append(infos[i].RefsIn, SectionRef{Line: ref.Line, Text: ref.Text})
+				break
+			}
+		}
+	}
+	return
+}
+
+// WriteStructureYAML writes infos, as returned by Structure, to w as a
+// YAML list with the same fields as their JSON encoding.
+func WriteStructureYAML(w io.Writer, infos []SectionInfo) (err error) {
+	if len(infos) == 0 {
+		_, err = fmt.Fprintln(w, "[]")
+		return
+	}
+	for _, info := range infos {
+		_, err = fmt.Fprintf(w, "- level: %d\n  number: %s\n  title: %s\n  anchor: %s\n  line: %d\n  end_line: %d\n",
+			info.Level, strconv.Quote(info.Number), strconv.Quote(info.Title), strconv.Quote(info.Anchor), info.Line, info.EndLine)
+		if err != nil {
+			return
+		}
+		if len(info.RefsIn) == 0 {
+			_, err = fmt.Fprintf(w, "  refs_in: []\n")
+			if err != nil {
+				return
+			}
+			continue
+		}
+		_, err = fmt.Fprintf(w, "  refs_in:\n")
+		if err != nil {
+			return
+		}
+		for _, ref := range info.RefsIn {
+			_, err = fmt.Fprintf(w, "    - line: %d\n      text: %s\n", ref.Line, strconv.Quote(ref.Text))
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package markproc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestStructure(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"See [sec dsgn].",
+		"## History <!-- toc-exclude -->",
+		"# Design",
+		"## Goals",
+		"Back to [sec intr], and [sec dsgn].",
+	}
+	want := []SectionInfo{
+		{Section{Level: 1, Number: "1", Title: "Intro", Anchor: "sec1", Line: 1}, 3,
+			[]SectionRef{{Line: 6, Text: "[sec intr]"}}},
+		{Section{Level: 1, Number: "2", Title: "Design", Anchor: "sec2", Line: 4}, 6,
+			[]SectionRef{{Line: 2, Text: "[sec dsgn]"}, {Line: 6, Text: "[sec dsgn]"}}},
+		{Section{Level: 2, Number: "2.1", Title: "Goals", Anchor: "sec2_1", Line: 5}, 6,
+			[]SectionRef{}},
+	}
+
+	p := NewProcessor(DefaultOptions())
+	have := p.Structure(lines)
+	Tassert(t, reflect.DeepEqual(want, have), "\nwant: %+v\nhave: %+v", want, have)
+
+	// a processed document has the same sections, at its own lines
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	wantProcessed := []SectionInfo{
+		{Section{Level: 1, Number: "1", Title: "Intro", Anchor: "sec1", Line: 2}, 6,
+			[]SectionRef{{Line: 10, Text: "[sec 1]"}}},
+		{Section{Level: 1, Number: "2", Title: "Design", Anchor: "sec2", Line: 7}, 10,
+			[]SectionRef{{Line: 3, Text: "[sec 2]"}, {Line: 10, Text: "[sec 2]"}}},
+		{Section{Level: 2, Number: "2.1", Title: "Goals", Anchor: "sec2_1", Line: 9}, 10,
+			[]SectionRef{}},
+	}
+	have = p.Structure(out)
+	Tassert(t, reflect.DeepEqual(wantProcessed, have), "\nwant: %+v\nhave: %+v", wantProcessed, have)
+
+	var b bytes.Buffer
+	Ck(WriteStructureYAML(&b, want[1:]))
+	wantYAML := `- level: 1
+  number: "2"
+  title: "Design"
+  anchor: "sec2"
+  line: 4
+  end_line: 6
+  refs_in:
+    - line: 2
+      text: "[sec dsgn]"
+    - line: 6
+      text: "[sec dsgn]"
+- level: 2
+  number: "2.1"
+  title: "Goals"
+  anchor: "sec2_1"
+  line: 5
+  end_line: 6
+  refs_in: []
+`
+	Tassert(t, b.String() == wantYAML, "\nwant: %s\nhave: %s", wantYAML, b.String())
+}
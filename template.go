@@ -0,0 +1,128 @@
+package markproc
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// templateItemRe matches one "- Title" item of an outline template.
+var templateItemRe = regexp.MustCompile(`^(\s*)-\s+(.+)$`)
+
+// OutlineTemplate lists the sections a document is required to have,
+// in the order they must appear.
+type OutlineTemplate []RequiredSection
+
+// RequiredSection is one section of an OutlineTemplate.  Parent is the
+// title of the required section it must be nested in, or "" if it may
+// appear at any level.
+type RequiredSection struct {
+	Title  string
+	Parent string
+}
+
+// ReadOutlineTemplate reads an outline template written as a YAML list
+// of section titles, optionally under a "sections:" key.  Indented
+// items must be nested in the item above them:
+//
+//	sections:
+//	  - Introduction
+//	  - Design
+//	    - Security Considerations
+//	  - Alternatives Considered
+//
+// Only this subset of YAML is understood.
+func ReadOutlineTemplate(r io.Reader) (tmpl OutlineTemplate, err error) {
+	tmpl = OutlineTemplate{}
+	type open struct {
+		indent int
+		title  string
+	}
+	stack := []open{}
+	scanner := newLineScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "sections:" || trimmed == "---" {
+			continue
+		}
+		m := templateItemRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("outline template line %d: expected \"- Title\"", n)
+		}
+		indent := len(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		sec := RequiredSection{Title: unquote(m[2])}
+		if len(stack) > 0 {
+			sec.Parent = stack[len(stack)-1].title
+		}
+		tmpl = append(tmpl, sec)
+		stack = append(stack, open{indent, sec.Title})
+	}
+	err = scanner.Err()
+	return
+}
+
+// verifyOutline checks the input document against p.RequiredOutline,
+// recording a warning for each required section that is missing or
+// misplaced.
+func (p *Processor) verifyOutline() (err error) {
+	sections := p.Outline(p.input)
+	find := func(title string) int {
+		for i, sec := range sections {
+			if strings.EqualFold(strings.TrimSpace(sec.Title), title) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	problems := 0
+	prev, prevTitle := -1, ""
+	for _, req := range p.RequiredOutline {
+		i := find(req.Title)
+		if i < 0 {
+			p.recordLine("outline-missing", 0, "", fmt.Sprintf("Required section missing: %s", req.Title))
+			problems++
+			continue
+		}
+		sec := sections[i]
+		if i < prev {
+			p.recordLine("outline-misplaced", sec.Line, sec.Title, fmt.Sprintf("Required section %s should come after %s", req.Title, prevTitle))
+			problems++
+		} else {
+			prev, prevTitle = i, req.Title
+		}
+		if req.Parent == "" {
+			continue
+		}
+		parent := find(req.Parent)
+		if parent >= 0 && !contains(sections, parent, i) {
+			p.recordLine("outline-misplaced", sec.Line, sec.Title, fmt.Sprintf("Required section %s should be inside %s", req.Title, req.Parent))
+			problems++
+		}
+	}
+	if problems > 0 {
+		err = fmt.Errorf("%d required sections missing or misplaced", problems)
+	}
+	return
+}
+
+// contains reports whether sections[child] is nested in
+// sections[parent].
+func contains(sections []Section, parent, child int) bool {
+	if child <= parent {
+		return false
+	}
+	for _, sec := range sections[parent+1 : child+1] {
+		if sec.Level <= sections[parent].Level {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestReadOutlineTemplate(t *testing.T) {
+	input := `sections:
+  - Introduction
+  - Design
+    - "Security Considerations"
+  - Alternatives Considered
+`
+	have, err := ReadOutlineTemplate(strings.NewReader(input))
+	Tassert(t, err == nil, "ReadOutlineTemplate failed: %v", err)
+	want := OutlineTemplate{
+		{Title: "Introduction"},
+		{Title: "Design"},
+		{Title: "Security Considerations", Parent: "Design"},
+		{Title: "Alternatives Considered"},
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %v\nhave: %v", want, have)
+
+	_, err = ReadOutlineTemplate(strings.NewReader("title: x\n"))
+	Tassert(t, err != nil, "bad template accepted")
+}
+
+func TestRequiredOutline(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RequiredOutline = OutlineTemplate{
+		{Title: "Introduction"},
+		{Title: "Design"},
+		{Title: "Security Considerations", Parent: "Design"},
+		{Title: "Alternatives Considered"},
+	}
+
+	p := NewProcessor(opts)
+	_, err := p.Process([]string{
+		"# Introduction",
+		"# Design",
+		"## Security Considerations",
+		"# Alternatives Considered",
+	})
+	Tassert(t, err == nil, "Process failed: %v", err)
+
+	p.Stderr = io.Discard
+	_, err = p.Process([]string{
+		"# Introduction",
+		"# Alternatives Considered",
+		"# Design",
+		"# Security Considerations",
+	})
+	Tassert(t, err != nil, "misplaced sections not reported")
+	have := []string{}
+	for _, w := range p.Warnings() {
+		have = append(have, w.Rule+" "+w.Message)
+	}
+	want := []string{
+		"outline-misplaced Required section Security Considerations should be inside Design",
+		"outline-misplaced Required section Alternatives Considered should come after Security Considerations",
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+
+	_, err = p.Process([]string{"# Introduction"})
+	Tassert(t, err != nil, "missing sections not reported")
+	Tassert(t, len(p.Warnings()) == 3 && p.Warnings()[0].Rule == "outline-missing", "have %v", p.Warnings())
+}
@@ -0,0 +1,129 @@
+package markproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// headingMarkersRe matches the <!-- marker --> comments and {#id}
+	// attribute, if its id is a safe anchor name, at the end of a
+	// heading, e.g. <!-- toc-exclude -->.
+	headingMarkersRe = regexp.MustCompile(`(\s*(?:<!--\s*(?:markproc:\s*)?[\w-]+\s*-->|\{#[\p{L}\p{N}_.:-]+\}))+\s*$`)
+	// markerRe matches one marker comment.
+	markerRe = regexp.MustCompile(`<!--\s*(?:markproc:\s*)?([\w-]+)\s*-->`)
+)
+
+// headingMarkers splits the marker comments and {#id} attribute off
+// the end of a heading title.
+func headingMarkers(title string) (clean, markers string) {
+	if loc := headingMarkersRe.FindStringIndex(title); loc != nil {
+		return title[:loc[0]], title[loc[0]:]
+	}
+	return title, ""
+}
+
+// hasMarker reports whether markers, as returned by headingMarkers,
+// include <!-- name -->.
+func hasMarker(markers, name string) bool {
+	for _, m := range markerRe.FindAllStringSubmatch(markers, -1) {
+		if m[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// headingID returns the anchor name given by the {#id} attribute among
+// markers, as returned by headingMarkers, or "" if there is none.
+func headingID(markers string) string {
+	if m := attrIDRe.FindStringSubmatch(markers); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// headingTitle splits text, a numbered heading after its number, into
+// its title and the anchor name its {#id} attribute gives, if any, and
+// reports whether it is excluded from tables of contents.
+func headingTitle(text string) (title, id string, excluded bool) {
+	title, markers := headingMarkers(text)
+	return title, headingID(markers), hasMarker(markers, "toc-exclude")
+}
+
+// tocExcluded returns title without its marker comments, and whether
+// one of them is <!-- toc-exclude -->.
+func tocExcluded(title string) (clean string, excluded bool) {
+	clean, markers := headingMarkers(title)
+	return clean, hasMarker(markers, "toc-exclude")
+}
+
+// isTOCMarker reports whether line asks for a table of contents.
+func isTOCMarker(line string) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "[toc]", "<!-- toc -->":
+		return true
+	}
+	return isDirective(line, "toc")
+}
+
+// The comments passMkTOC puts around a table of contents.
+const (
+	tocStart = "<!-- toc -->"
+	tocEnd   = "<!-- /toc -->"
+)
+
+// passMkTOC replaces each [toc] or <!-- toc --> marker line with a
+// nested list of links to the numbered headings, between <!-- toc -->
+// and <!-- /toc --> comments.  It must run after passMkHeads.  Headings
+// deeper than p.TOCDepth are left out unless TOCDepth is zero.
+func (p *Processor) passMkTOC(lines []string) []string {
+	type entry struct {
+		level                 int
+		number, title, anchor string
+	}
+	entries := []entry{}
+	minLevel := 0
+	anchor := p.anchorNamer()
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if headerMatch := p.numberedHeading(line); len(headerMatch) > 0 {
+			level := len(headerMatch[1])
+			number := strings.TrimSuffix(headerMatch[2], ".")
+			title, id, excluded := headingTitle(headerMatch[3])
+			name := anchor(number, title)
+			if id != "" {
+				name = id
+			}
+			if excluded || p.TOCDepth > 0 && level > p.TOCDepth {
+				continue
+			}
+			if minLevel == 0 || level < minLevel {
+				minLevel = level
+			}
+			entries = append(entries, entry{level, number, title, name})
+		}
+	}
+	toc := []string{}
+	for _, e := range entries {
+		indent := strings.Repeat("  ", e.level-minLevel)
+		toc = append(toc, fmt.Sprintf(`%s- <a href="#%s">%s %s</a>`, indent, e.anchor, p.numberText(e.number), escapeText(e.title)))
+	}
+
+	out := p.newLineWriter(lines)
+	for i, line := range lines {
+		if !code[i] && isTOCMarker(line) {
+			// the comments let a later run find and replace the list
+			out.add(i, tocStart)
+			out.add(i, toc...)
+			out.add(i, tocEnd)
+			continue
+		}
+		out.add(i, line)
+	}
+	return p.done(out)
+}
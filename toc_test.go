@@ -0,0 +1,84 @@
+package markproc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPassMkTOC(t *testing.T) {
+	lines := []string{
+		`<!-- toc -->`,
+		`<a name="sec1"></a>`,
+		`# 1. Intro`,
+		`<a name="sec1_1"></a>`,
+		`## 1.1. Scope`,
+		`<a name="sec1_1_1"></a>`,
+		`### 1.1.1. Details`,
+		"```",
+		"[toc]",
+		"```",
+		`<a name="sec2"></a>`,
+		`# 2. Design`,
+	}
+	expectedLines := []string{
+		`<!-- toc -->`,
+		`- <a href="#sec1">1. Intro</a>`,
+		`  - <a href="#sec1_1">1.1. Scope</a>`,
+		`- <a href="#sec2">2. Design</a>`,
+		`<!-- /toc -->`,
+		`<a name="sec1"></a>`,
+		`# 1. Intro`,
+		`<a name="sec1_1"></a>`,
+		`## 1.1. Scope`,
+		`<a name="sec1_1_1"></a>`,
+		`### 1.1.1. Details`,
+		"```",
+		"[toc]",
+		"```",
+		`<a name="sec2"></a>`,
+		`# 2. Design`,
+	}
+
+	opts := DefaultOptions()
+	opts.TOCDepth = 2
+	p := NewProcessor(opts)
+	result := p.passMkTOC(lines)
+	if !reflect.DeepEqual(result, expectedLines) {
+		t.Errorf("passMkTOC failed:\nwant: %q\nhave: %q", expectedLines, result)
+	}
+}
+
+func TestTOCExclude(t *testing.T) {
+	lines := []string{
+		"[toc]",
+		"# Intro",
+		"# Document History <!-- toc-exclude -->",
+		"# Design",
+	}
+
+	p := NewProcessor(DefaultOptions())
+	out, err := p.Process(lines)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := []string{
+		`<!-- toc -->`,
+		`- <a href="#sec1">1. Intro</a>`,
+		`- <a href="#sec3">3. Design</a>`,
+		`<!-- /toc -->`,
+		`<a name="sec1"></a>`,
+		`# 1. Intro`,
+		`<a name="sec2"></a>`,
+		`# 2. Document History <!-- toc-exclude -->`,
+		`<a name="sec3"></a>`,
+		`# 3. Design`,
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("\nwant: %q\nhave: %q", want, out)
+	}
+
+	sections := p.Outline(lines)
+	if len(sections) != 2 || sections[1].Number != "3" {
+		t.Errorf("have %v", sections)
+	}
+}
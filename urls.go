@@ -0,0 +1,198 @@
+package markproc
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies for dead external links, for Options.URLPolicy.
+const (
+	// URLWarn warns about each dead link.
+	URLWarn = "warn"
+	// URLError also makes verification fail.
+	URLError = "error"
+)
+
+// externalURLRe matches an http or https URL in markdown or HTML.
+var externalURLRe = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+
+// URLStatus is the result of checking one URL.
+type URLStatus struct {
+	// Code is the HTTP status code of the response, or zero if the
+	// request failed.
+	Code int
+	// Location is where a redirect points to.
+	Location string
+	// Err is why the request failed.
+	Err error
+}
+
+// Dead reports whether the URL couldn't be fetched.
+func (s URLStatus) Dead() bool {
+	return s.Err != nil || s.Code >= 400
+}
+
+// Redirected reports whether the URL points somewhere else.
+func (s URLStatus) Redirected() bool {
+	return s.Code >= 300 && s.Code < 400
+}
+
+// URLChecker checks external URLs, remembering the result for each so
+// that every URL is requested once however many documents link to it.
+// A URLChecker is safe for concurrent use.
+type URLChecker struct {
+	client *http.Client
+	// sem holds a token for each request in flight.
+	sem chan struct{}
+	mu  sync.Mutex
+	// cache holds the result for each URL checked or being checked.
+	cache map[string]*urlEntry
+}
+
+// urlEntry is the cached result for a URL; done is closed once status
+// is set.
+type urlEntry struct {
+	done   chan struct{}
+	status URLStatus
+}
+
+// NewURLChecker returns a URLChecker giving up on each request after
+// timeout, if not zero, and making at most jobs requests at once, or 8
+// if jobs is zero.
+func NewURLChecker(timeout time.Duration, jobs int) *URLChecker {
+	if jobs <= 0 {
+		jobs = 8
+	}
+	return &URLChecker{
+		client: &http.Client{
+			Timeout: timeout,
+			// report redirects rather than following them
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		sem:   make(chan struct{}, jobs),
+		cache: map[string]*urlEntry{},
+	}
+}
+
+// Check returns the status of each of urls, requesting those not
+// checked before concurrently.
+func (c *URLChecker) Check(urls []string) map[string]URLStatus {
+	var wg sync.WaitGroup
+	entries := map[string]*urlEntry{}
+	for _, url := range urls {
+		if entries[url] != nil {
+			continue
+		}
+		c.mu.Lock()
+		e, ok := c.cache[url]
+		if !ok {
+			e = &urlEntry{done: make(chan struct{})}
+			c.cache[url] = e
+		}
+		c.mu.Unlock()
+		entries[url] = e
+		if ok {
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			c.sem <- struct{}{}
+			e.status = c.fetch(url)
+			<-c.sem
+			close(e.done)
+		}(url)
+	}
+	wg.Wait()
+
+	statuses := map[string]URLStatus{}
+	for url, e := range entries {
+		// another call may still be checking it
+		<-e.done
+		statuses[url] = e.status
+	}
+	return statuses
+}
+
+// fetch requests url with HEAD, falling back to GET for servers that
+// don't support HEAD.
+func (c *URLChecker) fetch(url string) (status URLStatus) {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			return URLStatus{Err: err}
+		}
+		req.Header.Set("User-Agent", "markproc-link-checker")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			status = URLStatus{Err: err}
+			continue
+		}
+		resp.Body.Close()
+		status = URLStatus{Code: resp.StatusCode, Location: resp.Header.Get("Location")}
+		if method == http.MethodHead && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			continue
+		}
+		break
+	}
+	return
+}
+
+// externalURLs returns the http and https URLs linked to outside code
+// in lines, and the index of the first line each is on.
+func externalURLs(lines []string) (urls []string, first map[string]int) {
+	first = map[string]int{}
+	code := codeMask(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		line = codeSpanRe.ReplaceAllString(line, "")
+		for _, url := range externalURLRe.FindAllString(line, -1) {
+			url = strings.TrimRight(url, ".,;:!?*_")
+			if _, ok := first[url]; ok {
+				continue
+			}
+			first[url] = i
+			urls = append(urls, url)
+		}
+	}
+	return
+}
+
+// verifyURLs checks the external links of the input document, warning
+// about each dead or redirected one.  Dead links make verification
+// fail if p.URLPolicy is URLError.
+func (p *Processor) verifyURLs() (err error) {
+	checker := p.URLChecker
+	if checker == nil {
+		checker = NewURLChecker(p.Limits.NetworkTimeout, 0)
+	}
+	urls, first := externalURLs(p.input)
+	statuses := checker.Check(urls)
+	dead := 0
+	for _, url := range urls {
+		status := statuses[url]
+		line := first[url] + 1
+		switch {
+		case status.Err != nil:
+			p.warnfLine("url-dead", line, url, "Dead link %s: %v", url, status.Err)
+			dead++
+		case status.Dead():
+			p.warnfLine("url-dead", line, url, "Dead link %s: %d %s", url, status.Code, http.StatusText(status.Code))
+			dead++
+		case status.Redirected():
+			p.warnfLine("url-redirected", line, url, "Link %s redirects to %s", url, status.Location)
+		}
+	}
+	if dead > 0 && p.URLPolicy == URLError {
+		err = fmt.Errorf("%d dead links", dead)
+	}
+	return
+}
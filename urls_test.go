@@ -0,0 +1,71 @@
+package markproc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestCheckURLs(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	lines := []string{
+		"# Links",
+		"See [ok](" + srv.URL + "/ok), <" + srv.URL + "/moved> and " + srv.URL + "/gone.",
+		"[spec]: " + srv.URL + "/nohead",
+		"Again " + srv.URL + "/ok and `" + srv.URL + "/code`.",
+		"```",
+		srv.URL + "/block",
+		"```",
+	}
+	opts := DefaultOptions()
+	opts.CheckURLs = true
+	opts.URLChecker = NewURLChecker(time.Second, 2)
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	_, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	have := []string{}
+	for _, w := range p.Warnings() {
+		have = append(have, w.Rule+": "+w.Message)
+	}
+	want := []string{
+		"url-redirected: Link " + srv.URL + "/moved redirects to /ok",
+		"url-dead: Dead link " + srv.URL + "/gone: 404 Not Found",
+	}
+	Tassert(t, reflect.DeepEqual(have, want), "\nwant: %q\nhave: %q", want, have)
+	Tassert(t, p.Warnings()[1].Line == 2, "have %+v", p.Warnings()[1])
+
+	p.URLPolicy = URLError
+	_, err = p.Process(lines)
+	Tassert(t, err != nil, "want error for a dead link")
+	wantRequests := map[string]int{
+		"HEAD /ok": 1, "HEAD /moved": 1, "HEAD /gone": 1,
+		"HEAD /nohead": 1, "GET /nohead": 1,
+	}
+	Tassert(t, reflect.DeepEqual(requests, wantRequests), "\nwant: %v\nhave: %v", wantRequests, requests)
+}
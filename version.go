@@ -0,0 +1,108 @@
+package markproc
+
+import (
+	"strings"
+)
+
+// The comments passVersion puts around a version stamp and around the
+// footer it appends to a document.
+const (
+	versionStart = "<!-- version -->"
+	versionEnd   = "<!-- /version -->"
+	footerStart  = "<!-- version-footer -->"
+	footerEnd    = "<!-- /version-footer -->"
+)
+
+// isVersionMarker reports whether line asks for a version stamp.
+func isVersionMarker(line string) bool {
+	return strings.TrimSpace(line) == versionStart || isDirective(line, "version")
+}
+
+// stampsVersion reports whether p has a document version or date to
+// stamp.
+func (p *Processor) stampsVersion() bool {
+	return p.DocVersion != "" || p.DocDate != ""
+}
+
+// versionStamp returns the text of p's version stamp, e.g.
+// "Version v1.2.0 (2026-10-16)".
+func (p *Processor) versionStamp() string {
+	switch {
+	case p.DocVersion == "":
+		return p.DocDate
+	case p.DocDate == "":
+		return "Version " + p.DocVersion
+	}
+	return "Version " + p.DocVersion + " (" + p.DocDate + ")"
+}
+
+// versionFooter returns the footer passVersion appends with
+// VersionFooter set.
+func (p *Processor) versionFooter() []string {
+	return []string{footerStart, "", "---", "", p.versionStamp(), footerEnd}
+}
+
+// passVersion replaces each <!-- markproc: version --> or
+// <!-- version --> line with p's version stamp, between
+// <!-- version --> and <!-- /version --> comments.  With
+// p.VersionFooter set the stamp is also appended to the document below
+// a rule, between <!-- version-footer --> and <!-- /version-footer -->
+// comments; Split copies it to every page.  The stamps and footer of an
+// earlier run are replaced.
+func (p *Processor) passVersion(lines []string) []string {
+	out := p.newLineWriter(lines)
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case code[i]:
+			out.add(i, line)
+		case isVersionMarker(line):
+			if strings.TrimSpace(line) == versionStart {
+				i = skipBlock(lines, i, versionEnd)
+			}
+			out.add(i, versionStart, p.versionStamp(), versionEnd)
+		case strings.TrimSpace(line) == footerStart:
+			i = skipBlock(lines, i, footerEnd)
+		default:
+			out.add(i, line)
+		}
+	}
+	if p.VersionFooter && len(lines) > 0 {
+		out.add(len(lines)-1, p.versionFooter()...)
+	}
+	return p.done(out)
+}
+
+// footer returns the footer passVersion appended to lines, or nil.
+func footer(lines []string) []string {
+	code := codeMask(lines)
+	for i, line := range lines {
+		if !code[i] && strings.TrimSpace(line) == footerStart {
+			end := skipBlock(lines, i, footerEnd)
+			if end > i {
+				return lines[i : end+1]
+			}
+		}
+	}
+	return nil
+}
+
+// stripVersion removes the stamps passVersion put after each version
+// marker of lines, leaving the marker, and the footer it appended.
+func stripVersion(lines []string) []string {
+	out := []string{}
+	code := codeMask(lines)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !code[i] && line == footerStart {
+			i = skipBlock(lines, i, footerEnd)
+			continue
+		}
+		out = append(out, lines[i])
+		if !code[i] && line == versionStart {
+			i = skipBlock(lines, i, versionEnd)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package markproc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/stevegt/goadapt"
+)
+
+func TestVersion(t *testing.T) {
+	lines := []string{
+		"# Intro",
+		"<!-- markproc: version -->",
+		"# Design",
+		"Text.",
+	}
+	opts := DefaultOptions()
+	opts.DocVersion, opts.DocDate, opts.VersionFooter = "v1.2.0", "2026-10-16", true
+	p := NewProcessor(opts)
+	p.Stderr = io.Discard
+	out, err := p.Process(lines)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	want := []string{
+		`<a name="sec1"></a>`,
+		"# 1. Intro",
+		"<!-- version -->",
+		"Version v1.2.0 (2026-10-16)",
+		"<!-- /version -->",
+		`<a name="sec2"></a>`,
+		"# 2. Design",
+		"Text.",
+		"<!-- version-footer -->",
+		"",
+		"---",
+		"",
+		"Version v1.2.0 (2026-10-16)",
+		"<!-- /version-footer -->",
+	}
+	Tassert(t, reflect.DeepEqual(want, out), "\nwant: %q\nhave: %q", want, out)
+
+	// a later run stamps the new version in place of the old
+	p.DocVersion = "v1.3.0"
+	again, err := p.Process(out)
+	Tassert(t, err == nil, "Process failed: %v", err)
+	Tassert(t, len(again) == len(out) && again[3] == "Version v1.3.0 (2026-10-16)" && again[12] == again[3], "have %q", again)
+
+	stripped := p.Strip(out)
+	want = []string{"# Intro", "<!-- version -->", "# Design", "Text."}
+	Tassert(t, reflect.DeepEqual(want, stripped), "\nwant: %q\nhave: %q", want, stripped)
+
+	// every page of a split document gets the footer
+	pages := p.Split(out, 1)
+	Tassert(t, len(pages) == 3, "have %q", pages)
+	for _, page := range pages {
+		Tassert(t, reflect.DeepEqual(out[8:], page.Lines[len(page.Lines)-6:]), "%s: have %q", page.Path, page.Lines)
+	}
+}